@@ -0,0 +1,175 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package alerts
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	finance "github.com/clebi/yfinance"
+)
+
+// Headers of the webhook requests
+const (
+	SignatureHeader = "X-Gofin-Signature"
+	EventHeader     = "X-Gofin-Event"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// ErrNoSMTP is returned for a rule sending emails when no SMTP server is configured
+var ErrNoSMTP = errors.New("no SMTP server configured for email alerts")
+
+// Channel sends the notification of an alert event
+type Channel interface {
+	Send(event *es.AlertEvent) error
+}
+
+// Sign computes the signature of a webhook body: sha256= followed by the hex HMAC-SHA256 of the body
+//
+// 	Sign(secret, body)
+//
+// A receiver checks the requests by comparing the signature of the body it got with the signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhook posts alert events as JSON to an URL
+type Webhook struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Send posts an event, a response which is not a success is an error
+func (webhook *Webhook) Send(event *es.AlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, "alert")
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	}
+	resp, err := webhook.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", webhook.URL, resp.Status)
+	}
+	return nil
+}
+
+// SMTPServer is the server sending alert emails
+type SMTPServer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// Email sends alert events as plain text emails
+type Email struct {
+	Server *SMTPServer
+	To     []string
+}
+
+func emailMessage(from string, to []string, event *es.AlertEvent) []byte {
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&body, "Subject: [gofin] %s on %s\r\n", event.Name, event.Symbol)
+	fmt.Fprintf(&body, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "The alert %s fired on %s for %s.\r\n\r\n", event.Name, event.Date.Format(finance.DateFormat), event.Symbol)
+	fmt.Fprintf(&body, "Condition: %s\r\n", event.Condition)
+	keys := make([]string, 0, len(event.Values))
+	for key := range event.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&body, "%s: %g\r\n", key, event.Values[key])
+	}
+	return body.Bytes()
+}
+
+// Send mails an event to all the recipients
+func (email *Email) Send(event *es.AlertEvent) error {
+	return smtp.SendMail(email.Server.Addr, email.Server.Auth, email.Server.From, email.To,
+		emailMessage(email.Server.From, email.To, event))
+}
+
+// Notifier gives the channels of an alert rule
+type Notifier interface {
+	Channels(rule es.AlertRule) ([]Channel, error)
+}
+
+// Dispatcher builds the webhook and the email channels of the rules
+type Dispatcher struct {
+	smtp   *SMTPServer
+	client *http.Client
+}
+
+// NewDispatcher creates a new dispatcher, rules cannot send emails without SMTP server
+func NewDispatcher(server *SMTPServer) *Dispatcher {
+	return &Dispatcher{
+		smtp:   server,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Channels returns the channels of a rule, an error when the rule sends emails without SMTP server
+func (dispatcher *Dispatcher) Channels(rule es.AlertRule) ([]Channel, error) {
+	var channels []Channel
+	if rule.Webhook != nil {
+		channels = append(channels, &Webhook{URL: rule.Webhook.URL, Secret: rule.Webhook.Secret, Client: dispatcher.client})
+	}
+	if len(rule.Email) > 0 {
+		if dispatcher.smtp == nil {
+			return nil, ErrNoSMTP
+		}
+		channels = append(channels, &Email{Server: dispatcher.smtp, To: rule.Email})
+	}
+	return channels, nil
+}
+
+// Notify sends an event through all the channels, the failures are added to the errors of the event
+//
+// 	Notify(event, channels)
+func Notify(event *es.AlertEvent, channels []Channel) {
+	for _, channel := range channels {
+		if err := channel.Send(event); err != nil {
+			event.Errors = append(event.Errors, err.Error())
+		}
+	}
+}
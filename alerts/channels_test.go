@@ -0,0 +1,195 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package alerts
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+var testEvent = &es.AlertEvent{
+	Username:  "user",
+	Name:      "low",
+	Symbol:    "CW8.PA",
+	Condition: "close < 300",
+	Date:      time.Date(2017, time.January, 20, 0, 0, 0, 0, time.UTC),
+	Fired:     testNow,
+	Values:    map[string]float64{"close": 299.5},
+}
+
+func TestSign(t *testing.T) {
+	// reference value of RFC 4231 test case 2
+	assert.Equal(t, "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		Sign("Jefe", []byte("what do ya want for nothing?")))
+}
+
+func TestWebhook(t *testing.T) {
+	var received es.AlertEvent
+	var signature, eventHeader string
+	var valid bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		eventHeader = r.Header.Get(EventHeader)
+		valid = signature == Sign("secret", body)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+	webhook := &Webhook{URL: server.URL, Secret: "secret", Client: http.DefaultClient}
+	assert.Nil(t, webhook.Send(testEvent))
+	assert.True(t, valid)
+	assert.Equal(t, "alert", eventHeader)
+	assert.Equal(t, "low", received.Name)
+	assert.Equal(t, 299.5, received.Values["close"])
+	webhook.Secret = ""
+	assert.Nil(t, webhook.Send(testEvent))
+	assert.Equal(t, "", signature)
+}
+
+func TestWebhookErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	webhook := &Webhook{URL: server.URL, Client: http.DefaultClient}
+	assert.EqualError(t, webhook.Send(testEvent), "webhook "+server.URL+" answered 403 Forbidden")
+	server.Close()
+	assert.NotNil(t, webhook.Send(testEvent))
+}
+
+// smtpMail is a mail received by the stand-in SMTP server
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer starts a minimal SMTP server accepting one mail
+func startSMTPServer(t *testing.T) (string, chan smtpMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mails := make(chan smtpMail, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		var mail smtpMail
+		reply("220 localhost ESMTP")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				mail.from = strings.Trim(strings.TrimSpace(line)[10:], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 end with .")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					mail.data += line
+				}
+				mails <- mail
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return listener.Addr().String(), mails
+}
+
+func TestEmail(t *testing.T) {
+	addr, mails := startSMTPServer(t)
+	email := &Email{Server: &SMTPServer{Addr: addr, From: "gofin@test.test"}, To: []string{"a@test.test", "b@test.test"}}
+	assert.Nil(t, email.Send(testEvent))
+	mail := <-mails
+	assert.Equal(t, "gofin@test.test", mail.from)
+	assert.Equal(t, []string{"a@test.test", "b@test.test"}, mail.to)
+	assert.Contains(t, mail.data, "Subject: [gofin] low on CW8.PA\r\n")
+	assert.Contains(t, mail.data, "To: a@test.test, b@test.test\r\n")
+	assert.Contains(t, mail.data, "The alert low fired on 2017-01-20 for CW8.PA.\r\n")
+	assert.Contains(t, mail.data, "close: 299.5\r\n")
+}
+
+func TestDispatcher(t *testing.T) {
+	rule := es.AlertRule{Webhook: &es.AlertWebhook{URL: "http://test.test/hook", Secret: "secret"}, Email: []string{"a@test.test"}}
+	_, err := NewDispatcher(nil).Channels(rule)
+	assert.Equal(t, ErrNoSMTP, err)
+	server := &SMTPServer{Addr: "localhost:25", From: "gofin@test.test"}
+	channels, err := NewDispatcher(server).Channels(rule)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(channels))
+	assert.Equal(t, "http://test.test/hook", channels[0].(*Webhook).URL)
+	assert.Equal(t, "secret", channels[0].(*Webhook).Secret)
+	assert.Equal(t, server, channels[1].(*Email).Server)
+	assert.Equal(t, []string{"a@test.test"}, channels[1].(*Email).To)
+	channels, err = NewDispatcher(nil).Channels(es.AlertRule{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(channels))
+}
+
+type errorChannel struct {
+	msg string
+}
+
+func (channel *errorChannel) Send(event *es.AlertEvent) error {
+	return errors.New(channel.msg)
+}
+
+func TestNotify(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Path)
+	}))
+	defer server.Close()
+	event := *testEvent
+	Notify(&event, []Channel{
+		&errorChannel{msg: "first"},
+		&Webhook{URL: server.URL + "/hook", Client: http.DefaultClient},
+		&errorChannel{msg: "second"},
+	})
+	assert.Equal(t, []string{"/hook"}, sent)
+	assert.Equal(t, []string{"first", "second"}, event.Errors)
+}
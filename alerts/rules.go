@@ -0,0 +1,106 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package alerts evaluates the alert rules of the users and notifies them when a rule fires
+//
+// A rule fires on the edge of its condition: when the condition becomes true on a new bar after
+// having been false, so that a price staying below a threshold notifies only once. Conditions are
+// screening expressions, as in close < 300 or rsi(14) > 70, which can also use the profit and loss
+// of the user's position with pnl, in percent of its cost.
+package alerts
+
+import (
+	"math"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/screener"
+)
+
+// VariablePnL is the profit and loss of the position of the rule's symbol, in percent
+const VariablePnL = "pnl"
+
+// Condition reads the condition of an alert rule
+//
+// 	Condition("close < 300 or pnl < -10")
+//
+// returns the expression of the condition
+func Condition(text string) (*screener.Expression, error) {
+	return screener.ParseWithVariables(text, VariablePnL)
+}
+
+// UsesPnL tells whether a condition needs the profit and loss of the position
+func UsesPnL(condition *screener.Expression) bool {
+	for _, term := range condition.Terms() {
+		if term == VariablePnL {
+			return true
+		}
+	}
+	return false
+}
+
+// PnL computes the profit and loss of a position at a price, in percent of its cost
+//
+// 	PnL(position, 310.5)
+//
+// returns NaN when no shares are held or when the position did not cost anything
+func PnL(position es.PositionAgg, close float64) float64 {
+	if position.Number <= 0 || position.Cost <= 0 {
+		return math.NaN()
+	}
+	return 100 * (float64(position.Number)*close - position.Cost) / position.Cost
+}
+
+// Evaluate checks a rule against the bars of its symbol and updates the state of the rule
+//
+// 	Evaluate(rule, condition, bars, values, now)
+//
+// The bars are sorted by date and values holds the variables of the condition. A bar which is not
+// newer than the last evaluated one is ignored, so evaluating twice after the same ingestion is harmless.
+// returns the event when the rule fires, nil otherwise
+func Evaluate(rule *es.AlertRule, condition *screener.Expression, bars []es.StockBar,
+	values map[string]float64, now time.Time) (*es.AlertEvent, error) {
+	if len(bars) == 0 {
+		return nil, nil
+	}
+	last := bars[len(bars)-1]
+	if rule.LastDate != nil && !last.Date.After(*rule.LastDate) {
+		return nil, nil
+	}
+	if err := condition.Values(bars, values); err != nil {
+		return nil, err
+	}
+	matched := condition.Match(values)
+	fire := matched && !rule.Triggered
+	rule.Triggered = matched
+	rule.LastDate = &last.Date
+	if !fire {
+		return nil, nil
+	}
+	eventValues := map[string]float64{}
+	for _, key := range condition.Terms() {
+		if value := values[key]; !math.IsNaN(value) && !math.IsInf(value, 0) {
+			eventValues[key] = value
+		}
+	}
+	return &es.AlertEvent{
+		Username:  rule.Username,
+		Name:      rule.Name,
+		Symbol:    rule.Symbol,
+		Condition: rule.Condition,
+		Date:      last.Date,
+		Fired:     now,
+		Values:    eventValues,
+		Muted:     rule.Muted(now),
+	}, nil
+}
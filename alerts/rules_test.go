@@ -0,0 +1,99 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package alerts
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+var testNow = time.Date(2017, time.January, 20, 18, 0, 0, 0, time.UTC)
+
+func testBars(closes ...float64) []es.StockBar {
+	bars := make([]es.StockBar, len(closes))
+	for i, close := range closes {
+		bars[i] = es.StockBar{Symbol: "TEST", Date: time.Date(2017, time.January, 2+i, 0, 0, 0, 0, time.UTC), Close: close}
+	}
+	return bars
+}
+
+func TestCondition(t *testing.T) {
+	condition, err := Condition("pnl < -10 or close < 300")
+	assert.Nil(t, err)
+	assert.True(t, UsesPnL(condition))
+	condition, err = Condition("rsi(14) > 70")
+	assert.Nil(t, err)
+	assert.False(t, UsesPnL(condition))
+	_, err = Condition("close <")
+	assert.NotNil(t, err)
+}
+
+func TestPnL(t *testing.T) {
+	assert.InDelta(t, -12.5, PnL(es.PositionAgg{Number: 10, Cost: 400}, 35), 1e-9)
+	assert.InDelta(t, 25, PnL(es.PositionAgg{Number: 10, Cost: 400}, 50), 1e-9)
+	assert.True(t, math.IsNaN(PnL(es.PositionAgg{Number: 0, Cost: 400}, 50)))
+	assert.True(t, math.IsNaN(PnL(es.PositionAgg{Number: 10, Cost: 0}, 50)))
+}
+
+func TestEvaluate(t *testing.T) {
+	condition, _ := Condition("close < 300")
+	rule := &es.AlertRule{Username: "user", Name: "low", Symbol: "TEST", Condition: "close < 300"}
+	closes := []float64{310, 299, 298, 305, 290}
+	fired := []bool{false, true, false, false, true}
+	for i := range closes {
+		bars := testBars(closes[:i+1]...)
+		event, err := Evaluate(rule, condition, bars, map[string]float64{}, testNow)
+		assert.Nil(t, err)
+		assert.Equal(t, fired[i], event != nil, "bar %d", i)
+		assert.Equal(t, closes[i] < 300, rule.Triggered)
+		assert.Equal(t, bars[i].Date, *rule.LastDate)
+		if event != nil {
+			assert.Equal(t, "user", event.Username)
+			assert.Equal(t, "low", event.Name)
+			assert.Equal(t, "TEST", event.Symbol)
+			assert.Equal(t, bars[i].Date, event.Date)
+			assert.Equal(t, testNow, event.Fired)
+			assert.Equal(t, map[string]float64{"close": closes[i]}, event.Values)
+			assert.False(t, event.Muted)
+		}
+		// a second evaluation after the same ingestion is ignored
+		event, err = Evaluate(rule, condition, bars, map[string]float64{}, testNow)
+		assert.Nil(t, err)
+		assert.Nil(t, event)
+	}
+}
+
+func TestEvaluateMuted(t *testing.T) {
+	condition, _ := Condition("pnl < -10 or rsi(14) > 70")
+	until := testNow.Add(time.Hour)
+	rule := &es.AlertRule{Name: "loss", Condition: "pnl < -10 or rsi(14) > 70", MutedUntil: &until}
+	event, err := Evaluate(rule, condition, testBars(10), map[string]float64{VariablePnL: -12}, testNow)
+	assert.Nil(t, err)
+	assert.True(t, event.Muted)
+	assert.Equal(t, map[string]float64{VariablePnL: -12}, event.Values)
+	assert.True(t, rule.Triggered)
+}
+
+func TestEvaluateNoBars(t *testing.T) {
+	condition, _ := Condition("close < 300")
+	rule := &es.AlertRule{Condition: "close < 300"}
+	event, err := Evaluate(rule, condition, nil, map[string]float64{}, testNow)
+	assert.Nil(t, err)
+	assert.Nil(t, event)
+	assert.Nil(t, rule.LastDate)
+}
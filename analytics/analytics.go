@@ -0,0 +1,90 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// ErrNotEnoughData is returned when there are less than three closes to compute statistics on
+var ErrNotEnoughData = errors.New("not enough data")
+
+// Report contains the return and risk statistics of a symbol
+//
+// Returns are daily and the statistics are computed on the simple returns, except the log ones.
+type Report struct {
+	Symbol        string    `json:"symbol"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Observations  int       `json:"observations"`
+	MeanReturn    float64   `json:"mean_return"`
+	MeanLogReturn float64   `json:"mean_log_return"`
+	AnnualReturn  float64   `json:"annual_return"`
+	Volatility    float64   `json:"volatility"`
+	RiskFree      float64   `json:"risk_free"`
+	Sharpe        float64   `json:"sharpe"`
+	Sortino       float64   `json:"sortino"`
+	MaxDrawdown   Drawdown  `json:"max_drawdown"`
+	Skewness      float64   `json:"skewness"`
+	Kurtosis      float64   `json:"kurtosis"`
+	Confidence    float64   `json:"confidence"`
+	VaR           float64   `json:"var"`
+	CVaR          float64   `json:"cvar"`
+}
+
+// Analyze computes the return and risk statistics of the closes of bars
+//
+// 	Analyze(bars, 0.02, 0.95)
+//
+// The risk free rate is annual and the confidence is the one of the value at risk.
+// returns the report of the bars sorted by date
+func Analyze(bars []es.StockBar, riskFree float64, confidence float64) (*Report, error) {
+	if len(bars) < 3 {
+		return nil, ErrNotEnoughData
+	}
+	dates := make([]time.Time, len(bars))
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		if bar.Close <= 0 {
+			return nil, fmt.Errorf("close must be positive on %s", bar.Date.Format("2006-01-02"))
+		}
+		dates[i], closes[i] = bar.Date, bar.Close
+	}
+	returns := Returns(closes)
+	logReturns := LogReturns(closes)
+	report := &Report{
+		Symbol:        bars[0].Symbol,
+		Start:         dates[0],
+		End:           dates[len(dates)-1],
+		Observations:  len(returns),
+		MeanReturn:    Mean(returns),
+		MeanLogReturn: Mean(logReturns),
+		AnnualReturn:  math.Exp(Mean(logReturns)*TradingDays) - 1,
+		Volatility:    Volatility(returns),
+		RiskFree:      riskFree,
+		Sharpe:        Sharpe(returns, riskFree),
+		Sortino:       Sortino(returns, riskFree),
+		MaxDrawdown:   MaxDrawdown(dates, closes),
+		Skewness:      Skewness(returns),
+		Kurtosis:      Kurtosis(returns),
+		Confidence:    confidence,
+	}
+	report.VaR, report.CVaR = HistoricalVaR(returns, confidence)
+	return report, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBars(closes ...float64) []es.StockBar {
+	dates := testDates(len(closes))
+	bars := make([]es.StockBar, len(closes))
+	for i, close := range closes {
+		bars[i] = es.StockBar{Symbol: "TEST", Date: dates[i], Close: close}
+	}
+	return bars
+}
+
+func TestAnalyze(t *testing.T) {
+	report, err := Analyze(testBars(testCloses...), 0.0252, 0.95)
+	assert.Nil(t, err)
+	dates := testDates(4)
+	assert.Equal(t, "TEST", report.Symbol)
+	assert.Equal(t, dates[0], report.Start)
+	assert.Equal(t, dates[3], report.End)
+	assert.Equal(t, 3, report.Observations)
+	assert.InDelta(t, 0.1/3, report.MeanReturn, 1e-9)
+	assert.InDelta(t, math.Log(1.089)/3, report.MeanLogReturn, 1e-9)
+	assert.InDelta(t, math.Pow(1.089, 84)-1, report.AnnualReturn, 1e-6)
+	assert.InDelta(t, 1.833030, report.Volatility, 1e-6)
+	assert.InDelta(t, 4.568828, report.Sharpe, 1e-6)
+	assert.InDelta(t, 9.128527, report.Sortino, 1e-6)
+	assert.InDelta(t, 0.1, report.MaxDrawdown.Depth, 1e-9)
+	assert.InDelta(t, -1/math.Sqrt(2), report.Skewness, 1e-9)
+	assert.InDelta(t, -1.5, report.Kurtosis, 1e-9)
+	assert.InDelta(t, 0.1, report.VaR, 1e-9)
+	assert.InDelta(t, 0.1, report.CVaR, 1e-9)
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	_, err := Analyze(testBars(1, 2), 0, 0.95)
+	assert.Equal(t, ErrNotEnoughData, err)
+	_, err = Analyze(testBars(1, 0, 2), 0, 0.95)
+	assert.EqualError(t, err, "close must be positive on 2017-01-03")
+}
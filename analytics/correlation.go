@@ -0,0 +1,146 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Matrices contains the covariance and the correlation of the daily returns of several series,
+// with the number of returns each pair was computed on
+//
+// A value is NaN when a pair has less than two returns in common.
+type Matrices struct {
+	Covariance   [][]float64
+	Correlation  [][]float64
+	Observations [][]int
+}
+
+// Covariance computes the sample covariance of two series of the same length, NaN when there are less than two values
+func Covariance(x []float64, y []float64) float64 {
+	if len(x) < 2 {
+		return math.NaN()
+	}
+	meanX, meanY := Mean(x), Mean(y)
+	var sum float64
+	for i := range x {
+		sum += (x[i] - meanX) * (y[i] - meanY)
+	}
+	return sum / float64(len(x)-1)
+}
+
+// Correlation computes the Pearson correlation of two series of the same length
+//
+// returns NaN when there are less than two values or when a series does not vary
+func Correlation(x []float64, y []float64) float64 {
+	deviation := StdDev(x) * StdDev(y)
+	if len(x) < 2 || deviation == 0 {
+		return math.NaN()
+	}
+	return Covariance(x, y) / deviation
+}
+
+// pairReturns computes the returns of two series of bars on the dates where both have a close
+func pairReturns(x []es.StockBar, y []es.StockBar) ([]float64, []float64) {
+	closes := make(map[int64]float64, len(y))
+	for _, bar := range y {
+		closes[bar.Date.Unix()] = bar.Close
+	}
+	var dates []int64
+	closesX := map[int64]float64{}
+	for _, bar := range x {
+		date := bar.Date.Unix()
+		if _, ok := closes[date]; ok {
+			if _, seen := closesX[date]; !seen {
+				dates = append(dates, date)
+			}
+			closesX[date] = bar.Close
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+	commonX, commonY := make([]float64, len(dates)), make([]float64, len(dates))
+	for i, date := range dates {
+		commonX[i], commonY[i] = closesX[date], closes[date]
+	}
+	return Returns(commonX), Returns(commonY)
+}
+
+// alignedReturns computes the returns of series of bars on the dates where all of them have a close
+func alignedReturns(bars [][]es.StockBar) [][]float64 {
+	counts := map[int64]int{}
+	closes := make([]map[int64]float64, len(bars))
+	for i, series := range bars {
+		closes[i] = make(map[int64]float64, len(series))
+		for _, bar := range series {
+			date := bar.Date.Unix()
+			if _, seen := closes[i][date]; !seen {
+				counts[date]++
+			}
+			closes[i][date] = bar.Close
+		}
+	}
+	var dates []int64
+	for date, count := range counts {
+		if count == len(bars) {
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i] < dates[j] })
+	returns := make([][]float64, len(bars))
+	for i := range bars {
+		common := make([]float64, len(dates))
+		for j, date := range dates {
+			common[j] = closes[i][date]
+		}
+		returns[i] = Returns(common)
+	}
+	return returns
+}
+
+// ReturnMatrices computes the covariance and correlation matrices of the daily returns of series of bars
+//
+// 	ReturnMatrices(bars)
+//
+// Each pair is aligned on the dates where both series have a close, so a missing day of a series only
+// changes its pairs and a return may span several days.
+// returns the matrices in the order of the series
+func ReturnMatrices(bars [][]es.StockBar) Matrices {
+	count := len(bars)
+	matrices := Matrices{
+		Covariance:   make([][]float64, count),
+		Correlation:  make([][]float64, count),
+		Observations: make([][]int, count),
+	}
+	for i := range bars {
+		matrices.Covariance[i] = make([]float64, count)
+		matrices.Correlation[i] = make([]float64, count)
+		matrices.Observations[i] = make([]int, count)
+	}
+	for i := range bars {
+		for j := i; j < count; j++ {
+			x, y := pairReturns(bars[i], bars[j])
+			covariance, correlation := Covariance(x, y), Correlation(x, y)
+			if i == j && !math.IsNaN(correlation) {
+				correlation = 1
+			}
+			matrices.Covariance[i][j], matrices.Covariance[j][i] = covariance, covariance
+			matrices.Correlation[i][j], matrices.Correlation[j][i] = correlation, correlation
+			matrices.Observations[i][j], matrices.Observations[j][i] = len(x), len(x)
+		}
+	}
+	return matrices
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCovarianceCorrelation(t *testing.T) {
+	x := []float64{1, 2, 3, 4}
+	assert.InDelta(t, 5.0/3, Covariance(x, x), 1e-9)
+	assert.InDelta(t, -10.0/3, Covariance(x, []float64{8, 6, 4, 2}), 1e-9)
+	assert.InDelta(t, 1, Correlation(x, []float64{2, 4, 6, 8}), 1e-9)
+	assert.InDelta(t, -1, Correlation(x, []float64{8, 6, 4, 2}), 1e-9)
+	assert.True(t, math.IsNaN(Covariance([]float64{1}, []float64{1})))
+	assert.True(t, math.IsNaN(Correlation(x, []float64{1, 1, 1, 1})))
+}
+
+func TestReturnMatrices(t *testing.T) {
+	a := testBars(100, 110, 99, 108.9, 119.79)
+	b := testBars(50, 55, 49.5, 54.45, 59.895)
+	// c misses the third day, its returns are taken on the common days of each pair
+	c := append(testBars(10, 9, 8, 7, 6)[:2], testBars(10, 9, 8, 7, 6)[3:]...)
+	d := testBars(1, 1)
+	matrices := ReturnMatrices([][]es.StockBar{a, b, c, d})
+	assert.Equal(t, []int{4, 4, 3, 1}, matrices.Observations[0])
+	assert.Equal(t, 3, matrices.Observations[2][2])
+	assert.InDelta(t, 1, matrices.Correlation[0][1], 1e-9)
+	assert.InDelta(t, 1, matrices.Correlation[0][0], 1e-9)
+	assert.InDelta(t, Covariance(Returns([]float64{100, 110, 99, 108.9, 119.79}), Returns([]float64{50, 55, 49.5, 54.45, 59.895})),
+		matrices.Covariance[1][0], 1e-12)
+	ca, cc := Returns([]float64{100, 110, 108.9, 119.79}), Returns([]float64{10, 9, 7, 6})
+	assert.InDelta(t, Correlation(ca, cc), matrices.Correlation[0][2], 1e-12)
+	assert.Equal(t, matrices.Correlation[0][2], matrices.Correlation[2][0])
+	assert.True(t, math.IsNaN(matrices.Correlation[0][3]))
+	assert.True(t, math.IsNaN(matrices.Covariance[3][3]))
+}
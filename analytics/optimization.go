@@ -0,0 +1,403 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"errors"
+	"math"
+
+	"github.com/clebi/gofin/es"
+)
+
+const (
+	// maxActiveSetSteps bounds the iterations of the quadratic solver, which needs about twice the number of assets
+	maxActiveSetSteps = 1000
+	// searchSteps is the number of bisection and golden section steps of the searches along the frontier
+	searchSteps = 60
+	// weightTolerance is the change of weights under which the solver considers a point as optimal
+	weightTolerance = 1e-12
+)
+
+// ErrBadBounds is returned when the weights cannot sum to one within their bounds
+var ErrBadBounds = errors.New("weights cannot sum to one within their bounds")
+
+// Fixed, lower bound, free and upper bound states of a weight in the quadratic solver
+const (
+	weightFixed = iota - 2
+	weightLower
+	weightFree
+	weightUpper
+)
+
+// PortfolioPoint is a portfolio, its annual expected return, its annual volatility and its Sharpe ratio
+//
+// Weights are in the order of the assets of the optimizer.
+type PortfolioPoint struct {
+	Weights    []float64 `json:"weights"`
+	Return     float64   `json:"return"`
+	Volatility float64   `json:"volatility"`
+	Sharpe     float64   `json:"sharpe"`
+}
+
+// Optimizer computes mean-variance optimal portfolios of assets whose weights sum to one within bounds
+//
+// Returns are the annual expected returns of the assets and Covariance the annual covariance of their
+// returns. A lower bound of 0 for all the assets gives long-only portfolios.
+type Optimizer struct {
+	Returns    []float64
+	Covariance [][]float64
+	Lower      []float64
+	Upper      []float64
+	RiskFree   float64
+	// ridge is the covariance with a tiny diagonal term, so that perfectly correlated assets still have
+	// a single optimal portfolio
+	ridge [][]float64
+}
+
+// EstimateReturns computes the annual expected returns and covariance of the daily returns of series of bars
+//
+// 	EstimateReturns(bars)
+//
+// The series are aligned on the dates where all of them have a close.
+// returns the expected returns, the covariance and the number of daily returns they were computed on
+func EstimateReturns(bars [][]es.StockBar) ([]float64, [][]float64, int, error) {
+	series := alignedReturns(bars)
+	if len(series) == 0 || len(series[0]) < 2 {
+		return nil, nil, 0, ErrNotEnoughData
+	}
+	returns := make([]float64, len(series))
+	covariance := make([][]float64, len(series))
+	for i := range series {
+		returns[i] = Mean(series[i]) * TradingDays
+		covariance[i] = make([]float64, len(series))
+		for j := range series {
+			covariance[i][j] = Covariance(series[i], series[j]) * TradingDays
+		}
+	}
+	return returns, covariance, len(series[0]), nil
+}
+
+// NewOptimizer creates an optimizer, the lower and upper bounds are the ones of the weights of each asset
+//
+// 	NewOptimizer(returns, covariance, lower, upper, 0.02)
+func NewOptimizer(returns []float64, covariance [][]float64, lower []float64, upper []float64, riskFree float64) (*Optimizer, error) {
+	count := len(returns)
+	if count == 0 || len(covariance) != count || len(lower) != count || len(upper) != count {
+		return nil, errors.New("returns, covariance and bounds must have the same size")
+	}
+	var lowest, highest, scale float64
+	for i := range returns {
+		if len(covariance[i]) != count {
+			return nil, errors.New("returns, covariance and bounds must have the same size")
+		}
+		if lower[i] > upper[i] {
+			return nil, ErrBadBounds
+		}
+		lowest += lower[i]
+		highest += upper[i]
+		scale = math.Max(scale, covariance[i][i])
+	}
+	if lowest > 1+weightTolerance || highest < 1-weightTolerance {
+		return nil, ErrBadBounds
+	}
+	ridge := make([][]float64, count)
+	for i := range covariance {
+		ridge[i] = make([]float64, count)
+		copy(ridge[i], covariance[i])
+		ridge[i][i] += 1e-9*scale + 1e-18
+	}
+	return &Optimizer{
+		Returns:    returns,
+		Covariance: covariance,
+		Lower:      lower,
+		Upper:      upper,
+		RiskFree:   riskFree,
+		ridge:      ridge,
+	}, nil
+}
+
+// Point computes the expected return, the volatility and the Sharpe ratio of weights
+func (optimizer *Optimizer) Point(weights []float64) PortfolioPoint {
+	point := PortfolioPoint{Weights: weights}
+	var variance float64
+	for i, weight := range weights {
+		point.Return += weight * optimizer.Returns[i]
+		for j, other := range weights {
+			variance += weight * other * optimizer.Covariance[i][j]
+		}
+	}
+	point.Volatility = math.Sqrt(math.Max(variance, 0))
+	if point.Volatility > 0 {
+		point.Sharpe = (point.Return - optimizer.RiskFree) / point.Volatility
+	}
+	return point
+}
+
+// extremeReturn gives the weights of the highest expected return, or of the lowest one, by filling the
+// assets from their lower bound in the order of their returns
+func (optimizer *Optimizer) extremeReturn(highest bool) []float64 {
+	weights := make([]float64, len(optimizer.Returns))
+	left := 1.0
+	for i := range weights {
+		weights[i] = optimizer.Lower[i]
+		left -= weights[i]
+	}
+	used := make([]bool, len(weights))
+	for left > 0 {
+		best := -1
+		for i := range weights {
+			if used[i] {
+				continue
+			}
+			if best < 0 || highest && optimizer.Returns[i] > optimizer.Returns[best] ||
+				!highest && optimizer.Returns[i] < optimizer.Returns[best] {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		used[best] = true
+		added := math.Min(left, optimizer.Upper[best]-optimizer.Lower[best])
+		weights[best] += added
+		left -= added
+	}
+	return weights
+}
+
+// solve minimizes the variance minus tradeOff times the expected return of the portfolio, with an active
+// set method: the weights at their bounds are fixed and the others solve the problem with only the
+// sum-to-one constraint, until a step hits a bound or a bound holds a weight it should release
+func (optimizer *Optimizer) solve(tradeOff float64) []float64 {
+	count := len(optimizer.Returns)
+	weights := optimizer.extremeReturn(false)
+	states := make([]int, count)
+	for i, weight := range weights {
+		switch {
+		case optimizer.Lower[i] == optimizer.Upper[i]:
+			states[i] = weightFixed
+		case weight <= optimizer.Lower[i]:
+			states[i] = weightLower
+		case weight >= optimizer.Upper[i]:
+			states[i] = weightUpper
+		}
+	}
+	gradient := make([]float64, count)
+	for step := 0; step < maxActiveSetSteps; step++ {
+		var scale float64
+		for i := range gradient {
+			gradient[i] = -tradeOff * optimizer.Returns[i]
+			for j, weight := range weights {
+				gradient[i] += optimizer.ridge[i][j] * weight
+			}
+			scale = math.Max(scale, math.Abs(gradient[i]))
+		}
+		var free []int
+		for i, state := range states {
+			if state == weightFree {
+				free = append(free, i)
+			}
+		}
+		direction, multiplier := optimizer.freeStep(free, gradient, states)
+		var length float64
+		for _, value := range direction {
+			length = math.Max(length, math.Abs(value))
+		}
+		if length > weightTolerance {
+			ratio, blocking := 1.0, -1
+			for k, i := range free {
+				limit := ratio
+				if direction[k] < 0 {
+					limit = (optimizer.Lower[i] - weights[i]) / direction[k]
+				} else if direction[k] > 0 {
+					limit = (optimizer.Upper[i] - weights[i]) / direction[k]
+				}
+				if limit < ratio {
+					ratio, blocking = limit, k
+				}
+			}
+			for k, i := range free {
+				weights[i] += ratio * direction[k]
+			}
+			if blocking >= 0 {
+				i := free[blocking]
+				if direction[blocking] < 0 {
+					states[i], weights[i] = weightLower, optimizer.Lower[i]
+				} else {
+					states[i], weights[i] = weightUpper, optimizer.Upper[i]
+				}
+			}
+			continue
+		}
+		// the weights are optimal with the bounds held, release the bound which hinders the most
+		worst, violation := -1, weightTolerance*(1+scale)
+		for i, state := range states {
+			if value := gradient[i] + multiplier; state == weightLower && -value > violation {
+				worst, violation = i, -value
+			} else if state == weightUpper && value > violation {
+				worst, violation = i, value
+			}
+		}
+		if worst < 0 {
+			break
+		}
+		states[worst] = weightFree
+	}
+	return weights
+}
+
+// freeStep solves the step of the free weights which minimizes the objective with the others held and the
+// sum of the weights kept, and the multiplier of the sum-to-one constraint
+func (optimizer *Optimizer) freeStep(free []int, gradient []float64, states []int) ([]float64, float64) {
+	if len(free) == 0 {
+		// the multiplier is the one closest to hold all the bounds
+		multiplier, found := 0.0, false
+		for i, state := range states {
+			if state == weightLower && (!found || -gradient[i] > multiplier) {
+				multiplier, found = -gradient[i], true
+			}
+		}
+		if !found {
+			for i, state := range states {
+				if state == weightUpper && (!found || -gradient[i] < multiplier) {
+					multiplier, found = -gradient[i], true
+				}
+			}
+		}
+		return []float64{}, multiplier
+	}
+	size := len(free) + 1
+	system := make([][]float64, size)
+	for k, i := range free {
+		system[k] = make([]float64, size+1)
+		for l, j := range free {
+			system[k][l] = optimizer.ridge[i][j]
+		}
+		system[k][size-1] = 1
+		system[k][size] = -gradient[i]
+	}
+	system[size-1] = make([]float64, size+1)
+	for l := range free {
+		system[size-1][l] = 1
+	}
+	solution := solveLinear(system)
+	return solution[:len(free)], solution[len(free)]
+}
+
+// solveLinear solves a linear system given as an augmented matrix with Gaussian elimination and partial pivoting
+func solveLinear(system [][]float64) []float64 {
+	size := len(system)
+	for column := 0; column < size; column++ {
+		pivot := column
+		for row := column + 1; row < size; row++ {
+			if math.Abs(system[row][column]) > math.Abs(system[pivot][column]) {
+				pivot = row
+			}
+		}
+		system[column], system[pivot] = system[pivot], system[column]
+		if system[column][column] == 0 {
+			continue
+		}
+		for row := column + 1; row < size; row++ {
+			factor := system[row][column] / system[column][column]
+			for k := column; k <= size; k++ {
+				system[row][k] -= factor * system[column][k]
+			}
+		}
+	}
+	solution := make([]float64, size)
+	for row := size - 1; row >= 0; row-- {
+		value := system[row][size]
+		for k := row + 1; k < size; k++ {
+			value -= system[row][k] * solution[k]
+		}
+		if system[row][row] != 0 {
+			solution[row] = value / system[row][row]
+		}
+	}
+	return solution
+}
+
+// MinVariance computes the portfolio with the lowest volatility
+func (optimizer *Optimizer) MinVariance() PortfolioPoint {
+	return optimizer.Point(optimizer.solve(0))
+}
+
+// targetReturn computes the portfolio with the lowest volatility among the ones with an expected return,
+// by searching the trade-off between the variance and the return which reaches it
+func (optimizer *Optimizer) targetReturn(target float64) PortfolioPoint {
+	low, high := 0.0, 1.0
+	point := optimizer.Point(optimizer.solve(high))
+	for i := 0; i < searchSteps && point.Return < target; i++ {
+		low, high = high, high*2
+		point = optimizer.Point(optimizer.solve(high))
+	}
+	for i := 0; i < searchSteps; i++ {
+		middle := (low + high) / 2
+		if optimizer.Point(optimizer.solve(middle)).Return < target {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return optimizer.Point(optimizer.solve(high))
+}
+
+// Frontier computes the efficient frontier, portfolios with the lowest volatility for expected returns
+// evenly spaced from the one of the minimum variance portfolio to the highest one
+//
+// 	optimizer.Frontier(20)
+//
+// returns the portfolios sorted by expected return
+func (optimizer *Optimizer) Frontier(points int) []PortfolioPoint {
+	lowest := optimizer.MinVariance()
+	highest := optimizer.Point(optimizer.extremeReturn(true)).Return
+	if points < 2 || highest-lowest.Return <= weightTolerance {
+		return []PortfolioPoint{lowest}
+	}
+	frontier := make([]PortfolioPoint, points)
+	frontier[0] = lowest
+	for i := 1; i < points; i++ {
+		target := lowest.Return + (highest-lowest.Return)*float64(i)/float64(points-1)
+		frontier[i] = optimizer.targetReturn(target)
+	}
+	return frontier
+}
+
+// MaxSharpe computes the efficient portfolio with the highest Sharpe ratio
+//
+// The Sharpe ratio has a single maximum along the efficient frontier, which is found by a golden section
+// search on the expected return.
+func (optimizer *Optimizer) MaxSharpe() PortfolioPoint {
+	best := optimizer.MinVariance()
+	low, high := best.Return, optimizer.Point(optimizer.extremeReturn(true)).Return
+	ratio := (math.Sqrt(5) - 1) / 2
+	left, right := high-ratio*(high-low), low+ratio*(high-low)
+	leftPoint, rightPoint := optimizer.targetReturn(left), optimizer.targetReturn(right)
+	for i := 0; i < searchSteps && high-low > weightTolerance; i++ {
+		if leftPoint.Sharpe < rightPoint.Sharpe {
+			low, left, leftPoint = left, right, rightPoint
+			right = low + ratio*(high-low)
+			rightPoint = optimizer.targetReturn(right)
+		} else {
+			high, right, rightPoint = right, left, leftPoint
+			left = high - ratio*(high-low)
+			leftPoint = optimizer.targetReturn(left)
+		}
+	}
+	if point := optimizer.targetReturn((low + high) / 2); point.Sharpe > best.Sharpe {
+		best = point
+	}
+	return best
+}
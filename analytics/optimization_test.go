@@ -0,0 +1,117 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptimizer(t *testing.T, returns []float64, variances []float64, lower []float64, upper []float64) *Optimizer {
+	covariance := make([][]float64, len(variances))
+	for i, variance := range variances {
+		covariance[i] = make([]float64, len(variances))
+		covariance[i][i] = variance
+	}
+	optimizer, err := NewOptimizer(returns, covariance, lower, upper, 0)
+	assert.Nil(t, err)
+	return optimizer
+}
+
+func assertWeights(t *testing.T, expected []float64, actual []float64) {
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		assert.InDelta(t, expected[i], actual[i], 1e-6)
+	}
+}
+
+func TestMinVariance(t *testing.T) {
+	optimizer := testOptimizer(t, []float64{0.1, 0.05}, []float64{0.04, 0.01}, []float64{0, 0}, []float64{1, 1})
+	point := optimizer.MinVariance()
+	assertWeights(t, []float64{0.2, 0.8}, point.Weights)
+	assert.InDelta(t, 0.06, point.Return, 1e-9)
+	assert.InDelta(t, math.Sqrt(0.008), point.Volatility, 1e-9)
+	assert.InDelta(t, 0.06/math.Sqrt(0.008), point.Sharpe, 1e-9)
+	optimizer = testOptimizer(t, []float64{0.1, 0.05}, []float64{0.04, 0.01}, []float64{0, 0}, []float64{1, 0.5})
+	assertWeights(t, []float64{0.5, 0.5}, optimizer.MinVariance().Weights)
+	optimizer = testOptimizer(t, []float64{0.1, 0.05, 0.02}, []float64{0.04, 0.01, 0.01},
+		[]float64{0.3, 0, 0}, []float64{0.3, 1, 1})
+	assertWeights(t, []float64{0.3, 0.35, 0.35}, optimizer.MinVariance().Weights)
+	// identical assets share the weight
+	optimizer, err := NewOptimizer([]float64{0.1, 0.1}, [][]float64{{0.04, 0.04}, {0.04, 0.04}}, []float64{0, 0}, []float64{1, 1}, 0)
+	assert.Nil(t, err)
+	assertWeights(t, []float64{0.5, 0.5}, optimizer.MinVariance().Weights)
+}
+
+func TestMaxSharpe(t *testing.T) {
+	optimizer := testOptimizer(t, []float64{0.1, 0.05}, []float64{0.04, 0.01}, []float64{0, 0}, []float64{1, 1})
+	point := optimizer.MaxSharpe()
+	assertWeights(t, []float64{1.0 / 3, 2.0 / 3}, point.Weights)
+	assert.InDelta(t, math.Sqrt(0.5), point.Sharpe, 1e-9)
+	// the short position is only allowed with a negative lower bound
+	optimizer = testOptimizer(t, []float64{0.1, 0.05, -0.02}, []float64{0.04, 0.01, 0.01},
+		[]float64{-1, -1, -1}, []float64{2, 2, 2})
+	assertWeights(t, []float64{2.5 / 5.5, 5 / 5.5, -2 / 5.5}, optimizer.MaxSharpe().Weights)
+	optimizer = testOptimizer(t, []float64{0.1, 0.05, -0.02}, []float64{0.04, 0.01, 0.01},
+		[]float64{0, 0, 0}, []float64{1, 1, 1})
+	assertWeights(t, []float64{1.0 / 3, 2.0 / 3, 0}, optimizer.MaxSharpe().Weights)
+}
+
+func TestFrontier(t *testing.T) {
+	optimizer := testOptimizer(t, []float64{0.1, 0.05}, []float64{0.04, 0.01}, []float64{0, 0}, []float64{1, 1})
+	frontier := optimizer.Frontier(5)
+	assert.Equal(t, 5, len(frontier))
+	for i, point := range frontier {
+		assert.InDelta(t, 0.06+0.01*float64(i), point.Return, 1e-9)
+		if i > 0 {
+			assert.True(t, point.Volatility > frontier[i-1].Volatility)
+		}
+	}
+	assertWeights(t, []float64{0.2, 0.8}, frontier[0].Weights)
+	assertWeights(t, []float64{0.6, 0.4}, frontier[2].Weights)
+	assertWeights(t, []float64{1, 0}, frontier[4].Weights)
+	optimizer = testOptimizer(t, []float64{0.1, 0.1}, []float64{0.04, 0.01}, []float64{0, 0}, []float64{1, 1})
+	assert.Equal(t, 1, len(optimizer.Frontier(5)))
+}
+
+func TestNewOptimizerErrors(t *testing.T) {
+	covariance := [][]float64{{0.04, 0}, {0, 0.01}}
+	_, err := NewOptimizer([]float64{0.1, 0.05}, covariance, []float64{0, 0}, []float64{0.4, 0.5}, 0)
+	assert.Equal(t, ErrBadBounds, err)
+	_, err = NewOptimizer([]float64{0.1, 0.05}, covariance, []float64{0.6, 0.5}, []float64{1, 1}, 0)
+	assert.Equal(t, ErrBadBounds, err)
+	_, err = NewOptimizer([]float64{0.1, 0.05}, covariance, []float64{0.5, 0}, []float64{0.4, 1}, 0)
+	assert.Equal(t, ErrBadBounds, err)
+	_, err = NewOptimizer([]float64{0.1}, covariance, []float64{0}, []float64{1}, 0)
+	assert.EqualError(t, err, "returns, covariance and bounds must have the same size")
+}
+
+func TestEstimateReturns(t *testing.T) {
+	a := testBars(100, 110, 99, 108.9)
+	b := testBars(50, 50, 55, 55)
+	returns, covariance, observations, err := EstimateReturns([][]es.StockBar{a, b})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, observations)
+	assert.InDelta(t, Mean([]float64{0.1, -0.1, 0.1})*TradingDays, returns[0], 1e-9)
+	assert.InDelta(t, Mean([]float64{0, 0.1, 0})*TradingDays, returns[1], 1e-9)
+	assert.InDelta(t, Covariance([]float64{0.1, -0.1, 0.1}, []float64{0, 0.1, 0})*TradingDays, covariance[0][1], 1e-9)
+	assert.Equal(t, covariance[0][1], covariance[1][0])
+	_, _, _, err = EstimateReturns([][]es.StockBar{a, testBars(1, 2)})
+	assert.Equal(t, ErrNotEnoughData, err)
+	_, _, _, err = EstimateReturns(nil)
+	assert.Equal(t, ErrNotEnoughData, err)
+}
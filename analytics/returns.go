@@ -0,0 +1,142 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package analytics computes return and risk statistics on daily closes
+package analytics
+
+import "math"
+
+// TradingDays is the number of trading days in a year, used to annualize daily statistics
+const TradingDays = 252
+
+// Returns computes the daily returns of closes, close / previous close - 1
+func Returns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return []float64{}
+	}
+	returns := make([]float64, len(closes)-1)
+	for i := range returns {
+		returns[i] = closes[i+1]/closes[i] - 1
+	}
+	return returns
+}
+
+// LogReturns computes the daily logarithmic returns of closes, log(close / previous close)
+func LogReturns(closes []float64) []float64 {
+	if len(closes) < 2 {
+		return []float64{}
+	}
+	returns := make([]float64, len(closes)-1)
+	for i := range returns {
+		returns[i] = math.Log(closes[i+1] / closes[i])
+	}
+	return returns
+}
+
+// Mean computes the average of values, 0 when there is none
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// StdDev computes the sample standard deviation of values, 0 when there are less than two
+func StdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := Mean(values)
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(squares / float64(len(values)-1))
+}
+
+// Volatility computes the annualized volatility of daily returns
+func Volatility(returns []float64) float64 {
+	return StdDev(returns) * math.Sqrt(TradingDays)
+}
+
+// Sharpe computes the annualized Sharpe ratio of daily returns
+//
+// 	Sharpe(returns, 0.02)
+//
+// The risk free rate is annual. returns 0 when the returns do not vary
+func Sharpe(returns []float64, riskFree float64) float64 {
+	deviation := StdDev(returns)
+	if deviation == 0 {
+		return 0
+	}
+	return (Mean(returns) - riskFree/TradingDays) / deviation * math.Sqrt(TradingDays)
+}
+
+// Sortino computes the annualized Sortino ratio of daily returns, only the returns below the risk free rate are a risk
+//
+// 	Sortino(returns, 0.02)
+//
+// The risk free rate is annual. returns 0 when no return is below the risk free rate
+func Sortino(returns []float64, riskFree float64) float64 {
+	target := riskFree / TradingDays
+	var squares float64
+	for _, value := range returns {
+		if value < target {
+			squares += (value - target) * (value - target)
+		}
+	}
+	if squares == 0 {
+		return 0
+	}
+	downside := math.Sqrt(squares / float64(len(returns)))
+	return (Mean(returns) - target) / downside * math.Sqrt(TradingDays)
+}
+
+// moments returns the second, third and fourth central moments of values
+func moments(values []float64) (float64, float64, float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	mean := Mean(values)
+	var m2, m3, m4 float64
+	for _, value := range values {
+		deviation := value - mean
+		m2 += deviation * deviation
+		m3 += deviation * deviation * deviation
+		m4 += deviation * deviation * deviation * deviation
+	}
+	count := float64(len(values))
+	return m2 / count, m3 / count, m4 / count
+}
+
+// Skewness computes the skewness of values, 0 when they do not vary
+func Skewness(values []float64) float64 {
+	m2, m3, _ := moments(values)
+	if m2 == 0 {
+		return 0
+	}
+	return m3 / math.Pow(m2, 1.5)
+}
+
+// Kurtosis computes the excess kurtosis of values, 0 for a normal distribution or when they do not vary
+func Kurtosis(values []float64) float64 {
+	m2, _, m4 := moments(values)
+	if m2 == 0 {
+		return 0
+	}
+	return m4/(m2*m2) - 3
+}
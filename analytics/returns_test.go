@@ -0,0 +1,56 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCloses = []float64{100, 110, 99, 108.9}
+
+func TestReturns(t *testing.T) {
+	returns := Returns(testCloses)
+	assert.Equal(t, 3, len(returns))
+	assert.InDelta(t, 0.1, returns[0], 1e-9)
+	assert.InDelta(t, -0.1, returns[1], 1e-9)
+	assert.InDelta(t, 0.1, returns[2], 1e-9)
+	assert.Equal(t, []float64{}, Returns([]float64{100}))
+	logReturns := LogReturns(testCloses)
+	assert.InDelta(t, math.Log(1.1), logReturns[0], 1e-9)
+	assert.InDelta(t, math.Log(0.9), logReturns[1], 1e-9)
+	assert.Equal(t, []float64{}, LogReturns(nil))
+}
+
+func TestRatios(t *testing.T) {
+	returns := Returns(testCloses)
+	assert.InDelta(t, 0.115470, StdDev(returns), 1e-6)
+	assert.InDelta(t, 1.833030, Volatility(returns), 1e-6)
+	assert.InDelta(t, 4.582576, Sharpe(returns, 0), 1e-6)
+	assert.InDelta(t, 4.568828, Sharpe(returns, 0.0252), 1e-6)
+	assert.InDelta(t, 9.165151, Sortino(returns, 0), 1e-6)
+	assert.InDelta(t, 9.128527, Sortino(returns, 0.0252), 1e-6)
+	assert.Equal(t, 0.0, Sharpe([]float64{0.01, 0.01}, 0))
+	assert.Equal(t, 0.0, Sortino([]float64{0.01, 0.02}, 0))
+}
+
+func TestMoments(t *testing.T) {
+	returns := Returns(testCloses)
+	assert.InDelta(t, -1/math.Sqrt(2), Skewness(returns), 1e-9)
+	assert.InDelta(t, -1.5, Kurtosis(returns), 1e-9)
+	assert.Equal(t, 0.0, Skewness([]float64{1, 1}))
+	assert.Equal(t, 0.0, Kurtosis(nil))
+}
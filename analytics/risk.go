@@ -0,0 +1,90 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Drawdown is the largest fall of a price from a peak
+//
+// Depth is the fall as a fraction of the peak, Recovery is the first date the price is back to the peak
+// and is nil when it never was.
+type Drawdown struct {
+	Depth    float64    `json:"depth"`
+	Peak     time.Time  `json:"peak"`
+	Trough   time.Time  `json:"trough"`
+	Recovery *time.Time `json:"recovery"`
+}
+
+// MaxDrawdown finds the largest drawdown of closes
+//
+// 	MaxDrawdown(dates, closes)
+//
+// returns the drawdown, with a depth of 0 when the price never fell
+func MaxDrawdown(dates []time.Time, closes []float64) Drawdown {
+	var drawdown Drawdown
+	if len(closes) == 0 {
+		return drawdown
+	}
+	peak := 0
+	drawdown.Peak, drawdown.Trough = dates[0], dates[0]
+	worstPeak := -1
+	for i, value := range closes {
+		if value > closes[peak] {
+			peak = i
+		}
+		if depth := 1 - value/closes[peak]; depth > drawdown.Depth {
+			drawdown.Depth = depth
+			drawdown.Peak = dates[peak]
+			drawdown.Trough = dates[i]
+			worstPeak = peak
+		}
+	}
+	if worstPeak < 0 {
+		return drawdown
+	}
+	for i := range closes {
+		if dates[i].After(drawdown.Trough) && closes[i] >= closes[worstPeak] {
+			recovery := dates[i]
+			drawdown.Recovery = &recovery
+			break
+		}
+	}
+	return drawdown
+}
+
+// HistoricalVaR computes the value at risk and the conditional value at risk of daily returns
+//
+// 	HistoricalVaR(returns, 0.95)
+//
+// The value at risk is the loss which is not exceeded on the confidence share of the days, the
+// conditional value at risk is the average loss of the other days.
+// returns both as positive fractions
+func HistoricalVaR(returns []float64, confidence float64) (float64, float64) {
+	if len(returns) == 0 {
+		return 0, 0
+	}
+	sorted := make([]float64, len(returns))
+	copy(sorted, returns)
+	sort.Float64s(sorted)
+	// the tolerance keeps exact shares such as 20% of 10 days from rounding down
+	index := int(math.Floor((1-confidence)*float64(len(sorted)) + 1e-9))
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return -sorted[index], -Mean(sorted[:index+1])
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDates(count int) []time.Time {
+	dates := make([]time.Time, count)
+	for i := range dates {
+		dates[i] = time.Date(2017, time.January, 2+i, 0, 0, 0, 0, time.UTC)
+	}
+	return dates
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	dates := testDates(7)
+	drawdown := MaxDrawdown(dates, []float64{100, 120, 90, 110, 80, 121, 130})
+	assert.InDelta(t, 1-80.0/120, drawdown.Depth, 1e-9)
+	assert.Equal(t, dates[1], drawdown.Peak)
+	assert.Equal(t, dates[4], drawdown.Trough)
+	assert.Equal(t, dates[5], *drawdown.Recovery)
+	drawdown = MaxDrawdown(dates[:4], testCloses)
+	assert.InDelta(t, 0.1, drawdown.Depth, 1e-9)
+	assert.Nil(t, drawdown.Recovery)
+	drawdown = MaxDrawdown(dates[:3], []float64{1, 2, 3})
+	assert.Equal(t, 0.0, drawdown.Depth)
+	assert.Nil(t, drawdown.Recovery)
+}
+
+func TestHistoricalVaR(t *testing.T) {
+	returns := []float64{0.01, -0.04, 0.02, -0.01, 0.03, -0.02, 0, 0.01, -0.03, 0.02}
+	valueAtRisk, conditional := HistoricalVaR(returns, 0.95)
+	assert.InDelta(t, 0.04, valueAtRisk, 1e-9)
+	assert.InDelta(t, 0.04, conditional, 1e-9)
+	valueAtRisk, conditional = HistoricalVaR(returns, 0.8)
+	assert.InDelta(t, 0.02, valueAtRisk, 1e-9)
+	assert.InDelta(t, 0.03, conditional, 1e-9)
+	valueAtRisk, conditional = HistoricalVaR(nil, 0.95)
+	assert.Equal(t, []float64{0, 0}, []float64{valueAtRisk, conditional})
+}
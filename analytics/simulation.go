@@ -0,0 +1,155 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/clebi/gofin/es"
+)
+
+// SimulationPercentiles are the percentiles of the terminal value given by a simulation
+var SimulationPercentiles = []float64{5, 10, 25, 50, 75, 90, 95}
+
+// Simulation contains the parameters of a Monte Carlo simulation
+//
+// Horizon is a number of trading days. The contribution is added to the value every Every days, at the
+// close, and the probability to reach the target is computed when there is one. The seed makes the
+// paths reproducible.
+type Simulation struct {
+	Paths        int
+	Horizon      int
+	Contribution float64
+	Every        int
+	Target       float64
+	Seed         int64
+}
+
+// Band is a percentile of the simulated values
+type Band struct {
+	Percentile float64 `json:"percentile"`
+	Value      float64 `json:"value"`
+}
+
+// SimulationResult contains the distribution of the terminal value of the simulated paths
+//
+// Contributed is the sum of the contributions of a path and Probability the share of the paths which
+// end at or above the target, nil without target.
+type SimulationResult struct {
+	Initial     float64  `json:"initial"`
+	Paths       int      `json:"paths"`
+	Horizon     int      `json:"horizon"`
+	Contributed float64  `json:"contributed"`
+	Mean        float64  `json:"mean"`
+	Terminal    []Band   `json:"terminal"`
+	Target      float64  `json:"target,omitempty"`
+	Probability *float64 `json:"probability,omitempty"`
+}
+
+// Percentile computes a percentile of sorted values, interpolating between the two closest ones
+//
+// 	Percentile(sorted, 95)
+func Percentile(sorted []float64, percentile float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	position := percentile / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	if lower < 0 {
+		return sorted[0]
+	}
+	return sorted[lower] + (position-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// PortfolioReturns computes the daily returns of a portfolio of series of bars held with constant weights
+//
+// 	PortfolioReturns(bars, []float64{0.6, 0.4})
+//
+// The series are aligned on the dates where all of them have a close, the weights are the shares of the
+// value of the portfolio in each series.
+// returns the weighted sums of the returns of the series
+func PortfolioReturns(bars [][]es.StockBar, weights []float64) []float64 {
+	series := alignedReturns(bars)
+	if len(series) == 0 {
+		return []float64{}
+	}
+	returns := make([]float64, len(series[0]))
+	for i := range returns {
+		for j := range series {
+			returns[i] += weights[j] * series[j][i]
+		}
+	}
+	return returns
+}
+
+// MonteCarlo simulates future values of a portfolio by drawing its daily returns from past ones
+//
+// 	MonteCarlo(returns, 100000, Simulation{Paths: 1000, Horizon: 2520, Contribution: 500, Every: 21})
+//
+// Each day of a path draws one of the returns with replacement, so the distribution of the returns is
+// kept but not their order.
+// returns the percentiles of the terminal value
+func MonteCarlo(returns []float64, initial float64, simulation Simulation) (*SimulationResult, error) {
+	if len(returns) == 0 {
+		return nil, ErrNotEnoughData
+	}
+	if simulation.Paths <= 0 || simulation.Horizon <= 0 {
+		return nil, errors.New("paths and horizon must be positive")
+	}
+	if initial < 0 || simulation.Contribution < 0 {
+		return nil, errors.New("initial value and contribution cannot be negative")
+	}
+	random := rand.New(rand.NewSource(simulation.Seed))
+	terminal := make([]float64, simulation.Paths)
+	reached := 0
+	for path := range terminal {
+		value := initial
+		for day := 1; day <= simulation.Horizon; day++ {
+			value *= 1 + returns[random.Intn(len(returns))]
+			if simulation.Every > 0 && day%simulation.Every == 0 {
+				value += simulation.Contribution
+			}
+		}
+		terminal[path] = value
+		if simulation.Target > 0 && value >= simulation.Target {
+			reached++
+		}
+	}
+	sort.Float64s(terminal)
+	result := &SimulationResult{
+		Initial:  initial,
+		Paths:    simulation.Paths,
+		Horizon:  simulation.Horizon,
+		Mean:     Mean(terminal),
+		Terminal: make([]Band, len(SimulationPercentiles)),
+		Target:   simulation.Target,
+	}
+	if simulation.Every > 0 {
+		result.Contributed = simulation.Contribution * float64(simulation.Horizon/simulation.Every)
+	}
+	for i, percentile := range SimulationPercentiles {
+		result.Terminal[i] = Band{Percentile: percentile, Value: Percentile(terminal, percentile)}
+	}
+	if simulation.Target > 0 {
+		probability := float64(reached) / float64(simulation.Paths)
+		result.Probability = &probability
+	}
+	return result, nil
+}
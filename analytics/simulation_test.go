@@ -0,0 +1,75 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package analytics
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	assert.Equal(t, 1.0, Percentile(sorted, 0))
+	assert.Equal(t, 3.0, Percentile(sorted, 50))
+	assert.Equal(t, 5.0, Percentile(sorted, 100))
+	assert.InDelta(t, 4.6, Percentile(sorted, 90), 1e-9)
+	assert.Equal(t, 7.0, Percentile([]float64{7}, 25))
+}
+
+func TestPortfolioReturns(t *testing.T) {
+	a := testBars(100, 110, 121, 133.1)
+	// b misses the third day, the return of the fourth one spans two days
+	b := append(testBars(50, 45, 40, 36)[:2], testBars(50, 45, 40, 36)[3])
+	returns := PortfolioReturns([][]es.StockBar{a, b}, []float64{0.5, 0.5})
+	assert.Equal(t, 2, len(returns))
+	assert.InDelta(t, 0.5*0.1+0.5*-0.1, returns[0], 1e-9)
+	assert.InDelta(t, 0.5*0.21+0.5*-0.2, returns[1], 1e-9)
+	assert.Equal(t, []float64{}, PortfolioReturns([][]es.StockBar{a, testBars(1)}, []float64{0.5, 0.5}))
+	assert.Equal(t, []float64{}, PortfolioReturns(nil, nil))
+}
+
+func TestMonteCarlo(t *testing.T) {
+	result, err := MonteCarlo([]float64{0.01}, 1000, Simulation{Paths: 10, Horizon: 4, Contribution: 100, Every: 2, Target: 1200})
+	assert.Nil(t, err)
+	expected := (1000*1.01*1.01+100)*1.01*1.01 + 100
+	assert.Equal(t, len(SimulationPercentiles), len(result.Terminal))
+	for _, band := range result.Terminal {
+		assert.InDelta(t, expected, band.Value, 1e-9)
+	}
+	assert.InDelta(t, expected, result.Mean, 1e-9)
+	assert.Equal(t, 200.0, result.Contributed)
+	assert.Equal(t, 1.0, *result.Probability)
+	returns := []float64{-0.02, -0.01, 0, 0.01, 0.02, 0.03}
+	result, err = MonteCarlo(returns, 1000, Simulation{Paths: 500, Horizon: 20, Target: 1100, Seed: 3})
+	assert.Nil(t, err)
+	for i := 1; i < len(result.Terminal); i++ {
+		assert.True(t, result.Terminal[i].Value >= result.Terminal[i-1].Value)
+	}
+	assert.True(t, *result.Probability > 0 && *result.Probability < 1)
+	again, _ := MonteCarlo(returns, 1000, Simulation{Paths: 500, Horizon: 20, Target: 1100, Seed: 3})
+	assert.Equal(t, result, again)
+	result, _ = MonteCarlo(returns, 1000, Simulation{Paths: 1, Horizon: 1})
+	assert.Nil(t, result.Probability)
+}
+
+func TestMonteCarloErrors(t *testing.T) {
+	_, err := MonteCarlo(nil, 1000, Simulation{Paths: 1, Horizon: 1})
+	assert.Equal(t, ErrNotEnoughData, err)
+	_, err = MonteCarlo([]float64{0}, 1000, Simulation{Horizon: 1})
+	assert.EqualError(t, err, "paths and horizon must be positive")
+	_, err = MonteCarlo([]float64{0}, -1, Simulation{Paths: 1, Horizon: 1})
+	assert.EqualError(t, err, "initial value and contribution cannot be negative")
+}
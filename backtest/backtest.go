@@ -0,0 +1,103 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package backtest simulates trading strategies over the stored history of a symbol
+//
+// A strategy sees each bar at its close and answers with orders, which the simulated broker fills
+// at the open of the next bar so that a strategy never trades on a price it could not have known.
+package backtest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// ErrNoBars is returned when there is no bar to run a backtest on
+var ErrNoBars = errors.New("no bars to backtest")
+
+// Strategy decides the orders of a backtest
+type Strategy interface {
+	// OnBar is called on the close of each bar with the account valued at this close and returns the
+	// orders to fill at the open of the next bar
+	OnBar(bar es.StockBar, account Account) []Order
+}
+
+// Point is a value of the equity curve, at the close of a bar
+type Point struct {
+	Date   time.Time `json:"date"`
+	Cash   float64   `json:"cash"`
+	Shares float64   `json:"shares"`
+	Equity float64   `json:"equity"`
+}
+
+// Result contains the trades, the equity curve and the statistics of a backtest
+type Result struct {
+	Symbol     string     `json:"symbol"`
+	Config     Config     `json:"config"`
+	Trades     []Trade    `json:"trades"`
+	Equity     []Point    `json:"equity"`
+	Statistics Statistics `json:"statistics"`
+}
+
+// Run backtests a strategy over bars sorted by date
+//
+// 	Run(bars, &BuyAndHold{}, config)
+//
+// returns the result, the orders given on the last bar are not filled
+func Run(bars []es.StockBar, strategy Strategy, config Config) (*Result, error) {
+	return RunFrom(bars, time.Time{}, strategy, config)
+}
+
+// RunFrom backtests a strategy over bars sorted by date, starting to trade on a day
+//
+// 	RunFrom(bars, start, strategy, config)
+//
+// The bars before the start only warm the strategy up: its orders are ignored and they are not
+// part of the equity curve.
+// returns the result, the orders given on the last bar are not filled
+func RunFrom(bars []es.StockBar, start time.Time, strategy Strategy, config Config) (*Result, error) {
+	if err := config.check(); err != nil {
+		return nil, err
+	}
+	first := 0
+	for first < len(bars) && bars[first].Date.Before(start) {
+		strategy.OnBar(bars[first], Account{Cash: config.Cash, Equity: config.Cash})
+		first++
+	}
+	if first == len(bars) {
+		return nil, ErrNoBars
+	}
+	broker := &broker{config: config, account: Account{Cash: config.Cash}}
+	result := &Result{
+		Symbol: bars[first].Symbol,
+		Config: config,
+		Trades: []Trade{},
+		Equity: make([]Point, 0, len(bars)-first),
+	}
+	var orders []Order
+	for _, bar := range bars[first:] {
+		for _, order := range orders {
+			if trade := broker.fill(order, bar); trade != nil {
+				result.Trades = append(result.Trades, *trade)
+			}
+		}
+		account := &broker.account
+		account.Equity = account.Cash + account.Shares*bar.Close
+		result.Equity = append(result.Equity, Point{Date: bar.Date, Cash: account.Cash, Shares: account.Shares, Equity: account.Equity})
+		orders = strategy.OnBar(bar, *account)
+	}
+	result.Statistics = computeStatistics(bars[first:], result, config)
+	return result, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBars(prices ...float64) []es.StockBar {
+	bars := make([]es.StockBar, len(prices))
+	for i, price := range prices {
+		bars[i] = es.StockBar{Symbol: "TEST", Date: testDate.AddDate(0, 0, i), Open: price, Close: price}
+	}
+	return bars
+}
+
+// scriptStrategy gives fixed orders on some bars and records the accounts it sees
+type scriptStrategy struct {
+	orders   map[int][]Order
+	accounts []Account
+}
+
+func (strategy *scriptStrategy) OnBar(bar es.StockBar, account Account) []Order {
+	strategy.accounts = append(strategy.accounts, account)
+	return strategy.orders[len(strategy.accounts)-1]
+}
+
+func TestRun(t *testing.T) {
+	strategy := &scriptStrategy{orders: map[int][]Order{0: {Buy(5)}, 2: {Sell(2), Sell(1)}, 3: {Sell(2)}}}
+	bars := testBars(100, 102, 110, 105)
+	result, err := Run(bars, strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, "TEST", result.Symbol)
+	assert.Equal(t, 3, len(result.Trades))
+	assert.Equal(t, bars[1].Date, result.Trades[0].Date)
+	assert.Equal(t, 102.0, result.Trades[0].Price)
+	assert.Equal(t, bars[3].Date, result.Trades[2].Date)
+	assert.Equal(t, []Point{
+		{Date: bars[0].Date, Cash: 1000, Shares: 0, Equity: 1000},
+		{Date: bars[1].Date, Cash: 490, Shares: 5, Equity: 1000},
+		{Date: bars[2].Date, Cash: 490, Shares: 5, Equity: 1040},
+		{Date: bars[3].Date, Cash: 805, Shares: 2, Equity: 1015},
+	}, result.Equity)
+	assert.Equal(t, Account{Cash: 490, Shares: 5, CostBasis: 510, Equity: 1040}, strategy.accounts[2])
+	assert.Equal(t, 1015.0, result.Statistics.FinalEquity)
+}
+
+func TestRunFrom(t *testing.T) {
+	strategy := &scriptStrategy{orders: map[int][]Order{0: {Buy(5)}, 1: {Buy(1)}}}
+	bars := testBars(100, 102, 110, 105)
+	result, err := RunFrom(bars, bars[2].Date, strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(strategy.accounts))
+	assert.Equal(t, Account{Cash: 1000, Equity: 1000}, strategy.accounts[0])
+	assert.Equal(t, 0, len(result.Trades))
+	assert.Equal(t, 2, len(result.Equity))
+	assert.Equal(t, bars[2].Date, result.Statistics.Start)
+
+	_, err = RunFrom(bars, bars[3].Date.AddDate(0, 0, 1), strategy, Config{Cash: 1000})
+	assert.Equal(t, ErrNoBars, err)
+	_, err = Run(nil, strategy, Config{Cash: 1000})
+	assert.Equal(t, ErrNoBars, err)
+	_, err = Run(bars, strategy, Config{})
+	assert.EqualError(t, err, "cash must be positive")
+}
+
+func TestStatistics(t *testing.T) {
+	bars := testBars(100, 100, 110, 99, 120)
+	result, err := Run(bars, &BuyAndHold{}, Config{Cash: 1000, Commission: 1, RiskFree: 0.01})
+	assert.Nil(t, err)
+	stats := result.Statistics
+	assert.Equal(t, bars[0].Date, stats.Start)
+	assert.Equal(t, bars[4].Date, stats.End)
+	assert.Equal(t, 1000.0, stats.InitialEquity)
+	// 9 shares bought at 100 for 901 with the commission
+	assert.InDelta(t, 99+9*120, stats.FinalEquity, 1e-9)
+	assert.InDelta(t, 0.179, stats.TotalReturn, 1e-9)
+	assert.InDelta(t, 0.2, stats.BuyAndHoldReturn, 1e-9)
+	assert.InDelta(t, 1-(99+9*99.0)/(99+9*110), stats.MaxDrawdown.Depth, 1e-9)
+	assert.Equal(t, 1, stats.Trades)
+	assert.Equal(t, 0, stats.Sells)
+	assert.Equal(t, 0.0, stats.WinRate)
+	assert.Equal(t, 0.0, stats.ProfitFactor)
+	assert.Equal(t, 1.0, stats.Commissions)
+	assert.InDelta(t, 0.8, stats.Exposure, 1e-9)
+	assert.True(t, stats.AnnualReturn > stats.TotalReturn)
+	assert.True(t, stats.Volatility > 0)
+	assert.True(t, stats.Sharpe > 0)
+}
+
+func TestStatisticsSells(t *testing.T) {
+	strategy := &scriptStrategy{orders: map[int][]Order{0: {Buy(4)}, 1: {Sell(1)}, 2: {Sell(1)}, 3: {Sell(2)}}}
+	result, err := Run(testBars(100, 100, 110, 90, 95), strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	stats := result.Statistics
+	assert.Equal(t, 4, stats.Trades)
+	assert.Equal(t, 3, stats.Sells)
+	assert.InDelta(t, 1.0/3, stats.WinRate, 1e-9)
+	// one share won 10 on the first sell, then 10 and 2 * 5 were lost
+	assert.InDelta(t, 0.5, stats.ProfitFactor, 1e-9)
+}
@@ -0,0 +1,168 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Sides of the trades
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// epsilon is the smallest quantity of shares traded, smaller fractional quantities are rounding errors
+const epsilon = 1e-9
+
+// Order is a request to trade the backtested symbol, it is filled at the open of the next bar
+//
+// Quantity is a number of shares, positive to buy and negative to sell. With ByWeight the order brings
+// instead the value of the shares to Weight times the equity, a weight of 0 closes the position.
+type Order struct {
+	Quantity float64
+	Weight   float64
+	ByWeight bool
+}
+
+// Buy creates an order buying a number of shares
+func Buy(quantity float64) Order {
+	return Order{Quantity: quantity}
+}
+
+// Sell creates an order selling a number of shares
+func Sell(quantity float64) Order {
+	return Order{Quantity: -quantity}
+}
+
+// Target creates an order bringing the value of the shares to a fraction of the equity
+func Target(weight float64) Order {
+	return Order{Weight: weight, ByWeight: true}
+}
+
+// Config contains the settings of a backtest and of its simulated broker
+//
+// A trade pays the fixed commission plus the commission rate of its value. Buys are filled the
+// slippage fraction above the open and sells below it. Without Fractional, quantities are rounded
+// down to whole shares. The risk free rate is annual, for the Sharpe and Sortino ratios.
+type Config struct {
+	Cash           float64 `json:"cash" validate:"gt=0"`
+	Commission     float64 `json:"commission" validate:"gte=0"`
+	CommissionRate float64 `json:"commission_rate" validate:"gte=0,lt=1"`
+	Slippage       float64 `json:"slippage" validate:"gte=0,lt=1"`
+	Fractional     bool    `json:"fractional"`
+	RiskFree       float64 `json:"risk_free" validate:"gte=0,lt=1"`
+}
+
+func (config Config) check() error {
+	switch {
+	case !(config.Cash > 0):
+		return errors.New("cash must be positive")
+	case config.Commission < 0 || config.CommissionRate < 0:
+		return errors.New("commissions cannot be negative")
+	case config.CommissionRate >= 1:
+		return errors.New("commission rate must be lower than 1")
+	case config.Slippage < 0 || config.Slippage >= 1:
+		return errors.New("slippage must be between 0 and 1")
+	}
+	return nil
+}
+
+// Account is the state of the simulated account, CostBasis is the price paid for the shares held with the commissions
+type Account struct {
+	Cash      float64 `json:"cash"`
+	Shares    float64 `json:"shares"`
+	CostBasis float64 `json:"cost_basis"`
+	Equity    float64 `json:"equity"`
+}
+
+// Trade is an order filled by the simulated broker
+//
+// The profit of a sell is its proceeds net of commission less the cost basis of the shares sold.
+type Trade struct {
+	Date       time.Time `json:"date"`
+	Side       string    `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	Commission float64   `json:"commission"`
+	Profit     float64   `json:"profit,omitempty"`
+}
+
+// broker fills the orders of a strategy on an account
+type broker struct {
+	config  Config
+	account Account
+}
+
+func (broker *broker) round(quantity float64) float64 {
+	if broker.config.Fractional {
+		return quantity
+	}
+	return math.Floor(quantity + epsilon)
+}
+
+func (broker *broker) commission(quantity float64, price float64) float64 {
+	return broker.config.Commission + broker.config.CommissionRate*quantity*price
+}
+
+// fill executes an order at the open of a bar
+//
+// Sells are limited to the shares held and buys to the cash, an order which cannot trade anything gives no trade.
+func (broker *broker) fill(order Order, bar es.StockBar) *Trade {
+	open := bar.Open
+	if open <= 0 {
+		open = bar.Close
+	}
+	account := &broker.account
+	quantity := order.Quantity
+	if order.ByWeight {
+		equity := account.Cash + account.Shares*open
+		quantity = order.Weight*equity/open - account.Shares
+	}
+	if quantity < 0 {
+		price := open * (1 - broker.config.Slippage)
+		quantity = broker.round(math.Min(-quantity, account.Shares))
+		if order.ByWeight && order.Weight == 0 {
+			quantity = account.Shares
+		}
+		if quantity < epsilon {
+			return nil
+		}
+		commission := broker.commission(quantity, price)
+		basis := account.CostBasis * quantity / account.Shares
+		proceeds := quantity*price - commission
+		account.Cash += proceeds
+		account.Shares -= quantity
+		account.CostBasis -= basis
+		if account.Shares < epsilon {
+			account.Shares, account.CostBasis = 0, 0
+		}
+		return &Trade{Date: bar.Date, Side: SideSell, Quantity: quantity, Price: price, Commission: commission, Profit: proceeds - basis}
+	}
+	price := open * (1 + broker.config.Slippage)
+	affordable := (account.Cash - broker.config.Commission) / (price * (1 + broker.config.CommissionRate))
+	quantity = broker.round(math.Min(quantity, affordable))
+	if quantity < epsilon {
+		return nil
+	}
+	commission := broker.commission(quantity, price)
+	account.Cash -= quantity*price + commission
+	account.Shares += quantity
+	account.CostBasis += quantity*price + commission
+	return &Trade{Date: bar.Date, Side: SideBuy, Quantity: quantity, Price: price, Commission: commission}
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+var testDate = time.Date(2017, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+func TestFillWholeShares(t *testing.T) {
+	broker := &broker{
+		config:  Config{Cash: 1000, Commission: 1, CommissionRate: 0.01, Slippage: 0.01},
+		account: Account{Cash: 1000},
+	}
+	trade := broker.fill(Buy(20), es.StockBar{Date: testDate, Open: 100, Close: 105})
+	assert.Equal(t, SideBuy, trade.Side)
+	assert.Equal(t, testDate, trade.Date)
+	assert.Equal(t, 9.0, trade.Quantity)
+	assert.InDelta(t, 101, trade.Price, 1e-9)
+	assert.InDelta(t, 10.09, trade.Commission, 1e-9)
+	assert.InDelta(t, 80.91, broker.account.Cash, 1e-9)
+	assert.InDelta(t, 919.09, broker.account.CostBasis, 1e-9)
+
+	trade = broker.fill(Sell(5), es.StockBar{Date: testDate, Open: 110})
+	assert.Equal(t, SideSell, trade.Side)
+	assert.Equal(t, 5.0, trade.Quantity)
+	assert.InDelta(t, 108.9, trade.Price, 1e-9)
+	assert.InDelta(t, 6.445, trade.Commission, 1e-9)
+	assert.InDelta(t, 538.055-919.09*5/9, trade.Profit, 1e-9)
+	assert.InDelta(t, 618.965, broker.account.Cash, 1e-9)
+	assert.InDelta(t, 919.09*4/9, broker.account.CostBasis, 1e-9)
+
+	trade = broker.fill(Sell(10), es.StockBar{Date: testDate, Open: 110})
+	assert.Equal(t, 4.0, trade.Quantity)
+	assert.Equal(t, 0.0, broker.account.Shares)
+	assert.Equal(t, 0.0, broker.account.CostBasis)
+	assert.Nil(t, broker.fill(Sell(1), es.StockBar{Date: testDate, Open: 110}))
+}
+
+func TestFillFractional(t *testing.T) {
+	broker := &broker{config: Config{Cash: 1000, Fractional: true}, account: Account{Cash: 1000}}
+	trade := broker.fill(Target(0.5), es.StockBar{Date: testDate, Open: 80})
+	assert.InDelta(t, 6.25, trade.Quantity, 1e-9)
+	assert.InDelta(t, 500, broker.account.Cash, 1e-9)
+	// the equity is 1125 at 100, only the 500 of cash can be invested
+	trade = broker.fill(Target(1), es.StockBar{Date: testDate, Open: 100})
+	assert.InDelta(t, 5, trade.Quantity, 1e-9)
+	assert.InDelta(t, 0, broker.account.Cash, 1e-9)
+	assert.Nil(t, broker.fill(Buy(1), es.StockBar{Date: testDate, Open: 100}))
+	trade = broker.fill(Target(0), es.StockBar{Date: testDate, Close: 120})
+	assert.Equal(t, SideSell, trade.Side)
+	assert.InDelta(t, 11.25, trade.Quantity, 1e-9)
+	assert.InDelta(t, 120, trade.Price, 1e-9)
+	assert.InDelta(t, 1350, broker.account.Cash, 1e-9)
+	assert.InDelta(t, 350, trade.Profit, 1e-9)
+	assert.Equal(t, 0.0, broker.account.Shares)
+}
+
+func TestFillNoCash(t *testing.T) {
+	broker := &broker{config: Config{Cash: 50, Commission: 10}, account: Account{Cash: 50}}
+	assert.Nil(t, broker.fill(Buy(1), es.StockBar{Date: testDate, Open: 45}))
+	assert.Nil(t, broker.fill(Target(1), es.StockBar{Date: testDate, Open: 45}))
+	assert.Equal(t, 50.0, broker.account.Cash)
+}
+
+func TestConfigCheck(t *testing.T) {
+	assert.Nil(t, Config{Cash: 1}.check())
+	for _, tt := range []struct {
+		config Config
+		msg    string
+	}{
+		{Config{}, "cash must be positive"},
+		{Config{Cash: 1, Commission: -1}, "commissions cannot be negative"},
+		{Config{Cash: 1, CommissionRate: 1}, "commission rate must be lower than 1"},
+		{Config{Cash: 1, Slippage: 1}, "slippage must be between 0 and 1"},
+	} {
+		assert.EqualError(t, tt.config.check(), tt.msg)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Metrics ranking the trials of a sweep, the higher the better
+const (
+	MetricSharpe       = "sharpe"
+	MetricSortino      = "sortino"
+	MetricTotalReturn  = "total_return"
+	MetricAnnualReturn = "annual_return"
+	MetricMaxDrawdown  = "max_drawdown"
+	MetricProfitFactor = "profit_factor"
+	MetricWinRate      = "win_rate"
+)
+
+// MaxTrials is the largest number of parameter sets of a sweep
+const MaxTrials = 10000
+
+// Metric returns a statistic by its name, the max drawdown is negated so that the higher is the better
+//
+// 	stats.Metric("sharpe")
+func (stats Statistics) Metric(name string) (float64, error) {
+	switch name {
+	case MetricSharpe:
+		return stats.Sharpe, nil
+	case MetricSortino:
+		return stats.Sortino, nil
+	case MetricTotalReturn:
+		return stats.TotalReturn, nil
+	case MetricAnnualReturn:
+		return stats.AnnualReturn, nil
+	case MetricMaxDrawdown:
+		return -stats.MaxDrawdown.Depth, nil
+	case MetricProfitFactor:
+		return stats.ProfitFactor, nil
+	case MetricWinRate:
+		return stats.WinRate, nil
+	}
+	return 0, fmt.Errorf("unknown metric: %s", name)
+}
+
+// Range is the values a parameter takes in a sweep, from Min to Max by Step
+type Range struct {
+	Name string  `json:"name" validate:"required"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Step float64 `json:"step" validate:"gte=0"`
+}
+
+func (r Range) checkBounds() error {
+	for _, bound := range []float64{r.Min, r.Max, r.Step} {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("min, max and step of %s must be finite", r.Name)
+		}
+	}
+	if r.Max < r.Min {
+		return fmt.Errorf("max of %s is lower than its min", r.Name)
+	}
+	return nil
+}
+
+// Values returns the values of the range, Max is included when it is on a step
+func (r Range) Values() ([]float64, error) {
+	if err := r.checkBounds(); err != nil {
+		return nil, err
+	}
+	if !(r.Step > 0) {
+		return nil, fmt.Errorf("step of %s must be positive", r.Name)
+	}
+	// the count is checked before its conversion to an int, which overflows for a tiny step
+	count := math.Floor((r.Max-r.Min)/r.Step+1e-9) + 1
+	if !(count <= MaxTrials) {
+		return nil, fmt.Errorf("too many values for %s: more than %d", r.Name, MaxTrials)
+	}
+	values := make([]float64, int(count))
+	for i := range values {
+		values[i] = r.Min + float64(i)*r.Step
+	}
+	return values, nil
+}
+
+// Parameters are the values of the parameters of a strategy by name
+type Parameters map[string]float64
+
+// Grid returns all the combinations of the values of the ranges
+//
+// 	Grid([]Range{{Name: "fast", Min: 10, Max: 50, Step: 10}, {Name: "slow", Min: 100, Max: 200, Step: 50}})
+//
+// returns the parameter sets, the last range varying the fastest
+func Grid(ranges []Range) ([]Parameters, error) {
+	sets := []Parameters{{}}
+	for _, r := range ranges {
+		values, err := r.Values()
+		if err != nil {
+			return nil, err
+		}
+		if len(sets)*len(values) > MaxTrials {
+			return nil, fmt.Errorf("too many combinations: more than %d", MaxTrials)
+		}
+		next := make([]Parameters, 0, len(sets)*len(values))
+		for _, set := range sets {
+			for _, value := range values {
+				combination := Parameters{r.Name: value}
+				for name, other := range set {
+					combination[name] = other
+				}
+				next = append(next, combination)
+			}
+		}
+		sets = next
+	}
+	return sets, nil
+}
+
+// Random draws parameter sets, a range with a step gives one of its values and a range without step any value
+//
+// 	Random(ranges, 100, 42)
+//
+// The seed makes the draws reproducible.
+// returns the parameter sets
+func Random(ranges []Range, samples int, seed int64) ([]Parameters, error) {
+	if samples <= 0 || samples > MaxTrials {
+		return nil, fmt.Errorf("samples must be between 1 and %d", MaxTrials)
+	}
+	values := make([][]float64, len(ranges))
+	for i, r := range ranges {
+		if err := r.checkBounds(); err != nil {
+			return nil, err
+		}
+		if r.Step > 0 {
+			var err error
+			if values[i], err = r.Values(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	random := rand.New(rand.NewSource(seed))
+	sets := make([]Parameters, samples)
+	for i := range sets {
+		sets[i] = Parameters{}
+		for j, r := range ranges {
+			if values[j] != nil {
+				sets[i][r.Name] = values[j][random.Intn(len(values[j]))]
+			} else {
+				sets[i][r.Name] = r.Min + random.Float64()*(r.Max-r.Min)
+			}
+		}
+	}
+	return sets, nil
+}
+
+// StrategyFactory creates a new strategy for a parameter set
+type StrategyFactory func(params Parameters) (Strategy, error)
+
+// RulesFactory creates rules strategies whose conditions have the parameters between braces
+//
+// 	RulesFactory("sma({fast}) > sma({slow})", "sma({fast}) < sma({slow})", 1)
+func RulesFactory(entry string, exit string, weight float64) StrategyFactory {
+	return func(params Parameters) (Strategy, error) {
+		pairs := make([]string, 0, 2*len(params))
+		for name, value := range params {
+			pairs = append(pairs, "{"+name+"}", strconv.FormatFloat(value, 'f', -1, 64))
+		}
+		replacer := strings.NewReplacer(pairs...)
+		return NewRules(replacer.Replace(entry), replacer.Replace(exit), weight)
+	}
+}
+
+// Trial is the backtest of a parameter set, Score is its metric and Error why it could not run
+type Trial struct {
+	Parameters Parameters `json:"parameters"`
+	Score      float64    `json:"score"`
+	Statistics Statistics `json:"statistics"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func runTrial(bars []es.StockBar, start time.Time, params Parameters, factory StrategyFactory, config Config, metric string) Trial {
+	trial := Trial{Parameters: params}
+	strategy, err := factory(params)
+	if err == nil {
+		var result *Result
+		if result, err = RunFrom(bars, start, strategy, config); err == nil {
+			trial.Statistics = result.Statistics
+			trial.Score, err = result.Statistics.Metric(metric)
+		}
+	}
+	if err != nil {
+		trial.Error = err.Error()
+	}
+	return trial
+}
+
+// Sweep backtests a strategy with each parameter set on a pool of workers and ranks the trials by a metric
+//
+// 	Sweep(bars, start, sets, factory, config, "sharpe", 0)
+//
+// The bars before the start warm the strategies up. There is one worker by CPU when workers is not positive.
+// returns the trials from the best to the worst, the ones which failed last
+func Sweep(bars []es.StockBar, start time.Time, sets []Parameters, factory StrategyFactory,
+	config Config, metric string, workers int) ([]Trial, error) {
+	if _, err := (Statistics{}).Metric(metric); err != nil {
+		return nil, err
+	}
+	if len(bars) == 0 {
+		return nil, ErrNoBars
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	trials := make([]Trial, len(sets))
+	indexes := make(chan int)
+	var wait sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for index := range indexes {
+				trials[index] = runTrial(bars, start, sets[index], factory, config, metric)
+			}
+		}()
+	}
+	for i := range sets {
+		indexes <- i
+	}
+	close(indexes)
+	wait.Wait()
+	sort.SliceStable(trials, func(i, j int) bool {
+		if (trials[i].Error == "") != (trials[j].Error == "") {
+			return trials[i].Error == ""
+		}
+		return trials[i].Score > trials[j].Score
+	})
+	return trials, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/analytics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetric(t *testing.T) {
+	stats := Statistics{Sharpe: 1.5, TotalReturn: 0.2, MaxDrawdown: analytics.Drawdown{Depth: 0.1}, WinRate: 0.5}
+	value, err := stats.Metric(MetricSharpe)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, value)
+	value, _ = stats.Metric(MetricTotalReturn)
+	assert.Equal(t, 0.2, value)
+	value, _ = stats.Metric(MetricMaxDrawdown)
+	assert.Equal(t, -0.1, value)
+	value, _ = stats.Metric(MetricWinRate)
+	assert.Equal(t, 0.5, value)
+	_, err = stats.Metric("luck")
+	assert.EqualError(t, err, "unknown metric: luck")
+}
+
+func TestRangeValues(t *testing.T) {
+	values, err := Range{Name: "fast", Min: 10, Max: 50, Step: 20}.Values()
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{10, 30, 50}, values)
+	values, err = Range{Name: "weight", Min: 0, Max: 0.3, Step: 0.1}.Values()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(values))
+	_, err = Range{Name: "fast", Min: 10, Max: 50}.Values()
+	assert.EqualError(t, err, "step of fast must be positive")
+	_, err = Range{Name: "fast", Min: 50, Max: 10, Step: 1}.Values()
+	assert.EqualError(t, err, "max of fast is lower than its min")
+	_, err = Range{Name: "fast", Min: 0, Max: 10001, Step: 1}.Values()
+	assert.EqualError(t, err, "too many values for fast: more than 10000")
+	_, err = Range{Name: "fast", Min: -1e308, Max: 1e308, Step: 1e-300}.Values()
+	assert.EqualError(t, err, "too many values for fast: more than 10000")
+	_, err = Range{Name: "fast", Min: 0, Max: math.Inf(1), Step: 1}.Values()
+	assert.EqualError(t, err, "min, max and step of fast must be finite")
+	_, err = Random([]Range{{Name: "weight", Min: math.NaN(), Max: 1}}, 1, 42)
+	assert.EqualError(t, err, "min, max and step of weight must be finite")
+}
+
+func TestGrid(t *testing.T) {
+	sets, err := Grid([]Range{{Name: "fast", Min: 10, Max: 20, Step: 10}, {Name: "slow", Min: 100, Max: 200, Step: 100}})
+	assert.Nil(t, err)
+	assert.Equal(t, []Parameters{
+		{"fast": 10, "slow": 100},
+		{"fast": 10, "slow": 200},
+		{"fast": 20, "slow": 100},
+		{"fast": 20, "slow": 200},
+	}, sets)
+	_, err = Grid([]Range{{Name: "a", Min: 1, Max: 1000, Step: 1}, {Name: "b", Min: 1, Max: 1000, Step: 1}})
+	assert.EqualError(t, err, "too many combinations: more than 10000")
+}
+
+func TestRandom(t *testing.T) {
+	ranges := []Range{{Name: "fast", Min: 10, Max: 50, Step: 10}, {Name: "weight", Min: 0.5, Max: 1}}
+	sets, err := Random(ranges, 20, 42)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, len(sets))
+	for _, set := range sets {
+		assert.Contains(t, []float64{10, 20, 30, 40, 50}, set["fast"])
+		assert.True(t, set["weight"] >= 0.5 && set["weight"] <= 1)
+	}
+	again, _ := Random(ranges, 20, 42)
+	assert.Equal(t, sets, again)
+	_, err = Random(ranges, 0, 42)
+	assert.EqualError(t, err, "samples must be between 1 and 10000")
+}
+
+func TestRulesFactory(t *testing.T) {
+	factory := RulesFactory("close > {level}", "close < {level} - {gap}", 1)
+	strategy, err := factory(Parameters{"level": 100, "gap": 2.5})
+	assert.Nil(t, err)
+	result, err := Run(testBars(90, 101, 102, 97, 96), strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result.Trades))
+	assert.Equal(t, 96.0, result.Trades[1].Price)
+	_, err = factory(Parameters{"level": 100})
+	assert.NotNil(t, err)
+	_, err = RulesFactory("close > sma({period})", "", 1)(Parameters{"period": 1e12})
+	assert.EqualError(t, err, "period of sma must be at most 1000: 1000000000000")
+}
+
+func TestSweep(t *testing.T) {
+	bars := testBars(90, 100, 110, 120, 130)
+	factory := RulesFactory("close > {level}", "", 1)
+	sets := []Parameters{{"level": 125}, {}, {"level": 95}, {"level": 105}}
+	trials, err := Sweep(bars, testDate, sets, factory, Config{Cash: 1100, Fractional: true}, MetricTotalReturn, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(trials))
+	assert.Equal(t, Parameters{"level": 95}, trials[0].Parameters)
+	assert.InDelta(t, 130.0/110-1, trials[0].Score, 1e-9)
+	assert.Equal(t, Parameters{"level": 105}, trials[1].Parameters)
+	assert.InDelta(t, 130.0/120-1, trials[1].Score, 1e-9)
+	assert.Equal(t, Parameters{"level": 125}, trials[2].Parameters)
+	assert.Equal(t, 0.0, trials[2].Score)
+	assert.Equal(t, Parameters{}, trials[3].Parameters)
+	assert.NotEqual(t, "", trials[3].Error)
+	_, err = Sweep(bars, testDate, sets, factory, Config{Cash: 1100}, "luck", 0)
+	assert.EqualError(t, err, "unknown metric: luck")
+	_, err = Sweep(nil, testDate, sets, factory, Config{Cash: 1100}, MetricSharpe, 0)
+	assert.Equal(t, ErrNoBars, err)
+}
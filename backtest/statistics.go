@@ -0,0 +1,112 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"github.com/clebi/gofin/analytics"
+	"github.com/clebi/gofin/es"
+)
+
+// daysPerYear is the average number of calendar days in a year, for the annual return
+const daysPerYear = 365.25
+
+// Statistics contains the performance of a backtest
+//
+// The volatility, the Sharpe and Sortino ratios and the drawdown are computed on the daily equity.
+// The profit factor is the gross profit of the sells divided by their gross loss, 0 without loss.
+// Exposure is the share of the days ending with shares held and the buy and hold return is the one
+// of the symbol over the same days, as a benchmark.
+type Statistics struct {
+	Start            time.Time          `json:"start"`
+	End              time.Time          `json:"end"`
+	InitialEquity    float64            `json:"initial_equity"`
+	FinalEquity      float64            `json:"final_equity"`
+	TotalReturn      float64            `json:"total_return"`
+	AnnualReturn     float64            `json:"annual_return"`
+	Volatility       float64            `json:"volatility"`
+	Sharpe           float64            `json:"sharpe"`
+	Sortino          float64            `json:"sortino"`
+	MaxDrawdown      analytics.Drawdown `json:"max_drawdown"`
+	Trades           int                `json:"trades"`
+	Sells            int                `json:"sells"`
+	WinRate          float64            `json:"win_rate"`
+	ProfitFactor     float64            `json:"profit_factor"`
+	Commissions      float64            `json:"commissions"`
+	Exposure         float64            `json:"exposure"`
+	BuyAndHoldReturn float64            `json:"buy_and_hold_return"`
+}
+
+func computeStatistics(bars []es.StockBar, result *Result, config Config) Statistics {
+	dates := make([]time.Time, len(result.Equity))
+	equity := make([]float64, len(result.Equity))
+	held := 0
+	for i, point := range result.Equity {
+		dates[i], equity[i] = point.Date, point.Equity
+		if point.Shares > 0 {
+			held++
+		}
+	}
+	last := len(equity) - 1
+	stats := Statistics{
+		Start:         dates[0],
+		End:           dates[last],
+		InitialEquity: config.Cash,
+		FinalEquity:   equity[last],
+		TotalReturn:   equity[last]/config.Cash - 1,
+		MaxDrawdown:   analytics.MaxDrawdown(dates, equity),
+		Trades:        len(result.Trades),
+		Exposure:      float64(held) / float64(len(equity)),
+	}
+	if days := dates[last].Sub(dates[0]).Hours() / 24; days > 0 && equity[last] > 0 {
+		stats.AnnualReturn = math.Pow(equity[last]/config.Cash, daysPerYear/days) - 1
+	}
+	// an account emptied by its commissions has no return after it
+	var returns []float64
+	for _, value := range analytics.Returns(append([]float64{config.Cash}, equity...)) {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			returns = append(returns, value)
+		}
+	}
+	stats.Volatility = analytics.Volatility(returns)
+	stats.Sharpe = analytics.Sharpe(returns, config.RiskFree)
+	stats.Sortino = analytics.Sortino(returns, config.RiskFree)
+	var wins int
+	var grossProfit, grossLoss float64
+	for _, trade := range result.Trades {
+		stats.Commissions += trade.Commission
+		if trade.Side != SideSell {
+			continue
+		}
+		stats.Sells++
+		if trade.Profit > 0 {
+			wins++
+			grossProfit += trade.Profit
+		} else {
+			grossLoss -= trade.Profit
+		}
+	}
+	if stats.Sells > 0 {
+		stats.WinRate = float64(wins) / float64(stats.Sells)
+	}
+	if grossLoss > 0 {
+		stats.ProfitFactor = grossProfit / grossLoss
+	}
+	if bars[0].Close > 0 {
+		stats.BuyAndHoldReturn = bars[len(bars)-1].Close/bars[0].Close - 1
+	}
+	return stats
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"errors"
+	"math"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/screener"
+)
+
+// VariablePnL is the profit and loss of the shares held in percent of their cost basis, for the conditions of the rules
+const VariablePnL = "pnl"
+
+// BuyAndHold invests all the cash on the first day and keeps the shares, it is the usual benchmark
+type BuyAndHold struct {
+	bought bool
+}
+
+// OnBar buys on the first bar only
+func (strategy *BuyAndHold) OnBar(bar es.StockBar, account Account) []Order {
+	if strategy.bought {
+		return nil
+	}
+	strategy.bought = true
+	return []Order{Target(1)}
+}
+
+// Rules enters a position when its entry condition holds and leaves it when its exit condition holds
+//
+// Conditions are screening expressions, as in sma(50) > sma(200), which can also use pnl to stop a
+// loss or to take a profit. The position is the weight fraction of the equity.
+type Rules struct {
+	entry      *screener.Expression
+	exit       *screener.Expression
+	weight     float64
+	entryTerms *screener.Stream
+	exitTerms  *screener.Stream
+}
+
+// NewRules creates a strategy from an entry and an exit condition, without exit the position is held to the end
+//
+// 	NewRules("rsi(14) < 30", "rsi(14) > 70 or pnl < -10", 1)
+//
+// returns the strategy or the error of a bad condition
+func NewRules(entry string, exit string, weight float64) (*Rules, error) {
+	if !(weight > 0 && weight <= 1) {
+		return nil, errors.New("weight must be between 0 and 1")
+	}
+	strategy := &Rules{weight: weight}
+	var err error
+	if strategy.entry, err = screener.ParseWithVariables(entry, VariablePnL); err != nil {
+		return nil, err
+	}
+	if strategy.entryTerms, err = strategy.entry.Stream(); err != nil {
+		return nil, err
+	}
+	if exit == "" {
+		return strategy, nil
+	}
+	if strategy.exit, err = screener.ParseWithVariables(exit, VariablePnL); err != nil {
+		return nil, err
+	}
+	if strategy.exitTerms, err = strategy.exit.Stream(); err != nil {
+		return nil, err
+	}
+	return strategy, nil
+}
+
+// WarmUp returns the number of bars needed to compute all the indicators of the conditions
+func (strategy *Rules) WarmUp() int {
+	warmUp := strategy.entry.WarmUp()
+	if strategy.exit != nil && strategy.exit.WarmUp() > warmUp {
+		warmUp = strategy.exit.WarmUp()
+	}
+	return warmUp
+}
+
+// OnBar checks the entry condition without shares and the exit condition with shares
+func (strategy *Rules) OnBar(bar es.StockBar, account Account) []Order {
+	values := map[string]float64{VariablePnL: math.NaN()}
+	if account.Shares > 0 && account.CostBasis > 0 {
+		values[VariablePnL] = 100 * (account.Shares*bar.Close - account.CostBasis) / account.CostBasis
+	}
+	strategy.entryTerms.Update(bar, values)
+	if strategy.exitTerms != nil {
+		strategy.exitTerms.Update(bar, values)
+	}
+	switch {
+	case account.Shares == 0 && strategy.entry.Match(values):
+		return []Order{Target(strategy.weight)}
+	case account.Shares > 0 && strategy.exit != nil && strategy.exit.Match(values):
+		return []Order{Target(0)}
+	}
+	return nil
+}
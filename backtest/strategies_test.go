@@ -0,0 +1,81 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuyAndHold(t *testing.T) {
+	result, err := Run(testBars(100, 101, 102), &BuyAndHold{}, Config{Cash: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(result.Trades))
+	assert.Equal(t, 9.0, result.Trades[0].Quantity)
+}
+
+func TestRules(t *testing.T) {
+	strategy, err := NewRules("close > 100", "close < 95 or pnl > 15", 1)
+	assert.Nil(t, err)
+	result, err := Run(testBars(90, 101, 105, 110, 94, 96, 102, 120, 118), strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	expected := []Trade{
+		{Date: testDate.AddDate(0, 0, 2), Side: SideBuy, Quantity: 9, Price: 105},
+		{Date: testDate.AddDate(0, 0, 5), Side: SideSell, Quantity: 9, Price: 96, Profit: -81},
+		{Date: testDate.AddDate(0, 0, 7), Side: SideBuy, Quantity: 7, Price: 120},
+	}
+	assert.Equal(t, expected, result.Trades)
+	assert.Equal(t, 905.0, result.Statistics.FinalEquity)
+}
+
+func TestRulesPnL(t *testing.T) {
+	strategy, err := NewRules("close > 100", "pnl > 4", 0.5)
+	assert.Nil(t, err)
+	result, err := Run(testBars(90, 101, 105, 110, 94), strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result.Trades))
+	assert.Equal(t, 4.0, result.Trades[0].Quantity)
+	assert.Equal(t, SideSell, result.Trades[1].Side)
+	assert.Equal(t, 94.0, result.Trades[1].Price)
+}
+
+func TestRulesWithoutExit(t *testing.T) {
+	strategy, err := NewRules("sma(2) > 100", "", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strategy.WarmUp())
+	result, err := Run(testBars(99, 101, 102, 50, 40), strategy, Config{Cash: 1000})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(result.Trades))
+	// sma(2) is above 100 on the third bar, the shares are bought at the next open
+	assert.Equal(t, 50.0, result.Trades[0].Price)
+	assert.Equal(t, 20.0, result.Trades[0].Quantity)
+}
+
+func TestNewRulesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		entry  string
+		exit   string
+		weight float64
+		msg    string
+	}{
+		{"close > 1", "", 0, "weight must be between 0 and 1"},
+		{"close > 1", "", 1.5, "weight must be between 0 and 1"},
+		{"close >", "", 1, "unexpected end of expression"},
+		{"close > 1", "foo(2) > 1", 1, "unknown indicator: foo"},
+	} {
+		_, err := NewRules(tt.entry, tt.exit, tt.weight)
+		assert.EqualError(t, err, tt.msg)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"errors"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// ErrNoWindow is returned when the bars are too few for a single walk-forward window
+var ErrNoWindow = errors.New("not enough bars for a walk-forward window")
+
+// Split is the number of bars of the in-sample and out-of-sample periods of a walk-forward window
+type Split struct {
+	InSample    int `json:"in_sample" validate:"gt=0"`
+	OutOfSample int `json:"out_of_sample" validate:"gt=0"`
+}
+
+// Window is a walk-forward window, the best parameters of the in-sample period tested on the
+// out-of-sample period which follows
+type Window struct {
+	InStart     time.Time  `json:"in_start"`
+	InEnd       time.Time  `json:"in_end"`
+	OutStart    time.Time  `json:"out_start"`
+	OutEnd      time.Time  `json:"out_end"`
+	Parameters  Parameters `json:"parameters"`
+	InScore     float64    `json:"in_score"`
+	OutScore    float64    `json:"out_score"`
+	InSample    Statistics `json:"in_sample"`
+	OutOfSample Statistics `json:"out_of_sample"`
+}
+
+// WalkForwardResult contains the windows of a walk-forward analysis and their average scores
+//
+// Efficiency is the average out-of-sample score over the average in-sample one, a value far below 1
+// is the sign of parameters fitted to the noise of the in-sample periods.
+type WalkForwardResult struct {
+	Windows    []Window `json:"windows"`
+	InScore    float64  `json:"in_score"`
+	OutScore   float64  `json:"out_score"`
+	Efficiency float64  `json:"efficiency"`
+}
+
+// WalkForward optimizes a strategy on rolling in-sample periods and tests each best parameter set on
+// the out-of-sample period which follows
+//
+// 	WalkForward(bars, start, sets, factory, config, "sharpe", Split{InSample: 250, OutOfSample: 60}, 0)
+//
+// The windows start on the start day and move by the out-of-sample length, the bars before the
+// start and before each period warm the strategies up.
+// returns the windows, the last bars which do not fill an out-of-sample period are left out
+func WalkForward(bars []es.StockBar, start time.Time, sets []Parameters, factory StrategyFactory,
+	config Config, metric string, split Split, workers int) (*WalkForwardResult, error) {
+	if split.InSample <= 0 || split.OutOfSample <= 0 {
+		return nil, errors.New("in-sample and out-of-sample lengths must be positive")
+	}
+	first := 0
+	for first < len(bars) && bars[first].Date.Before(start) {
+		first++
+	}
+	result := &WalkForwardResult{Windows: []Window{}}
+	var inTotal, outTotal float64
+	for inStart := first; inStart+split.InSample+split.OutOfSample <= len(bars); inStart += split.OutOfSample {
+		outStart := inStart + split.InSample
+		outEnd := outStart + split.OutOfSample
+		trials, err := Sweep(bars[:outStart], bars[inStart].Date, sets, factory, config, metric, workers)
+		if err != nil {
+			return nil, err
+		}
+		if len(trials) == 0 || trials[0].Error != "" {
+			return nil, errors.New("no parameter set could run on " + bars[inStart].Date.Format("2006-01-02"))
+		}
+		best := trials[0]
+		out := runTrial(bars[:outEnd], bars[outStart].Date, best.Parameters, factory, config, metric)
+		if out.Error != "" {
+			return nil, errors.New(out.Error)
+		}
+		result.Windows = append(result.Windows, Window{
+			InStart:     bars[inStart].Date,
+			InEnd:       bars[outStart-1].Date,
+			OutStart:    bars[outStart].Date,
+			OutEnd:      bars[outEnd-1].Date,
+			Parameters:  best.Parameters,
+			InScore:     best.Score,
+			OutScore:    out.Score,
+			InSample:    best.Statistics,
+			OutOfSample: out.Statistics,
+		})
+		inTotal += best.Score
+		outTotal += out.Score
+	}
+	if len(result.Windows) == 0 {
+		return nil, ErrNoWindow
+	}
+	count := float64(len(result.Windows))
+	result.InScore, result.OutScore = inTotal/count, outTotal/count
+	if result.InScore > 0 {
+		result.Efficiency = result.OutScore / result.InScore
+	}
+	return result, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package backtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkForward(t *testing.T) {
+	bars := testBars(100, 101, 102, 103, 104, 105, 106, 107, 108, 109, 110, 111)
+	sets := []Parameters{{"level": 100}, {"level": 1000}}
+	factory := RulesFactory("close > {level}", "", 1)
+	config := Config{Cash: 1000, Fractional: true}
+	result, err := WalkForward(bars, bars[0].Date, sets, factory, config, MetricTotalReturn, Split{InSample: 4, OutOfSample: 2}, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(result.Windows))
+	window := result.Windows[0]
+	assert.Equal(t, bars[0].Date, window.InStart)
+	assert.Equal(t, bars[3].Date, window.InEnd)
+	assert.Equal(t, bars[4].Date, window.OutStart)
+	assert.Equal(t, bars[5].Date, window.OutEnd)
+	assert.Equal(t, Parameters{"level": 100}, window.Parameters)
+	assert.Equal(t, bars[2].Date, result.Windows[1].InStart)
+	assert.Equal(t, bars[11].Date, result.Windows[3].OutEnd)
+	var in, out float64
+	for _, window := range result.Windows {
+		assert.True(t, window.InScore > 0)
+		in += window.InScore
+		out += window.OutScore
+	}
+	assert.InDelta(t, in/4, result.InScore, 1e-9)
+	assert.InDelta(t, out/4, result.OutScore, 1e-9)
+	assert.InDelta(t, result.OutScore/result.InScore, result.Efficiency, 1e-9)
+}
+
+func TestWalkForwardErrors(t *testing.T) {
+	bars := testBars(100, 101, 102)
+	factory := RulesFactory("close > {level}", "", 1)
+	sets := []Parameters{{"level": 100}}
+	_, err := WalkForward(bars, bars[0].Date, sets, factory, Config{Cash: 1000}, MetricSharpe, Split{InSample: 2, OutOfSample: 2}, 0)
+	assert.Equal(t, ErrNoWindow, err)
+	_, err = WalkForward(bars, bars[0].Date, sets, factory, Config{Cash: 1000}, MetricSharpe, Split{InSample: 2}, 0)
+	assert.EqualError(t, err, "in-sample and out-of-sample lengths must be positive")
+	_, err = WalkForward(bars, bars[0].Date, []Parameters{{}}, factory, Config{Cash: 1000}, MetricSharpe, Split{InSample: 1, OutOfSample: 1}, 0)
+	assert.EqualError(t, err, "no parameter set could run on "+bars[0].Date.Format("2006-01-02"))
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	finance "github.com/clebi/yfinance"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	alertRuleIndexName  = "alert-rules"
+	alertRuleIndexType  = "alert_rule"
+	alertEventIndexName = "alert-events"
+	alertEventIndexType = "alert_event"
+	maxAlertRules       = 1000
+	maxAlertEvents      = 10000
+)
+
+// AlertWebhook is the address an alert is posted to, the body is signed with the secret when there is one
+type AlertWebhook struct {
+	URL    string `json:"url" validate:"required,url"`
+	Secret string `json:"secret,omitempty"`
+}
+
+// AlertRule is a condition on a symbol which notifies a user when it becomes true
+//
+// Triggered is the result of the last evaluation, made on the bar of LastDate: the rule fires again
+// only once its condition has been false.
+type AlertRule struct {
+	Username   string        `json:"username"`
+	Name       string        `json:"name" validate:"required"`
+	Symbol     string        `json:"symbol" validate:"required"`
+	Condition  string        `json:"condition" validate:"required"`
+	Webhook    *AlertWebhook `json:"webhook,omitempty"`
+	Email      []string      `json:"email,omitempty" validate:"omitempty,dive,email"`
+	MutedUntil *time.Time    `json:"muted_until,omitempty"`
+	Triggered  bool          `json:"triggered"`
+	LastDate   *time.Time    `json:"last_date,omitempty"`
+}
+
+// ID returns the storage identifier of a rule, a user has only one rule with a name
+func (rule AlertRule) ID() string {
+	return fmt.Sprintf("%s_%s", rule.Username, rule.Name)
+}
+
+// Muted tells whether the notifications of the rule are muted at a time
+func (rule AlertRule) Muted(now time.Time) bool {
+	return rule.MutedUntil != nil && now.Before(*rule.MutedUntil)
+}
+
+// AlertEvent is the firing of an alert rule on the bar of a day
+//
+// Values are the prices and indicators of the condition, Errors the failures of the notification channels.
+type AlertEvent struct {
+	Username  string             `json:"username"`
+	Name      string             `json:"name"`
+	Symbol    string             `json:"symbol"`
+	Condition string             `json:"condition"`
+	Date      time.Time          `json:"date"`
+	Fired     time.Time          `json:"fired"`
+	Values    map[string]float64 `json:"values"`
+	Muted     bool               `json:"muted"`
+	Errors    []string           `json:"errors,omitempty"`
+}
+
+// ID returns the storage identifier of an event, a rule fires at most once on a bar
+func (event AlertEvent) ID() string {
+	return fmt.Sprintf("%s_%s_%s", event.Username, event.Name, event.Date.Format(finance.DateFormat))
+}
+
+// IAlertStock contains all es alert actions
+type IAlertStock interface {
+	SetAlertRule(rule *AlertRule) error
+	GetAlertRule(username string, name string) (*AlertRule, error)
+	GetAlertRules(username string) ([]AlertRule, error)
+	GetSymbolAlertRules(symbol string) ([]AlertRule, error)
+	DeleteAlertRule(username string, name string) error
+	AddAlertEvent(event *AlertEvent) error
+	GetAlertEvents(username string, name string, startDate time.Time, endDate time.Time) ([]AlertEvent, error)
+}
+
+// AlertStock manage alert rules and their history in elasticsearch
+type AlertStock struct {
+	es *elastic.Client
+}
+
+// NewAlert create a new elasticsearch alerts manager
+func NewAlert(es *elastic.Client) IAlertStock {
+	return &AlertStock{
+		es: es,
+	}
+}
+
+// SetAlertRule creates or replaces a rule of a user
+//
+//  SetAlertRule(rule)
+func (alertStock *AlertStock) SetAlertRule(rule *AlertRule) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := alertStock.es.Index().
+		Index(alertRuleIndexName).
+		Type(alertRuleIndexType).
+		Id(rule.ID()).
+		BodyJson(rule).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetAlertRule gets a rule of a user by its name
+//
+//  GetAlertRule(username, name)
+//
+// return the rule, nil if the user has no rule with this name
+func (alertStock *AlertStock) GetAlertRule(username string, name string) (*AlertRule, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	result, err := alertStock.es.Get().
+		Index(alertRuleIndexName).
+		Type(alertRuleIndexType).
+		Id(AlertRule{Username: username, Name: name}.ID()).
+		Do(esContext)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	var rule AlertRule
+	err = json.Unmarshal(*result.Source, &rule)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (alertStock *AlertStock) searchRules(query string) ([]AlertRule, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	results, err := alertStock.es.Search(alertRuleIndexName).
+		Type(alertRuleIndexType).
+		Query(elastic.NewQueryStringQuery(query)).
+		Size(maxAlertRules).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	rules := make([]AlertRule, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &rules[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// GetAlertRules gets all the rules of a user
+//
+//  GetAlertRules(username)
+//
+// return the list of rules
+func (alertStock *AlertStock) GetAlertRules(username string) ([]AlertRule, error) {
+	return alertStock.searchRules(fmt.Sprintf("username = %s", username))
+}
+
+// GetSymbolAlertRules gets the rules of all users on a symbol
+//
+//  GetSymbolAlertRules("CW8.PA")
+//
+// return the list of rules
+func (alertStock *AlertStock) GetSymbolAlertRules(symbol string) ([]AlertRule, error) {
+	return alertStock.searchRules(fmt.Sprintf("symbol = %s", symbol))
+}
+
+// DeleteAlertRule deletes a rule of a user, deleting a missing rule is not an error
+//
+//  DeleteAlertRule(username, name)
+func (alertStock *AlertStock) DeleteAlertRule(username string, name string) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := alertStock.es.Delete().
+		Index(alertRuleIndexName).
+		Type(alertRuleIndexType).
+		Id(AlertRule{Username: username, Name: name}.ID()).
+		Do(esContext)
+	if err != nil && !elastic.IsNotFound(err) {
+		return storeError(err)
+	}
+	return nil
+}
+
+// AddAlertEvent adds an event into the history of the alerts
+//
+//  AddAlertEvent(event)
+func (alertStock *AlertStock) AddAlertEvent(event *AlertEvent) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := alertStock.es.Index().
+		Index(alertEventIndexName).
+		Type(alertEventIndexType).
+		Id(event.ID()).
+		BodyJson(event).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetAlertEvents gets the history of the alerts of a user between two dates, of all rules when name is empty
+//
+//  GetAlertEvents(username, "cw8-low", startDate, endDate)
+//
+// return the list of events sorted by date
+func (alertStock *AlertStock) GetAlertEvents(username string, name string, startDate time.Time, endDate time.Time) ([]AlertEvent, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	queryString := fmt.Sprintf("username = %s AND date: [%s TO %s]",
+		username, startDate.Format(finance.DateFormat), endDate.Format(finance.DateFormat))
+	if name != "" {
+		queryString += fmt.Sprintf(" AND name = %s", name)
+	}
+	results, err := alertStock.es.Search(alertEventIndexName).
+		Type(alertEventIndexType).
+		Query(elastic.NewQueryStringQuery(queryString)).
+		Sort("date", true).
+		Size(maxAlertEvents).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	events := make([]AlertEvent, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &events[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
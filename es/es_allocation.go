@@ -0,0 +1,105 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"context"
+	"encoding/json"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	allocationIndexName = "stock-allocations"
+	allocationIndexType = "stock_allocation"
+)
+
+// AllocationTarget is the weight wanted for a single symbol or for an asset class made of several symbols
+//
+// When buying an asset class, the first symbol of the list is used.
+type AllocationTarget struct {
+	Name    string   `json:"name" validate:"required"`
+	Symbols []string `json:"symbols" validate:"required,min=1"`
+	Weight  float64  `json:"weight" validate:"gte=0,lte=1"`
+}
+
+// Allocation contains the target weights of a user
+type Allocation struct {
+	Username string             `json:"username"`
+	Targets  []AllocationTarget `json:"targets" validate:"required,dive"`
+}
+
+// IAllocationStock contains all es allocation actions
+type IAllocationStock interface {
+	SetAllocation(allocation *Allocation) error
+	GetAllocation(username string) (*Allocation, error)
+}
+
+// AllocationStock manage target allocations in elasticsearch
+type AllocationStock struct {
+	es *elastic.Client
+}
+
+// NewAllocation create a new elasticsearch allocations manager
+func NewAllocation(es *elastic.Client) IAllocationStock {
+	return &AllocationStock{
+		es: es,
+	}
+}
+
+// SetAllocation replaces the target allocation of a user
+//
+//  SetAllocation(allocation)
+func (allocStock *AllocationStock) SetAllocation(allocation *Allocation) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := allocStock.es.Index().
+		Index(allocationIndexName).
+		Type(allocationIndexType).
+		Id(allocation.Username).
+		BodyJson(allocation).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetAllocation gets the target allocation of a user
+//
+//  GetAllocation(username)
+//
+// return the allocation, without targets if the user has not defined one
+func (allocStock *AllocationStock) GetAllocation(username string) (*Allocation, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	result, err := allocStock.es.Get().
+		Index(allocationIndexName).
+		Type(allocationIndexType).
+		Id(username).
+		Do(esContext)
+	if elastic.IsNotFound(err) {
+		return &Allocation{Username: username, Targets: []AllocationTarget{}}, nil
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	var allocation Allocation
+	err = json.Unmarshal(*result.Source, &allocation)
+	if err != nil {
+		return nil, err
+	}
+	return &allocation, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"context"
+	"fmt"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+// StorageTimeoutError is returned when elasticsearch does not answer a request in time
+type StorageTimeoutError struct {
+	Err error
+}
+
+func (err *StorageTimeoutError) Error() string {
+	return fmt.Sprintf("storage timeout: %s", err.Err)
+}
+
+// StorageUnavailableError is returned when elasticsearch cannot be reached
+type StorageUnavailableError struct {
+	Err error
+}
+
+func (err *StorageUnavailableError) Error() string {
+	return fmt.Sprintf("storage unavailable: %s", err.Err)
+}
+
+// storeError types the error of a request to elasticsearch, the others errors are returned unchanged
+func storeError(err error) error {
+	switch {
+	case err == context.DeadlineExceeded || elastic.IsTimeout(err):
+		return &StorageTimeoutError{Err: err}
+	case elastic.IsConnErr(err):
+		return &StorageUnavailableError{Err: err}
+	}
+	return err
+}
+
+// truncatedError is returned by the reads whose hits do not fit in the size of their search, part of the
+// hits would be missing from the results
+func truncatedError(kind string, total int64, size int) error {
+	return fmt.Errorf("too many %s to read: %d for at most %d", kind, total, size)
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	fxIndexName       = "fx-rates"
+	fxIndexType       = "fx_rate"
+	currencyIndexName = "symbol-currencies"
+	currencyIndexType = "symbol_currency"
+	fxDateFormat      = "2006-01-02"
+	maxRates          = 10000
+)
+
+// FxRate is the value of one unit of the From currency in the To currency on a day
+type FxRate struct {
+	From string    `json:"from" validate:"required,len=3"`
+	To   string    `json:"to" validate:"required,len=3"`
+	Date time.Time `json:"date"`
+	Rate float64   `json:"rate" validate:"gt=0"`
+}
+
+// SymbolCurrency is the currency in which a symbol is quoted
+type SymbolCurrency struct {
+	Symbol   string `json:"symbol" validate:"required"`
+	Currency string `json:"currency" validate:"required,len=3"`
+}
+
+// IFxStock contains all es currency actions
+type IFxStock interface {
+	AddRate(rate *FxRate) error
+	GetRates(currencies []string, startDate time.Time, endDate time.Time) ([]FxRate, error)
+	SetSymbolCurrency(currency *SymbolCurrency) error
+	GetSymbolCurrencies(symbols []string) (map[string]string, error)
+}
+
+// FxStock manage exchange rates and symbol currencies in elasticsearch
+type FxStock struct {
+	es *elastic.Client
+}
+
+// NewFx create a new elasticsearch exchange rates manager
+func NewFx(es *elastic.Client) IFxStock {
+	return &FxStock{
+		es: es,
+	}
+}
+
+// AddRate creates or replaces the rate of a currency pair on a day
+//
+//  AddRate(rate)
+func (fxStock *FxStock) AddRate(rate *FxRate) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := fxStock.es.Index().
+		Index(fxIndexName).
+		Type(fxIndexType).
+		Id(fmt.Sprintf("%s_%s_%s", rate.From, rate.To, rate.Date.Format(fxDateFormat))).
+		BodyJson(rate).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetRates gets the rates between a set of currencies
+//
+//  GetRates([]string{"EUR", "USD"}, startDate, endDate)
+//
+// return the rates whose both currencies are in the set, sorted by date, or an error when there are too many
+func (fxStock *FxStock) GetRates(currencies []string, startDate time.Time, endDate time.Time) ([]FxRate, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	set := strings.Join(currencies, " OR ")
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("from: (%s) AND to: (%s) AND date: [%s TO %s]",
+		set, set, startDate.Format(fxDateFormat), endDate.Format(fxDateFormat)))
+	results, err := fxStock.es.Search(fxIndexName).
+		Type(fxIndexType).
+		Query(query).
+		Sort("date", false).
+		Size(maxRates).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if results.TotalHits() > maxRates {
+		return nil, truncatedError("rates", results.TotalHits(), maxRates)
+	}
+	// the newest rates come first, the last ones of the slice
+	rates := make([]FxRate, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &rates[len(rates)-1-i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rates, nil
+}
+
+// SetSymbolCurrency creates or replaces the currency of a symbol
+//
+//  SetSymbolCurrency(currency)
+func (fxStock *FxStock) SetSymbolCurrency(currency *SymbolCurrency) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := fxStock.es.Index().
+		Index(currencyIndexName).
+		Type(currencyIndexType).
+		Id(currency.Symbol).
+		BodyJson(currency).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetSymbolCurrencies gets the currencies set for a list of symbols
+//
+//  GetSymbolCurrencies([]string{"CW8.PA", "AAPL"})
+//
+// return the currencies by symbol, symbols without currency are left out
+func (fxStock *FxStock) GetSymbolCurrencies(symbols []string) (map[string]string, error) {
+	currencies := map[string]string{}
+	if len(symbols) == 0 {
+		return currencies, nil
+	}
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	ids := make([]string, len(symbols))
+	copy(ids, symbols)
+	results, err := fxStock.es.Search(currencyIndexName).
+		Type(currencyIndexType).
+		Query(elastic.NewIdsQuery(currencyIndexType).Ids(ids...)).
+		Size(len(ids)).
+		Do(esContext)
+	if elastic.IsNotFound(err) {
+		return currencies, nil
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	for _, hit := range results.Hits.Hits {
+		var currency SymbolCurrency
+		if err = json.Unmarshal(*hit.Source, &currency); err != nil {
+			return nil, err
+		}
+		currencies[currency.Symbol] = currency.Currency
+	}
+	return currencies, nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	profileIndexName = "import-profiles"
+	profileIndexType = "import_profile"
+	maxProfiles      = 1000
+)
+
+// ImportProfile describes how to read the CSV export of a broker
+//
+// DateFormat is a go time layout. Without ActionColumn, the sign of the quantity tells buys from sells.
+// Without CurrencyColumn, trades are in the currency of their symbol.
+type ImportProfile struct {
+	Username           string   `json:"username"`
+	Name               string   `json:"name" validate:"required"`
+	Delimiter          string   `json:"delimiter" validate:"max=1"`
+	SkipLines          int      `json:"skip_lines" validate:"gte=0"`
+	DateColumn         string   `json:"date_column" validate:"required"`
+	DateFormat         string   `json:"date_format" validate:"required"`
+	SymbolColumn       string   `json:"symbol_column" validate:"required"`
+	QuantityColumn     string   `json:"quantity_column" validate:"required"`
+	PriceColumn        string   `json:"price_column" validate:"required"`
+	AmountColumn       string   `json:"amount_column"`
+	FeeColumns         []string `json:"fee_columns"`
+	ActionColumn       string   `json:"action_column"`
+	CurrencyColumn     string   `json:"currency_column"`
+	BuyMarkers         []string `json:"buy_markers"`
+	SellMarkers        []string `json:"sell_markers"`
+	DecimalSeparator   string   `json:"decimal_separator" validate:"max=1"`
+	ThousandsSeparator string   `json:"thousands_separator" validate:"max=1"`
+}
+
+// IImportProfileStock contains all es import profile actions
+type IImportProfileStock interface {
+	SetProfile(profile *ImportProfile) error
+	GetProfile(username string, name string) (*ImportProfile, error)
+	GetProfiles(username string) ([]ImportProfile, error)
+}
+
+// ImportProfileStock manage import profiles in elasticsearch
+type ImportProfileStock struct {
+	es *elastic.Client
+}
+
+// NewImportProfile create a new elasticsearch import profiles manager
+func NewImportProfile(es *elastic.Client) IImportProfileStock {
+	return &ImportProfileStock{
+		es: es,
+	}
+}
+
+func profileID(username string, name string) string {
+	return fmt.Sprintf("%s_%s", username, name)
+}
+
+// SetProfile creates or replaces an import profile
+//
+//  SetProfile(profile)
+func (profileStock *ImportProfileStock) SetProfile(profile *ImportProfile) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := profileStock.es.Index().
+		Index(profileIndexName).
+		Type(profileIndexType).
+		Id(profileID(profile.Username, profile.Name)).
+		BodyJson(profile).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetProfile gets an import profile by its name
+//
+//  GetProfile(username, name)
+//
+// return the profile or nil when it does not exist
+func (profileStock *ImportProfileStock) GetProfile(username string, name string) (*ImportProfile, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	result, err := profileStock.es.Get().
+		Index(profileIndexName).
+		Type(profileIndexType).
+		Id(profileID(username, name)).
+		Do(esContext)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	var profile ImportProfile
+	err = json.Unmarshal(*result.Source, &profile)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetProfiles gets all the import profiles of a user
+//
+//  GetProfiles(username)
+//
+// return the list of profiles
+func (profileStock *ImportProfileStock) GetProfiles(username string) ([]ImportProfile, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("username = %s", username))
+	results, err := profileStock.es.Search(profileIndexName).
+		Type(profileIndexType).
+		Query(query).
+		Size(maxProfiles).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	profiles := make([]ImportProfile, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &profiles[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	optimizationIndexName = "optimizations"
+	optimizationIndexType = "optimization"
+	maxOptimizations      = 1000
+)
+
+// Optimization is a stored parameter sweep of a strategy, kept to compare runs later
+//
+// Parameters and Score are the ones of the best trial, Efficiency the walk-forward efficiency when
+// there was a walk-forward analysis. Request and Result are the parameters and the answer of the
+// optimize route.
+type Optimization struct {
+	Username   string             `json:"username"`
+	Name       string             `json:"name"`
+	Symbol     string             `json:"symbol"`
+	Created    time.Time          `json:"created"`
+	Metric     string             `json:"metric"`
+	Parameters map[string]float64 `json:"parameters"`
+	Score      float64            `json:"score"`
+	Efficiency *float64           `json:"efficiency,omitempty"`
+	Request    json.RawMessage    `json:"request"`
+	Result     json.RawMessage    `json:"result"`
+}
+
+// ID returns the storage identifier of an optimization, a user has only one optimization with a name
+func (optimization Optimization) ID() string {
+	return fmt.Sprintf("%s_%s", optimization.Username, optimization.Name)
+}
+
+// IOptimizationStock contains all es optimization actions
+type IOptimizationStock interface {
+	SetOptimization(optimization *Optimization) error
+	GetOptimization(username string, name string) (*Optimization, error)
+	GetOptimizations(username string) ([]Optimization, error)
+	DeleteOptimization(username string, name string) error
+}
+
+// OptimizationStock manage strategy optimizations in elasticsearch
+type OptimizationStock struct {
+	es *elastic.Client
+}
+
+// NewOptimization create a new elasticsearch optimizations manager
+func NewOptimization(es *elastic.Client) IOptimizationStock {
+	return &OptimizationStock{
+		es: es,
+	}
+}
+
+// SetOptimization creates or replaces an optimization of a user
+//
+//  SetOptimization(optimization)
+func (optimizationStock *OptimizationStock) SetOptimization(optimization *Optimization) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := optimizationStock.es.Index().
+		Index(optimizationIndexName).
+		Type(optimizationIndexType).
+		Id(optimization.ID()).
+		BodyJson(optimization).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetOptimization gets an optimization of a user by its name
+//
+//  GetOptimization(username, name)
+//
+// return the optimization, nil if the user has no optimization with this name
+func (optimizationStock *OptimizationStock) GetOptimization(username string, name string) (*Optimization, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	result, err := optimizationStock.es.Get().
+		Index(optimizationIndexName).
+		Type(optimizationIndexType).
+		Id(Optimization{Username: username, Name: name}.ID()).
+		Do(esContext)
+	if elastic.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, storeError(err)
+	}
+	var optimization Optimization
+	err = json.Unmarshal(*result.Source, &optimization)
+	if err != nil {
+		return nil, err
+	}
+	return &optimization, nil
+}
+
+// GetOptimizations gets all the optimizations of a user
+//
+//  GetOptimizations(username)
+//
+// return the list of optimizations sorted by creation date
+func (optimizationStock *OptimizationStock) GetOptimizations(username string) ([]Optimization, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	results, err := optimizationStock.es.Search(optimizationIndexName).
+		Type(optimizationIndexType).
+		Query(elastic.NewQueryStringQuery(fmt.Sprintf("username = %s", username))).
+		Sort("created", true).
+		Size(maxOptimizations).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	optimizations := make([]Optimization, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &optimizations[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return optimizations, nil
+}
+
+// DeleteOptimization deletes an optimization of a user, deleting a missing optimization is not an error
+//
+//  DeleteOptimization(username, name)
+func (optimizationStock *OptimizationStock) DeleteOptimization(username string, name string) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := optimizationStock.es.Delete().
+		Index(optimizationIndexName).
+		Type(optimizationIndexType).
+		Id(Optimization{Username: username, Name: name}.ID()).
+		Do(esContext)
+	if err != nil && !elastic.IsNotFound(err) {
+		return storeError(err)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ package es
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -27,17 +28,33 @@ const (
 	symbolsAggName = "symbols"
 	numberAggName  = "number"
 	costAggName    = "cost"
+	maxTrades      = 10000
+)
+
+// Kinds of positions which are not trades, trades have no kind
+const (
+	KindDividend = "dividend"
+	KindFee      = "fee"
+	KindTransfer = "transfer"
 )
 
 // Position contains all values representing a stock position
+//
+// Number is positive for a buy and negative for a sell, Value is the unit price and Cost is the
+// cash amount of the trade including fees: positive when paid, negative when received.
+// Dividends, fees and cash transfers have a Kind, no Number and only a Cost.
+// Value and Cost are in Currency, the currency of the symbol when it is empty.
 type Position struct {
-	Username string    `json:"username" validate:"required"`
-	Broker   string    `json:"broker" validate:"required"`
-	Symbol   string    `json:"symbol" validate:"required"`
-	Date     time.Time `json:"date,string" validate:"required"`
-	Number   int       `json:"number,int" validate:"required"`
-	Value    float64   `json:"value,float" validate:"gt=0"`
-	Cost     float64   `json:"cost,float" validate:"required"`
+	Username  string    `json:"username" validate:"required"`
+	Broker    string    `json:"broker" validate:"required"`
+	Symbol    string    `json:"symbol" validate:"required"`
+	Date      time.Time `json:"date,string" validate:"required"`
+	Number    int       `json:"number,int" validate:"required"`
+	Value     float64   `json:"value,float" validate:"gt=0"`
+	Cost      float64   `json:"cost,float" validate:"required"`
+	Kind      string    `json:"kind,omitempty"`
+	Reference string    `json:"reference,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
 }
 
 func (position Position) String() string {
@@ -45,6 +62,22 @@ func (position Position) String() string {
 		position.Username, position.Broker, position.Symbol, position.Date, position.Number, position.Value, position.Cost)
 }
 
+// ID returns the storage identifier of a position, two trades with the same identifier are duplicates
+//
+// The reference given by the broker is used when there is one. Without it, the quantity, the price and the cost
+// tell apart the fills of a symbol on the same day, only the same trade imported twice gets the same identifier.
+func (position Position) ID() string {
+	if position.Reference != "" {
+		return fmt.Sprintf("%s_%s", position.Broker, position.Reference)
+	}
+	id := fmt.Sprintf("%s_%s_%s_%d_%g_%g", position.Broker, position.Date.Format(time.RFC3339), position.Symbol,
+		position.Number, position.Value, position.Cost)
+	if position.Kind != "" {
+		return id + "_" + position.Kind
+	}
+	return id
+}
+
 // PositionAgg contains the list of positions aggregation by symbol
 type PositionAgg struct {
 	Symbol string
@@ -60,6 +93,7 @@ func (position PositionAgg) String() string {
 type IPositionStock interface {
 	AddPosition(position *Position) error
 	GetPositions(username string) ([]PositionAgg, error)
+	GetTrades(username string) ([]Position, error)
 }
 
 // PositionStock manage positons in elasticsearch
@@ -91,14 +125,23 @@ func (posStock *PositionStock) AddPosition(position *Position) error {
 		"value":    position.Value,
 		"cost":     position.Cost,
 	}
+	if position.Kind != "" {
+		positionMap["kind"] = position.Kind
+	}
+	if position.Reference != "" {
+		positionMap["reference"] = position.Reference
+	}
+	if position.Currency != "" {
+		positionMap["currency"] = position.Currency
+	}
 	_, err := posStock.es.Index().
 		Index("stock-positions").
 		Type("stock_position").
-		Id(fmt.Sprintf("%s_%s_%s", position.Broker, position.Date.Format(time.RFC3339), position.Symbol)).
+		Id(position.ID()).
 		BodyJson(positionMap).
 		Do(esContext)
 	if err != nil {
-		return err
+		return storeError(err)
 	}
 	return nil
 }
@@ -117,14 +160,14 @@ func (posStock *PositionStock) GetPositions(username string) ([]PositionAgg, err
 		Field("symbol.keyword").
 		SubAggregation(numberAggName, numberAgg).
 		SubAggregation(costAggName, costAgg)
-	query := elastic.NewQueryStringQuery(fmt.Sprintf("username = %s", username))
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("username = %s AND NOT _exists_:kind", username))
 	results, err := posStock.es.Search("stock-positions").
 		Type("stock_position").
 		Query(query).
 		Aggregation(symbolsAggName, symbolsAgg).
 		Do(esContext)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err)
 	}
 	resAgg, _ := results.Aggregations.Terms(symbolsAggName)
 	positions := make([]PositionAgg, len(resAgg.Buckets))
@@ -143,3 +186,34 @@ func (posStock *PositionStock) GetPositions(username string) ([]PositionAgg, err
 	}
 	return positions, nil
 }
+
+// GetTrades gets all the trades of a user
+//
+// GetTrades(username)
+//
+// return the list of trades sorted by date, or an error when there are too many
+func (posStock *PositionStock) GetTrades(username string) ([]Position, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("username = %s", username))
+	results, err := posStock.es.Search("stock-positions").
+		Type("stock_position").
+		Query(query).
+		Sort("date", true).
+		Size(maxTrades).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if results.TotalHits() > maxTrades {
+		return nil, truncatedError("trades", results.TotalHits(), maxTrades)
+	}
+	trades := make([]Position, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &trades[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trades, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	finance "github.com/clebi/yfinance"
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	signalIndexName = "stock-signals"
+	signalIndexType = "stock_signal"
+	maxSignals      = 10000
+)
+
+// Signal is an event detected on the history of a stock, such as a moving average crossover
+//
+// Date is the day of the bar the event happened on, Value the close of that day and Detected the time of the scan.
+type Signal struct {
+	Symbol   string    `json:"symbol"`
+	Date     time.Time `json:"date"`
+	Kind     string    `json:"kind"`
+	Value    float64   `json:"value"`
+	Detail   string    `json:"detail,omitempty"`
+	Detected time.Time `json:"detected"`
+}
+
+// ID returns the storage identifier of a signal, a scan of the same days replaces the signals
+func (signal Signal) ID() string {
+	return fmt.Sprintf("%s_%s_%s", signal.Symbol, signal.Date.Format(finance.DateFormat), signal.Kind)
+}
+
+// ISignalStock contains all es signal actions
+type ISignalStock interface {
+	AddSignal(signal *Signal) error
+	GetSignals(symbol string, kind string, startDate time.Time, endDate time.Time) ([]Signal, error)
+}
+
+// SignalStock manage signals in elasticsearch
+type SignalStock struct {
+	es *elastic.Client
+}
+
+// NewSignal create a new elasticsearch signals manager
+func NewSignal(es *elastic.Client) ISignalStock {
+	return &SignalStock{
+		es: es,
+	}
+}
+
+// AddSignal adds or replaces a signal into elasticsearch storage
+//
+//  AddSignal(signal)
+func (signalStock *SignalStock) AddSignal(signal *Signal) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := signalStock.es.Index().
+		Index(signalIndexName).
+		Type(signalIndexType).
+		Id(signal.ID()).
+		BodyJson(signal).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetSignals gets the signals of a symbol between two dates, of all kinds when kind is empty
+//
+//  GetSignals("CW8.PA", "golden_cross", startDate, endDate)
+//
+// return the list of signals sorted by date
+func (signalStock *SignalStock) GetSignals(symbol string, kind string, startDate time.Time, endDate time.Time) ([]Signal, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	queryString := fmt.Sprintf("symbol = %s AND date: [%s TO %s]",
+		symbol, startDate.Format(finance.DateFormat), endDate.Format(finance.DateFormat))
+	if kind != "" {
+		queryString += fmt.Sprintf(" AND kind = %s", kind)
+	}
+	results, err := signalStock.es.Search(signalIndexName).
+		Type(signalIndexType).
+		Query(elastic.NewQueryStringQuery(queryString)).
+		Sort("date", true).
+		Size(maxSignals).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	signals := make([]Signal, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &signals[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return signals, nil
+}
@@ -17,8 +17,10 @@ package es
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	finance "github.com/clebi/yfinance"
@@ -33,8 +35,13 @@ const (
 	avgCloseAggregationName = "avg_close"
 	movCloseAggregationName = "mov_close"
 	statsAggregationName    = "stats"
+	maxBars                 = 10000
+	maxSymbols              = 10000
 )
 
+// ErrNotEnoughPoints is returned when the history of a stock is shorter than the number of points asked
+var ErrNotEnoughPoints = errors.New("not enough points in the history")
+
 type stockValue struct {
 	Date string `json:"date"`
 }
@@ -46,20 +53,104 @@ type StocksStats struct {
 	Avg               float64
 }
 
+// Models of the moving averages of the stock aggregations
+const (
+	MovAvgSimple      = "simple"
+	MovAvgLinear      = "linear"
+	MovAvgEWMA        = "ewma"
+	MovAvgHolt        = "holt"
+	MovAvgHoltWinters = "holt_winters"
+)
+
+// MovAvg is a moving average of the closes of stock aggregations
+//
+// Window is in days and Period, the seasonality of the Holt-Winters model, in buckets. Alpha, Beta and
+// Gamma are the smoothing factors of the models which have them, elasticsearch chooses them when they
+// are 0. Name is the key of the moving average in the overlays of the aggregations, but for the first one.
+type MovAvg struct {
+	Name           string
+	Model          string
+	Window         int
+	Alpha          float64
+	Beta           float64
+	Gamma          float64
+	Period         int
+	Multiplicative bool
+}
+
+// aggregation creates the movavg aggregation of the average closes of buckets of step days
+func (movAvg MovAvg) aggregation(step int) *elastic.MovAvgAggregation {
+	agg := elastic.NewMovAvgAggregation().BucketsPath(avgCloseAggregationName).
+		Window(int(math.Ceil(float64(movAvg.Window) / float64(step))))
+	switch movAvg.Model {
+	case MovAvgLinear:
+		agg = agg.Model(elastic.NewLinearMovAvgModel())
+	case MovAvgEWMA:
+		model := elastic.NewEWMAMovAvgModel()
+		if movAvg.Alpha > 0 {
+			model = model.Alpha(movAvg.Alpha)
+		}
+		agg = agg.Model(model)
+	case MovAvgHolt:
+		model := elastic.NewHoltLinearMovAvgModel()
+		if movAvg.Alpha > 0 {
+			model = model.Alpha(movAvg.Alpha)
+		}
+		if movAvg.Beta > 0 {
+			model = model.Beta(movAvg.Beta)
+		}
+		agg = agg.Model(model)
+	case MovAvgHoltWinters:
+		model := elastic.NewHoltWintersMovAvgModel().Period(movAvg.Period)
+		if movAvg.Alpha > 0 {
+			model = model.Alpha(movAvg.Alpha)
+		}
+		if movAvg.Beta > 0 {
+			model = model.Beta(movAvg.Beta)
+		}
+		if movAvg.Gamma > 0 {
+			model = model.Gamma(movAvg.Gamma)
+		}
+		if movAvg.Multiplicative {
+			model = model.SeasonalityType("mult")
+		}
+		agg = agg.Model(model)
+	}
+	return agg
+}
+
 // StocksAgg is the a stock aggregation
+//
+// MovClose is the first moving average and Overlays the named ones after it.
 type StocksAgg struct {
-	Symbol   string  `json:"symbol"`
-	MsTime   int64   `json:"mstime"`
-	AvgClose float64 `json:"close"`
-	MovClose float64 `json:"mv_close"`
+	Symbol   string             `json:"symbol"`
+	MsTime   int64              `json:"mstime"`
+	AvgClose float64            `json:"close"`
+	MovClose float64            `json:"mv_close"`
+	Overlays map[string]float64 `json:"overlays,omitempty"`
+}
+
+// StockBar contains the values of a stock for one day
+type StockBar struct {
+	Symbol string    `json:"symbol"`
+	Date   time.Time `json:"date"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume float64   `json:"volume"`
 }
 
 // IStock contains elasticsearch manager actions
+//
+// The reads taking a context give up when it is done, at the latest after their own timeout.
 type IStock interface {
 	Index(stock finance.Stock) error
-	GetStocksAgg(symbol string, movAvgWindow int, step int, startDate time.Time, endDate time.Time) ([]StocksAgg, error)
-	GetStockStats(symbol string, startDate time.Time, endDate time.Time) (*StocksStats, error)
-	GetDateForNumPoint(symbol string, numPoints int, endDate time.Time) (*time.Time, error)
+	GetStocksAgg(ctx context.Context, symbol string, movAvgs []MovAvg, step int, startDate time.Time, endDate time.Time) ([]StocksAgg, error)
+	GetStockStats(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) (*StocksStats, error)
+	GetDateForNumPoint(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*time.Time, error)
+	GetBars(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) ([]StockBar, error)
+	GetSymbols() ([]string, error)
 }
 
 // Stock manage stocks in elasticsearch
@@ -94,25 +185,32 @@ func (esStock *Stock) Index(stock finance.Stock) error {
 		BodyJson(stockMap).
 		Do(esContext)
 	if err != nil {
-		return err
+		return storeError(err)
 	}
 	return nil
 }
 
-// GetStocksAgg retrieves aggregations of stock values by dates
+// GetStocksAgg retrieves aggregations of stock values by dates with moving averages of their closes
 //
-//  GetStocksAgg("TEST", startDate, endDate)
+//  GetStocksAgg(ctx, "TEST", []MovAvg{{Name: "mv_20", Window: 20}, {Name: "ewma_50", Model: MovAvgEWMA, Window: 50}}, 1, startDate, endDate)
 //
 // returns an array ofg stocks aggregations
-func (esStock *Stock) GetStocksAgg(symbol string, movAvgWindow int, step int, startDate time.Time, endDate time.Time) ([]StocksAgg, error) {
+func (esStock *Stock) GetStocksAgg(ctx context.Context, symbol string, movAvgs []MovAvg, step int, startDate time.Time, endDate time.Time) ([]StocksAgg, error) {
+	if len(movAvgs) == 0 {
+		return nil, errors.New("GetStocksAgg: no moving average")
+	}
+	movAvgWindow := 0
+	for _, movAvg := range movAvgs {
+		if movAvg.Window > movAvgWindow {
+			movAvgWindow = movAvg.Window
+		}
+	}
 	movStartDate := startDate.AddDate(0, 0, movAvgWindow*-1)
-	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	esContext, esCancel := context.WithTimeout(ctx, indexTimeout)
 	defer esCancel()
 	query := elastic.NewQueryStringQuery(fmt.Sprintf("symbol = %s AND date: [%s TO %s]",
 		symbol, movStartDate.Format(finance.DateFormat), endDate.Format(finance.DateFormat)))
 	avgCloseAgg := elastic.NewAvgAggregation().Field("close")
-	movCloseAgg := elastic.NewMovAvgAggregation().BucketsPath(avgCloseAggregationName).
-		Window(int(math.Ceil(float64(movAvgWindow) / float64(step))))
 	minDateAgg := elastic.NewMinAggregation().Field("date")
 	selectAgg := elastic.NewBucketSelectorAggregation().
 		AddBucketsPath("avg_close", avgCloseAggregationName).
@@ -120,9 +218,11 @@ func (esStock *Stock) GetStocksAgg(symbol string, movAvgWindow int, step int, st
 		Script(elastic.NewScript(fmt.Sprintf("params.avg_close > 0 && params.date >= %dL", startDate.Unix()*1000)))
 	timeAgg := elastic.NewDateHistogramAggregation().Field("date").Interval(fmt.Sprintf("%dd", step)).
 		SubAggregation(avgCloseAggregationName, avgCloseAgg).
-		SubAggregation(movCloseAggregationName, movCloseAgg).
 		SubAggregation("min_date", minDateAgg).
 		SubAggregation("selector", selectAgg)
+	for i, movAvg := range movAvgs {
+		timeAgg = timeAgg.SubAggregation(fmt.Sprintf("%s_%d", movCloseAggregationName, i), movAvg.aggregation(step))
+	}
 	results, err := esStock.es.Search(indexName).
 		Type(indexType).
 		Query(query).
@@ -130,22 +230,29 @@ func (esStock *Stock) GetStocksAgg(symbol string, movAvgWindow int, step int, st
 		Size(0).
 		Do(esContext)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err)
 	}
 	resAgg, _ := results.Aggregations.DateHistogram(timeAggregationName)
 	stocks := make([]StocksAgg, len(resAgg.Buckets))
 	for i, bucket := range resAgg.Buckets {
 		avg, _ := bucket.Avg(avgCloseAggregationName)
-		mov, movOk := bucket.MovAvg(movCloseAggregationName)
 		stocks[i] = StocksAgg{
 			Symbol:   symbol,
 			MsTime:   int64(bucket.Key),
 			AvgClose: *avg.Value,
+			MovClose: *avg.Value,
+			Overlays: map[string]float64{},
 		}
-		if movOk {
-			stocks[i].MovClose = *mov.Value
-		} else {
-			stocks[i].MovClose = *avg.Value
+		for j, movAvg := range movAvgs {
+			mov, movOk := bucket.MovAvg(fmt.Sprintf("%s_%d", movCloseAggregationName, j))
+			if !movOk || mov.Value == nil {
+				continue
+			}
+			if j == 0 {
+				stocks[i].MovClose = *mov.Value
+			} else if movAvg.Name != "" {
+				stocks[i].Overlays[movAvg.Name] = *mov.Value
+			}
 		}
 	}
 	return stocks, nil
@@ -153,11 +260,11 @@ func (esStock *Stock) GetStocksAgg(symbol string, movAvgWindow int, step int, st
 
 // GetStockStats retrives the stats about a stock
 //
-// 	GetStockStats("CW8.PA", startDate, endDate)
+// 	GetStockStats(ctx, "CW8.PA", startDate, endDate)
 //
 // return the stock stats
-func (esStock *Stock) GetStockStats(symbol string, startDate time.Time, endDate time.Time) (*StocksStats, error) {
-	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+func (esStock *Stock) GetStockStats(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) (*StocksStats, error) {
+	esContext, esCancel := context.WithTimeout(ctx, indexTimeout)
 	defer esCancel()
 	query := elastic.NewQueryStringQuery(fmt.Sprintf("symbol = %s AND date: [%s TO %s]",
 		symbol, startDate.Format(finance.DateFormat), endDate.Format(finance.DateFormat)))
@@ -169,7 +276,7 @@ func (esStock *Stock) GetStockStats(symbol string, startDate time.Time, endDate
 		Size(0).
 		Do(esContext)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err)
 	}
 	resAgg, _ := results.Aggregations.ExtendedStats(statsAggregationName)
 	return &StocksStats{
@@ -181,11 +288,11 @@ func (esStock *Stock) GetStockStats(symbol string, startDate time.Time, endDate
 
 // GetDateForNumPoint compute the start date to get a number of data points
 //
-// 	GetDateForNumPoint("CW8.PA", endDate)
+// 	GetDateForNumPoint(ctx, "CW8.PA", 50, endDate)
 //
-// returns the start date
-func (esStock *Stock) GetDateForNumPoint(symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
-	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+// returns the start date, ErrNotEnoughPoints when the history has less points
+func (esStock *Stock) GetDateForNumPoint(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
+	esContext, esCancel := context.WithTimeout(ctx, indexTimeout)
 	defer esCancel()
 	startDate := endDate.AddDate(0, 0, int(float64(numPoints)*2)*-1)
 	query := elastic.NewQueryStringQuery(fmt.Sprintf("symbol = %s AND date: [%s TO %s]",
@@ -198,7 +305,10 @@ func (esStock *Stock) GetDateForNumPoint(symbol string, numPoints int, endDate t
 		Size(1).
 		Do(esContext)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err)
+	}
+	if len(results.Hits.Hits) == 0 {
+		return nil, ErrNotEnoughPoints
 	}
 	var value stockValue
 	err = json.Unmarshal(*results.Hits.Hits[0].Source, &value)
@@ -211,3 +321,62 @@ func (esStock *Stock) GetDateForNumPoint(symbol string, numPoints int, endDate t
 	}
 	return &date, nil
 }
+
+// GetBars retrieves the daily values of a stock between two dates
+//
+// 	GetBars(ctx, "CW8.PA", startDate, endDate)
+//
+// returns the bars sorted by date
+func (esStock *Stock) GetBars(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) ([]StockBar, error) {
+	esContext, esCancel := context.WithTimeout(ctx, indexTimeout)
+	defer esCancel()
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("symbol = %s AND date: [%s TO %s]",
+		symbol, startDate.Format(finance.DateFormat), endDate.Format(finance.DateFormat)))
+	results, err := esStock.es.Search(indexName).
+		Type(indexType).
+		Query(query).
+		Sort("date", true).
+		Size(maxBars).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	bars := make([]StockBar, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &bars[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bars, nil
+}
+
+// GetSymbols lists the symbols which have a stored history
+//
+// 	GetSymbols()
+//
+// returns the symbols sorted by name
+func (esStock *Stock) GetSymbols() ([]string, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	symbolsAgg := elastic.NewTermsAggregation().Field("symbol.keyword").Size(maxSymbols)
+	results, err := esStock.es.Search(indexName).
+		Type(indexType).
+		Aggregation(symbolsAggName, symbolsAgg).
+		Size(0).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	resAgg, _ := results.Aggregations.Terms(symbolsAggName)
+	symbols := make([]string, len(resAgg.Buckets))
+	for i, bucket := range resAgg.Buckets {
+		key, ok := bucket.Key.(string)
+		if !ok {
+			return nil, errors.New("GetSymbols: Bad aggregation key")
+		}
+		symbols[i] = key
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
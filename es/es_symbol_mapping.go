@@ -0,0 +1,100 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	mappingIndexName = "symbol-mappings"
+	mappingIndexType = "symbol_mapping"
+	maxMappings      = 10000
+)
+
+// SymbolMapping links a security identifier found in statements (ticker, CUSIP, ISIN or name) to a symbol
+type SymbolMapping struct {
+	Username   string `json:"username"`
+	Identifier string `json:"identifier" validate:"required"`
+	Symbol     string `json:"symbol" validate:"required"`
+}
+
+// ISymbolMappingStock contains all es symbol mapping actions
+type ISymbolMappingStock interface {
+	SetSymbolMapping(mapping *SymbolMapping) error
+	GetSymbolMappings(username string) ([]SymbolMapping, error)
+}
+
+// SymbolMappingStock manage symbol mappings in elasticsearch
+type SymbolMappingStock struct {
+	es *elastic.Client
+}
+
+// NewSymbolMapping create a new elasticsearch symbol mappings manager
+func NewSymbolMapping(es *elastic.Client) ISymbolMappingStock {
+	return &SymbolMappingStock{
+		es: es,
+	}
+}
+
+// SetSymbolMapping creates or replaces the mapping of a security identifier
+//
+//  SetSymbolMapping(mapping)
+func (mappingStock *SymbolMappingStock) SetSymbolMapping(mapping *SymbolMapping) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := mappingStock.es.Index().
+		Index(mappingIndexName).
+		Type(mappingIndexType).
+		Id(fmt.Sprintf("%s_%s", mapping.Username, strings.ToUpper(mapping.Identifier))).
+		BodyJson(mapping).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetSymbolMappings gets all the symbol mappings of a user
+//
+//  GetSymbolMappings(username)
+//
+// return the list of mappings
+func (mappingStock *SymbolMappingStock) GetSymbolMappings(username string) ([]SymbolMapping, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("username = %s", username))
+	results, err := mappingStock.es.Search(mappingIndexName).
+		Type(mappingIndexType).
+		Query(query).
+		Size(maxMappings).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	mappings := make([]SymbolMapping, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &mappings[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mappings, nil
+}
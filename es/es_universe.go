@@ -0,0 +1,98 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package es
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	elastic "gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	universeIndexName = "universes"
+	universeIndexType = "universe"
+	maxUniverses      = 1000
+)
+
+// Universe is a named list of symbols to screen
+type Universe struct {
+	Username string   `json:"username"`
+	Name     string   `json:"name" validate:"required"`
+	Symbols  []string `json:"symbols" validate:"required,min=1"`
+}
+
+// IUniverseStock contains all es universe actions
+type IUniverseStock interface {
+	SetUniverse(universe *Universe) error
+	GetUniverses(username string) ([]Universe, error)
+}
+
+// UniverseStock manage universes in elasticsearch
+type UniverseStock struct {
+	es *elastic.Client
+}
+
+// NewUniverse create a new elasticsearch universes manager
+func NewUniverse(es *elastic.Client) IUniverseStock {
+	return &UniverseStock{
+		es: es,
+	}
+}
+
+// SetUniverse creates or replaces a universe of a user
+//
+//  SetUniverse(universe)
+func (universeStock *UniverseStock) SetUniverse(universe *Universe) error {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	_, err := universeStock.es.Index().
+		Index(universeIndexName).
+		Type(universeIndexType).
+		Id(fmt.Sprintf("%s_%s", universe.Username, universe.Name)).
+		BodyJson(universe).
+		Do(esContext)
+	if err != nil {
+		return storeError(err)
+	}
+	return nil
+}
+
+// GetUniverses gets all the universes of a user
+//
+//  GetUniverses(username)
+//
+// return the list of universes
+func (universeStock *UniverseStock) GetUniverses(username string) ([]Universe, error) {
+	esContext, esCancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer esCancel()
+	query := elastic.NewQueryStringQuery(fmt.Sprintf("username = %s", username))
+	results, err := universeStock.es.Search(universeIndexName).
+		Type(universeIndexType).
+		Query(query).
+		Size(maxUniverses).
+		Do(esContext)
+	if err != nil {
+		return nil, storeError(err)
+	}
+	universes := make([]Universe, len(results.Hits.Hits))
+	for i, hit := range results.Hits.Hits {
+		err = json.Unmarshal(*hit.Source, &universes[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return universes, nil
+}
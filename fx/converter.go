@@ -0,0 +1,122 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package fx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// dailyRate is the rate of a currency pair from a day
+type dailyRate struct {
+	date time.Time
+	rate float64
+}
+
+// MissingRateError is returned when a currency has no known rate to the base currency on a day
+type MissingRateError struct {
+	Currency string
+	Base     string
+	Date     time.Time
+}
+
+func (err *MissingRateError) Error() string {
+	return fmt.Sprintf("no %s/%s rate on %s", err.Currency, err.Base, err.Date.Format(rateDateFormat))
+}
+
+// Converter converts amounts into a base currency with the last known rate of a day
+type Converter struct {
+	base   string
+	series map[string][]dailyRate
+}
+
+// NewConverter creates a converter into a base currency
+//
+// 	NewConverter("EUR", rates)
+//
+// The rates of a pair are used in both ways and a currency without rate to the base is converted
+// through another currency which has one.
+func NewConverter(base string, rates []es.FxRate) *Converter {
+	converter := &Converter{base: base, series: map[string][]dailyRate{}}
+	for _, rate := range rates {
+		converter.add(rate.From, rate.To, rate.Date, rate.Rate)
+		converter.add(rate.To, rate.From, rate.Date, 1/rate.Rate)
+	}
+	for _, series := range converter.series {
+		sort.SliceStable(series, func(i, j int) bool { return series[i].date.Before(series[j].date) })
+	}
+	return converter
+}
+
+func pairKey(from string, to string) string {
+	return from + "/" + to
+}
+
+func (converter *Converter) add(from string, to string, date time.Time, rate float64) {
+	key := pairKey(from, to)
+	converter.series[key] = append(converter.series[key], dailyRate{date: date, rate: rate})
+}
+
+// Base returns the currency into which amounts are converted
+func (converter *Converter) Base() string {
+	return converter.base
+}
+
+// pairRate returns the last rate of a pair known on a day
+func (converter *Converter) pairRate(from string, to string, date time.Time) (float64, bool) {
+	series := converter.series[pairKey(from, to)]
+	index := sort.Search(len(series), func(i int) bool { return series[i].date.After(date) })
+	if index == 0 {
+		return 0, false
+	}
+	return series[index-1].rate, true
+}
+
+// Rate returns the value of one unit of a currency in the base currency on a day
+func (converter *Converter) Rate(currency string, date time.Time) (float64, error) {
+	if currency == converter.base {
+		return 1, nil
+	}
+	if rate, ok := converter.pairRate(currency, converter.base, date); ok {
+		return rate, nil
+	}
+	pivots := make([]string, 0, len(converter.series))
+	for key := range converter.series {
+		if strings.HasPrefix(key, currency+"/") {
+			pivots = append(pivots, strings.TrimPrefix(key, currency+"/"))
+		}
+	}
+	sort.Strings(pivots)
+	for _, pivot := range pivots {
+		toPivot, _ := converter.pairRate(currency, pivot, date)
+		toBase, ok := converter.pairRate(pivot, converter.base, date)
+		if toPivot > 0 && ok {
+			return toPivot * toBase, nil
+		}
+	}
+	return 0, &MissingRateError{Currency: currency, Base: converter.base, Date: date}
+}
+
+// Convert converts an amount of a currency into the base currency on a day
+func (converter *Converter) Convert(amount float64, currency string, date time.Time) (float64, error) {
+	rate, err := converter.Rate(currency, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
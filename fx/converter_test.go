@@ -0,0 +1,80 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package fx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func testDate(value string) time.Time {
+	date, _ := time.Parse("2006-01-02", value)
+	return date
+}
+
+var converterRates = []es.FxRate{
+	{From: "EUR", To: "USD", Date: testDate("2017-01-03"), Rate: 1.05},
+	{From: "EUR", To: "USD", Date: testDate("2017-01-02"), Rate: 1.04},
+	{From: "USD", To: "JPY", Date: testDate("2017-01-02"), Rate: 117},
+	{From: "GBP", To: "USD", Date: testDate("2017-01-02"), Rate: 1.25},
+}
+
+var rateTests = []struct {
+	base     string
+	currency string
+	date     string
+	rate     float64
+}{
+	{"USD", "USD", "2010-01-01", 1},
+	{"USD", "EUR", "2017-01-02", 1.04},
+	{"USD", "EUR", "2017-01-08", 1.05},
+	{"EUR", "USD", "2017-01-03", 1 / 1.05},
+	{"JPY", "EUR", "2017-01-02", 1.04 * 117},
+	{"GBP", "EUR", "2017-01-03", 1.05 / 1.25},
+}
+
+func TestRate(t *testing.T) {
+	for _, tt := range rateTests {
+		rate, err := NewConverter(tt.base, converterRates).Rate(tt.currency, testDate(tt.date))
+		assert.Nil(t, err)
+		assert.InDelta(t, tt.rate, rate, 1e-9, "%s/%s", tt.currency, tt.base)
+	}
+}
+
+func TestRateErrors(t *testing.T) {
+	converter := NewConverter("USD", converterRates)
+	_, err := converter.Rate("EUR", testDate("2017-01-01"))
+	assert.Equal(t, &MissingRateError{Currency: "EUR", Base: "USD", Date: testDate("2017-01-01")}, err)
+	assert.EqualError(t, err, "no EUR/USD rate on 2017-01-01")
+	_, err = converter.Convert(10, "CHF", testDate("2017-01-02"))
+	assert.EqualError(t, err, "no CHF/USD rate on 2017-01-02")
+}
+
+func TestConvert(t *testing.T) {
+	converter := NewConverter("EUR", converterRates)
+	assert.Equal(t, "EUR", converter.Base())
+	amount, err := converter.Convert(105, "USD", testDate("2017-01-03"))
+	assert.Nil(t, err)
+	assert.InDelta(t, 100, amount, 1e-9)
+}
+
+func TestDefaultCurrency(t *testing.T) {
+	assert.Equal(t, "EUR", DefaultCurrency("CW8.PA"))
+	assert.Equal(t, "GBP", DefaultCurrency("vod.l"))
+	assert.Equal(t, "USD", DefaultCurrency("AAPL"))
+	assert.Equal(t, "USD", DefaultCurrency("BRK.B"))
+}
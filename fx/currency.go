@@ -0,0 +1,58 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package fx converts amounts between currencies with daily exchange rates
+package fx
+
+import "strings"
+
+// USD is the currency of the symbols without exchange suffix
+const USD = "USD"
+
+// suffixCurrencies contains the currency of the Yahoo exchange suffixes
+var suffixCurrencies = map[string]string{
+	"AS": "EUR",
+	"BR": "EUR",
+	"DE": "EUR",
+	"F":  "EUR",
+	"LS": "EUR",
+	"MC": "EUR",
+	"MI": "EUR",
+	"PA": "EUR",
+	"L":  "GBP",
+	"SW": "CHF",
+	"TO": "CAD",
+	"V":  "CAD",
+	"AX": "AUD",
+	"HK": "HKD",
+	"T":  "JPY",
+	"ST": "SEK",
+	"OL": "NOK",
+	"CO": "DKK",
+}
+
+// DefaultCurrency guesses the currency of a symbol from its exchange suffix
+//
+// 	DefaultCurrency("CW8.PA")
+//
+// returns the currency, USD when the suffix is unknown
+func DefaultCurrency(symbol string) string {
+	dot := strings.LastIndex(symbol, ".")
+	if dot < 0 {
+		return USD
+	}
+	if currency, ok := suffixCurrencies[strings.ToUpper(symbol[dot+1:])]; ok {
+		return currency
+	}
+	return USD
+}
@@ -0,0 +1,154 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package fx
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+const rateDateFormat = "2006-01-02"
+
+var csvRateColumns = []string{"date", "from", "to", "rate"}
+
+// RateProvider gives the exchange rates between a set of currencies
+type RateProvider interface {
+	GetRates(currencies []string, startDate time.Time, endDate time.Time) ([]es.FxRate, error)
+}
+
+// RateStore saves exchange rates
+type RateStore interface {
+	AddRate(rate *es.FxRate) error
+}
+
+// CSVProvider gives the exchange rates read from a CSV file with date, from, to and rate columns
+type CSVProvider struct {
+	rates []es.FxRate
+}
+
+// NewCSVProvider reads all the rates of a CSV file
+//
+// 	NewCSVProvider(reader)
+//
+// Dates are written as 2006-01-02 and a rate is the value of one unit of from in to.
+// returns the provider or an error naming the first bad line
+func NewCSVProvider(reader io.Reader) (*CSVProvider, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header: %s", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range csvRateColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column: %s", name)
+		}
+	}
+	provider := &CSVProvider{}
+	for line := 2; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rate, err := parseCSVRate(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		provider.rates = append(provider.rates, *rate)
+	}
+	return provider, nil
+}
+
+func parseCSVRate(record []string, columns map[string]int) (*es.FxRate, error) {
+	value := func(name string) string {
+		if columns[name] >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[columns[name]])
+	}
+	date, err := time.Parse(rateDateFormat, value("date"))
+	if err != nil {
+		return nil, fmt.Errorf("bad date: %s", value("date"))
+	}
+	rate, err := strconv.ParseFloat(value("rate"), 64)
+	if err != nil || rate <= 0 {
+		return nil, fmt.Errorf("bad rate: %s", value("rate"))
+	}
+	from, to := strings.ToUpper(value("from")), strings.ToUpper(value("to"))
+	if len(from) != 3 || len(to) != 3 {
+		return nil, fmt.Errorf("bad currency pair: %s/%s", from, to)
+	}
+	return &es.FxRate{From: from, To: to, Date: date, Rate: rate}, nil
+}
+
+// GetRates returns the rates of the file between the currencies of the set and in the period
+func (provider *CSVProvider) GetRates(currencies []string, startDate time.Time, endDate time.Time) ([]es.FxRate, error) {
+	set := map[string]bool{}
+	for _, currency := range currencies {
+		set[currency] = true
+	}
+	var rates []es.FxRate
+	for _, rate := range provider.rates {
+		if set[rate.From] && set[rate.To] && !rate.Date.Before(startDate) && !rate.Date.After(endDate) {
+			rates = append(rates, rate)
+		}
+	}
+	return rates, nil
+}
+
+// Currencies returns the currencies found in the file
+func (provider *CSVProvider) Currencies() []string {
+	seen := map[string]bool{}
+	var currencies []string
+	for _, rate := range provider.rates {
+		for _, currency := range []string{rate.From, rate.To} {
+			if !seen[currency] {
+				seen[currency] = true
+				currencies = append(currencies, currency)
+			}
+		}
+	}
+	return currencies
+}
+
+// Feed copies the rates of a provider into a store
+//
+// 	Feed(provider, esFx, []string{"EUR", "USD"}, startDate, endDate)
+//
+// returns the number of saved rates
+func Feed(provider RateProvider, store RateStore, currencies []string, startDate time.Time, endDate time.Time) (int, error) {
+	rates, err := provider.GetRates(currencies, startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+	for i := range rates {
+		if err := store.AddRate(&rates[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(rates), nil
+}
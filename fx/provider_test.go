@@ -0,0 +1,80 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package fx
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRatesCSV = "\ufeffDate,From,To,Rate\n" +
+	"2017-01-02,eur,usd,1.04\n" +
+	"2017-01-03,EUR,USD,1.05\n" +
+	"2017-01-02,USD,JPY,117\n"
+
+type recordRateStore struct {
+	rates []es.FxRate
+	err   error
+}
+
+func (store *recordRateStore) AddRate(rate *es.FxRate) error {
+	if store.err != nil {
+		return store.err
+	}
+	store.rates = append(store.rates, *rate)
+	return nil
+}
+
+func TestCSVProvider(t *testing.T) {
+	provider, err := NewCSVProvider(strings.NewReader(testRatesCSV))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"EUR", "USD", "JPY"}, provider.Currencies())
+	rates, err := provider.GetRates([]string{"EUR", "USD"}, testDate("2017-01-03"), testDate("2017-12-31"))
+	assert.Nil(t, err)
+	assert.Equal(t, []es.FxRate{{From: "EUR", To: "USD", Date: testDate("2017-01-03"), Rate: 1.05}}, rates)
+}
+
+var csvProviderErrorTests = []struct {
+	data  string
+	error string
+}{
+	{"", "cannot read header: EOF"},
+	{"date,from,to\n", "missing column: rate"},
+	{"date,from,to,rate\n2017-01-32,EUR,USD,1\n", "line 2: bad date: 2017-01-32"},
+	{"date,from,to,rate\n2017-01-02,EUR,USD,-1\n", "line 2: bad rate: -1"},
+	{"date,from,to,rate\n2017-01-02,EURO,USD,1\n", "line 2: bad currency pair: EURO/USD"},
+}
+
+func TestCSVProviderErrors(t *testing.T) {
+	for _, tt := range csvProviderErrorTests {
+		_, err := NewCSVProvider(strings.NewReader(tt.data))
+		assert.EqualError(t, err, tt.error)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	provider, _ := NewCSVProvider(strings.NewReader(testRatesCSV))
+	store := &recordRateStore{}
+	count, err := Feed(provider, store, provider.Currencies(), testDate("2017-01-01"), testDate("2017-12-31"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, 3, len(store.rates))
+	_, err = Feed(provider, &recordRateStore{err: errors.New("store error")}, provider.Currencies(),
+		testDate("2017-01-01"), testDate("2017-12-31"))
+	assert.EqualError(t, err, "store error")
+}
@@ -0,0 +1,307 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/clebi/gofin/alerts"
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/screener"
+	"github.com/labstack/echo"
+)
+
+const (
+	// alertHistoryDays is the number of days of history listed when no start date is given
+	alertHistoryDays = 30
+	// alertMinDays is the least number of days of bars read to evaluate a rule, a week covers the week-ends
+	alertMinDays = 7
+)
+
+// AlertHistoryParams contains all the parameters for the alert history route
+type AlertHistoryParams struct {
+	DateRangeParams
+	Name string `schema:"name"`
+}
+
+// MuteParams contains all the parameters for the alert mute route, the rule is muted until the start of the day
+type MuteParams struct {
+	Until string `schema:"until" validate:"required"`
+}
+
+// AlertHandlers handles all requests about the alert rules of the user
+type AlertHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	now          GetDateFunc
+	errorHandler errorHandlerFunc
+}
+
+// NewAlertHandlers creates a new alert handlers object
+func NewAlertHandlers(context *Context) *AlertHandlers {
+	return &AlertHandlers{
+		Context:      context,
+		getDate:      getYesterDayDate,
+		now:          time.Now,
+		errorHandler: handleError,
+	}
+}
+
+// evaluateAlert checks a rule against the stored bars of its symbol, notifies the user and records the event
+// when it fires, then saves the new state of the rule
+func evaluateAlert(ctx context.Context, context *Context, rule *es.AlertRule, condition *screener.Expression,
+	now time.Time) (*es.AlertEvent, error) {
+	// two calendar days per bar cover the week-ends and the holidays
+	days := condition.WarmUp() * 2
+	if days < alertMinDays {
+		days = alertMinDays
+	}
+	bars, err := context.esStock.GetBars(ctx, rule.Symbol, now.AddDate(0, 0, -days), now)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]float64{}
+	if alerts.UsesPnL(condition) && len(bars) > 0 {
+		positions, err := context.esPosition.GetPositions(rule.Username)
+		if err != nil {
+			return nil, err
+		}
+		for _, position := range positions {
+			if position.Symbol == rule.Symbol {
+				values[alerts.VariablePnL] = alerts.PnL(position, bars[len(bars)-1].Close)
+			}
+		}
+	}
+	lastDate := rule.LastDate
+	event, err := alerts.Evaluate(rule, condition, bars, values, now)
+	if err != nil {
+		return nil, err
+	}
+	if event != nil {
+		if !event.Muted {
+			channels, err := context.notifier.Channels(*rule)
+			if err != nil {
+				event.Errors = append(event.Errors, err.Error())
+			}
+			alerts.Notify(event, channels)
+		}
+		if err := context.esAlert.AddAlertEvent(event); err != nil {
+			return nil, err
+		}
+	}
+	// the rule is only evaluated, and its state changed, on a new bar
+	if rule.LastDate != lastDate {
+		if err := context.esAlert.SetAlertRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+// evaluateAlerts checks a list of rules and returns the events of the ones which fired
+//
+// A stored rule whose condition does not parse, such as one saved before its bounds were checked, is skipped as
+// well as a rule failing its evaluation, the failures are logged and the other rules are still evaluated.
+func evaluateAlerts(ctx context.Context, context *Context, rules []es.AlertRule, now time.Time) []es.AlertEvent {
+	events := []es.AlertEvent{}
+	for i := range rules {
+		condition, err := alerts.Condition(rules[i].Condition)
+		if err != nil {
+			log.WithFields(log.Fields{"alert": rules[i].ID(), "error": err}).Error("Alert condition skipped")
+			continue
+		}
+		event, err := evaluateAlert(ctx, context, &rules[i], condition, now)
+		if err != nil {
+			log.WithFields(log.Fields{"alert": rules[i].ID(), "error": err}).Error("Alert evaluation skipped")
+			continue
+		}
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+	return events
+}
+
+// evaluateStoredAlerts reads rules and checks them, both under the lock of the alert evaluations
+func evaluateStoredAlerts(
+	ctx context.Context,
+	context *Context,
+	load func() ([]es.AlertRule, error), now time.Time) ([]es.AlertEvent, error) {
+	context.alertMutex.Lock()
+	defer context.alertMutex.Unlock()
+	rules, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return evaluateAlerts(ctx, context, rules, now), nil
+}
+
+// evaluateSymbolAlerts checks the rules of all users on a symbol, it is queued after each ingestion of the symbol
+func evaluateSymbolAlerts(ctx context.Context, context *Context, symbol string, now time.Time) ([]es.AlertEvent, error) {
+	return evaluateStoredAlerts(ctx, context, func() ([]es.AlertRule, error) {
+		return context.esAlert.GetSymbolAlertRules(symbol)
+	}, now)
+}
+
+func (handlers *AlertHandlers) getRule(name string) (*es.AlertRule, *HandlerERROR) {
+	rule, err := handlers.esAlert.GetAlertRule(defaultUsername, name)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	if rule == nil {
+		return nil, &HandlerERROR{error: &NotFoundError{Kind: "alert", Name: name}, Status: http.StatusNotFound}
+	}
+	return rule, nil
+}
+
+// SetAlert handles http request to create or replace an alert rule of the user
+//
+// The condition is a screening expression which can also use pnl, the profit and loss in percent of the
+// position. A replaced rule starts again as not triggered and fires on its next evaluation if its condition holds.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) SetAlert(c echo.Context) error {
+	rule := new(es.AlertRule)
+	if err := c.Bind(rule); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if err := handlers.validator.Struct(rule); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	if _, err := alerts.Condition(rule.Condition); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("condition", err))
+	}
+	if _, err := handlers.notifier.Channels(*rule); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("channels", err))
+	}
+	rule.Username = defaultUsername
+	rule.Triggered = false
+	rule.LastDate = nil
+	if err := handlers.esAlert.SetAlertRule(rule); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, rule)
+}
+
+// GetAlerts handles http request to list the alert rules of the user
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) GetAlerts(c echo.Context) error {
+	rules, err := handlers.esAlert.GetAlertRules(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, rules)
+}
+
+// GetAlert handles http request to retrieve an alert rule of the user
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) GetAlert(c echo.Context) error {
+	rule, httpErr := handlers.getRule(c.Param("name"))
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	return c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlert handles http request to delete an alert rule of the user, its history is kept
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) DeleteAlert(c echo.Context) error {
+	rule, httpErr := handlers.getRule(c.Param("name"))
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	if err := handlers.esAlert.DeleteAlertRule(defaultUsername, rule.Name); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, rule)
+}
+
+// MuteAlert handles http request to mute the notifications of an alert rule until a day
+//
+// A muted rule is still evaluated and its events are recorded in the history as muted.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) MuteAlert(c echo.Context) error {
+	var params MuteParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	until, err := parseQueryDate(params.Until, time.Time{})
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("until", err))
+	}
+	return handlers.setMute(c, &until)
+}
+
+// UnmuteAlert handles http request to send again the notifications of an alert rule
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) UnmuteAlert(c echo.Context) error {
+	return handlers.setMute(c, nil)
+}
+
+func (handlers *AlertHandlers) setMute(c echo.Context, until *time.Time) error {
+	rule, httpErr := handlers.getRule(c.Param("name"))
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	rule.MutedUntil = until
+	if err := handlers.esAlert.SetAlertRule(rule); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, rule)
+}
+
+// EvaluateAlerts handles http request to evaluate all the alert rules of the user on the stored bars
+//
+// The rules are also evaluated in the background after each ingestion of their symbol, the evaluations are
+// serialized so that a rule fires at most once on a bar.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) EvaluateAlerts(c echo.Context) error {
+	events, err := evaluateStoredAlerts(c.Request().Context(), handlers.Context, func() ([]es.AlertRule, error) {
+		return handlers.esAlert.GetAlertRules(defaultUsername)
+	}, handlers.now())
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, events)
+}
+
+// GetAlertHistory handles http request to list the events of the alert rules of the user between two days
+//
+// The period ends yesterday and lasts a month when its dates are not given, all rules are listed without name.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AlertHandlers) GetAlertHistory(c echo.Context) error {
+	var params AlertHistoryParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	start, end, httpErr := params.period(handlers.getDate(), alertHistoryDays)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	events, err := handlers.esAlert.GetAlertEvents(defaultUsername, params.Name, start, end)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,331 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/alerts"
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+const alertErrorMsg = "alert_error"
+
+var testAlertRule = es.AlertRule{Name: "low", Symbol: "CW8.PA", Condition: "close < 300"}
+
+var setAlertErrorTests = []struct {
+	echoContext     echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEchoBind{Msg: alertErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		alertErrorMsg,
+	},
+	{
+		&AlertEchoBind{Rule: testAlertRule},
+		&Context{validator: &ErrorStructValidator{Msg: alertErrorMsg}},
+		http.StatusBadRequest,
+		alertErrorMsg,
+	},
+	{
+		&AlertEchoBind{Rule: es.AlertRule{Name: "low", Symbol: "CW8.PA", Condition: "close <"}},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unexpected end of expression",
+	},
+	{
+		&AlertEchoBind{Rule: es.AlertRule{Name: "low", Symbol: "CW8.PA", Condition: "close < 300", Email: []string{"a@test.test"}}},
+		&Context{validator: &DummyStructValidator{}, notifier: alerts.NewDispatcher(nil)},
+		http.StatusBadRequest,
+		alerts.ErrNoSMTP.Error(),
+	},
+	{
+		&AlertEchoBind{Rule: testAlertRule},
+		&Context{
+			validator: &DummyStructValidator{},
+			notifier:  alerts.NewDispatcher(nil),
+			esAlert:   &ErrorEsAlert{Msg: alertErrorMsg},
+		},
+		http.StatusInternalServerError,
+		alertErrorMsg,
+	},
+}
+
+func TestSetAlertErrors(t *testing.T) {
+	for _, tt := range setAlertErrorTests {
+		handlers := AlertHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		res := handlers.SetAlert(tt.echoContext)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestSetAlertIndicatorOutOfRange(t *testing.T) {
+	esAlert := &DummyEsAlert{}
+	handlers := AlertHandlers{
+		Context:      &Context{validator: &DummyStructValidator{}, esAlert: esAlert},
+		errorHandler: createInvalidParamHandler(t, "condition", "period of sma must be at most 1000: 1e12"),
+	}
+	res := handlers.SetAlert(&AlertEchoBind{Rule: es.AlertRule{Name: "big", Symbol: "CW8.PA", Condition: "sma(1e12) > 0"}})
+	assert.NotNil(t, res)
+	assert.Equal(t, 0, len(esAlert.Rules))
+}
+
+func TestEvaluateAlertsSkipsBadCondition(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: []es.AlertRule{
+		{Name: "bad", Symbol: "CW8.PA", Condition: "sma(1e12) > 0"},
+		testAlertRule,
+	}}
+	channel := &RecordChannel{}
+	events := evaluateAlerts(context.Background(), createAlertContext(esAlert, channel), esAlert.Rules, getTestDate())
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "low", events[0].Name)
+	assert.Nil(t, esAlert.Rules[0].LastDate)
+}
+
+func TestGetAlertsErrors(t *testing.T) {
+	handlers := AlertHandlers{
+		Context:      &Context{esAlert: &ErrorEsAlert{Msg: alertErrorMsg}},
+		errorHandler: createErrorHandler(t, http.StatusInternalServerError, alertErrorMsg),
+	}
+	res := handlers.GetAlerts(&DummyEchoBind{})
+	assert.NotNil(t, res)
+}
+
+var alertRuleErrorTests = []struct {
+	esAlert         es.IAlertStock
+	expectedStatus  int
+	expectedMessage string
+}{
+	{&ErrorEsAlert{Msg: alertErrorMsg}, http.StatusInternalServerError, alertErrorMsg},
+	{&DummyEsAlert{}, http.StatusNotFound, "unknown alert: low"},
+}
+
+func TestAlertRuleErrors(t *testing.T) {
+	for _, tt := range alertRuleErrorTests {
+		handlers := AlertHandlers{
+			Context: &Context{
+				sh:        &AlertSchemaDecoder{Mute: MuteParams{Until: "2016-12-20"}},
+				validator: &DummyStructValidator{},
+				esAlert:   tt.esAlert,
+			},
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		for _, handler := range []echo.HandlerFunc{handlers.GetAlert, handlers.DeleteAlert, handlers.MuteAlert, handlers.UnmuteAlert} {
+			req, err := http.NewRequest("GET", testAlertURL, nil)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			c, _ := createEcho(req)
+			c.SetParamNames("name")
+			c.SetParamValues("low")
+			res := handler(c)
+			assert.NotNil(t, res)
+		}
+	}
+}
+
+var updateAlertErrorTests = []struct {
+	handler func(handlers *AlertHandlers) echo.HandlerFunc
+	context *Context
+	status  int
+	message string
+}{
+	{
+		func(handlers *AlertHandlers) echo.HandlerFunc { return handlers.DeleteAlert },
+		&Context{esAlert: &FailingEsAlert{
+			DummyEsAlert: DummyEsAlert{Rules: []es.AlertRule{testAlertRule}},
+			Msg:          alertErrorMsg,
+			FailDelete:   true,
+		}},
+		http.StatusInternalServerError,
+		alertErrorMsg,
+	},
+	{
+		func(handlers *AlertHandlers) echo.HandlerFunc { return handlers.UnmuteAlert },
+		&Context{esAlert: &FailingEsAlert{
+			DummyEsAlert: DummyEsAlert{Rules: []es.AlertRule{testAlertRule}},
+			Msg:          alertErrorMsg,
+			FailSet:      true,
+		}},
+		http.StatusInternalServerError,
+		alertErrorMsg,
+	},
+	{
+		func(handlers *AlertHandlers) echo.HandlerFunc { return handlers.MuteAlert },
+		&Context{sh: &ErrorSchemaDecoder{Msg: alertErrorMsg}},
+		http.StatusInternalServerError,
+		alertErrorMsg,
+	},
+	{
+		func(handlers *AlertHandlers) echo.HandlerFunc { return handlers.MuteAlert },
+		&Context{sh: &AlertSchemaDecoder{Mute: MuteParams{Until: "tomorrow"}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"bad date: tomorrow",
+	},
+}
+
+func TestUpdateAlertErrors(t *testing.T) {
+	for _, tt := range updateAlertErrorTests {
+		handlers := &AlertHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.status, tt.message),
+		}
+		req, err := http.NewRequest("GET", testAlertURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		c.SetParamNames("name")
+		c.SetParamValues("low")
+		res := tt.handler(handlers)(c)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestEvaluateAlertsErrors(t *testing.T) {
+	handlers := AlertHandlers{
+		Context:      &Context{esAlert: &ErrorEsAlert{Msg: alertErrorMsg}},
+		errorHandler: createErrorHandler(t, http.StatusInternalServerError, alertErrorMsg),
+		now:          getTestDate,
+	}
+	req, err := http.NewRequest("POST", testAlertEvaluateURL, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c, _ := createEcho(req)
+	res := handlers.EvaluateAlerts(c)
+	assert.NotNil(t, res)
+}
+
+var failingAlertContexts = []*Context{
+	{
+		esAlert: &DummyEsAlert{Rules: []es.AlertRule{testAlertRule}},
+		esStock: &ErrorBarsEsStock{Msg: alertErrorMsg},
+	},
+	{
+		esAlert:    &DummyEsAlert{Rules: []es.AlertRule{{Name: "loss", Symbol: "CW8.PA", Condition: "pnl < -10"}}},
+		esStock:    createAlertContext(nil, nil).esStock,
+		esPosition: &ErrorEsPosition{Msg: alertErrorMsg},
+	},
+	{
+		esAlert: &FailingEsAlert{
+			DummyEsAlert: DummyEsAlert{Rules: []es.AlertRule{testAlertRule}},
+			Msg:          alertErrorMsg,
+			FailEvents:   true,
+		},
+		esStock:  createAlertContext(nil, nil).esStock,
+		notifier: &RecordNotifier{Channel: &RecordChannel{}},
+	},
+	{
+		esAlert: &FailingEsAlert{
+			DummyEsAlert: DummyEsAlert{Rules: []es.AlertRule{{Name: "high", Symbol: "CW8.PA", Condition: "close > 305"}}},
+			Msg:          alertErrorMsg,
+			FailSet:      true,
+		},
+		esStock: createAlertContext(nil, nil).esStock,
+	},
+}
+
+func TestEvaluateAlertsSkipsFailingRule(t *testing.T) {
+	for _, alertContext := range failingAlertContexts {
+		rules, err := alertContext.esAlert.GetAlertRules(defaultUsername)
+		assert.Nil(t, err)
+		assert.Empty(t, evaluateAlerts(context.Background(), alertContext, rules, getTestDate()))
+	}
+	// the rules after the failing one are still evaluated
+	esAlert := &DummyEsAlert{Rules: []es.AlertRule{{Name: "loss", Symbol: "CW8.PA", Condition: "pnl < -10"}, testAlertRule}}
+	channel := &RecordChannel{}
+	alertContext := createAlertContext(esAlert, channel)
+	alertContext.esPosition = &ErrorEsPosition{Msg: alertErrorMsg}
+	events := evaluateAlerts(context.Background(), alertContext, esAlert.Rules, getTestDate())
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "low", events[0].Name)
+	assert.Equal(t, 1, len(channel.Events))
+}
+
+func TestEvaluateAlertChannelsError(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: []es.AlertRule{
+		{Name: "low", Symbol: "CW8.PA", Condition: "close < 300", Email: []string{"a@test.test"}},
+	}}
+	alertContext := createAlertContext(esAlert, nil)
+	alertContext.notifier = alerts.NewDispatcher(nil)
+	events := evaluateAlerts(context.Background(), alertContext, esAlert.Rules, getTestDate())
+	assert.Equal(t, []string{alerts.ErrNoSMTP.Error()}, events[0].Errors)
+	assert.Equal(t, []string{alerts.ErrNoSMTP.Error()}, esAlert.Events[0].Errors)
+}
+
+var alertHistoryErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: alertErrorMsg}},
+		http.StatusInternalServerError,
+		alertErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &AlertSchemaDecoder{History: AlertHistoryParams{DateRangeParams: DateRangeParams{From: "2016-02-30"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad date: 2016-02-30",
+	},
+	{
+		&Context{
+			sh:        &AlertSchemaDecoder{History: AlertHistoryParams{DateRangeParams: DateRangeParams{From: "2016-12-01", To: "2016-11-30"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"start 2016-12-01 is after end 2016-11-30",
+	},
+	{
+		&Context{
+			sh:        &AlertSchemaDecoder{},
+			validator: &DummyStructValidator{},
+			esAlert:   &ErrorEsAlert{Msg: alertErrorMsg},
+		},
+		http.StatusInternalServerError,
+		alertErrorMsg,
+	},
+}
+
+func TestGetAlertHistoryErrors(t *testing.T) {
+	for _, tt := range alertHistoryErrorTests {
+		handlers := AlertHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+		}
+		req, err := http.NewRequest("GET", testAlertHistoryURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.GetAlertHistory(c)
+		assert.NotNil(t, res)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/clebi/gofin/alerts"
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+)
+
+type AlertSchemaDecoder struct {
+	History AlertHistoryParams
+	Mute    MuteParams
+}
+
+func (decoder *AlertSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	switch params := dst.(type) {
+	case *AlertHistoryParams:
+		*params = decoder.History
+	case *MuteParams:
+		*params = decoder.Mute
+	default:
+		return errors.New("bad type for AlertSchemaDecoder")
+	}
+	return nil
+}
+
+type AlertEchoBind struct {
+	echo.Context
+	Rule es.AlertRule
+}
+
+func (echo AlertEchoBind) Bind(dst interface{}) error {
+	*dst.(*es.AlertRule) = echo.Rule
+	return nil
+}
+
+type DummyEsAlert struct {
+	Rules  []es.AlertRule
+	Events []es.AlertEvent
+	Name   string
+	Start  time.Time
+	End    time.Time
+}
+
+func (alertStock *DummyEsAlert) SetAlertRule(rule *es.AlertRule) error {
+	for i := range alertStock.Rules {
+		if alertStock.Rules[i].Name == rule.Name {
+			alertStock.Rules[i] = *rule
+			return nil
+		}
+	}
+	alertStock.Rules = append(alertStock.Rules, *rule)
+	return nil
+}
+
+func (alertStock *DummyEsAlert) GetAlertRule(username string, name string) (*es.AlertRule, error) {
+	for _, rule := range alertStock.Rules {
+		if rule.Name == name {
+			return &rule, nil
+		}
+	}
+	return nil, nil
+}
+
+func (alertStock *DummyEsAlert) GetAlertRules(username string) ([]es.AlertRule, error) {
+	rules := make([]es.AlertRule, len(alertStock.Rules))
+	copy(rules, alertStock.Rules)
+	return rules, nil
+}
+
+func (alertStock *DummyEsAlert) GetSymbolAlertRules(symbol string) ([]es.AlertRule, error) {
+	rules := []es.AlertRule{}
+	for _, rule := range alertStock.Rules {
+		if rule.Symbol == symbol {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (alertStock *DummyEsAlert) DeleteAlertRule(username string, name string) error {
+	for i := range alertStock.Rules {
+		if alertStock.Rules[i].Name == name {
+			alertStock.Rules = append(alertStock.Rules[:i], alertStock.Rules[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (alertStock *DummyEsAlert) AddAlertEvent(event *es.AlertEvent) error {
+	alertStock.Events = append(alertStock.Events, *event)
+	return nil
+}
+
+func (alertStock *DummyEsAlert) GetAlertEvents(username string, name string, start time.Time, end time.Time) ([]es.AlertEvent, error) {
+	alertStock.Name = name
+	alertStock.Start = start
+	alertStock.End = end
+	return alertStock.Events, nil
+}
+
+type ErrorEsAlert struct {
+	Msg string
+}
+
+func (alertStock *ErrorEsAlert) SetAlertRule(rule *es.AlertRule) error {
+	return errors.New(alertStock.Msg)
+}
+
+func (alertStock *ErrorEsAlert) GetAlertRule(username string, name string) (*es.AlertRule, error) {
+	return nil, errors.New(alertStock.Msg)
+}
+
+func (alertStock *ErrorEsAlert) GetAlertRules(username string) ([]es.AlertRule, error) {
+	return nil, errors.New(alertStock.Msg)
+}
+
+func (alertStock *ErrorEsAlert) GetSymbolAlertRules(symbol string) ([]es.AlertRule, error) {
+	return nil, errors.New(alertStock.Msg)
+}
+
+func (alertStock *ErrorEsAlert) DeleteAlertRule(username string, name string) error {
+	return errors.New(alertStock.Msg)
+}
+
+func (alertStock *ErrorEsAlert) AddAlertEvent(event *es.AlertEvent) error {
+	return errors.New(alertStock.Msg)
+}
+
+func (alertStock *ErrorEsAlert) GetAlertEvents(username string, name string, start time.Time, end time.Time) ([]es.AlertEvent, error) {
+	return nil, errors.New(alertStock.Msg)
+}
+
+// FailingEsAlert reads the rules but fails to update or delete them, or to record the events
+type FailingEsAlert struct {
+	DummyEsAlert
+	Msg        string
+	FailSet    bool
+	FailDelete bool
+	FailEvents bool
+}
+
+func (alertStock *FailingEsAlert) SetAlertRule(rule *es.AlertRule) error {
+	if alertStock.FailSet {
+		return errors.New(alertStock.Msg)
+	}
+	return alertStock.DummyEsAlert.SetAlertRule(rule)
+}
+
+func (alertStock *FailingEsAlert) DeleteAlertRule(username string, name string) error {
+	if alertStock.FailDelete {
+		return errors.New(alertStock.Msg)
+	}
+	return alertStock.DummyEsAlert.DeleteAlertRule(username, name)
+}
+
+func (alertStock *FailingEsAlert) AddAlertEvent(event *es.AlertEvent) error {
+	if alertStock.FailEvents {
+		return errors.New(alertStock.Msg)
+	}
+	return alertStock.DummyEsAlert.AddAlertEvent(event)
+}
+
+type RecordChannel struct {
+	Rules  []string
+	Events []es.AlertEvent
+}
+
+func (channel *RecordChannel) Send(event *es.AlertEvent) error {
+	channel.Events = append(channel.Events, *event)
+	return nil
+}
+
+type RecordNotifier struct {
+	Channel *RecordChannel
+}
+
+func (notifier *RecordNotifier) Channels(rule es.AlertRule) ([]alerts.Channel, error) {
+	notifier.Channel.Rules = append(notifier.Channel.Rules, rule.Name)
+	return []alerts.Channel{notifier.Channel}, nil
+}
@@ -0,0 +1,252 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/alerts"
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testAlertsURL        = "http://test.test/alerts"
+	testAlertURL         = "http://test.test/alerts/low"
+	testAlertMuteURL     = "http://test.test/alerts/low/mute"
+	testAlertEvaluateURL = "http://test.test/alerts/evaluate"
+	testAlertHistoryURL  = "http://test.test/alerts/history"
+	setAlertData         = "{\"name\":\"low\",\"symbol\":\"CW8.PA\",\"condition\":\"close < 300\"," +
+		"\"webhook\":{\"url\":\"http://test.test/hook\",\"secret\":\"secret\"},\"triggered\":true}"
+)
+
+func testAlertRules() []es.AlertRule {
+	mutedUntil := getTestDate().Add(24 * time.Hour)
+	return []es.AlertRule{
+		{Username: "tester", Name: "low", Symbol: "CW8.PA", Condition: "close < 300"},
+		{Username: "tester", Name: "loss", Symbol: "CW8.PA", Condition: "pnl < -10"},
+		{Username: "tester", Name: "muted", Symbol: "CW8.PA", Condition: "close < 305", MutedUntil: &mutedUntil},
+		{Username: "tester", Name: "high", Symbol: "CW8.PA", Condition: "close > 305"},
+		{Username: "tester", Name: "other", Symbol: "TEST2", Condition: "close < 1"},
+	}
+}
+
+func createAlertContext(esAlert es.IAlertStock, channel *RecordChannel) *Context {
+	return &Context{
+		esAlert:    esAlert,
+		esStock:    &BarsEsStock{bars: map[string][]es.StockBar{"CW8.PA": createIndicatorBars("CW8.PA", getSeriesTestDate(), 310, 299)}},
+		esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "CW8.PA", Number: 10, Cost: 4000}}},
+		notifier:   &RecordNotifier{Channel: channel},
+	}
+}
+
+func TestSetAlert(t *testing.T) {
+	esAlert := &DummyEsAlert{}
+	handlers := &AlertHandlers{
+		Context: &Context{
+			esAlert:   esAlert,
+			validator: &DummyStructValidator{},
+			notifier:  alerts.NewDispatcher(nil),
+		},
+	}
+	req, err := http.NewRequest("PUT", testAlertsURL, bytes.NewBufferString(setAlertData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.SetAlert(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, 1, len(esAlert.Rules))
+	rule := esAlert.Rules[0]
+	assert.Equal(t, "tester", rule.Username)
+	assert.Equal(t, "low", rule.Name)
+	assert.Equal(t, "close < 300", rule.Condition)
+	assert.Equal(t, &es.AlertWebhook{URL: "http://test.test/hook", Secret: "secret"}, rule.Webhook)
+	assert.False(t, rule.Triggered)
+	assert.Nil(t, rule.LastDate)
+}
+
+func TestGetAlerts(t *testing.T) {
+	handlers := &AlertHandlers{Context: &Context{esAlert: &DummyEsAlert{Rules: testAlertRules()}}}
+	req, err := http.NewRequest("GET", testAlertsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetAlerts(c)
+	var rules []es.AlertRule
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &rules))
+	assert.Equal(t, 5, len(rules))
+	assert.Equal(t, "pnl < -10", rules[1].Condition)
+}
+
+func TestGetAlert(t *testing.T) {
+	handlers := &AlertHandlers{Context: &Context{esAlert: &DummyEsAlert{Rules: testAlertRules()}}}
+	req, err := http.NewRequest("GET", testAlertURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("name")
+	c.SetParamValues("loss")
+	handlers.GetAlert(c)
+	var rule es.AlertRule
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &rule))
+	assert.Equal(t, "loss", rule.Name)
+}
+
+func TestDeleteAlert(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: testAlertRules()}
+	handlers := &AlertHandlers{Context: &Context{esAlert: esAlert}}
+	req, err := http.NewRequest("DELETE", testAlertURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("name")
+	c.SetParamValues("low")
+	handlers.DeleteAlert(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, 4, len(esAlert.Rules))
+	assert.Equal(t, "loss", esAlert.Rules[0].Name)
+}
+
+func TestMuteAlert(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: testAlertRules()}
+	handlers := &AlertHandlers{
+		Context: &Context{
+			sh:        &AlertSchemaDecoder{Mute: MuteParams{Until: "2016-12-20"}},
+			validator: &DummyStructValidator{},
+			esAlert:   esAlert,
+		},
+	}
+	req, err := http.NewRequest("PUT", testAlertMuteURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("name")
+	c.SetParamValues("low")
+	handlers.MuteAlert(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, time.Date(2016, time.December, 20, 0, 0, 0, 0, time.UTC), *esAlert.Rules[0].MutedUntil)
+
+	req, err = http.NewRequest("DELETE", testAlertMuteURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp = createEcho(req)
+	c.SetParamNames("name")
+	c.SetParamValues("low")
+	handlers.UnmuteAlert(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Nil(t, esAlert.Rules[0].MutedUntil)
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: testAlertRules()}
+	channel := &RecordChannel{}
+	handlers := &AlertHandlers{Context: createAlertContext(esAlert, channel), now: getTestDate}
+	req, err := http.NewRequest("POST", testAlertEvaluateURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.EvaluateAlerts(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var events []es.AlertEvent
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &events))
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, "low", events[0].Name)
+	assert.Equal(t, map[string]float64{"close": 299}, events[0].Values)
+	assert.True(t, getSeriesTestDate().Equal(events[0].Date))
+	assert.Equal(t, "loss", events[1].Name)
+	assert.InDelta(t, -25.25, events[1].Values[alerts.VariablePnL], 1e-9)
+	assert.Equal(t, "muted", events[2].Name)
+	assert.True(t, events[2].Muted)
+	assert.Equal(t, []string{"low", "loss"}, channel.Rules)
+	assert.Equal(t, 2, len(channel.Events))
+	assert.Equal(t, 3, len(esAlert.Events))
+	triggered := []bool{true, true, true, false, false}
+	for i, rule := range esAlert.Rules {
+		assert.Equal(t, triggered[i], rule.Triggered, rule.Name)
+		assert.Equal(t, rule.Symbol == "CW8.PA", rule.LastDate != nil, rule.Name)
+	}
+
+	c, resp = createEcho(req)
+	handlers.EvaluateAlerts(c)
+	assert.Equal(t, "[]", resp.Body.String())
+	assert.Equal(t, 2, len(channel.Events))
+}
+
+func TestEvaluateSymbolAlerts(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: testAlertRules()}
+	channel := &RecordChannel{}
+	events, err := evaluateSymbolAlerts(context.Background(), createAlertContext(esAlert, channel), "TEST2", getTestDate())
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(events))
+	assert.Equal(t, 0, len(channel.Events))
+	events, err = evaluateSymbolAlerts(context.Background(), createAlertContext(esAlert, channel), "CW8.PA", getTestDate())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, 2, len(channel.Events))
+}
+
+func TestGetAlertHistory(t *testing.T) {
+	var historyTests = []struct {
+		params        AlertHistoryParams
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			AlertHistoryParams{},
+			getSeriesTestDate().AddDate(0, 0, -alertHistoryDays),
+			getSeriesTestDate(),
+		},
+		{
+			AlertHistoryParams{Name: "low", DateRangeParams: DateRangeParams{From: "2016-01-04", To: "2016-06-30"}},
+			time.Date(2016, time.January, 4, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.June, 30, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range historyTests {
+		esAlert := &DummyEsAlert{Events: []es.AlertEvent{{Name: "low", Symbol: "CW8.PA", Values: map[string]float64{"close": 299}}}}
+		handlers := &AlertHandlers{
+			Context: &Context{
+				sh:        &AlertSchemaDecoder{History: tt.params},
+				validator: &DummyStructValidator{},
+				esAlert:   esAlert,
+			},
+			getDate: getTestDate,
+		}
+		req, err := http.NewRequest("GET", testAlertHistoryURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		handlers.GetAlertHistory(c)
+		var events []es.AlertEvent
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &events))
+		assert.Equal(t, 1, len(events))
+		assert.Equal(t, tt.params.Name, esAlert.Name)
+		assert.Equal(t, tt.expectedStart, esAlert.Start)
+		assert.Equal(t, tt.expectedEnd, esAlert.End)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// alertQueueSize is the number of symbols which can wait for the evaluation of their alert rules
+const alertQueueSize = 1024
+
+// alertQueue evaluates in the background the alert rules of the symbols ingested by the routes, so that the
+// notifications neither delay nor fail the responses
+//
+// A symbol waiting for its evaluation is queued only once, a symbol is dropped when the queue is full or closed.
+type alertQueue struct {
+	context *Context
+	now     GetDateFunc
+	mutex   sync.Mutex
+	pending map[string]bool
+	closed  bool
+	symbols chan string
+	done    chan struct{}
+}
+
+func newAlertQueue(context *Context, now GetDateFunc, size int) *alertQueue {
+	return &alertQueue{
+		context: context,
+		now:     now,
+		pending: map[string]bool{},
+		symbols: make(chan string, size),
+		done:    make(chan struct{}),
+	}
+}
+
+// push queues the evaluation of the alert rules of a symbol
+func (queue *alertQueue) push(symbol string) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if queue.closed || queue.pending[symbol] {
+		return
+	}
+	select {
+	case queue.symbols <- symbol:
+		queue.pending[symbol] = true
+	default:
+		log.WithFields(log.Fields{"symbol": symbol}).Error("Alert queue full")
+	}
+}
+
+// run evaluates the queued symbols one at a time until the queue is closed
+func (queue *alertQueue) run() {
+	defer close(queue.done)
+	for symbol := range queue.symbols {
+		queue.mutex.Lock()
+		delete(queue.pending, symbol)
+		queue.mutex.Unlock()
+		if _, err := evaluateSymbolAlerts(context.Background(), queue.context, symbol, queue.now()); err != nil {
+			log.WithFields(log.Fields{"symbol": symbol, "error": err}).Error("Alert evaluation failed")
+		}
+	}
+}
+
+// close stops the queue, the symbols already queued are still evaluated by run
+func (queue *alertQueue) close() {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	if !queue.closed {
+		queue.closed = true
+		close(queue.symbols)
+	}
+}
+
+// wait blocks until run has evaluated the symbols left in the closed queue
+func (queue *alertQueue) wait() {
+	<-queue.done
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertQueuePush(t *testing.T) {
+	queue := newAlertQueue(&Context{}, getTestDate, 1)
+	queue.push("CW8.PA")
+	queue.push("CW8.PA")
+	queue.push("TEST2")
+	assert.Equal(t, 1, len(queue.symbols))
+	assert.Equal(t, map[string]bool{"CW8.PA": true}, queue.pending)
+}
+
+func TestAlertQueueRun(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: testAlertRules()}
+	channel := &RecordChannel{}
+	queue := newAlertQueue(createAlertContext(esAlert, channel), getTestDate, alertQueueSize)
+	queue.push("CW8.PA")
+	queue.close()
+	queue.run()
+	assert.Equal(t, 2, len(channel.Events))
+	assert.Equal(t, 3, len(esAlert.Events))
+	assert.Equal(t, 0, len(queue.pending))
+}
+
+func TestAlertQueueClose(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: testAlertRules()}
+	channel := &RecordChannel{}
+	queue := newAlertQueue(createAlertContext(esAlert, channel), getTestDate, alertQueueSize)
+	go queue.run()
+	queue.push("CW8.PA")
+	queue.close()
+	queue.close()
+	queue.wait()
+	// the symbols pushed after the close are dropped
+	queue.push("TEST2")
+	assert.Equal(t, 2, len(channel.Events))
+	assert.Equal(t, 0, len(queue.pending))
+}
+
+func TestIndexStockQueuesAlerts(t *testing.T) {
+	context := &Context{historyAPI: &DummyFinanceAPI{}, esStock: &mockEsStock{}}
+	context.alertQueue = newAlertQueue(context, getTestDate, alertQueueSize)
+	assert.Nil(t, indexStock(context, "CW8.PA", getTestDate().AddDate(0, 0, -1), getTestDate()))
+	assert.Equal(t, "CW8.PA", <-context.alertQueue.symbols)
+}
+
+func TestAlertEvaluationsSerialized(t *testing.T) {
+	esAlert := &DummyEsAlert{Rules: []es.AlertRule{testAlertRule}}
+	channel := &RecordChannel{}
+	alertContext := createAlertContext(esAlert, channel)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := evaluateSymbolAlerts(context.Background(), alertContext, "CW8.PA", getTestDate())
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, len(channel.Events))
+	assert.Equal(t, 1, len(esAlert.Events))
+}
@@ -0,0 +1,143 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/portfolio"
+	"github.com/labstack/echo"
+)
+
+var errNoAllocation = errors.New("no target allocation")
+
+// RebalanceParams contains all the parameters for the rebalance route
+type RebalanceParams struct {
+	Cash     float64 `schema:"cash" validate:"gte=0"`
+	MinTrade float64 `schema:"min_trade" validate:"gte=0"`
+	NoSell   bool    `schema:"no_sell"`
+	Currency string  `schema:"currency" validate:"omitempty,len=3"`
+}
+
+// AllocationHandlers handles all requests about target allocation
+type AllocationHandlers struct {
+	*Context
+	now          GetDateFunc
+	errorHandler errorHandlerFunc
+}
+
+// NewAllocationHandlers creates a new allocation handlers object
+func NewAllocationHandlers(context *Context) *AllocationHandlers {
+	return &AllocationHandlers{
+		Context:      context,
+		now:          time.Now,
+		errorHandler: handleError,
+	}
+}
+
+// SetAllocation handles http request to save the user's target allocation
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AllocationHandlers) SetAllocation(c echo.Context) error {
+	allocation := new(es.Allocation)
+	if err := c.Bind(allocation); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if err := handlers.validator.Struct(allocation); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	allocation.Username = defaultUsername
+	if err := handlers.esAlloc.SetAllocation(allocation); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, allocation)
+}
+
+// GetAllocation handles http request to retrieve the user's target allocation
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AllocationHandlers) GetAllocation(c echo.Context) error {
+	allocation, err := handlers.esAlloc.GetAllocation(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, allocation)
+}
+
+// Rebalance handles http request to compute the orders to reach the user's target allocation
+//
+// With a base currency, the prices are converted at the last known exchange rate and the cash is in the base currency.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AllocationHandlers) Rebalance(c echo.Context) error {
+	var params RebalanceParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	allocation, err := handlers.esAlloc.GetAllocation(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	if len(allocation.Targets) == 0 {
+		return handlers.errorHandler(c, http.StatusBadRequest, errNoAllocation)
+	}
+	positions, err := handlers.esPosition.GetPositions(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	holdings := make([]portfolio.Holding, len(positions))
+	prices := map[string]float64{}
+	for i, position := range positions {
+		holdings[i] = portfolio.Holding{Symbol: position.Symbol, Number: position.Number}
+		prices[position.Symbol] = 0
+	}
+	for _, target := range allocation.Targets {
+		for _, symbol := range target.Symbols {
+			prices[symbol] = 0
+		}
+	}
+	symbols := make([]string, 0, len(prices))
+	for symbol := range prices {
+		symbols = append(symbols, symbol)
+	}
+	now := handlers.now()
+	exchange, httpErr := loadExchange(handlers.Context, params.Currency, symbols, nil, now, now)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	for _, symbol := range symbols {
+		quote, err := handlers.quotesAPI.GetQuote(symbol)
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusInternalServerError, &ProviderError{Err: err})
+		}
+		rate, err := exchange.SymbolRate(symbol, now)
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, err)
+		}
+		prices[symbol] = float64(quote.LastTradePriceOnly) * rate
+	}
+	rebalancing, err := portfolio.Rebalance(holdings, prices, allocation.Targets, portfolio.RebalanceOptions{
+		Cash:     params.Cash,
+		MinTrade: params.MinTrade,
+		NoSell:   params.NoSell,
+	})
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	return c.JSON(http.StatusOK, rebalancing)
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	allocationErrorMsg = "allocation_error"
+)
+
+var setAllocationErrorTests = []struct {
+	echo            echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEchoBind{Msg: allocationErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		allocationErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{validator: &ErrorStructValidator{Msg: allocationErrorMsg}},
+		http.StatusBadRequest,
+		allocationErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{
+			esAlloc:   &ErrorEsAllocation{Msg: allocationErrorMsg},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusInternalServerError,
+		allocationErrorMsg,
+	},
+}
+
+func TestSetAllocationErrors(t *testing.T) {
+	for _, tt := range setAllocationErrorTests {
+		handlers := AllocationHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		res := handlers.SetAllocation(tt.echo)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestGetAllocationErrors(t *testing.T) {
+	handlers := AllocationHandlers{
+		Context:      &Context{esAlloc: &ErrorEsAllocation{Msg: allocationErrorMsg}},
+		errorHandler: createErrorHandler(t, http.StatusInternalServerError, allocationErrorMsg),
+	}
+	res := handlers.GetAllocation(&DummyEchoBind{})
+	assert.NotNil(t, res)
+}
+
+var rebalanceTestTargets = &es.Allocation{Targets: []es.AllocationTarget{{Name: "world", Symbols: []string{"CW8.PA"}, Weight: 1}}}
+
+var rebalanceErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: allocationErrorMsg}},
+		http.StatusInternalServerError,
+		allocationErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &RebalanceSchemaDecoder{},
+			validator: &DummyStructValidator{},
+			esAlloc:   &ErrorEsAllocation{Msg: allocationErrorMsg},
+		},
+		http.StatusInternalServerError,
+		allocationErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &RebalanceSchemaDecoder{},
+			validator: &DummyStructValidator{},
+			esAlloc:   &DummyEsAllocation{},
+		},
+		http.StatusBadRequest,
+		errNoAllocation.Error(),
+	},
+	{
+		&Context{
+			sh:         &RebalanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esAlloc:    &DummyEsAllocation{Allocation: rebalanceTestTargets},
+			esPosition: &ErrorEsPosition{Msg: allocationErrorMsg},
+		},
+		http.StatusInternalServerError,
+		allocationErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &RebalanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esAlloc:    &DummyEsAllocation{Allocation: rebalanceTestTargets},
+			esPosition: &DummyEsPosition{},
+			quotesAPI:  &ErrorQuotesAPI{Msg: allocationErrorMsg},
+		},
+		http.StatusInternalServerError,
+		allocationErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &RebalanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esAlloc:    &DummyEsAllocation{Allocation: rebalanceTestTargets},
+			esPosition: &DummyEsPosition{},
+			quotesAPI:  &DummyQuotesAPI{},
+		},
+		http.StatusBadRequest,
+		"no price for CW8.PA",
+	},
+}
+
+func TestRebalanceErrors(t *testing.T) {
+	for _, tt := range rebalanceErrorTests {
+		handlers := AllocationHandlers{
+			Context:      tt.context,
+			now:          getTestDate,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		req, err := http.NewRequest("GET", "http://test.test/allocation/rebalance", nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.Rebalance(c)
+		assert.NotNil(t, res)
+	}
+}
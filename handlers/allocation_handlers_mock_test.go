@@ -0,0 +1,62 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+
+	"github.com/clebi/gofin/es"
+)
+
+type DummyEsAllocation struct {
+	Allocation *es.Allocation
+}
+
+func (allocStock *DummyEsAllocation) SetAllocation(allocation *es.Allocation) error {
+	allocStock.Allocation = allocation
+	return nil
+}
+
+func (allocStock *DummyEsAllocation) GetAllocation(username string) (*es.Allocation, error) {
+	if allocStock.Allocation == nil {
+		return &es.Allocation{Username: username, Targets: []es.AllocationTarget{}}, nil
+	}
+	return allocStock.Allocation, nil
+}
+
+type ErrorEsAllocation struct {
+	Msg string
+}
+
+func (allocStock *ErrorEsAllocation) SetAllocation(allocation *es.Allocation) error {
+	return errors.New(allocStock.Msg)
+}
+
+func (allocStock *ErrorEsAllocation) GetAllocation(username string) (*es.Allocation, error) {
+	return nil, errors.New(allocStock.Msg)
+}
+
+type RebalanceSchemaDecoder struct {
+	Params RebalanceParams
+}
+
+func (decoder *RebalanceSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*RebalanceParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for RebalanceSchemaDecoder")
+	}
+	return nil
+}
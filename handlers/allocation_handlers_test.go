@@ -0,0 +1,125 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	finance "github.com/clebi/yfinance"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	setAllocationData = "{\"targets\":[{\"name\":\"world\",\"symbols\":[\"CW8.PA\"],\"weight\":0.6}," +
+		"{\"name\":\"bonds\",\"symbols\":[\"BND\",\"AGG\"],\"weight\":0.4}]}"
+	setAllocationResult = "{\"username\":\"tester\",\"targets\":[{\"name\":\"world\",\"symbols\":[\"CW8.PA\"],\"weight\":0.6}," +
+		"{\"name\":\"bonds\",\"symbols\":[\"BND\",\"AGG\"],\"weight\":0.4}]}"
+	rebalanceResult = "{\"total\":1100,\"targets\":[" +
+		"{\"name\":\"world\",\"weight\":0.6,\"current_value\":1000,\"current_weight\":0.9090909090909091," +
+		"\"target_value\":660,\"final_value\":700,\"final_weight\":0.6363636363636364}," +
+		"{\"name\":\"bonds\",\"weight\":0.4,\"current_value\":0,\"current_weight\":0," +
+		"\"target_value\":440,\"final_value\":400,\"final_weight\":0.36363636363636365}]," +
+		"\"orders\":[{\"symbol\":\"CW8.PA\",\"number\":-3,\"price\":100,\"amount\":-300}," +
+		"{\"symbol\":\"BND\",\"number\":8,\"price\":50,\"amount\":400}],\"cash_left\":0}"
+)
+
+var allocationQuotesAPI = &IndicatorQuotesAPI{quotes: map[string]*finance.Quote{
+	"CW8.PA": {Symbol: "CW8.PA", LastTradePriceOnly: 100},
+	"BND":    {Symbol: "BND", LastTradePriceOnly: 50},
+	"AGG":    {Symbol: "AGG", LastTradePriceOnly: 25},
+}}
+
+func TestSetAllocation(t *testing.T) {
+	esAlloc := &DummyEsAllocation{}
+	handlers := &AllocationHandlers{
+		Context: &Context{
+			esAlloc:   esAlloc,
+			validator: &DummyStructValidator{},
+		},
+	}
+	req, err := http.NewRequest("PUT", "http://test.test/allocation", bytes.NewBufferString(setAllocationData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.SetAllocation(c)
+	assert.Equal(t, setAllocationResult, resp.Body.String())
+	assert.Equal(t, "tester", esAlloc.Allocation.Username)
+}
+
+func TestGetAllocation(t *testing.T) {
+	handlers := &AllocationHandlers{Context: &Context{esAlloc: &DummyEsAllocation{}}}
+	req, err := http.NewRequest("GET", "http://test.test/allocation", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetAllocation(c)
+	assert.Equal(t, "{\"username\":\"tester\",\"targets\":[]}", resp.Body.String())
+}
+
+func TestRebalance(t *testing.T) {
+	handlers := &AllocationHandlers{
+		Context: &Context{
+			sh:        &RebalanceSchemaDecoder{Params: RebalanceParams{Cash: 100}},
+			validator: &DummyStructValidator{},
+			quotesAPI: allocationQuotesAPI,
+			esAlloc: &DummyEsAllocation{Allocation: &es.Allocation{Targets: []es.AllocationTarget{
+				{Name: "world", Symbols: []string{"CW8.PA"}, Weight: 0.6},
+				{Name: "bonds", Symbols: []string{"BND", "AGG"}, Weight: 0.4},
+			}}},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "CW8.PA", Number: 10, Cost: 900}}},
+		},
+		now: getTestDate,
+	}
+	req, err := http.NewRequest("GET", "http://test.test/allocation/rebalance?cash=100", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.Rebalance(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, rebalanceResult, resp.Body.String())
+}
+
+func TestRebalanceCurrency(t *testing.T) {
+	handlers := &AllocationHandlers{
+		Context: &Context{
+			sh:        &RebalanceSchemaDecoder{Params: RebalanceParams{Cash: 100, Currency: "USD"}},
+			validator: &DummyStructValidator{},
+			quotesAPI: allocationQuotesAPI,
+			esFx:      &DummyEsFx{Rates: []es.FxRate{{From: "EUR", To: "USD", Date: testBarDate("2016-12-12"), Rate: 1.2}}},
+			esAlloc: &DummyEsAllocation{Allocation: &es.Allocation{Targets: []es.AllocationTarget{
+				{Name: "world", Symbols: []string{"CW8.PA"}, Weight: 0.6},
+				{Name: "bonds", Symbols: []string{"BND", "AGG"}, Weight: 0.4},
+			}}},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "CW8.PA", Number: 10, Cost: 900}}},
+		},
+		now: getTestDate,
+	}
+	req, err := http.NewRequest("GET", "http://test.test/allocation/rebalance?cash=100&currency=USD", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.Rebalance(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "{\"total\":1300,")
+	assert.Contains(t, resp.Body.String(), "{\"symbol\":\"CW8.PA\",\"number\":-3,\"price\":120,\"amount\":-360}")
+}
@@ -0,0 +1,420 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/analytics"
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+)
+
+const defaultConfidence = 0.95
+
+// Defaults of the simulation route, the contributions are monthly when their period is not given
+const (
+	defaultSimulationPaths = 1000
+	defaultContribution    = 21
+)
+
+// Defaults of the frontier route, the weights are between 0 and 1 or between -1 and 1 with short positions
+const (
+	defaultFrontierPoints = 20
+	shortWeight           = -1
+)
+
+var errNoHoldings = errors.New("no holdings to simulate")
+
+// AnalyticsParams contains all the parameters for the analytics route
+//
+// RiskFree is an annual rate and Confidence the one of the value at risk, 95% when it is not given.
+type AnalyticsParams struct {
+	Days       int     `schema:"days" validate:"gt=0"`
+	RiskFree   float64 `schema:"risk_free" validate:"gte=0,lt=1"`
+	Confidence float64 `schema:"confidence" validate:"omitempty,gt=0,lt=1"`
+}
+
+// CorrelationParams contains all the parameters for the correlation route
+type CorrelationParams struct {
+	Days    int      `schema:"days" validate:"gt=0"`
+	Symbols []string `schema:"symbols" validate:"min=2"`
+}
+
+// CorrelationReport contains the covariance and correlation matrices of the daily returns of symbols
+//
+// Rows and columns are in the order of the symbols, a value is null when a pair has less than two returns in common.
+type CorrelationReport struct {
+	Symbols      []string     `json:"symbols"`
+	Start        time.Time    `json:"start"`
+	End          time.Time    `json:"end"`
+	Covariance   [][]*float64 `json:"covariance"`
+	Correlation  [][]*float64 `json:"correlation"`
+	Observations [][]int      `json:"observations"`
+}
+
+// SimulationParams contains all the parameters for the simulation route
+//
+// Days is the history the daily returns are drawn from and Horizon the number of trading days to simulate.
+// The contribution is added every Every trading days. Without currency, the values of the holdings are
+// summed in their own currencies.
+type SimulationParams struct {
+	Days         int     `schema:"days" validate:"gt=0"`
+	Horizon      int     `schema:"horizon" validate:"gt=0"`
+	Paths        int     `schema:"paths" validate:"gte=0,lte=100000"`
+	Contribution float64 `schema:"contribution" validate:"gte=0"`
+	Every        int     `schema:"every" validate:"gte=0"`
+	Target       float64 `schema:"target" validate:"gte=0"`
+	Seed         int64   `schema:"seed"`
+	Currency     string  `schema:"currency" validate:"omitempty,len=3"`
+}
+
+// SimulationReport contains the result of a simulation of the current holdings, the period the returns were
+// drawn from and the weights of the holdings
+type SimulationReport struct {
+	*analytics.SimulationResult
+	Start        time.Time          `json:"start"`
+	End          time.Time          `json:"end"`
+	Observations int                `json:"observations"`
+	Weights      map[string]float64 `json:"weights"`
+}
+
+// FrontierParams contains all the parameters for the frontier route
+//
+// Bounds are the limits of the weights of some symbols, as SYMBOL:MIN:MAX where an empty limit keeps its
+// default. Without currency, the values of the current holdings are in their own currencies.
+type FrontierParams struct {
+	Days     int      `schema:"days" validate:"gt=0"`
+	Symbols  []string `schema:"symbols" validate:"min=2"`
+	RiskFree float64  `schema:"risk_free" validate:"gte=0,lt=1"`
+	Points   int      `schema:"points" validate:"gte=0,lte=100"`
+	Short    bool     `schema:"short"`
+	Bounds   []string `schema:"bounds"`
+	Currency string   `schema:"currency" validate:"omitempty,len=3"`
+}
+
+// weightBounds parses the bounds of the weights of the symbols
+func (params FrontierParams) weightBounds() ([]float64, []float64, error) {
+	lower := make([]float64, len(params.Symbols))
+	upper := make([]float64, len(params.Symbols))
+	indexes := map[string]int{}
+	for i, symbol := range params.Symbols {
+		if params.Short {
+			lower[i] = shortWeight
+		}
+		upper[i] = 1
+		indexes[symbol] = i
+	}
+	for _, bound := range params.Bounds {
+		fields := strings.Split(bound, ":")
+		if len(fields) != 3 {
+			return nil, nil, invalidParam("bounds", fmt.Errorf("bad bounds: %s", bound))
+		}
+		i, ok := indexes[fields[0]]
+		if !ok {
+			return nil, nil, invalidParam("bounds", fmt.Errorf("bounds of an unknown symbol: %s", fields[0]))
+		}
+		for k, limit := range []*float64{&lower[i], &upper[i]} {
+			if fields[k+1] == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[k+1], 64)
+			if err != nil {
+				return nil, nil, invalidParam("bounds", fmt.Errorf("bad bounds: %s", bound))
+			}
+			*limit = value
+		}
+	}
+	return lower, upper, nil
+}
+
+// FrontierReport contains the efficient frontier of symbols with its minimum variance and maximum Sharpe
+// portfolios, and the current portfolio of the user on the same symbols when there is one
+//
+// Weights, returns and volatilities are in the order of the symbols, returns and volatilities are annual.
+type FrontierReport struct {
+	Symbols      []string                   `json:"symbols"`
+	Start        time.Time                  `json:"start"`
+	End          time.Time                  `json:"end"`
+	Observations int                        `json:"observations"`
+	Returns      []float64                  `json:"returns"`
+	Volatilities []float64                  `json:"volatilities"`
+	MinVariance  analytics.PortfolioPoint   `json:"min_variance"`
+	MaxSharpe    analytics.PortfolioPoint   `json:"max_sharpe"`
+	Frontier     []analytics.PortfolioPoint `json:"frontier"`
+	Current      *analytics.PortfolioPoint  `json:"current,omitempty"`
+}
+
+// AnalyticsHandlers handles all requests about risk and return statistics of stocks
+type AnalyticsHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	errorHandler errorHandlerFunc
+	indexStock   indexStockFunc
+}
+
+// NewAnalyticsHandlers creates a new analytics handlers object
+func NewAnalyticsHandlers(context *Context) *AnalyticsHandlers {
+	return &AnalyticsHandlers{
+		Context:      context,
+		getDate:      getYesterDayDate,
+		errorHandler: handleError,
+		indexStock:   indexStock,
+	}
+}
+
+// GetAnalytics handles http request to compute the risk and return statistics of a stock over the last days
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AnalyticsHandlers) GetAnalytics(c echo.Context) error {
+	var params AnalyticsParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	if params.Confidence == 0 {
+		params.Confidence = defaultConfidence
+	}
+	symbol := c.Param("symbol")
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	bars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, end.AddDate(0, 0, -params.Days), end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	report, err := analytics.Analyze(bars, params.RiskFree, params.Confidence)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	report.Symbol = symbol
+	return c.JSON(http.StatusOK, report)
+}
+
+func nullableMatrix(matrix [][]float64) [][]*float64 {
+	values := make([][]*float64, len(matrix))
+	for i, row := range matrix {
+		values[i] = make([]*float64, len(row))
+		for j, value := range row {
+			values[i][j] = nullable(value)
+		}
+	}
+	return values
+}
+
+// GetCorrelation handles http request to compute the covariance and correlation of the returns of stocks over the last days
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AnalyticsHandlers) GetCorrelation(c echo.Context) error {
+	var params CorrelationParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -params.Days)
+	bars := make([][]es.StockBar, len(params.Symbols))
+	for i, symbol := range params.Symbols {
+		var httpErr *HandlerERROR
+		if bars[i], httpErr = loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, start, end); httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+	}
+	matrices := analytics.ReturnMatrices(bars)
+	return c.JSON(http.StatusOK, CorrelationReport{
+		Symbols:      params.Symbols,
+		Start:        start,
+		End:          end,
+		Covariance:   nullableMatrix(matrices.Covariance),
+		Correlation:  nullableMatrix(matrices.Correlation),
+		Observations: matrices.Observations,
+	})
+}
+
+// Simulate handles http request to simulate the future value of the current holdings with a Monte Carlo bootstrap
+//
+// The holdings keep their current weights and each simulated day draws a past daily return of the portfolio.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AnalyticsHandlers) Simulate(c echo.Context) error {
+	var params SimulationParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	if params.Paths == 0 {
+		params.Paths = defaultSimulationPaths
+	}
+	if params.Every == 0 && params.Contribution > 0 {
+		params.Every = defaultContribution
+	}
+	positions, err := handlers.esPosition.GetPositions(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	var symbols []string
+	numbers := map[string]int{}
+	for _, position := range positions {
+		if position.Number > 0 {
+			symbols = append(symbols, position.Symbol)
+			numbers[position.Symbol] = position.Number
+		}
+	}
+	if len(symbols) == 0 {
+		return handlers.errorHandler(c, http.StatusBadRequest, errNoHoldings)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -params.Days)
+	exchange, httpErr := loadExchange(handlers.Context, params.Currency, symbols, nil, end, end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	bars := make([][]es.StockBar, len(symbols))
+	values := make([]float64, len(symbols))
+	var total float64
+	for i, symbol := range symbols {
+		if bars[i], httpErr = loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, start, end); httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+		if len(bars[i]) == 0 {
+			return handlers.errorHandler(c, http.StatusBadRequest, fmt.Errorf("no price for %s", symbol))
+		}
+		rate, err := exchange.SymbolRate(symbol, end)
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, err)
+		}
+		values[i] = float64(numbers[symbol]) * bars[i][len(bars[i])-1].Close * rate
+		total += values[i]
+	}
+	report := SimulationReport{Start: start, End: end, Weights: map[string]float64{}}
+	weights := make([]float64, len(symbols))
+	for i, symbol := range symbols {
+		if total > 0 {
+			weights[i] = values[i] / total
+		}
+		report.Weights[symbol] = weights[i]
+	}
+	returns := analytics.PortfolioReturns(bars, weights)
+	report.Observations = len(returns)
+	report.SimulationResult, err = analytics.MonteCarlo(returns, total, analytics.Simulation{
+		Paths:        params.Paths,
+		Horizon:      params.Horizon,
+		Contribution: params.Contribution,
+		Every:        params.Every,
+		Target:       params.Target,
+		Seed:         params.Seed,
+	})
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// currentWeights computes the weights of the holdings of the user in the symbols at their last close,
+// nil when the user holds none of them
+func currentWeights(context *Context, symbols []string, bars [][]es.StockBar, currency string, date time.Time) ([]float64, *HandlerERROR) {
+	positions, err := context.esPosition.GetPositions(defaultUsername)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	numbers := map[string]int{}
+	for _, position := range positions {
+		numbers[position.Symbol] = position.Number
+	}
+	exchange, httpErr := loadExchange(context, currency, symbols, nil, date, date)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	weights := make([]float64, len(symbols))
+	var total float64
+	for i, symbol := range symbols {
+		if numbers[symbol] == 0 || len(bars[i]) == 0 {
+			continue
+		}
+		rate, err := exchange.SymbolRate(symbol, date)
+		if err != nil {
+			return nil, &HandlerERROR{error: err, Status: http.StatusBadRequest}
+		}
+		weights[i] = float64(numbers[symbol]) * bars[i][len(bars[i])-1].Close * rate
+		total += weights[i]
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights, nil
+}
+
+// GetFrontier handles http request to compute the efficient frontier of stocks over the last days
+//
+// The weights of the portfolios sum to one within their bounds. The current holdings of the user in the
+// symbols are compared to the frontier, the ones in other symbols are left out.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *AnalyticsHandlers) GetFrontier(c echo.Context) error {
+	var params FrontierParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	if params.Points == 0 {
+		params.Points = defaultFrontierPoints
+	}
+	lower, upper, err := params.weightBounds()
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -params.Days)
+	bars := make([][]es.StockBar, len(params.Symbols))
+	for i, symbol := range params.Symbols {
+		var httpErr *HandlerERROR
+		if bars[i], httpErr = loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, start, end); httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+	}
+	returns, covariance, observations, err := analytics.EstimateReturns(bars)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	optimizer, err := analytics.NewOptimizer(returns, covariance, lower, upper, params.RiskFree)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	report := FrontierReport{
+		Symbols:      params.Symbols,
+		Start:        start,
+		End:          end,
+		Observations: observations,
+		Returns:      returns,
+		Volatilities: make([]float64, len(returns)),
+		MinVariance:  optimizer.MinVariance(),
+		MaxSharpe:    optimizer.MaxSharpe(),
+		Frontier:     optimizer.Frontier(params.Points),
+	}
+	for i := range covariance {
+		report.Volatilities[i] = math.Sqrt(covariance[i][i])
+	}
+	weights, httpErr := currentWeights(handlers.Context, params.Symbols, bars, params.Currency, end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	if weights != nil {
+		current := optimizer.Point(weights)
+		report.Current = &current
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,363 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/analytics"
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const analyticsErrorMsg = "analytics_error"
+
+var analyticsErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: analyticsErrorMsg}},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &AnalyticsSchemaDecoder{Days: 10}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		analyticsErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, analyticsErrorMsg),
+	},
+	{
+		&Context{
+			sh:        &AnalyticsSchemaDecoder{Days: 10},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: analyticsErrorMsg},
+		},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &AnalyticsSchemaDecoder{Days: 10},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100, 110),
+			}},
+		},
+		http.StatusBadRequest,
+		"not enough data",
+		testIndexStockNoError,
+	},
+}
+
+func TestGetAnalyticsErrors(t *testing.T) {
+	for _, tt := range analyticsErrorTests {
+		handlers := AnalyticsHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testGetAnalyticsURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST1")
+		res := handlers.GetAnalytics(c)
+		assert.NotNil(t, res)
+	}
+}
+
+var correlationErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: analyticsErrorMsg}},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &CorrelationSchemaDecoder{Days: 10, Symbols: []string{"A", "B"}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		analyticsErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, analyticsErrorMsg),
+	},
+	{
+		&Context{
+			sh:        &CorrelationSchemaDecoder{Days: 10, Symbols: []string{"A", "B"}},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: analyticsErrorMsg},
+		},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+}
+
+func TestGetCorrelationErrors(t *testing.T) {
+	for _, tt := range correlationErrorTests {
+		handlers := AnalyticsHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testGetCorrelationURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.GetCorrelation(c)
+		assert.NotNil(t, res)
+	}
+}
+
+var testSimulationParams = SimulationParams{Days: 10, Horizon: 10}
+
+var simulationErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: analyticsErrorMsg}},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &SimulationSchemaDecoder{Params: testSimulationParams},
+			validator:  &DummyStructValidator{},
+			esPosition: &ErrorEsPosition{Msg: analyticsErrorMsg},
+		},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &SimulationSchemaDecoder{Params: testSimulationParams},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "TEST1", Number: 0}}},
+		},
+		http.StatusBadRequest,
+		errNoHoldings.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &SimulationSchemaDecoder{Params: SimulationParams{Days: 10, Horizon: 10, Currency: "EUR"}},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "TEST1", Number: 1}}},
+			esFx:       &ErrorEsFx{Msg: analyticsErrorMsg},
+		},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &SimulationSchemaDecoder{Params: testSimulationParams},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "TEST1", Number: 1}}},
+		},
+		http.StatusBadRequest,
+		analyticsErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, analyticsErrorMsg),
+	},
+	{
+		&Context{
+			sh:         &SimulationSchemaDecoder{Params: testSimulationParams},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "TEST1", Number: 1}}},
+			esStock:    &BarsEsStock{bars: map[string][]es.StockBar{}},
+		},
+		http.StatusBadRequest,
+		"no price for TEST1",
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &SimulationSchemaDecoder{Params: testSimulationParams},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "TEST1", Number: 1}}},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100),
+			}},
+		},
+		http.StatusBadRequest,
+		analytics.ErrNotEnoughData.Error(),
+		testIndexStockNoError,
+	},
+}
+
+func TestSimulateErrors(t *testing.T) {
+	for _, tt := range simulationErrorTests {
+		handlers := AnalyticsHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testSimulationURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.Simulate(c)
+		assert.NotNil(t, res)
+	}
+}
+
+var testFrontierParams = FrontierParams{Days: 10, Symbols: []string{"TEST1", "TEST2"}}
+
+func frontierBars() *BarsEsStock {
+	return &BarsEsStock{bars: map[string][]es.StockBar{
+		"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100, 110, 99, 108.9),
+		"TEST2": createIndicatorBars("TEST2", getSeriesTestDate(), 50, 52, 51, 53),
+	}}
+}
+
+var frontierErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: analyticsErrorMsg}},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &FrontierSchemaDecoder{Params: FrontierParams{Days: 10, Symbols: []string{"A", "B"}, Bounds: []string{"A:1"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad bounds: A:1",
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &FrontierSchemaDecoder{Params: FrontierParams{Days: 10, Symbols: []string{"A", "B"}, Bounds: []string{"A:x:1"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad bounds: A:x:1",
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &FrontierSchemaDecoder{Params: FrontierParams{Days: 10, Symbols: []string{"A", "B"}, Bounds: []string{"C:0:1"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bounds of an unknown symbol: C",
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &FrontierSchemaDecoder{Params: testFrontierParams}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		analyticsErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, analyticsErrorMsg),
+	},
+	{
+		&Context{
+			sh:        &FrontierSchemaDecoder{Params: testFrontierParams},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: analyticsErrorMsg},
+		},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &FrontierSchemaDecoder{Params: testFrontierParams},
+			validator: &DummyStructValidator{},
+			esStock:   &BarsEsStock{bars: map[string][]es.StockBar{}},
+		},
+		http.StatusBadRequest,
+		analytics.ErrNotEnoughData.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh: &FrontierSchemaDecoder{Params: FrontierParams{
+				Days:    10,
+				Symbols: []string{"TEST1", "TEST2"},
+				Bounds:  []string{"TEST1:0.8:", "TEST2:0.8:"},
+			}},
+			validator: &DummyStructValidator{},
+			esStock:   frontierBars(),
+		},
+		http.StatusBadRequest,
+		analytics.ErrBadBounds.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &FrontierSchemaDecoder{Params: testFrontierParams},
+			validator:  &DummyStructValidator{},
+			esStock:    frontierBars(),
+			esPosition: &ErrorEsPosition{Msg: analyticsErrorMsg},
+		},
+		http.StatusInternalServerError,
+		analyticsErrorMsg,
+		testIndexStockNoError,
+	},
+}
+
+func TestFrontierWeightBoundsErrors(t *testing.T) {
+	for _, bound := range []string{"A:1", "A:x:1", "C:0:1"} {
+		params := FrontierParams{Symbols: []string{"A", "B"}, Bounds: []string{bound}}
+		_, _, err := params.weightBounds()
+		if paramErr, ok := err.(*InvalidParamError); assert.True(t, ok) {
+			assert.Equal(t, "bounds", paramErr.Params[0].Name)
+		}
+	}
+}
+
+func TestGetFrontierErrors(t *testing.T) {
+	for _, tt := range frontierErrorTests {
+		handlers := AnalyticsHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testFrontierURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.GetFrontier(c)
+		assert.NotNil(t, res)
+	}
+}
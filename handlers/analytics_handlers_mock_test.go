@@ -0,0 +1,74 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import "errors"
+
+type AnalyticsSchemaDecoder struct {
+	Days       int
+	RiskFree   float64
+	Confidence float64
+}
+
+func (decoder *AnalyticsSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*AnalyticsParams); ok {
+		params.Days = decoder.Days
+		params.RiskFree = decoder.RiskFree
+		params.Confidence = decoder.Confidence
+	} else {
+		return errors.New("bad type for AnalyticsSchemaDecoder")
+	}
+	return nil
+}
+
+type CorrelationSchemaDecoder struct {
+	Days    int
+	Symbols []string
+}
+
+func (decoder *CorrelationSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*CorrelationParams); ok {
+		params.Days = decoder.Days
+		params.Symbols = decoder.Symbols
+	} else {
+		return errors.New("bad type for CorrelationSchemaDecoder")
+	}
+	return nil
+}
+
+type SimulationSchemaDecoder struct {
+	Params SimulationParams
+}
+
+func (decoder *SimulationSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*SimulationParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for SimulationSchemaDecoder")
+	}
+	return nil
+}
+
+type FrontierSchemaDecoder struct {
+	Params FrontierParams
+}
+
+func (decoder *FrontierSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*FrontierParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for FrontierSchemaDecoder")
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/analytics"
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testGetAnalyticsURL   = "http://test.test/analytics/TEST1"
+	testGetCorrelationURL = "http://test.test/analytics/correlation"
+	testSimulationURL     = "http://test.test/analytics/simulation"
+	testFrontierURL       = "http://test.test/analytics/frontier"
+)
+
+func TestGetAnalytics(t *testing.T) {
+	handlers := &AnalyticsHandlers{
+		Context: &Context{
+			sh:        &AnalyticsSchemaDecoder{Days: 10},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100, 110, 99, 108.9),
+			}},
+		},
+		getDate:    getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testGetAnalyticsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("symbol")
+	c.SetParamValues("TEST1")
+	handlers.GetAnalytics(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var report analytics.Report
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &report))
+	assert.Equal(t, "TEST1", report.Symbol)
+	assert.Equal(t, 3, report.Observations)
+	assert.Equal(t, 0.95, report.Confidence)
+	assert.InDelta(t, 1.833030, report.Volatility, 1e-6)
+	assert.InDelta(t, 4.582576, report.Sharpe, 1e-6)
+	assert.InDelta(t, 0.1, report.MaxDrawdown.Depth, 1e-9)
+	assert.Nil(t, report.MaxDrawdown.Recovery)
+}
+
+func TestGetCorrelation(t *testing.T) {
+	handlers := &AnalyticsHandlers{
+		Context: &Context{
+			sh:        &CorrelationSchemaDecoder{Days: 10, Symbols: []string{"TEST1", "TEST2", "TEST3"}},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100, 110, 99, 108.9),
+				"TEST2": createIndicatorBars("TEST2", getSeriesTestDate(), 10, 9, 10, 9),
+				"TEST3": createIndicatorBars("TEST3", getSeriesTestDate(), 10),
+			}},
+		},
+		getDate:    getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testGetCorrelationURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetCorrelation(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var report CorrelationReport
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &report))
+	assert.Equal(t, []string{"TEST1", "TEST2", "TEST3"}, report.Symbols)
+	assert.Equal(t, getSeriesTestDate(), report.End)
+	assert.Equal(t, []int{3, 3, 0}, report.Observations[0])
+	assert.InDelta(t, 1, *report.Correlation[0][0], 1e-9)
+	assert.InDelta(t, -1, *report.Correlation[0][1], 1e-9)
+	assert.InDelta(t, 0.013333, *report.Covariance[0][0], 1e-6)
+	assert.Nil(t, report.Correlation[2][0])
+	assert.Nil(t, report.Covariance[2][2])
+}
+
+func TestSimulate(t *testing.T) {
+	handlers := &AnalyticsHandlers{
+		Context: &Context{
+			sh:        &SimulationSchemaDecoder{Params: SimulationParams{Days: 10, Horizon: 42, Contribution: 100, Target: 1000}},
+			validator: &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{
+				{Symbol: "TEST1", Number: 10},
+				{Symbol: "TEST2", Number: 5},
+				{Symbol: "SOLD", Number: 0},
+			}},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100, 110, 99, 108.9),
+				"TEST2": createIndicatorBars("TEST2", getSeriesTestDate(), 50, 55, 49.5, 54.45),
+			}},
+		},
+		getDate:    getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testSimulationURL, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c, resp := createEcho(req)
+	handlers.Simulate(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var report struct {
+		analytics.SimulationResult
+		Observations int                `json:"observations"`
+		Weights      map[string]float64 `json:"weights"`
+	}
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &report))
+	assert.InDelta(t, 1361.25, report.Initial, 1e-9)
+	assert.Equal(t, defaultSimulationPaths, report.Paths)
+	assert.Equal(t, 42, report.Horizon)
+	assert.Equal(t, 200.0, report.Contributed)
+	assert.Equal(t, 3, report.Observations)
+	assert.InDelta(t, 0.8, report.Weights["TEST1"], 1e-9)
+	assert.InDelta(t, 0.2, report.Weights["TEST2"], 1e-9)
+	assert.Equal(t, 2, len(report.Weights))
+	assert.Equal(t, len(analytics.SimulationPercentiles), len(report.Terminal))
+	assert.NotNil(t, report.Probability)
+}
+
+func createFrontierContext(params FrontierParams) *Context {
+	return &Context{
+		sh:        &FrontierSchemaDecoder{Params: params},
+		validator: &DummyStructValidator{},
+		esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{
+			{Symbol: "TEST1", Number: 10},
+			{Symbol: "OTHER", Number: 5},
+		}},
+		esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+			"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 100, 110, 99, 108.9, 119.79),
+			"TEST2": createIndicatorBars("TEST2", getSeriesTestDate(), 50, 52, 51, 53, 54),
+		}},
+	}
+}
+
+func TestGetFrontier(t *testing.T) {
+	var frontierTests = []struct {
+		params   FrontierParams
+		maxFirst float64
+	}{
+		{FrontierParams{Days: 10, Symbols: []string{"TEST1", "TEST2"}, Points: 5}, 1},
+		{FrontierParams{Days: 10, Symbols: []string{"TEST1", "TEST2"}, Points: 5, Bounds: []string{"TEST1::0.3"}}, 0.3},
+	}
+	for _, tt := range frontierTests {
+		handlers := &AnalyticsHandlers{
+			Context:    createFrontierContext(tt.params),
+			getDate:    getTestDate,
+			indexStock: testIndexStockNoError,
+		}
+		req, err := http.NewRequest("GET", testFrontierURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, resp := createEcho(req)
+		handlers.GetFrontier(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var report FrontierReport
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &report))
+		assert.Equal(t, []string{"TEST1", "TEST2"}, report.Symbols)
+		assert.Equal(t, 4, report.Observations)
+		assert.Equal(t, 2, len(report.Returns))
+		assert.Equal(t, 5, len(report.Frontier))
+		for _, point := range append(report.Frontier, report.MinVariance, report.MaxSharpe) {
+			assert.InDelta(t, 1, point.Weights[0]+point.Weights[1], 1e-9)
+			assert.True(t, point.Weights[0] <= tt.maxFirst+1e-9)
+			assert.True(t, point.Weights[0] >= -1e-9)
+			assert.True(t, point.Volatility >= report.MinVariance.Volatility-1e-9)
+		}
+		assert.Equal(t, []float64{1, 0}, report.Current.Weights)
+		assert.InDelta(t, report.Returns[0], report.Current.Return, 1e-9)
+	}
+}
+
+func TestWeightBounds(t *testing.T) {
+	params := FrontierParams{Symbols: []string{"A", "B", "C"}, Short: true, Bounds: []string{"A:0.1:0.5", "C::0.2"}}
+	lower, upper, err := params.weightBounds()
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{0.1, -1, -1}, lower)
+	assert.Equal(t, []float64{0.5, 1, 0.2}, upper)
+	params = FrontierParams{Symbols: []string{"A", "B"}}
+	lower, upper, err = params.weightBounds()
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{0, 0}, lower)
+	assert.Equal(t, []float64{1, 1}, upper)
+}
@@ -0,0 +1,363 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/clebi/gofin/backtest"
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+)
+
+// Strategies of the backtest route
+const (
+	strategyBuyAndHold = "buy_and_hold"
+	strategyRules      = "rules"
+)
+
+// defaultBacktestCash is the initial cash of a backtest when it is not given
+const defaultBacktestCash = 10000
+
+// Search methods of the optimize route
+const (
+	methodGrid   = "grid"
+	methodRandom = "random"
+)
+
+// Defaults of the optimize route
+const (
+	defaultOptimizeSamples = 100
+	defaultOptimizeTop     = 10
+)
+
+var errNoEntry = errors.New("entry is required for the rules strategy")
+
+// BacktestParams contains all the parameters for the backtest route
+//
+// The rules strategy invests the weight fraction of the equity when the entry condition holds and sells
+// when the exit condition holds, the weight is 1 when it is not given.
+type BacktestParams struct {
+	Symbol   string          `json:"symbol" validate:"required"`
+	Days     int             `json:"days" validate:"gt=0"`
+	Strategy string          `json:"strategy" validate:"eq=buy_and_hold|eq=rules"`
+	Entry    string          `json:"entry"`
+	Exit     string          `json:"exit"`
+	Weight   float64         `json:"weight" validate:"gte=0,lte=1"`
+	Config   backtest.Config `json:"config"`
+}
+
+// newStrategy creates the strategy of the parameters and gives the number of bars it needs to warm up
+func (params BacktestParams) newStrategy() (backtest.Strategy, int, error) {
+	if params.Strategy == strategyBuyAndHold {
+		return &backtest.BuyAndHold{}, 0, nil
+	}
+	if params.Entry == "" {
+		return nil, 0, invalidParam("entry", errNoEntry)
+	}
+	strategy, err := backtest.NewRules(params.Entry, params.Exit, params.Weight)
+	if err != nil {
+		return nil, 0, err
+	}
+	return strategy, strategy.WarmUp(), nil
+}
+
+// BacktestHandlers handles all requests about backtesting strategies
+type BacktestHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	now          GetDateFunc
+	errorHandler errorHandlerFunc
+	indexStock   indexStockFunc
+}
+
+// NewBacktestHandlers creates a new backtest handlers object
+func NewBacktestHandlers(context *Context) *BacktestHandlers {
+	return &BacktestHandlers{
+		Context:      context,
+		getDate:      getYesterDayDate,
+		now:          time.Now,
+		errorHandler: handleError,
+		indexStock:   indexStock,
+	}
+}
+
+// Backtest handles http request to simulate a strategy over the last days of a stock
+//
+// The bars before the period warm the indicators of the strategy up, the result contains the trades,
+// the equity curve and the performance statistics.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *BacktestHandlers) Backtest(c echo.Context) error {
+	params := new(BacktestParams)
+	if err := c.Bind(params); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if params.Config.Cash == 0 {
+		params.Config.Cash = defaultBacktestCash
+	}
+	if params.Weight == 0 {
+		params.Weight = 1
+	}
+	if err := handlers.validator.Struct(params); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	strategy, warmUp, err := params.newStrategy()
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -params.Days)
+	// two calendar days per bar cover the week-ends and the holidays
+	bars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, params.Symbol, start.AddDate(0, 0, -warmUp*2), end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	result, err := backtest.RunFrom(bars, start, strategy, params.Config)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// OptimizeParams contains all the parameters for the optimize route
+//
+// The entry and exit conditions of the rules strategy name the parameters between braces, as in
+// "sma({fast}) > sma({slow})", and the ranges give their values. The grid method tries all the
+// combinations of the values, the random one draws samples of them. The trials are ranked by the metric
+// and the top ones are returned, the optimization is stored when it has a name.
+type OptimizeParams struct {
+	Name        string           `json:"name"`
+	Symbol      string           `json:"symbol" validate:"required"`
+	Days        int              `json:"days" validate:"gt=0"`
+	Entry       string           `json:"entry" validate:"required"`
+	Exit        string           `json:"exit"`
+	Weight      float64          `json:"weight" validate:"gte=0,lte=1"`
+	Ranges      []backtest.Range `json:"ranges" validate:"required,dive"`
+	Method      string           `json:"method" validate:"eq=grid|eq=random"`
+	Samples     int              `json:"samples" validate:"gte=0"`
+	Seed        int64            `json:"seed"`
+	Metric      string           `json:"metric"`
+	Top         int              `json:"top" validate:"gte=0"`
+	WalkForward *backtest.Split  `json:"walk_forward,omitempty"`
+	Config      backtest.Config  `json:"config"`
+}
+
+// parameterSets draws the parameter sets of the search method
+func (params OptimizeParams) parameterSets() ([]backtest.Parameters, error) {
+	if params.Method == methodRandom {
+		return backtest.Random(params.Ranges, params.Samples, params.Seed)
+	}
+	return backtest.Grid(params.Ranges)
+}
+
+// OptimizationResult contains the best trials of an optimization over the whole period and the
+// walk-forward analysis when there was one, Count is the number of trials
+type OptimizationResult struct {
+	Symbol      string                      `json:"symbol"`
+	Metric      string                      `json:"metric"`
+	Method      string                      `json:"method"`
+	Count       int                         `json:"count"`
+	Trials      []backtest.Trial            `json:"trials"`
+	WalkForward *backtest.WalkForwardResult `json:"walk_forward,omitempty"`
+}
+
+// optimizeWarmUp gives the largest warm-up of the strategies of the parameter sets
+//
+// returns the error of the first set when no strategy can be created
+func optimizeWarmUp(factory backtest.StrategyFactory, sets []backtest.Parameters) (int, error) {
+	warmUp, valid := 0, false
+	var firstErr error
+	for _, set := range sets {
+		strategy, err := factory(set)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		valid = true
+		if rules, ok := strategy.(*backtest.Rules); ok && rules.WarmUp() > warmUp {
+			warmUp = rules.WarmUp()
+		}
+	}
+	if !valid {
+		return 0, firstErr
+	}
+	return warmUp, nil
+}
+
+// newOptimization creates the stored optimization of a request and its result
+func newOptimization(params *OptimizeParams, result *OptimizationResult, created time.Time) (*es.Optimization, error) {
+	request, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	response, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	optimization := &es.Optimization{
+		Username: defaultUsername,
+		Name:     params.Name,
+		Symbol:   params.Symbol,
+		Created:  created,
+		Metric:   params.Metric,
+		Request:  request,
+		Result:   response,
+	}
+	if len(result.Trials) > 0 && result.Trials[0].Error == "" {
+		optimization.Parameters = result.Trials[0].Parameters
+		optimization.Score = result.Trials[0].Score
+	}
+	if result.WalkForward != nil {
+		efficiency := result.WalkForward.Efficiency
+		optimization.Efficiency = &efficiency
+	}
+	return optimization, nil
+}
+
+// Optimize handles http request to search the parameters of a rules strategy over the last days of a stock
+//
+// The trials run concurrently on all the processors. With a walk-forward split, the best parameters of
+// each in-sample period are also tested on the out-of-sample period which follows it.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *BacktestHandlers) Optimize(c echo.Context) error {
+	params := new(OptimizeParams)
+	if err := c.Bind(params); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if params.Config.Cash == 0 {
+		params.Config.Cash = defaultBacktestCash
+	}
+	if params.Weight == 0 {
+		params.Weight = 1
+	}
+	if params.Method == "" {
+		params.Method = methodGrid
+	}
+	if params.Samples == 0 {
+		params.Samples = defaultOptimizeSamples
+	}
+	if params.Metric == "" {
+		params.Metric = backtest.MetricSharpe
+	}
+	if params.Top == 0 {
+		params.Top = defaultOptimizeTop
+	}
+	if err := handlers.validator.Struct(params); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	if _, err := (backtest.Statistics{}).Metric(params.Metric); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("metric", err))
+	}
+	sets, err := params.parameterSets()
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("ranges", err))
+	}
+	factory := backtest.RulesFactory(params.Entry, params.Exit, params.Weight)
+	warmUp, err := optimizeWarmUp(factory, sets)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -params.Days)
+	bars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, params.Symbol, start.AddDate(0, 0, -warmUp*2), end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	trials, err := backtest.Sweep(bars, start, sets, factory, params.Config, params.Metric, 0)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	result := &OptimizationResult{
+		Symbol: params.Symbol,
+		Metric: params.Metric,
+		Method: params.Method,
+		Count:  len(trials),
+		Trials: trials,
+	}
+	if len(trials) > params.Top {
+		result.Trials = trials[:params.Top]
+	}
+	if params.WalkForward != nil {
+		result.WalkForward, err = backtest.WalkForward(bars, start, sets, factory, params.Config, params.Metric,
+			*params.WalkForward, 0)
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, err)
+		}
+	}
+	if params.Name != "" {
+		optimization, err := newOptimization(params, result, handlers.now())
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+		}
+		if err := handlers.esOptim.SetOptimization(optimization); err != nil {
+			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+		}
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// getOptimization gets a stored optimization of the user by its name, it is an error when there is none
+func (handlers *BacktestHandlers) getOptimization(name string) (*es.Optimization, *HandlerERROR) {
+	optimization, err := handlers.esOptim.GetOptimization(defaultUsername, name)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	if optimization == nil {
+		return nil, &HandlerERROR{error: &NotFoundError{Kind: "optimization", Name: name}, Status: http.StatusNotFound}
+	}
+	return optimization, nil
+}
+
+// GetOptimizations handles http request to list the stored optimizations to compare them
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *BacktestHandlers) GetOptimizations(c echo.Context) error {
+	optimizations, err := handlers.esOptim.GetOptimizations(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, optimizations)
+}
+
+// GetOptimization handles http request to get a stored optimization with its request and its result
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *BacktestHandlers) GetOptimization(c echo.Context) error {
+	optimization, httpErr := handlers.getOptimization(c.Param("name"))
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	return c.JSON(http.StatusOK, optimization)
+}
+
+// DeleteOptimization handles http request to delete a stored optimization
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *BacktestHandlers) DeleteOptimization(c echo.Context) error {
+	optimization, httpErr := handlers.getOptimization(c.Param("name"))
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	if err := handlers.esOptim.DeleteOptimization(defaultUsername, optimization.Name); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, optimization)
+}
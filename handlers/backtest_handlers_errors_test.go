@@ -0,0 +1,279 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/backtest"
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+const backtestErrorMsg = "backtest_error"
+
+var testBacktestParams = BacktestParams{Symbol: "TEST1", Days: 10, Strategy: strategyRules, Entry: "close > 1"}
+
+var backtestErrorTests = []struct {
+	echoContext     echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&ErrorEchoBind{Msg: backtestErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&BacktestEchoBind{Params: testBacktestParams},
+		&Context{validator: &ErrorStructValidator{Msg: backtestErrorMsg}},
+		http.StatusBadRequest,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&BacktestEchoBind{Params: BacktestParams{Symbol: "TEST1", Days: 10, Strategy: strategyRules}},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		errNoEntry.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&BacktestEchoBind{Params: BacktestParams{Symbol: "TEST1", Days: 10, Strategy: strategyRules, Entry: "close >"}},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unexpected end of expression",
+		testIndexStockNoError,
+	},
+	{
+		&BacktestEchoBind{Params: testBacktestParams},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		backtestErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, backtestErrorMsg),
+	},
+	{
+		&BacktestEchoBind{Params: testBacktestParams},
+		&Context{validator: &DummyStructValidator{}, esStock: &ErrorBarsEsStock{Msg: backtestErrorMsg}},
+		http.StatusInternalServerError,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&BacktestEchoBind{Params: testBacktestParams},
+		&Context{validator: &DummyStructValidator{}, esStock: &BarsEsStock{bars: map[string][]es.StockBar{}}},
+		http.StatusBadRequest,
+		backtest.ErrNoBars.Error(),
+		testIndexStockNoError,
+	},
+}
+
+func TestBacktestErrors(t *testing.T) {
+	for _, tt := range backtestErrorTests {
+		handlers := BacktestHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		res := handlers.Backtest(tt.echoContext)
+		assert.NotNil(t, res)
+	}
+}
+
+var testOptimizeParams = OptimizeParams{
+	Name:   "levels",
+	Symbol: "TEST1",
+	Days:   10,
+	Entry:  "close > {level}",
+	Ranges: []backtest.Range{{Name: "level", Min: 1, Max: 2, Step: 1}},
+}
+
+func testOptimizeBars() *BarsEsStock {
+	return &BarsEsStock{bars: map[string][]es.StockBar{
+		"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 1, 2, 3),
+	}}
+}
+
+func optimizeParams(change func(params *OptimizeParams)) OptimizeParams {
+	params := testOptimizeParams
+	change(&params)
+	return params
+}
+
+var optimizeErrorTests = []struct {
+	echoContext     echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&ErrorEchoBind{Msg: backtestErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: testOptimizeParams},
+		&Context{validator: &ErrorStructValidator{Msg: backtestErrorMsg}},
+		http.StatusBadRequest,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: optimizeParams(func(params *OptimizeParams) { params.Metric = "luck" })},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unknown metric: luck",
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: optimizeParams(func(params *OptimizeParams) {
+			params.Ranges = []backtest.Range{{Name: "level", Min: 1, Max: 2}}
+		})},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"step of level must be positive",
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: optimizeParams(func(params *OptimizeParams) { params.Entry = "close >" })},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unexpected end of expression",
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: testOptimizeParams},
+		&Context{validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		backtestErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, backtestErrorMsg),
+	},
+	{
+		&OptimizeEchoBind{Params: testOptimizeParams},
+		&Context{validator: &DummyStructValidator{}, esStock: &ErrorBarsEsStock{Msg: backtestErrorMsg}},
+		http.StatusInternalServerError,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: testOptimizeParams},
+		&Context{validator: &DummyStructValidator{}, esStock: &BarsEsStock{bars: map[string][]es.StockBar{}}},
+		http.StatusBadRequest,
+		backtest.ErrNoBars.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: optimizeParams(func(params *OptimizeParams) {
+			params.WalkForward = &backtest.Split{InSample: 100, OutOfSample: 10}
+		})},
+		&Context{validator: &DummyStructValidator{}, esStock: testOptimizeBars()},
+		http.StatusBadRequest,
+		backtest.ErrNoWindow.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&OptimizeEchoBind{Params: testOptimizeParams},
+		&Context{
+			validator: &DummyStructValidator{},
+			esStock:   testOptimizeBars(),
+			esOptim:   &ErrorEsOptimization{Msg: backtestErrorMsg},
+		},
+		http.StatusInternalServerError,
+		backtestErrorMsg,
+		testIndexStockNoError,
+	},
+}
+
+func TestOptimizeErrors(t *testing.T) {
+	for _, tt := range optimizeErrorTests {
+		handlers := BacktestHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			now:          getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		res := handlers.Optimize(tt.echoContext)
+		assert.NotNil(t, res)
+	}
+}
+
+var optimizationErrorTests = []struct {
+	esOptim         es.IOptimizationStock
+	handler         func(handlers *BacktestHandlers, c echo.Context) error
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEsOptimization{Msg: backtestErrorMsg},
+		(*BacktestHandlers).GetOptimizations,
+		http.StatusInternalServerError,
+		backtestErrorMsg,
+	},
+	{
+		&ErrorEsOptimization{Msg: backtestErrorMsg},
+		(*BacktestHandlers).GetOptimization,
+		http.StatusInternalServerError,
+		backtestErrorMsg,
+	},
+	{
+		&DummyEsOptimization{},
+		(*BacktestHandlers).GetOptimization,
+		http.StatusNotFound,
+		"unknown optimization: fast",
+	},
+	{
+		&DummyEsOptimization{},
+		(*BacktestHandlers).DeleteOptimization,
+		http.StatusNotFound,
+		"unknown optimization: fast",
+	},
+	{
+		&FailingEsOptimization{
+			DummyEsOptimization: DummyEsOptimization{Optimizations: []es.Optimization{{Name: "fast"}}},
+			Msg:                 backtestErrorMsg,
+		},
+		(*BacktestHandlers).DeleteOptimization,
+		http.StatusInternalServerError,
+		backtestErrorMsg,
+	},
+}
+
+func TestOptimizationErrors(t *testing.T) {
+	for _, tt := range optimizationErrorTests {
+		handlers := &BacktestHandlers{
+			Context:      &Context{esOptim: tt.esOptim},
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		req, err := http.NewRequest("GET", testOptimizationURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := createEcho(req)
+		c.SetParamNames("name")
+		c.SetParamValues("fast")
+		res := tt.handler(handlers, c)
+		assert.NotNil(t, res)
+	}
+}
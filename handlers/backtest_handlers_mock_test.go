@@ -0,0 +1,120 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+)
+
+type BacktestEchoBind struct {
+	echo.Context
+	Params BacktestParams
+}
+
+func (echo BacktestEchoBind) Bind(dst interface{}) error {
+	*dst.(*BacktestParams) = echo.Params
+	return nil
+}
+
+func (echo BacktestEchoBind) Request() *http.Request {
+	return &http.Request{}
+}
+
+type OptimizeEchoBind struct {
+	echo.Context
+	Params OptimizeParams
+}
+
+func (echo OptimizeEchoBind) Bind(dst interface{}) error {
+	*dst.(*OptimizeParams) = echo.Params
+	return nil
+}
+
+func (echo OptimizeEchoBind) Request() *http.Request {
+	return &http.Request{}
+}
+
+type DummyEsOptimization struct {
+	Optimizations []es.Optimization
+}
+
+func (optimizationStock *DummyEsOptimization) SetOptimization(optimization *es.Optimization) error {
+	for i := range optimizationStock.Optimizations {
+		if optimizationStock.Optimizations[i].Name == optimization.Name {
+			optimizationStock.Optimizations[i] = *optimization
+			return nil
+		}
+	}
+	optimizationStock.Optimizations = append(optimizationStock.Optimizations, *optimization)
+	return nil
+}
+
+func (optimizationStock *DummyEsOptimization) GetOptimization(username string, name string) (*es.Optimization, error) {
+	for _, optimization := range optimizationStock.Optimizations {
+		if optimization.Name == name {
+			return &optimization, nil
+		}
+	}
+	return nil, nil
+}
+
+func (optimizationStock *DummyEsOptimization) GetOptimizations(username string) ([]es.Optimization, error) {
+	optimizations := make([]es.Optimization, len(optimizationStock.Optimizations))
+	copy(optimizations, optimizationStock.Optimizations)
+	return optimizations, nil
+}
+
+func (optimizationStock *DummyEsOptimization) DeleteOptimization(username string, name string) error {
+	for i := range optimizationStock.Optimizations {
+		if optimizationStock.Optimizations[i].Name == name {
+			optimizationStock.Optimizations = append(optimizationStock.Optimizations[:i], optimizationStock.Optimizations[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+type ErrorEsOptimization struct {
+	Msg string
+}
+
+func (optimizationStock *ErrorEsOptimization) SetOptimization(optimization *es.Optimization) error {
+	return errors.New(optimizationStock.Msg)
+}
+
+func (optimizationStock *ErrorEsOptimization) GetOptimization(username string, name string) (*es.Optimization, error) {
+	return nil, errors.New(optimizationStock.Msg)
+}
+
+func (optimizationStock *ErrorEsOptimization) GetOptimizations(username string) ([]es.Optimization, error) {
+	return nil, errors.New(optimizationStock.Msg)
+}
+
+func (optimizationStock *ErrorEsOptimization) DeleteOptimization(username string, name string) error {
+	return errors.New(optimizationStock.Msg)
+}
+
+// FailingEsOptimization finds the optimizations but fails to delete them
+type FailingEsOptimization struct {
+	DummyEsOptimization
+	Msg string
+}
+
+func (optimizationStock *FailingEsOptimization) DeleteOptimization(username string, name string) error {
+	return errors.New(optimizationStock.Msg)
+}
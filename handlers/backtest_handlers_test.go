@@ -0,0 +1,211 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/backtest"
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const testBacktestURL = "http://test.test/backtest"
+
+func TestBacktest(t *testing.T) {
+	var backtestTests = []struct {
+		data           string
+		expectedTrades []backtest.Trade
+		expectedCash   float64
+	}{
+		{
+			"{\"symbol\":\"TEST1\",\"days\":4,\"strategy\":\"buy_and_hold\"}",
+			[]backtest.Trade{
+				{Date: getSeriesTestDate().AddDate(0, 0, -3), Side: backtest.SideBuy, Quantity: 99, Price: 101},
+			},
+			defaultBacktestCash,
+		},
+		{
+			"{\"symbol\":\"TEST1\",\"days\":4,\"strategy\":\"rules\",\"entry\":\"sma(3) > 100\",\"exit\":\"close < 102\"," +
+				"\"weight\":0.5,\"config\":{\"cash\":1000,\"commission\":1}}",
+			[]backtest.Trade{
+				{Date: getSeriesTestDate().AddDate(0, 0, -1), Side: backtest.SideBuy, Quantity: 4, Price: 101, Commission: 1},
+				{Date: getSeriesTestDate(), Side: backtest.SideSell, Quantity: 4, Price: 100, Commission: 1, Profit: -6},
+			},
+			1000,
+		},
+	}
+	// the bars have no open, the orders are filled at the close of the next bar
+	for _, tt := range backtestTests {
+		handlers := &BacktestHandlers{
+			Context: &Context{
+				validator: &DummyStructValidator{},
+				esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+					"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 98, 99, 100, 101, 104, 101, 100),
+				}},
+			},
+			getDate:    getTestDate,
+			indexStock: testIndexStockNoError,
+		}
+		req, err := http.NewRequest("POST", testBacktestURL, bytes.NewBufferString(tt.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		c, resp := createEcho(req)
+		handlers.Backtest(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var result backtest.Result
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, "TEST1", result.Symbol)
+		assert.Equal(t, tt.expectedCash, result.Config.Cash)
+		assert.Equal(t, 5, len(result.Equity))
+		assert.Equal(t, len(tt.expectedTrades), len(result.Trades))
+		for i, trade := range tt.expectedTrades {
+			assert.True(t, trade.Date.Equal(result.Trades[i].Date))
+			result.Trades[i].Date = trade.Date
+			assert.Equal(t, trade, result.Trades[i])
+		}
+	}
+}
+
+const testOptimizeURL = "http://test.test/backtest/optimize"
+const testOptimizationURL = "http://test.test/backtest/optimizations"
+
+func createOptimizeHandlers(esOptim es.IOptimizationStock) *BacktestHandlers {
+	return &BacktestHandlers{
+		Context: &Context{
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 98, 99, 100, 101, 104, 101, 100),
+			}},
+			esOptim: esOptim,
+		},
+		getDate:    getTestDate,
+		now:        getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	esOptim := &DummyEsOptimization{}
+	handlers := createOptimizeHandlers(esOptim)
+	data := "{\"name\":\"levels\",\"symbol\":\"TEST1\",\"days\":4,\"entry\":\"close > {level}\",\"metric\":\"total_return\"," +
+		"\"ranges\":[{\"name\":\"level\",\"min\":99,\"max\":104,\"step\":5}],\"top\":1}"
+	req, err := http.NewRequest("POST", testOptimizeURL, bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.Optimize(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var result OptimizationResult
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &result))
+	assert.Equal(t, "TEST1", result.Symbol)
+	assert.Equal(t, backtest.MetricTotalReturn, result.Metric)
+	assert.Equal(t, methodGrid, result.Method)
+	assert.Equal(t, 2, result.Count)
+	assert.Equal(t, 1, len(result.Trials))
+	assert.Equal(t, backtest.Parameters{"level": 104}, result.Trials[0].Parameters)
+	assert.Equal(t, 0.0, result.Trials[0].Score)
+	assert.Nil(t, result.WalkForward)
+	assert.Equal(t, 1, len(esOptim.Optimizations))
+	optimization := esOptim.Optimizations[0]
+	assert.Equal(t, "levels", optimization.Name)
+	assert.Equal(t, defaultUsername, optimization.Username)
+	assert.Equal(t, getTestDate(), optimization.Created)
+	assert.Equal(t, map[string]float64{"level": 104}, optimization.Parameters)
+	assert.Nil(t, optimization.Efficiency)
+	var request OptimizeParams
+	assert.Nil(t, json.Unmarshal(optimization.Request, &request))
+	assert.Equal(t, float64(defaultBacktestCash), request.Config.Cash)
+	assert.Equal(t, 1.0, request.Weight)
+}
+
+func TestOptimizeWalkForward(t *testing.T) {
+	esOptim := &DummyEsOptimization{}
+	handlers := createOptimizeHandlers(esOptim)
+	data := "{\"symbol\":\"TEST1\",\"days\":4,\"entry\":\"close > {level}\",\"exit\":\"close < {level}\",\"method\":\"random\"," +
+		"\"samples\":5,\"seed\":1,\"ranges\":[{\"name\":\"level\",\"min\":99,\"max\":104}]," +
+		"\"walk_forward\":{\"in_sample\":2,\"out_of_sample\":1}}"
+	req, err := http.NewRequest("POST", testOptimizeURL, bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.Optimize(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var result OptimizationResult
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &result))
+	assert.Equal(t, backtest.MetricSharpe, result.Metric)
+	assert.Equal(t, methodRandom, result.Method)
+	assert.Equal(t, 5, result.Count)
+	assert.Equal(t, 5, len(result.Trials))
+	assert.Equal(t, 3, len(result.WalkForward.Windows))
+	assert.Equal(t, 0, len(esOptim.Optimizations))
+}
+
+func TestGetOptimizations(t *testing.T) {
+	handlers := &BacktestHandlers{Context: &Context{esOptim: &DummyEsOptimization{Optimizations: []es.Optimization{
+		{Name: "fast", Symbol: "TEST1"}, {Name: "slow", Symbol: "TEST2"},
+	}}}}
+	req, err := http.NewRequest("GET", testOptimizationURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetOptimizations(c)
+	var optimizations []es.Optimization
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &optimizations))
+	assert.Equal(t, 2, len(optimizations))
+	assert.Equal(t, "slow", optimizations[1].Name)
+}
+
+func TestGetOptimization(t *testing.T) {
+	handlers := &BacktestHandlers{Context: &Context{esOptim: &DummyEsOptimization{Optimizations: []es.Optimization{
+		{Name: "fast", Symbol: "TEST1", Result: json.RawMessage("{\"count\":4}")},
+	}}}}
+	req, err := http.NewRequest("GET", testOptimizationURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("name")
+	c.SetParamValues("fast")
+	handlers.GetOptimization(c)
+	var optimization es.Optimization
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &optimization))
+	assert.Equal(t, "TEST1", optimization.Symbol)
+	assert.Equal(t, "{\"count\":4}", string(optimization.Result))
+}
+
+func TestDeleteOptimization(t *testing.T) {
+	esOptim := &DummyEsOptimization{Optimizations: []es.Optimization{{Name: "fast"}, {Name: "slow"}}}
+	handlers := &BacktestHandlers{Context: &Context{esOptim: esOptim}}
+	req, err := http.NewRequest("DELETE", testOptimizationURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("name")
+	c.SetParamValues("fast")
+	handlers.DeleteOptimization(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, []es.Optimization{{Name: "slow"}}, esOptim.Optimizations)
+}
@@ -16,6 +16,10 @@ package handlers
 
 // Context is the context of the application
 import (
+	"sync"
+	"time"
+
+	"github.com/clebi/gofin/alerts"
 	"github.com/clebi/gofin/es"
 	finance "github.com/clebi/yfinance"
 	elastic "gopkg.in/olivere/elastic.v5"
@@ -35,6 +39,20 @@ type Context struct {
 	quotesAPI  finance.QuotesAPI
 	esStock    es.IStock
 	esPosition es.IPositionStock
+	esAlloc    es.IAllocationStock
+	esProfile  es.IImportProfileStock
+	esMapping  es.ISymbolMappingStock
+	esFx       es.IFxStock
+	esUniverse es.IUniverseStock
+	esSignal   es.ISignalStock
+	esAlert    es.IAlertStock
+	notifier   alerts.Notifier
+	esOptim    es.IOptimizationStock
+	fanOut     FanOut
+	alertQueue *alertQueue
+	// alertMutex serializes the evaluations of the alert rules, the rules are read and saved under it so that
+	// two evaluations in the process cannot both fire a rule on the same bar
+	alertMutex sync.Mutex
 }
 
 //NewContext creates a new context for handlers
@@ -45,8 +63,18 @@ func NewContext(
 	historyAPI finance.HistoryAPI,
 	quotesAPI finance.QuotesAPI,
 	esStock es.IStock,
-	esPosition es.IPositionStock) *Context {
-	return &Context{
+	esPosition es.IPositionStock,
+	esAlloc es.IAllocationStock,
+	esProfile es.IImportProfileStock,
+	esMapping es.ISymbolMappingStock,
+	esFx es.IFxStock,
+	esUniverse es.IUniverseStock,
+	esSignal es.ISignalStock,
+	esAlert es.IAlertStock,
+	notifier alerts.Notifier,
+	esOptim es.IOptimizationStock,
+	fanOut FanOut) *Context {
+	context := &Context{
 		es:         es,
 		sh:         sh,
 		validator:  validator,
@@ -54,5 +82,26 @@ func NewContext(
 		quotesAPI:  quotesAPI,
 		esStock:    esStock,
 		esPosition: esPosition,
+		esAlloc:    esAlloc,
+		esProfile:  esProfile,
+		esMapping:  esMapping,
+		esFx:       esFx,
+		esUniverse: esUniverse,
+		esSignal:   esSignal,
+		esAlert:    esAlert,
+		notifier:   notifier,
+		esOptim:    esOptim,
+		fanOut:     fanOut,
 	}
+	context.alertQueue = newAlertQueue(context, time.Now, alertQueueSize)
+	go context.alertQueue.run()
+	return context
+}
+
+// Close stops the background work of the context, it returns once the alerts of the queued symbols are evaluated
+//
+// It should be called after the server is shut down, the symbols ingested later are not evaluated.
+func (context *Context) Close() {
+	context.alertQueue.close()
+	context.alertQueue.wait()
 }
@@ -0,0 +1,117 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFanOutWorkers = 8
+	defaultFanOutTimeout = 30 * time.Second
+)
+
+// FanOut limits the work done concurrently for the symbols of a request
+//
+// Workers is the number of symbols processed at once and Timeout the deadline of the whole request, the
+// defaults are used when they are 0.
+type FanOut struct {
+	Workers int
+	Timeout time.Duration
+}
+
+func (fanOut FanOut) workers(jobs int) int {
+	workers := fanOut.Workers
+	if workers <= 0 {
+		workers = defaultFanOutWorkers
+	}
+	if workers > jobs {
+		workers = jobs
+	}
+	return workers
+}
+
+func (fanOut FanOut) timeout() time.Duration {
+	if fanOut.Timeout <= 0 {
+		return defaultFanOutTimeout
+	}
+	return fanOut.Timeout
+}
+
+// forEachSymbol calls process for every symbol concurrently and waits for all of them until the deadline
+//
+// The results keep the order of the symbols, whatever the order they were processed in. The context given to
+// process is done at the deadline or with the parent, process should give up the calls it makes with it. The
+// symbols not processed by then fail with a timeout while the results of the others are kept.
+func (fanOut FanOut) forEachSymbol(
+	parent context.Context,
+	symbols []string,
+	process func(ctx context.Context, symbol string) SymbolResult) []SymbolResult {
+	ctx, cancel := context.WithTimeout(parent, fanOut.timeout())
+	defer cancel()
+	var mutex sync.Mutex
+	results := make([]SymbolResult, len(symbols))
+	finished := make([]bool, len(symbols))
+	jobs := make(chan int)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < fanOut.workers(len(symbols)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				result := process(ctx, symbols[i])
+				mutex.Lock()
+				results[i], finished[i] = result, true
+				mutex.Unlock()
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range symbols {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	// the workers still running write in results, the caller gets a copy
+	mutex.Lock()
+	defer mutex.Unlock()
+	processed := make([]SymbolResult, len(symbols))
+	for i, symbol := range symbols {
+		processed[i] = results[i]
+		if !finished[i] {
+			err := fmt.Errorf("%s not processed within %s", symbol, fanOut.timeout())
+			processed[i] = symbolFailure(symbol, ErrorCodeTimeout, err)
+		}
+	}
+	return processed
+}
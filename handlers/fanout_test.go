@@ -0,0 +1,83 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEachSymbol(t *testing.T) {
+	symbols := make([]string, 20)
+	delays := make(map[string]time.Duration, len(symbols))
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("S%d", i)
+		// the first symbols are the slowest so that they end last
+		delays[symbols[i]] = time.Duration(len(symbols)-i) * time.Millisecond
+	}
+	var mutex sync.Mutex
+	running, maxRunning := 0, 0
+	results := FanOut{Workers: 3}.forEachSymbol(context.Background(), symbols, func(ctx context.Context, symbol string) SymbolResult {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+		time.Sleep(delays[symbol])
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return SymbolResult{Symbol: symbol, Data: symbol}
+	})
+	assert.Len(t, results, len(symbols))
+	for i, result := range results {
+		assert.Equal(t, SymbolResult{Symbol: symbols[i], Data: symbols[i]}, result)
+	}
+	assert.Equal(t, 3, maxRunning)
+	assert.Empty(t, FanOut{}.forEachSymbol(context.Background(), nil, nil))
+}
+
+func TestForEachSymbolTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var mutex sync.Mutex
+	started := []string{}
+	results := FanOut{Workers: 1, Timeout: 10 * time.Millisecond}.forEachSymbol(context.Background(), []string{"A", "B", "C"}, func(ctx context.Context, symbol string) SymbolResult {
+		mutex.Lock()
+		started = append(started, symbol)
+		mutex.Unlock()
+		if symbol == "B" {
+			<-release
+		}
+		return SymbolResult{Symbol: symbol, Data: symbol}
+	})
+	assert.Equal(t, []SymbolResult{
+		{Symbol: "A", Data: "A"},
+		symbolFailure("B", ErrorCodeTimeout, errors.New("B not processed within 10ms")),
+		symbolFailure("C", ErrorCodeTimeout, errors.New("C not processed within 10ms")),
+	}, results)
+	assert.Equal(t, ListStatusPartial, newListResult(results).Status)
+	// the symbols not started at the deadline are skipped
+	time.Sleep(10 * time.Millisecond)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, []string{"A", "B"}, started)
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/fx"
+	"github.com/clebi/gofin/portfolio"
+	"github.com/labstack/echo"
+)
+
+// fxLookbackDays is the number of days read before a period to know the exchange rates at its start
+const fxLookbackDays = 31
+
+// FxImportReport contains the outcome of the import of exchange rates
+type FxImportReport struct {
+	Imported int `json:"imported"`
+}
+
+// FxHandlers handles all requests about currencies
+type FxHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	errorHandler errorHandlerFunc
+}
+
+// NewFxHandlers creates a new currency handlers object
+func NewFxHandlers(context *Context) *FxHandlers {
+	return &FxHandlers{
+		Context:      context,
+		getDate:      time.Now,
+		errorHandler: handleError,
+	}
+}
+
+// symbolCurrencies finds the currency of symbols, from the stored ones or from their exchange suffix
+func symbolCurrencies(context *Context, symbols []string) (map[string]string, *HandlerERROR) {
+	stored, err := context.esFx.GetSymbolCurrencies(symbols)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	currencies := map[string]string{}
+	for _, symbol := range symbols {
+		currency, ok := stored[symbol]
+		if !ok {
+			currency = fx.DefaultCurrency(symbol)
+		}
+		currencies[symbol] = currency
+	}
+	return currencies, nil
+}
+
+// loadExchange reads the rates needed to convert the symbols and the trades into a base currency
+//
+// There is no exchange, and nothing is converted, without base currency.
+func loadExchange(
+	context *Context,
+	base string,
+	symbols []string,
+	trades []es.Position,
+	start time.Time,
+	end time.Time) (*portfolio.Exchange, *HandlerERROR) {
+	if base == "" {
+		return nil, nil
+	}
+	base = strings.ToUpper(base)
+	currencies, httpErr := symbolCurrencies(context, symbols)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	set := map[string]bool{base: true, fx.USD: true}
+	for _, currency := range currencies {
+		set[currency] = true
+	}
+	for _, trade := range trades {
+		if trade.Currency != "" {
+			set[trade.Currency] = true
+		}
+	}
+	var needed []string
+	for currency := range set {
+		needed = append(needed, currency)
+	}
+	sort.Strings(needed)
+	rates, err := context.esFx.GetRates(needed, start.AddDate(0, 0, -fxLookbackDays), end)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	return &portfolio.Exchange{Currencies: currencies, Converter: fx.NewConverter(base, rates)}, nil
+}
+
+// ImportRates handles http request to save the exchange rates of a CSV file sent as body
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *FxHandlers) ImportRates(c echo.Context) error {
+	provider, err := fx.NewCSVProvider(c.Request().Body)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	count, err := fx.Feed(provider, handlers.esFx, provider.Currencies(), time.Time{}, handlers.getDate())
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, FxImportReport{Imported: count})
+}
+
+// SetSymbolCurrency handles http request to set the currency in which a symbol is quoted
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *FxHandlers) SetSymbolCurrency(c echo.Context) error {
+	currency := new(es.SymbolCurrency)
+	if err := c.Bind(currency); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if err := handlers.validator.Struct(currency); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	currency.Currency = strings.ToUpper(currency.Currency)
+	if err := handlers.esFx.SetSymbolCurrency(currency); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, currency)
+}
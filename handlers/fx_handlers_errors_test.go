@@ -0,0 +1,109 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+const fxErrorMsg = "fx_error"
+
+var importRatesErrorTests = []struct {
+	context         *Context
+	body            string
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{esFx: &DummyEsFx{}},
+		"date,from,to\n",
+		http.StatusBadRequest,
+		"missing column: rate",
+	},
+	{
+		&Context{esFx: &ErrorEsFx{Msg: fxErrorMsg}},
+		importRatesData,
+		http.StatusInternalServerError,
+		fxErrorMsg,
+	},
+}
+
+func TestImportRatesErrors(t *testing.T) {
+	for _, tt := range importRatesErrorTests {
+		handlers := FxHandlers{
+			Context:      tt.context,
+			getDate:      time.Now,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		req, err := http.NewRequest("POST", "http://test.test/fx/rates", bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.ImportRates(c)
+		assert.NotNil(t, res)
+	}
+}
+
+var setSymbolCurrencyErrorTests = []struct {
+	echo            echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEchoBind{Msg: fxErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		fxErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{validator: &ErrorStructValidator{Msg: fxErrorMsg}},
+		http.StatusBadRequest,
+		fxErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{
+			esFx:      &ErrorEsFx{Msg: fxErrorMsg},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusInternalServerError,
+		fxErrorMsg,
+	},
+}
+
+func TestSetSymbolCurrencyErrors(t *testing.T) {
+	for _, tt := range setSymbolCurrencyErrorTests {
+		handlers := FxHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		res := handlers.SetSymbolCurrency(tt.echo)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestLoadExchangeErrors(t *testing.T) {
+	_, httpErr := loadExchange(&Context{esFx: &ErrorEsFx{Msg: fxErrorMsg}}, "EUR", []string{"TEST"}, nil, time.Now(), time.Now())
+	assert.Equal(t, http.StatusInternalServerError, httpErr.Status)
+	assert.EqualError(t, httpErr.error, fxErrorMsg)
+}
@@ -0,0 +1,86 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+type DummyEsFx struct {
+	Rates      []es.FxRate
+	Currencies map[string]string
+}
+
+func (fxStock *DummyEsFx) AddRate(rate *es.FxRate) error {
+	fxStock.Rates = append(fxStock.Rates, *rate)
+	return nil
+}
+
+func (fxStock *DummyEsFx) GetRates(currencies []string, startDate time.Time, endDate time.Time) ([]es.FxRate, error) {
+	return fxStock.Rates, nil
+}
+
+func (fxStock *DummyEsFx) SetSymbolCurrency(currency *es.SymbolCurrency) error {
+	if fxStock.Currencies == nil {
+		fxStock.Currencies = map[string]string{}
+	}
+	fxStock.Currencies[currency.Symbol] = currency.Currency
+	return nil
+}
+
+func (fxStock *DummyEsFx) GetSymbolCurrencies(symbols []string) (map[string]string, error) {
+	currencies := map[string]string{}
+	for _, symbol := range symbols {
+		if currency, ok := fxStock.Currencies[symbol]; ok {
+			currencies[symbol] = currency
+		}
+	}
+	return currencies, nil
+}
+
+type ErrorEsFx struct {
+	Msg string
+}
+
+func (fxStock *ErrorEsFx) AddRate(rate *es.FxRate) error {
+	return errors.New(fxStock.Msg)
+}
+
+func (fxStock *ErrorEsFx) GetRates(currencies []string, startDate time.Time, endDate time.Time) ([]es.FxRate, error) {
+	return nil, errors.New(fxStock.Msg)
+}
+
+func (fxStock *ErrorEsFx) SetSymbolCurrency(currency *es.SymbolCurrency) error {
+	return errors.New(fxStock.Msg)
+}
+
+func (fxStock *ErrorEsFx) GetSymbolCurrencies(symbols []string) (map[string]string, error) {
+	return nil, errors.New(fxStock.Msg)
+}
+
+type PositionSchemaDecoder struct {
+	Params PositionParams
+}
+
+func (decoder *PositionSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*PositionParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for PositionSchemaDecoder")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	importRatesData       = "date,from,to,rate\n2017-01-02,EUR,USD,1.04\n2017-01-03,EUR,USD,1.05\n2030-01-02,EUR,USD,2\n"
+	setSymbolCurrencyData = "{\"symbol\":\"VOD.L\",\"currency\":\"gbp\"}"
+)
+
+func getFxTestDate() time.Time {
+	return testBarDate("2017-06-01")
+}
+
+func TestImportRates(t *testing.T) {
+	esFx := &DummyEsFx{}
+	handlers := &FxHandlers{
+		Context: &Context{esFx: esFx},
+		getDate: getFxTestDate,
+	}
+	req, err := http.NewRequest("POST", "http://test.test/fx/rates", bytes.NewBufferString(importRatesData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.ImportRates(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, "{\"imported\":2}", resp.Body.String())
+	assert.Equal(t, es.FxRate{From: "EUR", To: "USD", Date: testBarDate("2017-01-03"), Rate: 1.05}, esFx.Rates[1])
+}
+
+func TestSetSymbolCurrency(t *testing.T) {
+	esFx := &DummyEsFx{}
+	handlers := &FxHandlers{
+		Context: &Context{
+			esFx:      esFx,
+			validator: &DummyStructValidator{},
+		},
+	}
+	req, err := http.NewRequest("PUT", "http://test.test/fx/symbols", bytes.NewBufferString(setSymbolCurrencyData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.SetSymbolCurrency(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, map[string]string{"VOD.L": "GBP"}, esFx.Currencies)
+}
+
+func TestLoadExchange(t *testing.T) {
+	context := &Context{esFx: &DummyEsFx{
+		Rates:      []es.FxRate{{From: "EUR", To: "USD", Date: testBarDate("2017-01-02"), Rate: 1.04}},
+		Currencies: map[string]string{"TEST": "CHF"},
+	}}
+	exchange, httpErr := loadExchange(context, "", []string{"TEST"}, nil, time.Now(), time.Now())
+	assert.Nil(t, httpErr)
+	assert.Nil(t, exchange)
+	exchange, httpErr = loadExchange(context, "usd", []string{"TEST", "CW8.PA"}, nil, time.Now(), time.Now())
+	assert.Nil(t, httpErr)
+	assert.Equal(t, map[string]string{"TEST": "CHF", "CW8.PA": "EUR"}, exchange.Currencies)
+	rate, err := exchange.SymbolRate("CW8.PA", testBarDate("2017-01-03"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1.04, rate)
+}
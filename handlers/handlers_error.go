@@ -14,22 +14,213 @@
 
 package handlers
 
-// handleError writes error to the http channel and logs internal errors
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/clebi/gofin/analytics"
+	"github.com/clebi/gofin/backtest"
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/fx"
+	"github.com/clebi/gofin/portfolio"
+	"github.com/go-playground/validator"
+	schema "github.com/gorilla/Schema"
 	"github.com/labstack/echo"
 	"github.com/labstack/gommon/log"
 )
 
+// Codes of the errors of the routes, the failures of the symbols in the list routes have the same codes
+const (
+	ErrorCodeInternal            = "internal_error"
+	ErrorCodeBadRequest          = "bad_request"
+	ErrorCodeInvalidParameter    = "invalid_parameter"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeInsufficientData    = "insufficient_data"
+	ErrorCodeProviderUnavailable = "provider_unavailable"
+	ErrorCodeStorageUnavailable  = "storage_unavailable"
+	ErrorCodeStorageTimeout      = "storage_timeout"
+	ErrorCodeTimeout             = "timeout"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:gofin:problem:"
+)
+
+// Problem is the body of an error response as described by RFC 7807, Code is the stable identifier of the
+// error, the detail of the internal and storage errors is not given
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          string         `json:"code"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// NotFoundError is returned when the resource asked does not exist
+type NotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("unknown %s: %s", err.Kind, err.Name)
+}
+
+// InvalidParam is a parameter of a request with a bad value
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// InvalidParamError is returned when parameters of a request have bad values
+type InvalidParamError struct {
+	Params []InvalidParam
+}
+
+func (err *InvalidParamError) Error() string {
+	reasons := make([]string, len(err.Params))
+	for i, param := range err.Params {
+		reasons[i] = param.Reason
+	}
+	return strings.Join(reasons, ", ")
+}
+
+func invalidParam(name string, err error) *InvalidParamError {
+	return &InvalidParamError{Params: []InvalidParam{{Name: name, Reason: err.Error()}}}
+}
+
+// ProviderError is returned when the provider of the history or of the quotes fails
+type ProviderError struct {
+	Err error
+}
+
+func (err *ProviderError) Error() string {
+	return err.Err.Error()
+}
+
+// insufficientDataErrors are the errors of the computations lacking data
+var insufficientDataErrors = []error{
+	es.ErrNotEnoughPoints,
+	analytics.ErrNotEnoughData,
+	backtest.ErrNoBars,
+	backtest.ErrNoWindow,
+	portfolio.ErrNoPrices,
+}
+
+// ParamName gives the name of the query parameter of a field, it can be registered as the tag name function
+// of the validator so that its errors name the parameters
+func ParamName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("schema"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name[:1]) + field.Name[1:]
+	}
+	return name
+}
+
+// validationError turns the errors of the validator and of the decoder into an InvalidParamError, the others
+// errors are returned unchanged
+func validationError(err error) error {
+	switch errs := err.(type) {
+	case validator.ValidationErrors:
+		params := make([]InvalidParam, len(errs))
+		for i, fieldErr := range errs {
+			rule := fieldErr.Tag()
+			if fieldErr.Param() != "" {
+				rule += "=" + fieldErr.Param()
+			}
+			params[i] = InvalidParam{
+				Name:   fieldErr.Field(),
+				Reason: fmt.Sprintf("%s must satisfy %s", fieldErr.Field(), rule),
+			}
+		}
+		return &InvalidParamError{Params: params}
+	case schema.MultiError:
+		params := make([]InvalidParam, 0, len(errs))
+		for name := range errs {
+			params = append(params, InvalidParam{Name: name, Reason: fmt.Sprintf("bad value of %s", name)})
+		}
+		sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+		return &InvalidParamError{Params: params}
+	}
+	return err
+}
+
+// errorCode gives the status and the code of an error, its type takes precedence over the status given by the
+// handler
+func errorCode(status int, err error) (int, string) {
+	switch err.(type) {
+	case *NotFoundError:
+		return http.StatusNotFound, ErrorCodeNotFound
+	case *InvalidParamError:
+		return http.StatusBadRequest, ErrorCodeInvalidParameter
+	case *ProviderError:
+		return http.StatusBadGateway, ErrorCodeProviderUnavailable
+	case *es.StorageUnavailableError:
+		return http.StatusServiceUnavailable, ErrorCodeStorageUnavailable
+	case *es.StorageTimeoutError:
+		return http.StatusGatewayTimeout, ErrorCodeStorageTimeout
+	case *fx.MissingRateError:
+		return http.StatusUnprocessableEntity, ErrorCodeInsufficientData
+	}
+	for _, dataErr := range insufficientDataErrors {
+		if err == dataErr {
+			return http.StatusUnprocessableEntity, ErrorCodeInsufficientData
+		}
+	}
+	switch status {
+	case http.StatusBadRequest:
+		return status, ErrorCodeBadRequest
+	case http.StatusNotFound:
+		return status, ErrorCodeNotFound
+	case http.StatusGatewayTimeout:
+		return status, ErrorCodeTimeout
+	}
+	return http.StatusInternalServerError, ErrorCodeInternal
+}
+
+// exposesDetail tells whether the text of an error can be given to the client,
+// the one of the internal and storage errors may expose the internals of the server
+func exposesDetail(code string) bool {
+	return code != ErrorCodeInternal && code != ErrorCodeStorageUnavailable && code != ErrorCodeStorageTimeout
+}
+
+func newProblem(c echo.Context, status int, err error) *Problem {
+	status, code := errorCode(status, err)
+	problem := &Problem{
+		Type:     problemTypePrefix + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: c.Request().URL.Path,
+		Code:     code,
+	}
+	if exposesDetail(code) {
+		problem.Detail = err.Error()
+	}
+	if paramErr, ok := err.(*InvalidParamError); ok {
+		problem.InvalidParams = paramErr.Params
+	}
+	return problem
+}
+
+// handleError writes the problem of an error to the http channel and logs the server errors
 func handleError(c echo.Context, status int, err error) error {
-	var msg string
-	if status == http.StatusInternalServerError {
+	problem := newProblem(c, status, err)
+	if problem.Status >= http.StatusInternalServerError {
 		log.Error(err)
-		msg = "unknown_error"
-	} else {
-		msg = err.Error()
 	}
-	c.JSON(status, errorDesc{Status: "error", Description: msg})
-	return nil
+	body, err := json.Marshal(problem)
+	if err != nil {
+		return err
+	}
+	return c.Blob(problem.Status, problemContentType, body)
 }
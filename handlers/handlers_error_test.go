@@ -15,37 +15,135 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"testing"
 
+	"github.com/clebi/gofin/analytics"
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/fx"
+	"github.com/go-playground/validator"
+	schema "github.com/gorilla/Schema"
 	"github.com/stretchr/testify/assert"
 )
 
 const (
-	errorMsg         = "test_error"
-	errorReqMethod   = "GET"
-	errorReqURL      = "TEST"
-	errorResp        = "{\"status\":\"error\",\"description\":\"test_error\"}"
-	unknownErrorResp = "{\"status\":\"error\",\"description\":\"unknown_error\"}"
+	errorMsg       = "test_error"
+	errorReqMethod = "GET"
+	errorReqURL    = "/TEST"
 )
 
-func TestHandleError(t *testing.T) {
-	req, err := http.NewRequest(errorReqMethod, errorReqURL, nil)
-	if err != nil {
-		t.Fatal(err)
+type validatedParams struct {
+	Days   int    `schema:"days" validate:"gt=0"`
+	AsOf   string `schema:"asOf" validate:"required"`
+	Window int    `validate:"gte=2"`
+}
+
+type httpResponse struct {
+	Status      int
+	ContentType string
+}
+
+func writeTestError(t *testing.T, status int, err error) (*httpResponse, *Problem) {
+	req, reqErr := http.NewRequest(errorReqMethod, errorReqURL, nil)
+	if reqErr != nil {
+		t.Fatal(reqErr)
 	}
 	c, resp := createEcho(req)
-	handleError(c, http.StatusBadRequest, errors.New(errorMsg))
-	assert.Equal(t, errorResp, resp.Body.String())
+	assert.Nil(t, handleError(c, status, err))
+	var problem Problem
+	if err := json.Unmarshal(resp.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	return &httpResponse{Status: resp.Code, ContentType: resp.Header().Get("Content-Type")}, &problem
+}
+
+func TestHandleError(t *testing.T) {
+	resp, problem := writeTestError(t, http.StatusBadRequest, errors.New(errorMsg))
+	assert.Equal(t, http.StatusBadRequest, resp.Status)
+	assert.Equal(t, problemContentType, resp.ContentType)
+	assert.Equal(t, Problem{
+		Type:     "urn:gofin:problem:bad_request",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   errorMsg,
+		Instance: errorReqURL,
+		Code:     ErrorCodeBadRequest,
+	}, *problem)
 }
 
 func TestHandleUnknownError(t *testing.T) {
-	req, err := http.NewRequest(errorReqMethod, errorReqURL, nil)
-	if err != nil {
-		t.Fatal(err)
+	resp, problem := writeTestError(t, http.StatusInternalServerError, errors.New(errorMsg))
+	assert.Equal(t, http.StatusInternalServerError, resp.Status)
+	assert.Equal(t, ErrorCodeInternal, problem.Code)
+	assert.Empty(t, problem.Detail)
+}
+
+var handleErrorTests = []struct {
+	status         int
+	err            error
+	expectedStatus int
+	expectedCode   string
+	expectedDetail string
+}{
+	{http.StatusNotFound, &NotFoundError{Kind: "alert", Name: "test"}, http.StatusNotFound, ErrorCodeNotFound,
+		"unknown alert: test"},
+	{http.StatusBadRequest, invalidParam("asOf", errors.New("bad date: x")), http.StatusBadRequest,
+		ErrorCodeInvalidParameter, "bad date: x"},
+	{http.StatusBadRequest, &ProviderError{Err: errors.New(errorMsg)}, http.StatusBadGateway,
+		ErrorCodeProviderUnavailable, errorMsg},
+	{http.StatusInternalServerError, &es.StorageTimeoutError{Err: errors.New(errorMsg)}, http.StatusGatewayTimeout,
+		ErrorCodeStorageTimeout, ""},
+	{http.StatusInternalServerError, &es.StorageUnavailableError{Err: errors.New(errorMsg)},
+		http.StatusServiceUnavailable, ErrorCodeStorageUnavailable, ""},
+	{http.StatusBadRequest, analytics.ErrNotEnoughData, http.StatusUnprocessableEntity, ErrorCodeInsufficientData,
+		analytics.ErrNotEnoughData.Error()},
+	{http.StatusInternalServerError, es.ErrNotEnoughPoints, http.StatusUnprocessableEntity,
+		ErrorCodeInsufficientData, es.ErrNotEnoughPoints.Error()},
+	{http.StatusBadRequest, &fx.MissingRateError{Currency: "USD", Base: "EUR", Date: testBarDate("2017-01-02")},
+		http.StatusUnprocessableEntity, ErrorCodeInsufficientData, "no USD/EUR rate on 2017-01-02"},
+	{http.StatusNotFound, errors.New(errorMsg), http.StatusNotFound, ErrorCodeNotFound, errorMsg},
+	{http.StatusGatewayTimeout, errors.New(errorMsg), http.StatusGatewayTimeout, ErrorCodeTimeout, errorMsg},
+	{http.StatusConflict, errors.New(errorMsg), http.StatusInternalServerError, ErrorCodeInternal, ""},
+}
+
+func TestHandleErrorCodes(t *testing.T) {
+	for _, tt := range handleErrorTests {
+		resp, problem := writeTestError(t, tt.status, tt.err)
+		assert.Equal(t, tt.expectedStatus, resp.Status)
+		assert.Equal(t, tt.expectedStatus, problem.Status)
+		assert.Equal(t, tt.expectedCode, problem.Code)
+		assert.Equal(t, problemTypePrefix+tt.expectedCode, problem.Type)
+		assert.Equal(t, http.StatusText(tt.expectedStatus), problem.Title)
+		assert.Equal(t, tt.expectedDetail, problem.Detail)
 	}
-	c, resp := createEcho(req)
-	handleError(c, http.StatusInternalServerError, errors.New(errorMsg))
-	assert.Equal(t, unknownErrorResp, resp.Body.String())
+}
+
+func TestHandleErrorInvalidParams(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(ParamName)
+	err := validationError(validate.Struct(validatedParams{AsOf: "2016-12-13", Window: 1}))
+	_, problem := writeTestError(t, http.StatusBadRequest, err)
+	assert.Equal(t, ErrorCodeInvalidParameter, problem.Code)
+	assert.Equal(t, "days must satisfy gt=0, window must satisfy gte=2", problem.Detail)
+	assert.Equal(t, []InvalidParam{
+		{Name: "days", Reason: "days must satisfy gt=0"},
+		{Name: "window", Reason: "window must satisfy gte=2"},
+	}, problem.InvalidParams)
+}
+
+func TestValidationErrorDecoder(t *testing.T) {
+	var params validatedParams
+	decoder := schema.NewDecoder()
+	err := decoder.Decode(&params, map[string][]string{"days": {"x"}, "Window": {"y"}})
+	assert.Equal(t, &InvalidParamError{Params: []InvalidParam{
+		{Name: "Window", Reason: "bad value of Window"},
+		{Name: "days", Reason: "bad value of days"},
+	}}, validationError(err))
+}
+
+func TestValidationErrorUnchanged(t *testing.T) {
+	err := errors.New(errorMsg)
+	assert.Equal(t, err, validationError(err))
 }
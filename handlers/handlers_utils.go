@@ -15,20 +15,30 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/clebi/gofin/es"
 	"github.com/labstack/echo"
 )
 
+// queryDateFormat is the format of the dates given in queries
+const queryDateFormat = "2006-01-02"
+
 type indexStockFunc func(context *Context, symbol string, start time.Time, end time.Time) *HandlerERROR
 
 func getQuery(c echo.Context, context *Context, params interface{}) *HandlerERROR {
 	if err := context.sh.Decode(params, c.Request().URL.Query()); err != nil {
+		if paramErr, ok := validationError(err).(*InvalidParamError); ok {
+			return &HandlerERROR{error: paramErr, Status: http.StatusBadRequest}
+		}
 		return &HandlerERROR{error: err, Status: http.StatusInternalServerError}
 	}
 	if err := context.validator.Struct(params); err != nil {
-		return &HandlerERROR{error: err, Status: http.StatusBadRequest}
+		return &HandlerERROR{error: validationError(err), Status: http.StatusBadRequest}
 	}
 	return nil
 }
@@ -36,7 +46,7 @@ func getQuery(c echo.Context, context *Context, params interface{}) *HandlerERRO
 func indexStock(context *Context, symbol string, start time.Time, end time.Time) *HandlerERROR {
 	stocks, err := context.historyAPI.GetHistory(symbol, start, end)
 	if err != nil {
-		return &HandlerERROR{error: err, Status: http.StatusBadRequest}
+		return &HandlerERROR{error: &ProviderError{Err: err}, Status: http.StatusBadRequest}
 	}
 	for _, stock := range stocks {
 		err = context.esStock.Index(stock)
@@ -44,5 +54,95 @@ func indexStock(context *Context, symbol string, start time.Time, end time.Time)
 			return &HandlerERROR{error: err, Status: http.StatusInternalServerError}
 		}
 	}
+	// the alert rules are evaluated in the background, a context without alert queue does not evaluate them
+	if context.alertQueue != nil {
+		context.alertQueue.push(symbol)
+	}
 	return nil
 }
+
+// loadBars indexes the history of a stock and reads back the stored daily values, the read stops with ctx
+func loadBars(
+	ctx context.Context,
+	context *Context,
+	index indexStockFunc,
+	symbol string,
+	start time.Time,
+	end time.Time) ([]es.StockBar, *HandlerERROR) {
+	if httpErr := index(context, symbol, start, end); httpErr != nil {
+		return nil, httpErr
+	}
+	bars, err := context.esStock.GetBars(ctx, symbol, start, end)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	return bars, nil
+}
+
+func parseQueryDate(value string, defaultDate time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultDate, nil
+	}
+	date, err := time.Parse(queryDateFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date: %s", value)
+	}
+	return date, nil
+}
+
+var errDaysOrFrom = errors.New("days or from is required")
+
+// DateRangeParams contains the dates of the read routes, formatted as 2006-01-02
+//
+// From and To give the period explicitly, the days of a route count back from To otherwise. AsOf replays
+// a route as it was on a day: data after it is ignored, and the period ends on it at the latest.
+type DateRangeParams struct {
+	From string `schema:"from"`
+	To   string `schema:"to"`
+	AsOf string `schema:"asOf"`
+}
+
+// parseAsOf reads the day the data of a query stops, the day of now without an as-of date
+//
+// The day of now is the last one with data, a later as-of date is rejected.
+func parseAsOf(value string, now time.Time) (time.Time, *HandlerERROR) {
+	last := now.Truncate(24 * time.Hour)
+	asOf, err := parseQueryDate(value, last)
+	if err != nil {
+		return time.Time{}, &HandlerERROR{error: invalidParam("asOf", err), Status: http.StatusBadRequest}
+	}
+	if asOf.After(last) {
+		err = fmt.Errorf("%s is after %s", value, last.Format(queryDateFormat))
+		return time.Time{}, &HandlerERROR{error: invalidParam("asOf", err), Status: http.StatusBadRequest}
+	}
+	return asOf, nil
+}
+
+// period returns the start and the end of the period, days before the end when there is no start
+//
+// The period needs its days or its start.
+func (params DateRangeParams) period(now time.Time, days int) (time.Time, time.Time, *HandlerERROR) {
+	asOf, httpErr := parseAsOf(params.AsOf, now)
+	if httpErr != nil {
+		return time.Time{}, time.Time{}, httpErr
+	}
+	end, err := parseQueryDate(params.To, asOf)
+	if err != nil {
+		return time.Time{}, time.Time{}, &HandlerERROR{error: invalidParam("to", err), Status: http.StatusBadRequest}
+	}
+	if end.After(asOf) {
+		end = asOf
+	}
+	if days == 0 && params.From == "" {
+		return time.Time{}, time.Time{}, &HandlerERROR{error: invalidParam("days", errDaysOrFrom), Status: http.StatusBadRequest}
+	}
+	start, err := parseQueryDate(params.From, end.AddDate(0, 0, -days))
+	if err != nil {
+		return time.Time{}, time.Time{}, &HandlerERROR{error: invalidParam("from", err), Status: http.StatusBadRequest}
+	}
+	if start.After(end) {
+		err = fmt.Errorf("start %s is after end %s", start.Format(queryDateFormat), end.Format(queryDateFormat))
+		return time.Time{}, time.Time{}, &HandlerERROR{error: invalidParam("from", err), Status: http.StatusBadRequest}
+	}
+	return start, end, nil
+}
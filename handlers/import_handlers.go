@@ -0,0 +1,169 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/importer"
+	"github.com/labstack/echo"
+)
+
+// ImportParams contains all the parameters for the import routes
+type ImportParams struct {
+	Profile string `schema:"profile"`
+	Broker  string `schema:"broker" validate:"required"`
+	DryRun  bool   `schema:"dry_run"`
+}
+
+// statementParser reads the positions of a statement whose securities are found through a resolver
+type statementParser func(io.Reader, importer.SymbolResolver, string, string) ([]importer.RowResult, error)
+
+// ImportHandlers handles all requests to import broker statements
+type ImportHandlers struct {
+	*Context
+	errorHandler errorHandlerFunc
+}
+
+// NewImportHandlers creates a new import handlers object
+func NewImportHandlers(context *Context) *ImportHandlers {
+	return &ImportHandlers{
+		Context:      context,
+		errorHandler: handleError,
+	}
+}
+
+// SetProfile handles http request to save a CSV mapping profile
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) SetProfile(c echo.Context) error {
+	profile := new(es.ImportProfile)
+	if err := c.Bind(profile); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if err := handlers.validator.Struct(profile); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	profile.Username = defaultUsername
+	if err := handlers.esProfile.SetProfile(profile); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// GetProfiles handles http request to list the user's CSV mapping profiles
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) GetProfiles(c echo.Context) error {
+	profiles, err := handlers.esProfile.GetProfiles(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, profiles)
+}
+
+// importRows checks the parsed rows against the stored trades and saves them unless in dry run
+func (handlers *ImportHandlers) importRows(c echo.Context, rows []importer.RowResult, dryRun bool) error {
+	existing, err := handlers.esPosition.GetTrades(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	report := importer.Import(rows, existing, handlers.esPosition, handlers.validator, dryRun)
+	return c.JSON(http.StatusOK, report)
+}
+
+// ImportCSV handles http request to import the trades of a broker CSV statement sent as body
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) ImportCSV(c echo.Context) error {
+	var params ImportParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	profile, err := handlers.esProfile.GetProfile(defaultUsername, params.Profile)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	if profile == nil {
+		return handlers.errorHandler(c, http.StatusNotFound, &NotFoundError{Kind: "profile", Name: params.Profile})
+	}
+	rows, err := importer.ParseCSV(c.Request().Body, *profile, defaultUsername, params.Broker)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	return handlers.importRows(c, rows, params.DryRun)
+}
+
+// SetSymbolMapping handles http request to map a security identifier of statements to a symbol
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) SetSymbolMapping(c echo.Context) error {
+	mapping := new(es.SymbolMapping)
+	if err := c.Bind(mapping); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if err := handlers.validator.Struct(mapping); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	mapping.Username = defaultUsername
+	if err := handlers.esMapping.SetSymbolMapping(mapping); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, mapping)
+}
+
+// GetSymbolMappings handles http request to list the user's symbol mappings
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) GetSymbolMappings(c echo.Context) error {
+	mappings, err := handlers.esMapping.GetSymbolMappings(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, mappings)
+}
+
+// importStatement parses a statement sent as body with the user's symbol mappings and imports it
+func (handlers *ImportHandlers) importStatement(c echo.Context, parse statementParser) error {
+	var params ImportParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	mappings, err := handlers.esMapping.GetSymbolMappings(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	rows, err := parse(c.Request().Body, importer.NewSymbolResolver(mappings), defaultUsername, params.Broker)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	return handlers.importRows(c, rows, params.DryRun)
+}
+
+// ImportOFX handles http request to import the investment transactions of an OFX or QFX statement sent as body
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) ImportOFX(c echo.Context) error {
+	return handlers.importStatement(c, importer.ParseOFX)
+}
+
+// ImportQIF handles http request to import the investment transactions of a QIF file sent as body
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ImportHandlers) ImportQIF(c echo.Context) error {
+	return handlers.importStatement(c, importer.ParseQIF)
+}
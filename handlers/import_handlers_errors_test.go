@@ -0,0 +1,277 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	importErrorMsg = "import_error"
+)
+
+var setProfileErrorTests = []struct {
+	echo            echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEchoBind{Msg: importErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		importErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{validator: &ErrorStructValidator{Msg: importErrorMsg}},
+		http.StatusBadRequest,
+		importErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{
+			esProfile: &ErrorEsProfile{Msg: importErrorMsg},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+}
+
+func TestSetProfileErrors(t *testing.T) {
+	for _, tt := range setProfileErrorTests {
+		handlers := ImportHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		res := handlers.SetProfile(tt.echo)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestGetProfilesErrors(t *testing.T) {
+	handlers := ImportHandlers{
+		Context:      &Context{esProfile: &ErrorEsProfile{Msg: importErrorMsg}},
+		errorHandler: createErrorHandler(t, http.StatusInternalServerError, importErrorMsg),
+	}
+	res := handlers.GetProfiles(&DummyEchoBind{})
+	assert.NotNil(t, res)
+}
+
+var importCSVErrorTests = []struct {
+	context         *Context
+	body            string
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: importErrorMsg}},
+		importCSVData,
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Profile: "simple"}},
+			validator: &DummyStructValidator{},
+			esProfile: &ErrorEsProfile{Msg: importErrorMsg},
+		},
+		importCSVData,
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Profile: "unknown"}},
+			validator: &DummyStructValidator{},
+			esProfile: &DummyEsProfile{},
+		},
+		importCSVData,
+		http.StatusNotFound,
+		"unknown profile: unknown",
+	},
+	{
+		&Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Profile: "simple"}},
+			validator: &DummyStructValidator{},
+			esProfile: &DummyEsProfile{Profiles: []es.ImportProfile{importTestProfile}},
+		},
+		"",
+		http.StatusBadRequest,
+		"cannot read header: EOF",
+	},
+	{
+		&Context{
+			sh:         &ImportSchemaDecoder{Params: ImportParams{Profile: "simple"}},
+			validator:  &DummyStructValidator{},
+			esProfile:  &DummyEsProfile{Profiles: []es.ImportProfile{importTestProfile}},
+			esPosition: &ErrorEsPosition{Msg: importErrorMsg},
+		},
+		importCSVData,
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	for _, tt := range importCSVErrorTests {
+		handlers := ImportHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		req, err := http.NewRequest("POST", "http://test.test/import/csv", bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.ImportCSV(c)
+		assert.NotNil(t, res)
+	}
+}
+
+var setSymbolMappingErrorTests = []struct {
+	echo            echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEchoBind{Msg: importErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		importErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{validator: &ErrorStructValidator{Msg: importErrorMsg}},
+		http.StatusBadRequest,
+		importErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{
+			esMapping: &ErrorEsMapping{Msg: importErrorMsg},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+}
+
+func TestSetSymbolMappingErrors(t *testing.T) {
+	for _, tt := range setSymbolMappingErrorTests {
+		handlers := ImportHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		res := handlers.SetSymbolMapping(tt.echo)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestGetSymbolMappingsErrors(t *testing.T) {
+	handlers := ImportHandlers{
+		Context:      &Context{esMapping: &ErrorEsMapping{Msg: importErrorMsg}},
+		errorHandler: createErrorHandler(t, http.StatusInternalServerError, importErrorMsg),
+	}
+	res := handlers.GetSymbolMappings(&DummyEchoBind{})
+	assert.NotNil(t, res)
+}
+
+var importStatementErrorTests = []struct {
+	context         *Context
+	body            string
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: importErrorMsg}},
+		importOFXData,
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Broker: "test"}},
+			validator: &DummyStructValidator{},
+			esMapping: &ErrorEsMapping{Msg: importErrorMsg},
+		},
+		importOFXData,
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Broker: "test"}},
+			validator: &DummyStructValidator{},
+			esMapping: &DummyEsMapping{},
+		},
+		"date,symbol\n",
+		http.StatusBadRequest,
+		"no OFX document",
+	},
+	{
+		&Context{
+			sh:         &ImportSchemaDecoder{Params: ImportParams{Broker: "test"}},
+			validator:  &DummyStructValidator{},
+			esMapping:  &DummyEsMapping{},
+			esPosition: &ErrorEsPosition{Msg: importErrorMsg},
+		},
+		importOFXData,
+		http.StatusInternalServerError,
+		importErrorMsg,
+	},
+}
+
+func TestImportOFXErrors(t *testing.T) {
+	for _, tt := range importStatementErrorTests {
+		handlers := ImportHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		req, err := http.NewRequest("POST", "http://test.test/import/ofx", bytes.NewBufferString(tt.body))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.ImportOFX(c)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestImportQIFErrors(t *testing.T) {
+	handlers := ImportHandlers{
+		Context: &Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Broker: "test"}},
+			validator: &DummyStructValidator{},
+			esMapping: &DummyEsMapping{},
+		},
+		errorHandler: createErrorHandler(t, http.StatusBadRequest, "no investment transactions in QIF"),
+	}
+	req, err := http.NewRequest("POST", "http://test.test/import/qif", bytes.NewBufferString("!Type:Bank\n^\n"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c, _ := createEcho(req)
+	res := handlers.ImportQIF(c)
+	assert.NotNil(t, res)
+}
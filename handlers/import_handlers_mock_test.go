@@ -0,0 +1,97 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+
+	"github.com/clebi/gofin/es"
+)
+
+type DummyEsProfile struct {
+	Profiles []es.ImportProfile
+}
+
+func (profileStock *DummyEsProfile) SetProfile(profile *es.ImportProfile) error {
+	profileStock.Profiles = append(profileStock.Profiles, *profile)
+	return nil
+}
+
+func (profileStock *DummyEsProfile) GetProfile(username string, name string) (*es.ImportProfile, error) {
+	for _, profile := range profileStock.Profiles {
+		if profile.Name == name {
+			return &profile, nil
+		}
+	}
+	return nil, nil
+}
+
+func (profileStock *DummyEsProfile) GetProfiles(username string) ([]es.ImportProfile, error) {
+	return profileStock.Profiles, nil
+}
+
+type ErrorEsProfile struct {
+	Msg string
+}
+
+func (profileStock *ErrorEsProfile) SetProfile(profile *es.ImportProfile) error {
+	return errors.New(profileStock.Msg)
+}
+
+func (profileStock *ErrorEsProfile) GetProfile(username string, name string) (*es.ImportProfile, error) {
+	return nil, errors.New(profileStock.Msg)
+}
+
+func (profileStock *ErrorEsProfile) GetProfiles(username string) ([]es.ImportProfile, error) {
+	return nil, errors.New(profileStock.Msg)
+}
+
+type DummyEsMapping struct {
+	Mappings []es.SymbolMapping
+}
+
+func (mappingStock *DummyEsMapping) SetSymbolMapping(mapping *es.SymbolMapping) error {
+	mappingStock.Mappings = append(mappingStock.Mappings, *mapping)
+	return nil
+}
+
+func (mappingStock *DummyEsMapping) GetSymbolMappings(username string) ([]es.SymbolMapping, error) {
+	return mappingStock.Mappings, nil
+}
+
+type ErrorEsMapping struct {
+	Msg string
+}
+
+func (mappingStock *ErrorEsMapping) SetSymbolMapping(mapping *es.SymbolMapping) error {
+	return errors.New(mappingStock.Msg)
+}
+
+func (mappingStock *ErrorEsMapping) GetSymbolMappings(username string) ([]es.SymbolMapping, error) {
+	return nil, errors.New(mappingStock.Msg)
+}
+
+type ImportSchemaDecoder struct {
+	Params ImportParams
+}
+
+func (decoder *ImportSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*ImportParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for ImportSchemaDecoder")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	setProfileData = "{\"name\":\"simple\",\"date_column\":\"date\",\"date_format\":\"2006-01-02\"," +
+		"\"symbol_column\":\"symbol\",\"quantity_column\":\"quantity\",\"price_column\":\"price\"}"
+	importCSVData = "date,symbol,quantity,price\n" +
+		"2017-04-20,AAPL,10,140\n" +
+		"2017-04-21,AAPL,-2,142\n" +
+		"bad,AAPL,1,1\n"
+	importCSVResult = "{\"dry_run\":true,\"imported\":1,\"duplicates\":1,\"errors\":1,\"rows\":[" +
+		"{\"row\":1,\"status\":\"duplicate\",\"position\":{\"username\":\"tester\",\"broker\":\"test\",\"symbol\":\"AAPL\"," +
+		"\"date\":\"2017-04-20T00:00:00Z\",\"number\":10,\"value\":140,\"cost\":1400}}," +
+		"{\"row\":2,\"status\":\"new\",\"position\":{\"username\":\"tester\",\"broker\":\"test\",\"symbol\":\"AAPL\"," +
+		"\"date\":\"2017-04-21T00:00:00Z\",\"number\":-2,\"value\":142,\"cost\":-284}}," +
+		"{\"row\":3,\"status\":\"error\",\"error\":\"bad date: bad\"}]}"
+	setSymbolMappingData = "{\"identifier\":\"US0378331005\",\"symbol\":\"AAPL\"}"
+	importOFXData        = "<OFX><INVSTMTMSGSRSV1><INVSTMTTRNRS><INVSTMTRS><INVTRANLIST>" +
+		"<BUYSTOCK><INVBUY><INVTRAN><FITID>1001<DTTRADE>20170420</INVTRAN>" +
+		"<SECID><UNIQUEID>US0378331005<UNIQUEIDTYPE>ISIN</SECID>" +
+		"<UNITS>10<UNITPRICE>140<TOTAL>-1400</INVBUY><BUYTYPE>BUY</BUYSTOCK>" +
+		"</INVTRANLIST></INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1></OFX>"
+	importOFXResult = "{\"dry_run\":false,\"imported\":1,\"duplicates\":0,\"errors\":0,\"rows\":[" +
+		"{\"row\":1,\"status\":\"imported\",\"position\":{\"username\":\"tester\",\"broker\":\"test\"," +
+		"\"symbol\":\"AAPL\",\"date\":\"2017-04-20T00:00:00Z\",\"number\":10,\"value\":140,\"cost\":1400," +
+		"\"reference\":\"1001\"}}]}"
+	importQIFData = "!Type:Invst\nD4/20'17\nNDiv\nYApple Inc\nT6.30\n^\n"
+)
+
+var importTestProfile = es.ImportProfile{
+	Name:           "simple",
+	DateColumn:     "date",
+	DateFormat:     "2006-01-02",
+	SymbolColumn:   "symbol",
+	QuantityColumn: "quantity",
+	PriceColumn:    "price",
+}
+
+func TestSetProfile(t *testing.T) {
+	esProfile := &DummyEsProfile{}
+	handlers := &ImportHandlers{
+		Context: &Context{
+			esProfile: esProfile,
+			validator: &DummyStructValidator{},
+		},
+	}
+	req, err := http.NewRequest("PUT", "http://test.test/import/profiles", bytes.NewBufferString(setProfileData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.SetProfile(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, 1, len(esProfile.Profiles))
+	assert.Equal(t, "tester", esProfile.Profiles[0].Username)
+	assert.Equal(t, "symbol", esProfile.Profiles[0].SymbolColumn)
+}
+
+func TestGetProfiles(t *testing.T) {
+	handlers := &ImportHandlers{
+		Context: &Context{esProfile: &DummyEsProfile{Profiles: []es.ImportProfile{{Name: "simple"}}}},
+	}
+	req, err := http.NewRequest("GET", "http://test.test/import/profiles", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetProfiles(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "\"name\":\"simple\"")
+}
+
+func TestImportCSV(t *testing.T) {
+	handlers := &ImportHandlers{
+		Context: &Context{
+			sh:        &ImportSchemaDecoder{Params: ImportParams{Profile: "simple", Broker: "test", DryRun: true}},
+			validator: &DummyStructValidator{},
+			esProfile: &DummyEsProfile{Profiles: []es.ImportProfile{importTestProfile}},
+			esPosition: &DummyEsPosition{Trades: []es.Position{
+				{Broker: "test", Symbol: "AAPL", Date: testBarDate("2017-04-20"), Number: 10, Value: 140, Cost: 1400},
+				// another fill of the same day is not a duplicate
+				{Broker: "test", Symbol: "AAPL", Date: testBarDate("2017-04-20"), Number: 5, Value: 139, Cost: 695},
+			}},
+		},
+	}
+	req, err := http.NewRequest("POST", "http://test.test/import/csv", bytes.NewBufferString(importCSVData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.ImportCSV(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, importCSVResult, resp.Body.String())
+}
+
+func TestSetSymbolMapping(t *testing.T) {
+	esMapping := &DummyEsMapping{}
+	handlers := &ImportHandlers{
+		Context: &Context{
+			esMapping: esMapping,
+			validator: &DummyStructValidator{},
+		},
+	}
+	req, err := http.NewRequest("PUT", "http://test.test/import/symbols", bytes.NewBufferString(setSymbolMappingData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.SetSymbolMapping(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, []es.SymbolMapping{{Username: "tester", Identifier: "US0378331005", Symbol: "AAPL"}}, esMapping.Mappings)
+}
+
+func TestGetSymbolMappings(t *testing.T) {
+	handlers := &ImportHandlers{
+		Context: &Context{esMapping: &DummyEsMapping{Mappings: []es.SymbolMapping{{Identifier: "Apple Inc", Symbol: "AAPL"}}}},
+	}
+	req, err := http.NewRequest("GET", "http://test.test/import/symbols", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetSymbolMappings(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "\"identifier\":\"Apple Inc\"")
+}
+
+func TestImportOFX(t *testing.T) {
+	esPosition := &DummyEsPosition{}
+	handlers := &ImportHandlers{
+		Context: &Context{
+			sh:         &ImportSchemaDecoder{Params: ImportParams{Broker: "test"}},
+			validator:  &DummyStructValidator{},
+			esMapping:  &DummyEsMapping{Mappings: []es.SymbolMapping{{Identifier: "US0378331005", Symbol: "AAPL"}}},
+			esPosition: esPosition,
+		},
+	}
+	req, err := http.NewRequest("POST", "http://test.test/import/ofx", bytes.NewBufferString(importOFXData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.ImportOFX(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, importOFXResult, resp.Body.String())
+}
+
+func TestImportQIF(t *testing.T) {
+	handlers := &ImportHandlers{
+		Context: &Context{
+			sh:         &ImportSchemaDecoder{Params: ImportParams{Broker: "test", DryRun: true}},
+			validator:  &DummyStructValidator{},
+			esMapping:  &DummyEsMapping{Mappings: []es.SymbolMapping{{Identifier: "apple inc", Symbol: "AAPL"}}},
+			esPosition: &DummyEsPosition{},
+		},
+	}
+	req, err := http.NewRequest("POST", "http://test.test/import/qif", bytes.NewBufferString(importQIFData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.ImportQIF(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "\"status\":\"new\"")
+	assert.Contains(t, resp.Body.String(), "\"symbol\":\"AAPL\"")
+	assert.Contains(t, resp.Body.String(), "\"kind\":\"dividend\"")
+}
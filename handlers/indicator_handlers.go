@@ -15,14 +15,26 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/indicators"
+	finance "github.com/clebi/yfinance"
 	"github.com/labstack/echo"
 )
 
+// indicatorLookbackDays is the minimum history read to compute requested indicators
+const indicatorLookbackDays = 365
+
 // Indicator contains all values of a stock indicator
+//
+// V50 and V200 are the standard deviation of the closes over their mean, the analytics route gives the
+// volatility of the returns.
 type Indicator struct {
 	Symbol   string
 	Name     string
@@ -34,8 +46,32 @@ type Indicator struct {
 	V200     float64
 }
 
+// IndicatorSet contains the last values of requested indicators by spec then by output,
+// a value is null while the indicator is warming up
+type IndicatorSet map[string]map[string]*float64
+
+// IndicatorSeriesParams contains all the parameters for the indicator series route
+type IndicatorSeriesParams struct {
+	DateRangeParams
+	Days       int      `schema:"days" validate:"omitempty,gt=0"`
+	Step       int      `schema:"step" validate:"gt=0"`
+	Indicators []string `schema:"ind" validate:"required"`
+}
+
+// IndicatorSeries contains the values of indicators by spec then by output, aligned with the times of the points,
+// a value is null while the indicator is warming up
+type IndicatorSeries struct {
+	Symbol  string                           `json:"symbol"`
+	MsTimes []int64                          `json:"mstime"`
+	Series  map[string]map[string][]*float64 `json:"series"`
+}
+
+// getStocksParams contains the parameters of the indicators route, the values are the last ones on AsOf
+// when it is given, formatted as 2006-01-02
 type getStocksParams struct {
-	Symbols []string `schema:"symbols"`
+	Symbols    []string `schema:"symbols"`
+	Indicators []string `schema:"ind"`
+	AsOf       string   `schema:"asOf"`
 }
 
 // IndicatorHandlers handles all request to avergaes requrests
@@ -56,20 +92,78 @@ func NewIndicatorHandlers(context *Context) IndicatorHandlers {
 	}
 }
 
-func (handlers *IndicatorHandlers) getStockStats(symbol string, numPoints int, endDate time.Time) (*es.StocksStats, error) {
-	startDate, err := handlers.esStock.GetDateForNumPoint(symbol, numPoints, endDate)
+func (handlers *IndicatorHandlers) getStockStats(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*es.StocksStats, error) {
+	startDate, err := handlers.esStock.GetDateForNumPoint(ctx, symbol, numPoints, endDate)
 	if err != nil {
 		return nil, err
 	}
-	stockStats, err := handlers.esStock.GetStockStats(symbol, *startDate, endDate)
+	stockStats, err := handlers.esStock.GetStockStats(ctx, symbol, *startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
 	return stockStats, nil
 }
 
+// historyErrorCode gives the code of an error of the stored history of a symbol, the history is insufficient
+// when it has less points than asked and unknown when it has none
+func (handlers *IndicatorHandlers) historyErrorCode(ctx context.Context, symbol string, endDate time.Time, err error) string {
+	if err != es.ErrNotEnoughPoints {
+		return symbolErrorCode(http.StatusInternalServerError, err)
+	}
+	if _, err := handlers.esStock.GetDateForNumPoint(ctx, symbol, 1, endDate); err == es.ErrNotEnoughPoints {
+		return ErrorCodeNotFound
+	}
+	return ErrorCodeInsufficientData
+}
+
+// symbolIndicator computes the indicator of a symbol, from the stored history only when it is as of a date
+//
+// As of a date, the quote only gives the name of the symbol and a failure of the provider leaves it empty.
+func (handlers *IndicatorHandlers) symbolIndicator(ctx context.Context, symbol string, endDate time.Time, asOf bool) SymbolResult {
+	httpErr := handlers.indexStock(handlers.Context, symbol, endDate.AddDate(0, 0, -365), endDate)
+	if httpErr != nil {
+		return symbolFailure(symbol, symbolErrorCode(httpErr.Status, httpErr.error), httpErr.error)
+	}
+	quote, err := handlers.quotesAPI.GetQuote(symbol)
+	if err != nil {
+		if !asOf {
+			return symbolFailure(symbol, ErrorCodeProviderUnavailable, err)
+		}
+		quote = &finance.Quote{Symbol: symbol}
+	}
+	numPoints := []int{200, 50}
+	if asOf {
+		numPoints = append(numPoints, 1)
+	}
+	stats := make([]*es.StocksStats, len(numPoints))
+	for i, points := range numPoints {
+		if stats[i], err = handlers.getStockStats(ctx, symbol, points, endDate); err != nil {
+			return symbolFailure(symbol, handlers.historyErrorCode(ctx, symbol, endDate, err), err)
+		}
+	}
+	stockStats200, stockStats50 := stats[0], stats[1]
+	value, mm200, mm50 := quote.LastTradePriceOnly, quote.TwoHundreddayMovingAverage, quote.FiftydayMovingAverage
+	if asOf {
+		value, mm200, mm50 = float32(stats[2].Avg), float32(stockStats200.Avg), float32(stockStats50.Avg)
+	}
+	return SymbolResult{Symbol: symbol, Data: Indicator{
+		Symbol:   quote.Symbol,
+		Name:     quote.Name,
+		Value:    value,
+		MM200:    mm200,
+		MM50:     mm50,
+		MM50D200: mm50 / mm200,
+		V200:     stockStats200.StandardDeviation / stockStats200.Avg,
+		V50:      stockStats50.StandardDeviation / stockStats50.Avg,
+	}}
+}
+
 // GetStocks retrieves the indicators for a list of stocks
 //
+// The response is a ListResult with the Indicator of each symbol or, when indicators are requested with the
+// ind parameter, with their last values as an IndicatorSet. With an as-of date, the prices and the moving averages come from the stored history instead of the quotes.
+// The symbols are processed concurrently within the limits of the fan-out of the context.
+//
 // This function is a handler for http server, it should not be called directly
 func (handlers *IndicatorHandlers) GetStocks(c echo.Context) error {
 	endDate := handlers.getDate()
@@ -77,34 +171,177 @@ func (handlers *IndicatorHandlers) GetStocks(c echo.Context) error {
 	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
 		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
 	}
-	indicators := make([]Indicator, len(params.Symbols))
-	for i, symbol := range params.Symbols {
-		httpErr := handlers.indexStock(handlers.Context, symbol, endDate.AddDate(0, 0, -365), endDate)
+	if params.AsOf != "" {
+		asOf, httpErr := parseAsOf(params.AsOf, endDate)
 		if httpErr != nil {
 			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
 		}
-		quote, err := handlers.quotesAPI.GetQuote(symbol)
-		if err != nil {
-			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+		endDate = asOf
+	}
+	if len(params.Indicators) > 0 {
+		return handlers.getIndicatorSets(c, params, endDate)
+	}
+	results := handlers.fanOut.forEachSymbol(c.Request().Context(), params.Symbols, func(ctx context.Context, symbol string) SymbolResult {
+		return handlers.symbolIndicator(ctx, symbol, endDate, params.AsOf != "")
+	})
+	return c.JSON(http.StatusOK, newListResult(results))
+}
+
+// warmUpDays returns the number of days of history needed for the warm-up of indicators
+func warmUpDays(specs []*indicators.Spec) int {
+	days := 0
+	for _, spec := range specs {
+		// two calendar days per bar cover the week-ends and the holidays
+		if need := spec.WarmUp() * 2; need > days {
+			days = need
 		}
-		stockStats200, err := handlers.getStockStats(symbol, 200, endDate)
+	}
+	return days
+}
+
+// lookbackDays returns the number of days of history read to compute the last values of indicators
+func lookbackDays(specs []*indicators.Spec) int {
+	if days := warmUpDays(specs); days > indicatorLookbackDays {
+		return days
+	}
+	return indicatorLookbackDays
+}
+
+func nullable(value float64) *float64 {
+	if math.IsNaN(value) {
+		return nil
+	}
+	return &value
+}
+
+func indicatorSet(specs []*indicators.Spec, bars []es.StockBar) (IndicatorSet, error) {
+	set := IndicatorSet{}
+	for _, spec := range specs {
+		last, err := indicators.Last(spec, bars)
 		if err != nil {
-			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+			return nil, err
+		}
+		values := make(map[string]*float64, len(last))
+		for output, value := range last {
+			values[output] = nullable(value)
 		}
-		stockStats50, err := handlers.getStockStats(symbol, 50, endDate)
+		set[spec.Key] = values
+	}
+	return set, nil
+}
+
+// symbolIndicatorSet computes the last values of indicators on a symbol
+//
+// The history is insufficient when all the indicators are still warming up.
+func symbolIndicatorSet(ctx context.Context, context *Context, index indexStockFunc, symbol string, specs []*indicators.Spec, start time.Time, end time.Time) SymbolResult {
+	bars, httpErr := loadBars(ctx, context, index, symbol, start, end)
+	if httpErr != nil {
+		return symbolFailure(symbol, symbolErrorCode(httpErr.Status, httpErr.error), httpErr.error)
+	}
+	if len(bars) == 0 {
+		return symbolFailure(symbol, ErrorCodeNotFound, fmt.Errorf("no history for %s", symbol))
+	}
+	set, err := indicatorSet(specs, bars)
+	if err != nil {
+		return symbolFailure(symbol, symbolErrorCode(http.StatusInternalServerError, err), err)
+	}
+	for _, values := range set {
+		for _, value := range values {
+			if value != nil {
+				return SymbolResult{Symbol: symbol, Data: set}
+			}
+		}
+	}
+	err = fmt.Errorf("not enough history for the indicators of %s: %d bars", symbol, len(bars))
+	return symbolFailure(symbol, ErrorCodeInsufficientData, err)
+}
+
+// getIndicatorSets computes indicators requested with specs such as rsi:14,ema:20,bb:20:2
+func (handlers *IndicatorHandlers) getIndicatorSets(c echo.Context, params getStocksParams, endDate time.Time) error {
+	specs, err := indicators.ParseSpecs(strings.Join(params.Indicators, ","))
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("ind", err))
+	}
+	startDate := endDate.AddDate(0, 0, -lookbackDays(specs))
+	results := handlers.fanOut.forEachSymbol(c.Request().Context(), params.Symbols, func(ctx context.Context, symbol string) SymbolResult {
+		return symbolIndicatorSet(ctx, handlers.Context, handlers.indexStock, symbol, specs, startDate, endDate)
+	})
+	return c.JSON(http.StatusOK, newListResult(results))
+}
+
+// GetCatalog lists the indicators which can be requested on the indicators route
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *IndicatorHandlers) GetCatalog(c echo.Context) error {
+	return c.JSON(http.StatusOK, indicators.Catalog())
+}
+
+// samplePoints returns the indexes of the last bar of each step of days between two dates
+func samplePoints(bars []es.StockBar, step int, start time.Time, end time.Time) []int {
+	var points []int
+	lastBucket := int64(-1)
+	for i, bar := range bars {
+		if bar.Date.Before(start) || bar.Date.After(end) {
+			continue
+		}
+		bucket := bar.Date.Unix() / int64(step*24*60*60)
+		if len(points) > 0 && bucket == lastBucket {
+			points[len(points)-1] = i
+		} else {
+			points = append(points, i)
+		}
+		lastBucket = bucket
+	}
+	return points
+}
+
+// GetSeries retrieves the values of indicators over the last days, or between dates, for charting
+//
+// Indicators are computed on the daily bars, warmed up on the bars before the period,
+// and one point is kept by step of days, the last bar of the step.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *IndicatorHandlers) GetSeries(c echo.Context) error {
+	var params IndicatorSeriesParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	specs, err := indicators.ParseSpecs(strings.Join(params.Indicators, ","))
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("ind", err))
+	}
+	symbol := c.Param("symbol")
+	start, end, httpErr := params.period(handlers.getDate(), params.Days)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	bars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, start.AddDate(0, 0, -warmUpDays(specs)), end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	points := samplePoints(bars, params.Step, start, end)
+	result := IndicatorSeries{
+		Symbol:  symbol,
+		MsTimes: make([]int64, len(points)),
+		Series:  make(map[string]map[string][]*float64, len(specs)),
+	}
+	for i, point := range points {
+		result.MsTimes[i] = bars[point].Date.Unix() * 1000
+	}
+	for _, spec := range specs {
+		series, err := indicators.Series(spec, bars)
 		if err != nil {
-			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+			return handlers.errorHandler(c, http.StatusBadRequest, err)
 		}
-		indicators[i] = Indicator{
-			Symbol:   quote.Symbol,
-			Name:     quote.Name,
-			Value:    quote.LastTradePriceOnly,
-			MM200:    quote.TwoHundreddayMovingAverage,
-			MM50:     quote.FiftydayMovingAverage,
-			MM50D200: quote.FiftydayMovingAverage / quote.TwoHundreddayMovingAverage,
-			V200:     stockStats200.StandardDeviation / stockStats200.Avg,
-			V50:      stockStats50.StandardDeviation / stockStats50.Avg,
+		outputs := make(map[string][]*float64, len(series))
+		for output, values := range series {
+			sampled := make([]*float64, len(points))
+			for i, point := range points {
+				sampled[i] = nullable(values[point])
+			}
+			outputs[output] = sampled
 		}
+		result.Series[spec.Key] = outputs
 	}
-	return c.JSON(http.StatusOK, indicators)
+	return c.JSON(http.StatusOK, result)
 }
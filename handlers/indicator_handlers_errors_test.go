@@ -19,6 +19,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/clebi/gofin/es"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,12 +32,14 @@ var indicatorGetStocksErrorTests = []struct {
 	expectedStatus  int
 	expectedMessage string
 	indexStockFunc  indexStockFunc
+	symbolCode      string
 }{
 	{
 		&Context{sh: &ErrorSchemaDecoder{Msg: indicatorGetStocksErrorMsg}},
 		http.StatusInternalServerError,
 		indicatorGetStocksErrorMsg,
 		nil,
+		"",
 	},
 	{
 		&Context{
@@ -46,6 +49,7 @@ var indicatorGetStocksErrorTests = []struct {
 		http.StatusInternalServerError,
 		indicatorGetStocksErrorMsg,
 		createTestIndexStockError(http.StatusInternalServerError, indicatorGetStocksErrorMsg),
+		ErrorCodeInternal,
 	},
 	{
 		&Context{
@@ -56,6 +60,7 @@ var indicatorGetStocksErrorTests = []struct {
 		http.StatusInternalServerError,
 		indicatorGetStocksErrorMsg,
 		testIndexStockNoError,
+		ErrorCodeProviderUnavailable,
 	},
 	{
 		&Context{
@@ -67,6 +72,7 @@ var indicatorGetStocksErrorTests = []struct {
 		http.StatusInternalServerError,
 		indicatorGetStocksErrorMsg,
 		testIndexStockNoError,
+		ErrorCodeProviderUnavailable,
 	},
 	{
 		&Context{
@@ -78,6 +84,7 @@ var indicatorGetStocksErrorTests = []struct {
 		http.StatusInternalServerError,
 		indicatorGetStocksErrorMsg,
 		testIndexStockNoError,
+		ErrorCodeInternal,
 	},
 	{
 		&Context{
@@ -89,6 +96,41 @@ var indicatorGetStocksErrorTests = []struct {
 		http.StatusInternalServerError,
 		indicatorGetStocksErrorMsg,
 		testIndexStockNoError,
+		ErrorCodeInternal,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, AsOf: "2016-13-01"},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad date: 2016-13-01",
+		testIndexStockNoError,
+		"",
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}},
+			validator: &DummyStructValidator{},
+			quotesAPI: &DummyQuotesAPI{},
+			esStock:   &ShortHistoryEsStock{points: 100},
+		},
+		http.StatusOK,
+		es.ErrNotEnoughPoints.Error(),
+		testIndexStockNoError,
+		ErrorCodeInsufficientData,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}},
+			validator: &DummyStructValidator{},
+			quotesAPI: &DummyQuotesAPI{},
+			esStock:   &ShortHistoryEsStock{},
+		},
+		http.StatusOK,
+		es.ErrNotEnoughPoints.Error(),
+		testIndexStockNoError,
+		ErrorCodeNotFound,
 	},
 }
 
@@ -104,8 +146,205 @@ func TestGetStocksErrors(t *testing.T) {
 		if err != nil {
 			t.Fatal(err.Error())
 		}
-		c, _ := createEcho(req)
+		c, resp := createEcho(req)
+		res := handlers.GetStocks(c)
+		if tt.symbolCode != "" {
+			assertSymbolFailure(t, resp, tt.symbolCode, tt.expectedMessage)
+			continue
+		}
+		assert.NotNil(t, res)
+	}
+}
+
+var indicatorSetsErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+	symbolCode      string
+}{
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, Indicators: []string{"rsi:14,foo"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"unknown indicator: foo",
+		testIndexStockNoError,
+		"",
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, Indicators: []string{"bb:0"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"period of bb must be at least 1: 0",
+		testIndexStockNoError,
+		"",
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, Indicators: []string{"rsi"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		indicatorGetStocksErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, indicatorGetStocksErrorMsg),
+		ErrorCodeProviderUnavailable,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, Indicators: []string{"rsi"}},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: indicatorGetStocksErrorMsg},
+		},
+		http.StatusInternalServerError,
+		indicatorGetStocksErrorMsg,
+		testIndexStockNoError,
+		ErrorCodeInternal,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, Indicators: []string{"rsi"}},
+			validator: &DummyStructValidator{},
+			esStock:   &BarsEsStock{bars: map[string][]es.StockBar{}},
+		},
+		http.StatusOK,
+		"no history for ERROR",
+		testIndexStockNoError,
+		ErrorCodeNotFound,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"ERROR"}, Indicators: []string{"rsi"}},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"ERROR": createIndicatorBars("ERROR", getTestDate(), 1, 2),
+			}},
+		},
+		http.StatusOK,
+		"not enough history for the indicators of ERROR: 2 bars",
+		testIndexStockNoError,
+		ErrorCodeInsufficientData,
+	},
+}
+
+func TestGetStocksIndicatorSetsErrors(t *testing.T) {
+	for _, tt := range indicatorSetsErrorTests {
+		handlers := IndicatorHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testGetStocksURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, resp := createEcho(req)
 		res := handlers.GetStocks(c)
+		if tt.symbolCode != "" {
+			assertSymbolFailure(t, resp, tt.symbolCode, tt.expectedMessage)
+			continue
+		}
+		assert.NotNil(t, res)
+	}
+}
+
+func TestGetStocksPeriodOutOfRange(t *testing.T) {
+	handlers := IndicatorHandlers{
+		Context: &Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"TEST"}, Indicators: []string{"sma:1e12"}},
+			validator: &DummyStructValidator{},
+		},
+		errorHandler: createInvalidParamHandler(t, "ind", "period of sma must be at most 1000: 1e12"),
+		getDate:      getTestDate,
+		indexStock:   testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testGetStocksURL, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c, _ := createEcho(req)
+	assert.NotNil(t, handlers.GetStocks(c))
+}
+
+var indicatorSeriesErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: indicatorGetStocksErrorMsg}},
+		http.StatusInternalServerError,
+		indicatorGetStocksErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSeriesSchemaDecoder{Days: 10, Step: 1, Indicators: []string{"macd:12:26:x"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad signal of macd: x",
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSeriesSchemaDecoder{Days: 10, Step: 1, Indicators: []string{"rsi"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		indicatorGetStocksErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, indicatorGetStocksErrorMsg),
+	},
+	{
+		&Context{
+			sh:        &IndicatorSeriesSchemaDecoder{Days: 10, Step: 1, Indicators: []string{"rsi"}},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: indicatorGetStocksErrorMsg},
+		},
+		http.StatusInternalServerError,
+		indicatorGetStocksErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &IndicatorSeriesSchemaDecoder{Days: 10, Step: 1, Indicators: []string{"rsi"}, Dates: DateRangeParams{AsOf: "x"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad date: x",
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh: &IndicatorSeriesSchemaDecoder{Step: 1, Indicators: []string{"rsi"},
+				Dates: DateRangeParams{From: "2016-12-01", To: "2016-12-31", AsOf: "2016-11-01"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"start 2016-12-01 is after end 2016-11-01",
+		testIndexStockNoError,
+	},
+}
+
+func TestGetSeriesErrors(t *testing.T) {
+	for _, tt := range indicatorSeriesErrorTests {
+		handlers := IndicatorHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testGetSeriesURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.GetSeries(c)
 		assert.NotNil(t, res)
 	}
 }
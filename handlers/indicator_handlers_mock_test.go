@@ -15,7 +15,9 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/clebi/gofin/es"
@@ -23,12 +25,16 @@ import (
 )
 
 type IndicatorSchemaDecoder struct {
-	Symbols []string
+	Symbols    []string
+	Indicators []string
+	AsOf       string
 }
 
 func (decoder *IndicatorSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
 	if indicator, ok := dst.(*getStocksParams); ok {
 		indicator.Symbols = decoder.Symbols
+		indicator.Indicators = decoder.Indicators
+		indicator.AsOf = decoder.AsOf
 	} else {
 		return errors.New("bad type for IndicatorSchemaDecoder")
 	}
@@ -45,19 +51,44 @@ func (api *IndicatorQuotesAPI) GetQuote(symbol string) (*finance.Quote, error) {
 
 type IndicatorTestEsStock struct {
 	es.Stock
-	index int
-	stats []es.StocksStats
+	mutex    sync.Mutex
+	stats    []es.StocksStats
+	endDates []time.Time
 }
 
-func (mock *IndicatorTestEsStock) GetDateForNumPoint(symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
+func (mock *IndicatorTestEsStock) GetDateForNumPoint(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
 	date := endDate.AddDate(0, 0, numPoints*-1)
 	return &date, nil
 }
 
-func (mock *IndicatorTestEsStock) GetStockStats(symbol string, startDate time.Time, endDate time.Time) (*es.StocksStats, error) {
-	stats := &mock.stats[mock.index]
-	mock.index++
-	return stats, nil
+func (mock *IndicatorTestEsStock) GetStockStats(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) (*es.StocksStats, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+	mock.endDates = append(mock.endDates, endDate)
+	for i, stats := range mock.stats {
+		if stats.Symbol == symbol {
+			mock.stats = append(mock.stats[:i], mock.stats[i+1:]...)
+			return &stats, nil
+		}
+	}
+	return nil, errors.New("no stats for " + symbol)
+}
+
+type ShortHistoryEsStock struct {
+	es.Stock
+	points int
+}
+
+func (mock *ShortHistoryEsStock) GetDateForNumPoint(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
+	if numPoints > mock.points {
+		return nil, es.ErrNotEnoughPoints
+	}
+	date := endDate.AddDate(0, 0, numPoints*-1)
+	return &date, nil
+}
+
+func (mock *ShortHistoryEsStock) GetStockStats(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) (*es.StocksStats, error) {
+	return &es.StocksStats{Symbol: symbol, Avg: 1}, nil
 }
 
 type IndicatorGetStockStatsError struct {
@@ -66,12 +97,12 @@ type IndicatorGetStockStatsError struct {
 	errs  []error
 }
 
-func (mock *IndicatorGetStockStatsError) GetDateForNumPoint(symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
+func (mock *IndicatorGetStockStatsError) GetDateForNumPoint(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
 	date := endDate.AddDate(0, 0, numPoints*-1)
 	return &date, nil
 }
 
-func (mock *IndicatorGetStockStatsError) GetStockStats(symbol string, startDate time.Time, endDate time.Time) (*es.StocksStats, error) {
+func (mock *IndicatorGetStockStatsError) GetStockStats(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) (*es.StocksStats, error) {
 	err := mock.errs[mock.index]
 	mock.index++
 	return nil, err
@@ -82,6 +113,40 @@ type IndicatorGetNumPointsError struct {
 	Msg string
 }
 
-func (mock *IndicatorGetNumPointsError) GetDateForNumPoint(symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
+func (mock *IndicatorGetNumPointsError) GetDateForNumPoint(ctx context.Context, symbol string, numPoints int, endDate time.Time) (*time.Time, error) {
 	return nil, errors.New(mock.Msg)
 }
+
+func createIndicatorBars(symbol string, end time.Time, closes ...float64) []es.StockBar {
+	bars := make([]es.StockBar, len(closes))
+	for i, close := range closes {
+		bars[i] = es.StockBar{
+			Symbol: symbol,
+			Date:   end.AddDate(0, 0, i-len(closes)+1),
+			High:   close + 1,
+			Low:    close - 1,
+			Close:  close,
+			Volume: 100,
+		}
+	}
+	return bars
+}
+
+type IndicatorSeriesSchemaDecoder struct {
+	Days       int
+	Step       int
+	Indicators []string
+	Dates      DateRangeParams
+}
+
+func (decoder *IndicatorSeriesSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*IndicatorSeriesParams); ok {
+		params.DateRangeParams = decoder.Dates
+		params.Days = decoder.Days
+		params.Step = decoder.Step
+		params.Indicators = decoder.Indicators
+	} else {
+		return errors.New("bad type for IndicatorSeriesSchemaDecoder")
+	}
+	return nil
+}
@@ -16,8 +16,10 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/clebi/gofin/es"
 	finance "github.com/clebi/yfinance"
@@ -25,10 +27,12 @@ import (
 )
 
 const (
+	testGetSeriesURL       = "http://test.test/indicators/TEST1/series"
 	testGetStocksURL       = "http://test.test/indicator"
-	testGetStocksResultStr = "[{\"Symbol\":\"TEST1\",\"Name\":\"TEST_NAME_1\",\"Value\":1.1,\"MM200\":1.3,\"MM50\":1.2," +
-		"\"MM50D200\":0.923077,\"V50\":0.2,\"V200\":0.1},{\"Symbol\":\"TEST2\",\"Name\":\"TEST_NAME_2\",\"Value\":2.1," +
-		"\"MM200\":2.3,\"MM50\":2.2,\"MM50D200\":0.9565218,\"V50\":0.5,\"V200\":0.25}]"
+	testGetStocksResultStr = "{\"status\":\"ok\",\"results\":[{\"symbol\":\"TEST1\",\"data\":{\"Symbol\":\"TEST1\"," +
+		"\"Name\":\"TEST_NAME_1\",\"Value\":1.1,\"MM200\":1.3,\"MM50\":1.2,\"MM50D200\":0.923077,\"V50\":0.2,\"V200\":0.1}}," +
+		"{\"symbol\":\"TEST2\",\"data\":{\"Symbol\":\"TEST2\",\"Name\":\"TEST_NAME_2\",\"Value\":2.1,\"MM200\":2.3," +
+		"\"MM50\":2.2,\"MM50D200\":0.9565218,\"V50\":0.5,\"V200\":0.25}}]}"
 )
 
 func TestGetStocks(t *testing.T) {
@@ -55,7 +59,6 @@ func TestGetStocks(t *testing.T) {
 				},
 			}},
 			esStock: &IndicatorTestEsStock{
-				index: 0,
 				stats: []es.StocksStats{
 					{Symbol: "TEST1", Avg: 10, StandardDeviation: 1},
 					{Symbol: "TEST1", Avg: 20, StandardDeviation: 4},
@@ -77,3 +80,199 @@ func TestGetStocks(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
 	assert.Equal(t, testGetStocksResultStr, resp.Body.String())
 }
+
+func TestGetStocksAsOf(t *testing.T) {
+	esStock := &IndicatorTestEsStock{stats: []es.StocksStats{
+		{Symbol: "TEST1", Avg: 10, StandardDeviation: 1},
+		{Symbol: "TEST1", Avg: 20, StandardDeviation: 4},
+		{Symbol: "TEST1", Avg: 15},
+	}}
+	handlers := &IndicatorHandlers{
+		Context: &Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"TEST1"}, AsOf: "2016-06-01"},
+			validator: &DummyStructValidator{},
+			quotesAPI: &IndicatorQuotesAPI{quotes: map[string]*finance.Quote{
+				"TEST1": {Symbol: "TEST1", Name: "TEST_NAME_1", LastTradePriceOnly: 1.1},
+			}},
+			esStock: esStock,
+		},
+		getDate:    getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testGetStocksURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetStocks(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, "{\"status\":\"ok\",\"results\":[{\"symbol\":\"TEST1\",\"data\":{\"Symbol\":\"TEST1\","+
+		"\"Name\":\"TEST_NAME_1\",\"Value\":15,\"MM200\":10,\"MM50\":20,\"MM50D200\":2,\"V50\":0.2,\"V200\":0.1}}]}",
+		resp.Body.String())
+	asOf := testBarDate("2016-06-01")
+	assert.Equal(t, []time.Time{asOf, asOf, asOf}, esStock.endDates)
+}
+
+func TestGetStocksAsOfQuoteError(t *testing.T) {
+	handlers := &IndicatorHandlers{
+		Context: &Context{
+			sh:        &IndicatorSchemaDecoder{Symbols: []string{"TEST1"}, AsOf: "2016-06-01"},
+			validator: &DummyStructValidator{},
+			quotesAPI: &ErrorQuotesAPI{Msg: "quote_error"},
+			esStock: &IndicatorTestEsStock{stats: []es.StocksStats{
+				{Symbol: "TEST1", Avg: 10, StandardDeviation: 1},
+				{Symbol: "TEST1", Avg: 20, StandardDeviation: 4},
+				{Symbol: "TEST1", Avg: 15},
+			}},
+		},
+		getDate:    getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testGetStocksURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetStocks(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, "{\"status\":\"ok\",\"results\":[{\"symbol\":\"TEST1\",\"data\":{\"Symbol\":\"TEST1\","+
+		"\"Name\":\"\",\"Value\":15,\"MM200\":10,\"MM50\":20,\"MM50D200\":2,\"V50\":0.2,\"V200\":0.1}}]}",
+		resp.Body.String())
+}
+
+func TestGetStocksIndicatorSets(t *testing.T) {
+	handlers := &IndicatorHandlers{
+		Context: &Context{
+			sh: &IndicatorSchemaDecoder{
+				Symbols:    []string{"TEST1", "TEST2"},
+				Indicators: []string{"sma:3,rsi:2", "bb:30"},
+			},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getTestDate(), 1, 2, 3, 4, 5),
+				"TEST2": createIndicatorBars("TEST2", getTestDate(), 5, 4, 3, 4),
+			}},
+		},
+		getDate:    getTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testGetStocksURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetStocks(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var result struct {
+		Status  string
+		Results []struct {
+			Symbol string
+			Data   IndicatorSet
+		}
+	}
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &result))
+	assert.Equal(t, ListStatusOK, result.Status)
+	sets := map[string]IndicatorSet{}
+	for _, symbolResult := range result.Results {
+		sets[symbolResult.Symbol] = symbolResult.Data
+	}
+	assert.Equal(t, 4.0, *sets["TEST1"]["sma:3"]["value"])
+	assert.Equal(t, 100.0, *sets["TEST1"]["rsi:2"]["value"])
+	assert.Nil(t, sets["TEST1"]["bb:30"]["upper"])
+	assert.InDelta(t, 11.0/3, *sets["TEST2"]["sma:3"]["value"], 1e-9)
+	assert.Equal(t, 3, len(sets["TEST2"]["bb:30"]))
+}
+
+func TestGetCatalog(t *testing.T) {
+	handlers := NewIndicatorHandlers(&Context{})
+	req, err := http.NewRequest("GET", "http://test.test/indicators/catalog", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetCatalog(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var catalog []struct {
+		Name    string
+		Outputs []string
+	}
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &catalog))
+	assert.Equal(t, "sma", catalog[0].Name)
+	assert.Contains(t, resp.Body.String(), "\"name\":\"macd\"")
+}
+
+func getSeriesTestDate() time.Time {
+	return getTestDate().Truncate(24 * time.Hour)
+}
+
+func TestGetSeries(t *testing.T) {
+	for _, tt := range []struct {
+		step        int
+		expectedSMA []float64
+	}{
+		{1, []float64{2, 3, 4, 5}},
+		{2, []float64{2, 4, 5}},
+	} {
+		handlers := &IndicatorHandlers{
+			Context: &Context{
+				sh:        &IndicatorSeriesSchemaDecoder{Days: 3, Step: tt.step, Indicators: []string{"sma:3", "bb:10"}},
+				validator: &DummyStructValidator{},
+				esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+					"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 1, 2, 3, 4, 5, 6),
+				}},
+			},
+			getDate:    getTestDate,
+			indexStock: testIndexStockNoError,
+		}
+		req, err := http.NewRequest("GET", testGetSeriesURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST1")
+		handlers.GetSeries(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var series IndicatorSeries
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &series))
+		assert.Equal(t, "TEST1", series.Symbol)
+		assert.Equal(t, len(tt.expectedSMA), len(series.MsTimes))
+		assert.Equal(t, getSeriesTestDate().Unix()*1000, series.MsTimes[len(series.MsTimes)-1])
+		for i, expected := range tt.expectedSMA {
+			assert.Equal(t, expected, *series.Series["sma:3"]["value"][i])
+			assert.Nil(t, series.Series["bb:10"]["upper"][i])
+		}
+	}
+}
+
+func TestGetSeriesDates(t *testing.T) {
+	for _, dates := range []DateRangeParams{
+		{From: getSeriesTestDate().AddDate(0, 0, -3).Format(queryDateFormat), To: getSeriesTestDate().AddDate(0, 0, -1).Format(queryDateFormat)},
+		{To: getSeriesTestDate().Format(queryDateFormat), AsOf: getSeriesTestDate().AddDate(0, 0, -1).Format(queryDateFormat)},
+	} {
+		handlers := &IndicatorHandlers{
+			Context: &Context{
+				sh:        &IndicatorSeriesSchemaDecoder{Days: 2, Step: 1, Indicators: []string{"sma:3"}, Dates: dates},
+				validator: &DummyStructValidator{},
+				esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+					"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 1, 2, 3, 4, 5, 6),
+				}},
+			},
+			getDate:    getTestDate,
+			indexStock: testIndexStockNoError,
+		}
+		req, err := http.NewRequest("GET", testGetSeriesURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST1")
+		handlers.GetSeries(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var series IndicatorSeries
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &series))
+		assert.Equal(t, getSeriesTestDate().AddDate(0, 0, -1).Unix()*1000, series.MsTimes[len(series.MsTimes)-1])
+		assert.Equal(t, []*float64{nullable(2), nullable(3), nullable(4)}, series.Series["sma:3"]["value"])
+	}
+}
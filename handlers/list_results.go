@@ -0,0 +1,81 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import log "github.com/Sirupsen/logrus"
+
+// Overall statuses of the list routes
+const (
+	ListStatusOK      = "ok"
+	ListStatusPartial = "partial"
+	ListStatusFailed  = "failed"
+)
+
+// SymbolError is the failure of a symbol in a list route
+type SymbolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SymbolResult is the result of a symbol in a list route, either its data or its error
+type SymbolResult struct {
+	Symbol string       `json:"symbol"`
+	Data   interface{}  `json:"data,omitempty"`
+	Error  *SymbolError `json:"error,omitempty"`
+}
+
+// ListResult is the response of a list route, a failing symbol does not prevent the others from being returned
+//
+// Status is ok when all the symbols succeeded, partial when only some of them did and failed otherwise.
+type ListResult struct {
+	Status  string         `json:"status"`
+	Results []SymbolResult `json:"results"`
+}
+
+// symbolFailure gives the result of a failing symbol, the message of the internal and storage errors is left
+// empty as the detail of their problems and the errors are logged instead
+func symbolFailure(symbol string, code string, err error) SymbolResult {
+	if !exposesDetail(code) {
+		log.Error(err)
+		return SymbolResult{Symbol: symbol, Error: &SymbolError{Code: code}}
+	}
+	return SymbolResult{Symbol: symbol, Error: &SymbolError{Code: code, Message: err.Error()}}
+}
+
+// symbolErrorCode gives the code of the error of a symbol, the one of a route failing with the same error
+func symbolErrorCode(status int, err error) string {
+	_, code := errorCode(status, err)
+	return code
+}
+
+// listStatus returns the overall status of the results of symbols
+func listStatus(results []SymbolResult) string {
+	failed := 0
+	for _, result := range results {
+		if result.Error != nil {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return ListStatusOK
+	case failed < len(results):
+		return ListStatusPartial
+	}
+	return ListStatusFailed
+}
+
+func newListResult(results []SymbolResult) *ListResult {
+	return &ListResult{Status: listStatus(results), Results: results}
+}
@@ -0,0 +1,156 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/portfolio"
+	"github.com/labstack/echo"
+)
+
+// barsLookbackDays is the number of days read before a period to know the prices at its start
+const barsLookbackDays = 10
+
+// PerformanceParams contains all the parameters for the performance route
+type PerformanceParams struct {
+	Period   string `schema:"period"`
+	Currency string `schema:"currency" validate:"omitempty,len=3"`
+}
+
+// PerformanceReport contains the performance of the portfolio, of each broker and of each position
+type PerformanceReport struct {
+	Period    string                            `json:"period"`
+	Currency  string                            `json:"currency,omitempty"`
+	Portfolio *portfolio.Performance            `json:"portfolio"`
+	Brokers   map[string]*portfolio.Performance `json:"brokers"`
+	Positions map[string]*portfolio.Performance `json:"positions"`
+}
+
+// PerformanceHandlers handles all requests about returns of the positions
+type PerformanceHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	errorHandler errorHandlerFunc
+	indexStock   indexStockFunc
+}
+
+// NewPerformanceHandlers creates a new performance handlers object
+func NewPerformanceHandlers(context *Context) *PerformanceHandlers {
+	return &PerformanceHandlers{
+		Context:      context,
+		getDate:      getYesterDayDate,
+		errorHandler: handleError,
+		indexStock:   indexStock,
+	}
+}
+
+func groupTrades(trades []es.Position, key func(trade es.Position) string) map[string][]es.Position {
+	groups := map[string][]es.Position{}
+	for _, trade := range trades {
+		groups[key(trade)] = append(groups[key(trade)], trade)
+	}
+	return groups
+}
+
+// returnFlows keeps the buys, the sells and the dividends, which are the income of the positions, fees and cash
+// transfers are left out
+func returnFlows(positions []es.Position) []es.Position {
+	var flows []es.Position
+	for _, position := range positions {
+		if position.Kind == "" || position.Kind == es.KindDividend {
+			flows = append(flows, position)
+		}
+	}
+	return flows
+}
+
+// computeGroups computes the performance of each group of trades, groups without any activity are left out
+func computeGroups(
+	groups map[string][]es.Position,
+	bars map[string][]es.StockBar,
+	start time.Time,
+	end time.Time,
+	exchange *portfolio.Exchange) map[string]*portfolio.Performance {
+	perfs := map[string]*portfolio.Performance{}
+	for name, trades := range groups {
+		perf, err := portfolio.Compute(trades, bars, start, end, exchange)
+		if err != nil || (perf.StartValue == 0 && perf.EndValue == 0 && perf.NetFlows == 0) {
+			continue
+		}
+		perfs[name] = perf
+	}
+	return perfs
+}
+
+// GetPerformance computes the time-weighted and money-weighted returns of the user's positions
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *PerformanceHandlers) GetPerformance(c echo.Context) error {
+	var params PerformanceParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	positions, err := handlers.esPosition.GetTrades(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	trades := returnFlows(positions)
+	report := PerformanceReport{
+		Period:    params.Period,
+		Brokers:   map[string]*portfolio.Performance{},
+		Positions: map[string]*portfolio.Performance{},
+	}
+	if len(trades) == 0 {
+		return c.JSON(http.StatusOK, report)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	inception := trades[0].Date
+	for _, trade := range trades {
+		if trade.Date.Before(inception) {
+			inception = trade.Date
+		}
+	}
+	start, err := portfolio.PeriodStart(params.Period, end, inception.Truncate(24*time.Hour))
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	bySymbol := groupTrades(trades, func(trade es.Position) string { return trade.Symbol })
+	bars := map[string][]es.StockBar{}
+	var symbols []string
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+		symbolBars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, start.AddDate(0, 0, -barsLookbackDays), end)
+		if httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+		bars[symbol] = symbolBars
+	}
+	exchange, httpErr := loadExchange(handlers.Context, params.Currency, symbols, trades, start, end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	report.Currency = strings.ToUpper(params.Currency)
+	report.Portfolio, err = portfolio.Compute(trades, bars, start, end, exchange)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	report.Brokers = computeGroups(groupTrades(trades, func(trade es.Position) string { return trade.Broker }), bars, start, end, exchange)
+	report.Positions = computeGroups(bySymbol, bars, start, end, exchange)
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	performanceErrorMsg = "performance_error"
+)
+
+var performanceErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: performanceErrorMsg}},
+		http.StatusInternalServerError,
+		performanceErrorMsg,
+		nil,
+	},
+	{
+		&Context{
+			sh:         &PerformanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &ErrorEsPosition{Msg: performanceErrorMsg},
+		},
+		http.StatusInternalServerError,
+		performanceErrorMsg,
+		nil,
+	},
+	{
+		&Context{
+			sh:         &PerformanceSchemaDecoder{Period: "10y"},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: performanceTestTrades},
+		},
+		http.StatusBadRequest,
+		"unknown period: 10y",
+		nil,
+	},
+	{
+		&Context{
+			sh:         &PerformanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: performanceTestTrades},
+		},
+		http.StatusBadRequest,
+		performanceErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, performanceErrorMsg),
+	},
+	{
+		&Context{
+			sh:         &PerformanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: performanceTestTrades},
+			esStock:    &ErrorBarsEsStock{Msg: performanceErrorMsg},
+		},
+		http.StatusInternalServerError,
+		performanceErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:         &PerformanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: performanceTestTrades},
+			esStock:    &BarsEsStock{},
+		},
+		http.StatusBadRequest,
+		"no prices for the period",
+		testIndexStockNoError,
+	},
+}
+
+func TestGetPerformanceErrors(t *testing.T) {
+	for _, tt := range performanceErrorTests {
+		handlers := PerformanceHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getPerformanceTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testPerformanceURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.GetPerformance(c)
+		assert.NotNil(t, res)
+	}
+}
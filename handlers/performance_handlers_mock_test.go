@@ -0,0 +1,62 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+type BarsEsStock struct {
+	es.Stock
+	bars map[string][]es.StockBar
+}
+
+func (mock *BarsEsStock) GetBars(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) ([]es.StockBar, error) {
+	var bars []es.StockBar
+	for _, bar := range mock.bars[symbol] {
+		if !bar.Date.Before(startDate) && !bar.Date.After(endDate) {
+			bars = append(bars, bar)
+		}
+	}
+	return bars, nil
+}
+
+type ErrorBarsEsStock struct {
+	es.Stock
+	Msg string
+}
+
+func (mock *ErrorBarsEsStock) GetBars(ctx context.Context, symbol string, startDate time.Time, endDate time.Time) ([]es.StockBar, error) {
+	return nil, errors.New(mock.Msg)
+}
+
+type PerformanceSchemaDecoder struct {
+	Period   string
+	Currency string
+}
+
+func (decoder *PerformanceSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*PerformanceParams); ok {
+		params.Period = decoder.Period
+		params.Currency = decoder.Currency
+	} else {
+		return errors.New("bad type for PerformanceSchemaDecoder")
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPerformanceURL = "http://test.test/performance?period=inception"
+
+func getPerformanceTestDate() time.Time {
+	return testBarDate("2019-01-01")
+}
+
+func testBarDate(value string) time.Time {
+	date, _ := time.Parse("2006-01-02", value)
+	return date
+}
+
+var performanceTestBars = map[string][]es.StockBar{
+	"TEST1": {
+		{Symbol: "TEST1", Date: testBarDate("2017-01-01"), Close: 100},
+		{Symbol: "TEST1", Date: testBarDate("2018-01-01"), Close: 110},
+		{Symbol: "TEST1", Date: testBarDate("2019-01-01"), Close: 121},
+	},
+	"TEST2": {
+		{Symbol: "TEST2", Date: testBarDate("2018-01-01"), Close: 50},
+		{Symbol: "TEST2", Date: testBarDate("2019-01-01"), Close: 40},
+	},
+}
+
+var performanceTestTrades = []es.Position{
+	{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2017-01-01"), Number: 10, Value: 100, Cost: 1000},
+	{Broker: "B2", Symbol: "TEST2", Date: testBarDate("2018-01-01"), Number: 10, Value: 50, Cost: 500},
+	{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2018-01-01"), Number: -5, Value: 110, Cost: -550},
+	{Broker: "B1", Date: testBarDate("2016-12-30"), Cost: 2000, Kind: es.KindTransfer},
+	{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2018-06-01"), Cost: -20, Kind: es.KindDividend},
+}
+
+func TestGetPerformance(t *testing.T) {
+	handlers := &PerformanceHandlers{
+		Context: &Context{
+			sh:         &PerformanceSchemaDecoder{Period: "inception"},
+			validator:  &DummyStructValidator{},
+			esStock:    &BarsEsStock{bars: performanceTestBars},
+			esPosition: &DummyEsPosition{Trades: performanceTestTrades},
+		},
+		getDate:    getPerformanceTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testPerformanceURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetPerformance(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var report PerformanceReport
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "inception", report.Period)
+	assert.Equal(t, 1000.0, report.Portfolio.StartValue)
+	assert.Equal(t, 1005.0, report.Portfolio.EndValue)
+	// the dividend is income of the valuation day which follows it
+	assert.InDelta(t, 1.1*1025.0/1050.0-1, report.Portfolio.TWR, 1e-9)
+	assert.InDelta(t, 1.1*625.0/550.0-1, report.Brokers["B1"].TWR, 1e-9)
+	assert.InDelta(t, -0.2, report.Brokers["B2"].TWR, 1e-9)
+	assert.InDelta(t, 1.1*625.0/550.0-1, report.Positions["TEST1"].TWR, 1e-9)
+	assert.InDelta(t, -0.2, report.Positions["TEST2"].TWR, 1e-9)
+}
+
+func TestGetPerformanceCurrency(t *testing.T) {
+	handlers := &PerformanceHandlers{
+		Context: &Context{
+			sh:         &PerformanceSchemaDecoder{Period: "inception", Currency: "eur"},
+			validator:  &DummyStructValidator{},
+			esStock:    &BarsEsStock{bars: performanceTestBars},
+			esPosition: &DummyEsPosition{Trades: performanceTestTrades},
+			esFx: &DummyEsFx{Rates: []es.FxRate{
+				{From: "EUR", To: "USD", Date: testBarDate("2017-01-01"), Rate: 1},
+				{From: "EUR", To: "USD", Date: testBarDate("2018-01-01"), Rate: 1.25},
+			}},
+		},
+		getDate:    getPerformanceTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testPerformanceURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetPerformance(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var report PerformanceReport
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "EUR", report.Currency)
+	assert.InDelta(t, 1.1*625.0/550.0-1, report.Positions["TEST1"].PriceReturn, 1e-9)
+	assert.InDelta(t, 1.1*625.0/550.0*0.8-1, report.Positions["TEST1"].TWR, 1e-9)
+	assert.InDelta(t, 0.8-1, report.Positions["TEST1"].FXReturn, 1e-9)
+}
+
+func TestGetPerformanceNoTrades(t *testing.T) {
+	handlers := &PerformanceHandlers{
+		Context: &Context{
+			sh:         &PerformanceSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{},
+		},
+		getDate:    getPerformanceTestDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", testPerformanceURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetPerformance(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, "{\"period\":\"\",\"portfolio\":null,\"brokers\":{},\"positions\":{}}", resp.Body.String())
+}
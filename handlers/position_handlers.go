@@ -15,23 +15,46 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/portfolio"
 	"github.com/labstack/echo"
 )
 
+// defaultUsername is the user owning the positions until users are authenticated
+const defaultUsername = "tester"
+
+// positionPriceDays is the number of days searched back for the close valuing a position on an as-of date
+const positionPriceDays = 14
+
+// PositionParams contains all the parameters for the positions route
+//
+// AsOf, formatted as 2006-01-02, gives the positions held at the end of a day valued at its close.
+type PositionParams struct {
+	Currency string `schema:"currency" validate:"omitempty,len=3"`
+	AsOf     string `schema:"asOf"`
+}
+
 // PositionDisplay contains all fields to display to the client
+//
+// Cost and Value are in Currency, the currency of the symbol unless a base currency is asked.
 type PositionDisplay struct {
 	es.PositionAgg
-	Name  string
-	Value float32
+	Name     string
+	Value    float32
+	Currency string
 }
 
 // PositionHandlers handles all request to position management
 type PositionHandlers struct {
 	*Context
 	errorHandler errorHandlerFunc
+	indexStock   indexStockFunc
 }
 
 // NewPositionHandlers creates a new position handlers object
@@ -39,7 +62,67 @@ func NewPositionHandlers(context *Context) *PositionHandlers {
 	return &PositionHandlers{
 		Context:      context,
 		errorHandler: handleError,
+		indexStock:   indexStock,
+	}
+}
+
+// getPositions returns the positions of the user, the ones held on the as-of date when it is given
+//
+// With a base currency, the trades are converted at the rate of their day before being aggregated and the returned
+// exchange converts the values.
+func (handlers *PositionHandlers) getPositions(
+	params PositionParams,
+	asOf time.Time) ([]es.PositionAgg, *portfolio.Exchange, *HandlerERROR) {
+	if params.AsOf == "" && params.Currency == "" {
+		positions, err := handlers.esPosition.GetPositions(defaultUsername)
+		if err != nil {
+			return nil, nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+		}
+		return positions, nil, nil
+	}
+	trades, err := handlers.esPosition.GetTrades(defaultUsername)
+	if err != nil {
+		return nil, nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	end := asOf.AddDate(0, 0, 1)
+	held := []es.Position{}
+	start := asOf
+	for _, trade := range trades {
+		if trade.Kind != "" || !trade.Date.Before(end) {
+			continue
+		}
+		if trade.Date.Before(start) {
+			start = trade.Date
+		}
+		held = append(held, trade)
+	}
+	positions := positionsAsOf(held, asOf)
+	symbols := make([]string, len(positions))
+	for i, position := range positions {
+		symbols[i] = position.Symbol
 	}
+	exchange, httpErr := loadExchange(handlers.Context, params.Currency, symbols, held, start, asOf)
+	if httpErr != nil {
+		return nil, nil, httpErr
+	}
+	converted, err := portfolio.ConvertTrades(held, exchange, strings.ToUpper(params.Currency))
+	if err != nil {
+		return nil, nil, &HandlerERROR{error: err, Status: http.StatusBadRequest}
+	}
+	return positionsAsOf(converted, asOf), exchange, nil
+}
+
+// closeAsOf returns the last close of a symbol on or before a day
+func (handlers *PositionHandlers) closeAsOf(ctx context.Context, symbol string, asOf time.Time) (float32, *HandlerERROR) {
+	bars, httpErr := loadBars(ctx, handlers.Context, handlers.indexStock, symbol, asOf.AddDate(0, 0, -positionPriceDays), asOf)
+	if httpErr != nil {
+		return 0, httpErr
+	}
+	if len(bars) == 0 {
+		err := fmt.Errorf("no price for %s on %s", symbol, asOf.Format(queryDateFormat))
+		return 0, &HandlerERROR{error: err, Status: http.StatusBadRequest}
+	}
+	return float32(bars[len(bars)-1].Close), nil
 }
 
 // AddPosition handles http request to save a position
@@ -51,7 +134,7 @@ func (handlers *PositionHandlers) AddPosition(c echo.Context) error {
 		return handlers.errorHandler(c, http.StatusBadRequest, err)
 	}
 	if err := handlers.validator.Struct(position); err != nil {
-		return handlers.errorHandler(c, http.StatusBadRequest, err)
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
 	}
 	if err := handlers.esPosition.AddPosition(position); err != nil {
 		return handlers.errorHandler(c, http.StatusBadRequest, err)
@@ -62,18 +145,62 @@ func (handlers *PositionHandlers) AddPosition(c echo.Context) error {
 // GetPositions handles http request to retrieve the user's positions
 //
 // This function is a handler for http server, it should not be called directly
+//
+// With a base currency, the cost of each trade is converted at the exchange rate of its day and the values at the last
+// known exchange rate.
+// With an as-of date, the trades made later are ignored and the positions are valued at the close and the
+// exchange rate of the day.
 func (handlers *PositionHandlers) GetPositions(c echo.Context) error {
-	positions, err := handlers.esPosition.GetPositions("tester")
-	if err != nil {
-		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	var params PositionParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	now := time.Now()
+	if params.AsOf != "" {
+		asOf, httpErr := parseAsOf(params.AsOf, getYesterDayDate())
+		if httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+		now = asOf
+	}
+	positions, exchange, httpErr := handlers.getPositions(params, now)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	symbols := make([]string, len(positions))
+	for i, position := range positions {
+		symbols[i] = position.Symbol
+	}
+	currencies, httpErr := symbolCurrencies(handlers.Context, symbols)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
 	}
 	displayPosition := make([]PositionDisplay, len(positions))
 	for i, position := range positions {
 		quote, err := handlers.quotesAPI.GetQuote(position.Symbol)
 		if err != nil {
-			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+			return handlers.errorHandler(c, http.StatusInternalServerError, &ProviderError{Err: err})
+		}
+		value := quote.LastTradePriceOnly
+		if params.AsOf != "" {
+			if value, httpErr = handlers.closeAsOf(c.Request().Context(), position.Symbol, now); httpErr != nil {
+				return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+			}
+		}
+		displayPosition[i] = PositionDisplay{
+			PositionAgg: position,
+			Name:        quote.Name,
+			Value:       value,
+			Currency:    currencies[position.Symbol],
+		}
+		if exchange != nil {
+			rate, err := exchange.SymbolRate(position.Symbol, now)
+			if err != nil {
+				return handlers.errorHandler(c, http.StatusBadRequest, err)
+			}
+			displayPosition[i].Value *= float32(rate)
+			displayPosition[i].Currency = strings.ToUpper(params.Currency)
 		}
-		displayPosition[i] = PositionDisplay{PositionAgg: position, Name: quote.Name, Value: quote.LastTradePriceOnly}
 	}
 	return c.JSON(http.StatusOK, displayPosition)
 }
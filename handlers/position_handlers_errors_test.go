@@ -73,26 +73,87 @@ func TestAddPositionErrors(t *testing.T) {
 }
 
 var getPositionErrorTests = []struct {
-	echo            echo.Context
 	context         *Context
 	expectedStatus  int
 	expectedMessage string
 }{
 	{
-		&DummyEchoBind{},
-		&Context{esPosition: &ErrorEsPosition{Msg: positionErrorMsg}},
+		&Context{sh: &ErrorSchemaDecoder{Msg: positionErrorMsg}},
+		http.StatusInternalServerError,
+		positionErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &PositionSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &ErrorEsPosition{Msg: positionErrorMsg},
+		},
 		http.StatusInternalServerError,
 		positionErrorMsg,
 	},
 	{
-		&DummyEchoBind{},
 		&Context{
+			sh:         &PositionSchemaDecoder{},
+			validator:  &DummyStructValidator{},
 			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "test_agg", Number: 5, Cost: 14}}},
+			esFx:       &ErrorEsFx{Msg: positionErrorMsg},
+		},
+		http.StatusInternalServerError,
+		positionErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &PositionSchemaDecoder{},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{PositionAgg: []es.PositionAgg{{Symbol: "test_agg", Number: 5, Cost: 14}}},
+			esFx:       &DummyEsFx{},
 			quotesAPI:  &ErrorQuotesAPI{Msg: positionQuoteAPIErrorMsg},
 		},
 		http.StatusInternalServerError,
 		positionQuoteAPIErrorMsg,
 	},
+	{
+		&Context{
+			sh:        &PositionSchemaDecoder{Params: PositionParams{Currency: "EUR"}},
+			validator: &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: []es.Position{
+				{Symbol: "test_agg", Date: testBarDate("2017-01-02"), Number: 5, Cost: 14},
+			}},
+			esFx:      &DummyEsFx{},
+			quotesAPI: &DummyQuotesAPI{},
+		},
+		http.StatusBadRequest,
+		"no USD/EUR rate on 2017-01-02",
+	},
+	{
+		&Context{
+			sh:        &PositionSchemaDecoder{Params: PositionParams{AsOf: "2017"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"bad date: 2017",
+	},
+	{
+		&Context{
+			sh:         &PositionSchemaDecoder{Params: PositionParams{AsOf: "2017-01-03"}},
+			validator:  &DummyStructValidator{},
+			esPosition: &ErrorEsPosition{Msg: positionErrorMsg},
+		},
+		http.StatusInternalServerError,
+		positionErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &PositionSchemaDecoder{Params: PositionParams{AsOf: "2017-01-03"}},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: []es.Position{{Symbol: "TEST", Date: testBarDate("2017-01-02"), Number: 5}}},
+			esFx:       &DummyEsFx{},
+			quotesAPI:  &DummyQuotesAPI{},
+			esStock:    &BarsEsStock{bars: map[string][]es.StockBar{}},
+		},
+		http.StatusBadRequest,
+		"no price for TEST on 2017-01-03",
+	},
 }
 
 func TestGetPositionsErrors(t *testing.T) {
@@ -100,12 +161,14 @@ func TestGetPositionsErrors(t *testing.T) {
 		handlers := PositionHandlers{
 			Context:      tt.context,
 			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			indexStock:   testIndexStockNoError,
 		}
-		_, err := http.NewRequest(testHistoryListMethod, testHistoryListRequest, nil)
+		req, err := http.NewRequest("GET", "http://test.test/position", nil)
 		if err != nil {
 			t.Fatal(err.Error())
 		}
-		res := handlers.GetPositions(tt.echo)
+		c, _ := createEcho(req)
+		res := handlers.GetPositions(c)
 		assert.NotNil(t, res)
 	}
 }
@@ -24,6 +24,7 @@ import (
 
 type DummyEsPosition struct {
 	PositionAgg []es.PositionAgg
+	Trades      []es.Position
 }
 
 func (posStock *DummyEsPosition) AddPosition(position *es.Position) error {
@@ -34,6 +35,10 @@ func (posStock *DummyEsPosition) GetPositions(username string) ([]es.PositionAgg
 	return posStock.PositionAgg, nil
 }
 
+func (posStock *DummyEsPosition) GetTrades(username string) ([]es.Position, error) {
+	return posStock.Trades, nil
+}
+
 type ErrorEsPosition struct {
 	Msg string
 }
@@ -46,6 +51,10 @@ func (posStock *ErrorEsPosition) GetPositions(username string) ([]es.PositionAgg
 	return nil, errors.New(posStock.Msg)
 }
 
+func (posStock *ErrorEsPosition) GetTrades(username string) ([]es.Position, error) {
+	return nil, errors.New(posStock.Msg)
+}
+
 type ErrorEchoBind struct {
 	echo.Context
 	Msg string
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/clebi/gofin/es"
 	finance "github.com/clebi/yfinance"
@@ -27,7 +28,10 @@ import (
 const (
 	addPositionData = "{\"username\":\"test_username\",\"broker\":\"test\",\"symbol\":\"test\"," +
 		"\"date\":\"2017-04-20T13:00:45Z\",\"number\":1,\"value\":22,\"cost\":24}"
-	getPositionsData = "[{\"Symbol\":\"test_agg\",\"Number\":5,\"Cost\":14,\"Name\":\"TEST NAME\",\"Value\":15}]"
+	getPositionsData = "[{\"Symbol\":\"test_agg\",\"Number\":5,\"Cost\":14,\"Name\":\"TEST NAME\",\"Value\":15," +
+		"\"Currency\":\"USD\"}]"
+	getPositionsEURData = "[{\"Symbol\":\"test_agg\",\"Number\":5,\"Cost\":28,\"Name\":\"TEST NAME\",\"Value\":30," +
+		"\"Currency\":\"EUR\"}]"
 )
 
 func TestAddPosition(t *testing.T) {
@@ -50,6 +54,9 @@ func TestAddPosition(t *testing.T) {
 func TestGetPositions(t *testing.T) {
 	handlers := &PositionHandlers{
 		Context: &Context{
+			sh:        &PositionSchemaDecoder{},
+			validator: &DummyStructValidator{},
+			esFx:      &DummyEsFx{},
 			quotesAPI: &DummyQuotesAPI{quote: finance.Quote{Name: "TEST NAME", LastTradePriceOnly: 15}},
 			esPosition: &DummyEsPosition{
 				PositionAgg: []es.PositionAgg{{Symbol: "test_agg", Number: 5, Cost: 14}},
@@ -65,3 +72,86 @@ func TestGetPositions(t *testing.T) {
 	handlers.GetPositions(c)
 	assert.Equal(t, getPositionsData, resp.Body.String())
 }
+
+func TestGetPositionsAsOf(t *testing.T) {
+	handlers := &PositionHandlers{
+		Context: &Context{
+			sh:        &PositionSchemaDecoder{Params: PositionParams{AsOf: "2017-01-03"}},
+			validator: &DummyStructValidator{},
+			esFx:      &DummyEsFx{},
+			quotesAPI: &DummyQuotesAPI{quote: finance.Quote{Name: "TEST NAME", LastTradePriceOnly: 15}},
+			esPosition: &DummyEsPosition{Trades: []es.Position{
+				{Symbol: "TEST", Date: testBarDate("2017-01-02"), Number: 5, Cost: 10},
+				{Symbol: "TEST", Date: testBarDate("2017-01-02"), Kind: es.KindDividend, Cost: -1},
+				{Symbol: "TEST", Date: testBarDate("2017-01-03").Add(20 * time.Hour), Number: -2, Cost: -5},
+				{Symbol: "TEST", Date: testBarDate("2017-01-04"), Number: 3, Cost: 9},
+				{Symbol: "LATER", Date: testBarDate("2017-01-05"), Number: 1, Cost: 4},
+			}},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST": createIndicatorBars("TEST", testBarDate("2017-01-04"), 3, 4, 5),
+			}},
+		},
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("GET", "http://test.test/position?asOf=2017-01-03", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetPositions(c)
+	assert.Equal(t, "[{\"Symbol\":\"TEST\",\"Number\":3,\"Cost\":5,\"Name\":\"TEST NAME\",\"Value\":4,"+
+		"\"Currency\":\"USD\"}]", resp.Body.String())
+}
+
+func TestGetPositionsCurrency(t *testing.T) {
+	handlers := &PositionHandlers{
+		Context: &Context{
+			sh:        &PositionSchemaDecoder{Params: PositionParams{Currency: "eur"}},
+			validator: &DummyStructValidator{},
+			esFx: &DummyEsFx{
+				Rates:      []es.FxRate{{From: "EUR", To: "GBP", Date: testBarDate("2017-01-02"), Rate: 0.5}},
+				Currencies: map[string]string{"test_agg": "GBP"},
+			},
+			quotesAPI: &DummyQuotesAPI{quote: finance.Quote{Name: "TEST NAME", LastTradePriceOnly: 15}},
+			esPosition: &DummyEsPosition{Trades: []es.Position{
+				{Symbol: "test_agg", Date: testBarDate("2017-01-03"), Number: 5, Cost: 14},
+			}},
+		},
+	}
+	req, err := http.NewRequest("GET", "http://test.test/position?currency=eur", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetPositions(c)
+	assert.Equal(t, getPositionsEURData, resp.Body.String())
+}
+
+func TestGetPositionsCurrencyTradeRates(t *testing.T) {
+	handlers := &PositionHandlers{
+		Context: &Context{
+			sh:        &PositionSchemaDecoder{Params: PositionParams{Currency: "eur"}},
+			validator: &DummyStructValidator{},
+			esFx: &DummyEsFx{
+				Rates: []es.FxRate{
+					{From: "EUR", To: "GBP", Date: testBarDate("2017-01-02"), Rate: 0.5},
+					{From: "EUR", To: "GBP", Date: testBarDate("2017-01-04"), Rate: 0.25},
+				},
+				Currencies: map[string]string{"test_agg": "GBP"},
+			},
+			quotesAPI: &DummyQuotesAPI{quote: finance.Quote{Name: "TEST NAME", LastTradePriceOnly: 15}},
+			esPosition: &DummyEsPosition{Trades: []es.Position{
+				{Symbol: "test_agg", Date: testBarDate("2017-01-03"), Number: 3, Cost: 6},
+				{Symbol: "test_agg", Date: testBarDate("2017-01-05"), Number: 2, Cost: 8},
+			}},
+		},
+	}
+	req, err := http.NewRequest("GET", "http://test.test/position?currency=eur", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetPositions(c)
+	assert.Equal(t, "[{\"Symbol\":\"test_agg\",\"Number\":5,\"Cost\":44,\"Name\":\"TEST NAME\",\"Value\":60,"+
+		"\"Currency\":\"EUR\"}]", resp.Body.String())
+}
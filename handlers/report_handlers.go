@@ -0,0 +1,189 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/portfolio"
+	"github.com/labstack/echo"
+)
+
+// Output formats of the reports
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatHTML = "html"
+)
+
+const reportDateFormat = "2006-01-02"
+
+var gainsCSVHeader = []string{"broker", "symbol", "acquired", "disposed", "quantity", "cost_basis", "proceeds", "gain", "term"}
+
+var gainsTemplate = template.Must(template.New("gains").Funcs(template.FuncMap{
+	"date":   func(date time.Time) string { return date.Format(reportDateFormat) },
+	"amount": func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Capital gains {{.Year}}{{with .Currency}} ({{.}}){{end}}</title>
+<style>
+body { font-family: sans-serif; font-size: 10pt; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #999; padding: 2px 6px; }
+td.number { text-align: right; }
+@media print { thead { display: table-header-group; } tr { page-break-inside: avoid; } }
+</style>
+</head>
+<body>
+<h1>Capital gains {{.Year}}{{with .Currency}} ({{.}}){{end}}</h1>
+<table>
+<thead><tr><th>Broker</th><th>Symbol</th><th>Acquired</th><th>Disposed</th><th>Quantity</th>` +
+	`<th>Cost basis</th><th>Proceeds</th><th>Gain</th><th>Term</th></tr></thead>
+<tbody>
+{{range .Lots}}<tr><td>{{.Broker}}</td><td>{{.Symbol}}</td><td>{{date .Acquired}}</td><td>{{date .Disposed}}</td>` +
+	`<td class="number">{{.Number}}</td><td class="number">{{amount .CostBasis}}</td>` +
+	`<td class="number">{{amount .Proceeds}}</td><td class="number">{{amount .Gain}}</td><td>{{.Term}}</td></tr>
+{{end}}</tbody>
+</table>
+<table>
+<thead><tr><th></th><th>Cost basis</th><th>Proceeds</th><th>Gain</th></tr></thead>
+<tbody>
+<tr><td>Short term</td><td class="number">{{amount .ShortTerm.CostBasis}}</td>` +
+	`<td class="number">{{amount .ShortTerm.Proceeds}}</td><td class="number">{{amount .ShortTerm.Gain}}</td></tr>
+<tr><td>Long term</td><td class="number">{{amount .LongTerm.CostBasis}}</td>` +
+	`<td class="number">{{amount .LongTerm.Proceeds}}</td><td class="number">{{amount .LongTerm.Gain}}</td></tr>
+<tr><th>Total</th><td class="number">{{amount .Total.CostBasis}}</td>` +
+	`<td class="number">{{amount .Total.Proceeds}}</td><td class="number">{{amount .Total.Gain}}</td></tr>
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// GainsParams contains all the parameters for the capital gains report route
+type GainsParams struct {
+	Year     int    `schema:"year" validate:"required,gte=1900"`
+	Format   string `schema:"format" validate:"omitempty,eq=json|eq=csv|eq=html"`
+	Currency string `schema:"currency" validate:"omitempty,len=3"`
+}
+
+// ReportHandlers handles all requests about reports for the user's records
+type ReportHandlers struct {
+	*Context
+	errorHandler errorHandlerFunc
+}
+
+// NewReportHandlers creates a new report handlers object
+func NewReportHandlers(context *Context) *ReportHandlers {
+	return &ReportHandlers{
+		Context:      context,
+		errorHandler: handleError,
+	}
+}
+
+func writeGainsCSV(report *portfolio.GainsReport) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write(gainsCSVHeader); err != nil {
+		return nil, err
+	}
+	for _, lot := range report.Lots {
+		err := writer.Write([]string{
+			lot.Broker,
+			lot.Symbol,
+			lot.Acquired.Format(reportDateFormat),
+			lot.Disposed.Format(reportDateFormat),
+			strconv.Itoa(lot.Number),
+			strconv.FormatFloat(lot.CostBasis, 'f', 2, 64),
+			strconv.FormatFloat(lot.Proceeds, 'f', 2, 64),
+			strconv.FormatFloat(lot.Gain, 'f', 2, 64),
+			lot.Term,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// GetGains handles http request to list the lots closed during a tax year
+//
+// With a base currency, the cost basis is converted at the rate of the buy and the proceeds at the rate of the sell.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ReportHandlers) GetGains(c echo.Context) error {
+	var params GainsParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	positions, err := handlers.esPosition.GetTrades(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	trades := securityTrades(positions)
+	if params.Currency != "" && len(trades) > 0 {
+		symbols := map[string]bool{}
+		var symbolList []string
+		start := trades[0].Date
+		for _, trade := range trades {
+			if !symbols[trade.Symbol] {
+				symbols[trade.Symbol] = true
+				symbolList = append(symbolList, trade.Symbol)
+			}
+			if trade.Date.Before(start) {
+				start = trade.Date
+			}
+		}
+		end := time.Date(params.Year, time.December, 31, 0, 0, 0, 0, time.UTC)
+		exchange, httpErr := loadExchange(handlers.Context, params.Currency, symbolList, trades, start, end)
+		if httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+		if trades, err = portfolio.ConvertTrades(trades, exchange, strings.ToUpper(params.Currency)); err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, err)
+		}
+	}
+	report, err := portfolio.Gains(trades, params.Year)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	report.Currency = strings.ToUpper(params.Currency)
+	switch params.Format {
+	case formatCSV:
+		data, err := writeGainsCSV(report)
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=gains-%d.csv", params.Year))
+		return c.Blob(http.StatusOK, "text/csv; charset=utf-8", data)
+	case formatHTML:
+		var buffer bytes.Buffer
+		if err := gainsTemplate.Execute(&buffer, report); err != nil {
+			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+		}
+		return c.HTML(http.StatusOK, buffer.String())
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const reportErrorMsg = "report_error"
+
+var getGainsErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: reportErrorMsg}},
+		http.StatusInternalServerError,
+		reportErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &GainsSchemaDecoder{},
+			validator: &ErrorStructValidator{Msg: reportErrorMsg},
+		},
+		http.StatusBadRequest,
+		reportErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &GainsSchemaDecoder{Params: GainsParams{Year: 2017}},
+			validator:  &DummyStructValidator{},
+			esPosition: &ErrorEsPosition{Msg: reportErrorMsg},
+		},
+		http.StatusInternalServerError,
+		reportErrorMsg,
+	},
+	{
+		&Context{
+			sh:        &GainsSchemaDecoder{Params: GainsParams{Year: 2017}},
+			validator: &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: []es.Position{
+				{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2017-03-01"), Number: -5, Value: 120, Cost: -590},
+			}},
+		},
+		http.StatusBadRequest,
+		"sell of TEST1 at B1 on 2017-03-01 exceeds held shares",
+	},
+}
+
+func TestGetGainsErrors(t *testing.T) {
+	for _, tt := range getGainsErrorTests {
+		handlers := ReportHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		req, err := http.NewRequest("GET", testGainsURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.GetGains(c)
+		assert.NotNil(t, res)
+	}
+}
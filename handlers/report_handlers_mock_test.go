@@ -0,0 +1,30 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import "errors"
+
+type GainsSchemaDecoder struct {
+	Params GainsParams
+}
+
+func (decoder *GainsSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*GainsParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for GainsSchemaDecoder")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testGainsURL    = "http://test.test/report/gains"
+	gainsJSONResult = "{\"year\":2017,\"lots\":[{\"broker\":\"B1\",\"symbol\":\"TEST1\"," +
+		"\"acquired\":\"2016-01-04T00:00:00Z\",\"disposed\":\"2017-03-01T00:00:00Z\",\"number\":5," +
+		"\"cost_basis\":505,\"proceeds\":590,\"gain\":85,\"term\":\"long\"}]," +
+		"\"short_term\":{\"cost_basis\":0,\"proceeds\":0,\"gain\":0}," +
+		"\"long_term\":{\"cost_basis\":505,\"proceeds\":590,\"gain\":85}," +
+		"\"total\":{\"cost_basis\":505,\"proceeds\":590,\"gain\":85}}"
+	gainsCSVResult = "broker,symbol,acquired,disposed,quantity,cost_basis,proceeds,gain,term\n" +
+		"B1,TEST1,2016-01-04,2017-03-01,5,505.00,590.00,85.00,long\n"
+)
+
+var gainsTestTrades = []es.Position{
+	{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2016-01-04"), Number: 10, Value: 100, Cost: 1010},
+	{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2017-03-01"), Number: -5, Value: 120, Cost: -590},
+	{Broker: "B1", Symbol: "TEST1", Date: testBarDate("2017-06-01"), Cost: -8, Kind: es.KindDividend},
+}
+
+var gainsTests = []struct {
+	format      string
+	contentType string
+	body        string
+}{
+	{"", "application/json; charset=UTF-8", gainsJSONResult},
+	{"csv", "text/csv; charset=utf-8", gainsCSVResult},
+}
+
+func TestGetGains(t *testing.T) {
+	for _, tt := range gainsTests {
+		handlers := &ReportHandlers{
+			Context: &Context{
+				sh:         &GainsSchemaDecoder{Params: GainsParams{Year: 2017, Format: tt.format}},
+				validator:  &DummyStructValidator{},
+				esPosition: &DummyEsPosition{Trades: gainsTestTrades},
+			},
+		}
+		req, err := http.NewRequest("GET", testGainsURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		handlers.GetGains(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		assert.Equal(t, tt.contentType, resp.Header().Get("Content-Type"))
+		assert.Equal(t, tt.body, resp.Body.String())
+	}
+}
+
+func TestGetGainsHTML(t *testing.T) {
+	handlers := &ReportHandlers{
+		Context: &Context{
+			sh:         &GainsSchemaDecoder{Params: GainsParams{Year: 2017, Format: "html"}},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: gainsTestTrades},
+		},
+	}
+	req, err := http.NewRequest("GET", testGainsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetGains(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "<title>Capital gains 2017</title>")
+	assert.Contains(t, resp.Body.String(), "<td>TEST1</td><td>2016-01-04</td><td>2017-03-01</td>")
+	assert.Contains(t, resp.Body.String(), "<td class=\"number\">85.00</td><td>long</td>")
+}
+
+func TestGetGainsCurrency(t *testing.T) {
+	handlers := &ReportHandlers{
+		Context: &Context{
+			sh:         &GainsSchemaDecoder{Params: GainsParams{Year: 2017, Currency: "eur"}},
+			validator:  &DummyStructValidator{},
+			esPosition: &DummyEsPosition{Trades: gainsTestTrades},
+			esFx: &DummyEsFx{Rates: []es.FxRate{
+				{From: "EUR", To: "USD", Date: testBarDate("2016-01-04"), Rate: 1.25},
+				{From: "EUR", To: "USD", Date: testBarDate("2017-03-01"), Rate: 1},
+			}},
+		},
+	}
+	req, err := http.NewRequest("GET", testGainsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetGains(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "{\"year\":2017,\"currency\":\"EUR\",")
+	assert.Contains(t, resp.Body.String(), "\"cost_basis\":404,\"proceeds\":590,\"gain\":186,")
+}
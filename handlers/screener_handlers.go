@@ -0,0 +1,196 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/screener"
+	"github.com/labstack/echo"
+)
+
+const (
+	defaultScreenerPageSize = 50
+	// screenerMarginDays is added to the warm-up of the indicators so that the last close is read
+	// even after a long week-end
+	screenerMarginDays = 7
+)
+
+// ScreenerParams contains all the parameters for the screener route
+//
+// Filter and Sort are screening expressions, Universe is the name of a universe of the user, all the
+// symbols with a stored history are screened without it. Page starts at 1.
+type ScreenerParams struct {
+	Filter   string `schema:"filter" validate:"required"`
+	Universe string `schema:"universe"`
+	Sort     string `schema:"sort"`
+	Order    string `schema:"order" validate:"omitempty,eq=asc|eq=desc"`
+	Page     int    `schema:"page" validate:"gte=0"`
+	Size     int    `schema:"size" validate:"gte=0,lte=500"`
+}
+
+// ScreenerResult contains a symbol matching the filter with the values of the filter and sort terms,
+// a value is null while its indicator is warming up
+type ScreenerResult struct {
+	Symbol string              `json:"symbol"`
+	Values map[string]*float64 `json:"values"`
+}
+
+// ScreenerPage contains a page of the symbols matching a filter and the total number of matching symbols
+type ScreenerPage struct {
+	Total   int              `json:"total"`
+	Page    int              `json:"page"`
+	Size    int              `json:"size"`
+	Results []ScreenerResult `json:"results"`
+}
+
+// ScreenerHandlers handles all requests about screening stocks
+type ScreenerHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	errorHandler errorHandlerFunc
+}
+
+// NewScreenerHandlers creates a new screener handlers object
+func NewScreenerHandlers(context *Context) *ScreenerHandlers {
+	return &ScreenerHandlers{
+		Context:      context,
+		getDate:      getYesterDayDate,
+		errorHandler: handleError,
+	}
+}
+
+// SetUniverse handles http request to create or replace a universe of symbols
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ScreenerHandlers) SetUniverse(c echo.Context) error {
+	universe := new(es.Universe)
+	if err := c.Bind(universe); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	if err := handlers.validator.Struct(universe); err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, validationError(err))
+	}
+	universe.Username = defaultUsername
+	if err := handlers.esUniverse.SetUniverse(universe); err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, universe)
+}
+
+// GetUniverses handles http request to list the user's universes
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ScreenerHandlers) GetUniverses(c echo.Context) error {
+	universes, err := handlers.esUniverse.GetUniverses(defaultUsername)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, universes)
+}
+
+// screenedSymbols returns the symbols of a universe of the user, or all the symbols with a stored history
+func (handlers *ScreenerHandlers) screenedSymbols(name string) ([]string, *HandlerERROR) {
+	if name == "" {
+		symbols, err := handlers.esStock.GetSymbols()
+		if err != nil {
+			return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+		}
+		return symbols, nil
+	}
+	universes, err := handlers.esUniverse.GetUniverses(defaultUsername)
+	if err != nil {
+		return nil, &HandlerERROR{error: err, Status: http.StatusInternalServerError}
+	}
+	for _, universe := range universes {
+		if universe.Name == name {
+			return universe.Symbols, nil
+		}
+	}
+	return nil, &HandlerERROR{error: &NotFoundError{Kind: "universe", Name: name}, Status: http.StatusNotFound}
+}
+
+// pageBounds returns the index of the first result of a page and the index after its last one, a page past the
+// results is empty
+func pageBounds(page int, size int, total int) (int, int) {
+	// the pages are counted first so that a large page cannot overflow the index of its first result
+	if page > (total+size-1)/size {
+		return total, total
+	}
+	first := (page - 1) * size
+	last := first + size
+	if last > total {
+		last = total
+	}
+	return first, last
+}
+
+// Screen handles http request to list the symbols matching a filter expression such as
+// rsi(14) < 30 and close > sma(200)
+//
+// Symbols are screened on their stored history, results are sorted by symbol without sort expression.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *ScreenerHandlers) Screen(c echo.Context) error {
+	var params ScreenerParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	if params.Page == 0 {
+		params.Page = 1
+	}
+	if params.Size == 0 {
+		params.Size = defaultScreenerPageSize
+	}
+	filter, err := screener.Parse(params.Filter)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("filter", err))
+	}
+	warmUp := filter.WarmUp()
+	var sortBy *screener.Expression
+	if params.Sort != "" {
+		if sortBy, err = screener.Parse(params.Sort); err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("sort", err))
+		}
+		if sortBy.WarmUp() > warmUp {
+			warmUp = sortBy.WarmUp()
+		}
+	}
+	symbols, httpErr := handlers.screenedSymbols(params.Universe)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	// two calendar days per bar cover the week-ends and the holidays
+	start := end.AddDate(0, 0, -warmUp*2-screenerMarginDays)
+	load := func(symbol string) ([]es.StockBar, error) {
+		return handlers.esStock.GetBars(c.Request().Context(), symbol, start, end)
+	}
+	results, err := screener.Screen(symbols, load, filter, sortBy, params.Order == "desc")
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	page := ScreenerPage{Total: len(results), Page: params.Page, Size: params.Size, Results: []ScreenerResult{}}
+	first, last := pageBounds(params.Page, params.Size, len(results))
+	for i := first; i < last; i++ {
+		values := make(map[string]*float64, len(results[i].Values))
+		for key, value := range results[i].Values {
+			values[key] = nullable(value)
+		}
+		page.Results = append(page.Results, ScreenerResult{Symbol: results[i].Symbol, Values: values})
+	}
+	return c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,178 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/labstack/echo"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	screenerErrorMsg = "screener_error"
+)
+
+var screenErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: screenerErrorMsg}},
+		http.StatusInternalServerError,
+		screenerErrorMsg,
+	},
+	{
+		&Context{sh: &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close >"}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unexpected end of expression",
+	},
+	{
+		&Context{
+			sh:        &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close > 1", Sort: "foo(3)"}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"unknown indicator: foo",
+	},
+	{
+		&Context{
+			sh:        &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close > 1"}},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorSymbolsEsStock{Msg: screenerErrorMsg},
+		},
+		http.StatusInternalServerError,
+		screenerErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close > 1", Universe: "mine"}},
+			validator:  &DummyStructValidator{},
+			esUniverse: &ErrorEsUniverse{Msg: screenerErrorMsg},
+		},
+		http.StatusInternalServerError,
+		screenerErrorMsg,
+	},
+	{
+		&Context{
+			sh:         &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close > 1", Universe: "mine"}},
+			validator:  &DummyStructValidator{},
+			esUniverse: &DummyEsUniverse{},
+		},
+		http.StatusNotFound,
+		"unknown universe: mine",
+	},
+	{
+		&Context{
+			sh:         &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close > 1", Universe: "mine"}},
+			validator:  &DummyStructValidator{},
+			esUniverse: &DummyEsUniverse{Universes: []es.Universe{{Name: "mine", Symbols: []string{"TEST1"}}}},
+			esStock:    &ErrorBarsEsStock{Msg: screenerErrorMsg},
+		},
+		http.StatusInternalServerError,
+		screenerErrorMsg,
+	},
+}
+
+func TestScreenErrors(t *testing.T) {
+	for _, tt := range screenErrorTests {
+		handlers := ScreenerHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+		}
+		req, err := http.NewRequest("GET", testScreenerURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.Screen(c)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestScreenFilterOutOfRange(t *testing.T) {
+	for _, params := range []ScreenerParams{
+		{Filter: "sma(1e12) > 0"},
+		{Filter: "close > 1", Sort: "sma(1e12)"},
+	} {
+		name := "filter"
+		if params.Sort != "" {
+			name = "sort"
+		}
+		handlers := ScreenerHandlers{
+			Context:      &Context{sh: &ScreenerSchemaDecoder{Params: params}, validator: &DummyStructValidator{}},
+			errorHandler: createInvalidParamHandler(t, name, "period of sma must be at most 1000: 1e12"),
+			getDate:      getTestDate,
+		}
+		req, err := http.NewRequest("GET", testScreenerURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		assert.NotNil(t, handlers.Screen(c))
+	}
+}
+
+var setUniverseErrorTests = []struct {
+	echo            echo.Context
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&ErrorEchoBind{Msg: screenerErrorMsg},
+		nil,
+		http.StatusBadRequest,
+		screenerErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{validator: &ErrorStructValidator{Msg: screenerErrorMsg}},
+		http.StatusBadRequest,
+		screenerErrorMsg,
+	},
+	{
+		&DummyEchoBind{},
+		&Context{
+			esUniverse: &ErrorEsUniverse{Msg: screenerErrorMsg},
+			validator:  &DummyStructValidator{},
+		},
+		http.StatusInternalServerError,
+		screenerErrorMsg,
+	},
+}
+
+func TestSetUniverseErrors(t *testing.T) {
+	for _, tt := range setUniverseErrorTests {
+		handlers := ScreenerHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+		}
+		res := handlers.SetUniverse(tt.echo)
+		assert.NotNil(t, res)
+	}
+}
+
+func TestGetUniversesErrors(t *testing.T) {
+	handlers := ScreenerHandlers{
+		Context:      &Context{esUniverse: &ErrorEsUniverse{Msg: screenerErrorMsg}},
+		errorHandler: createErrorHandler(t, http.StatusInternalServerError, screenerErrorMsg),
+	}
+	res := handlers.GetUniverses(&DummyEchoBind{})
+	assert.NotNil(t, res)
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"errors"
+
+	"github.com/clebi/gofin/es"
+)
+
+type ScreenerSchemaDecoder struct {
+	Params ScreenerParams
+}
+
+func (decoder *ScreenerSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*ScreenerParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for ScreenerSchemaDecoder")
+	}
+	return nil
+}
+
+type SymbolsEsStock struct {
+	BarsEsStock
+}
+
+func (mock *SymbolsEsStock) GetSymbols() ([]string, error) {
+	symbols := []string{}
+	for symbol := range mock.bars {
+		symbols = append(symbols, symbol)
+	}
+	return symbols, nil
+}
+
+type ErrorSymbolsEsStock struct {
+	es.Stock
+	Msg string
+}
+
+func (mock *ErrorSymbolsEsStock) GetSymbols() ([]string, error) {
+	return nil, errors.New(mock.Msg)
+}
+
+type DummyEsUniverse struct {
+	Universes []es.Universe
+}
+
+func (universeStock *DummyEsUniverse) SetUniverse(universe *es.Universe) error {
+	universeStock.Universes = append(universeStock.Universes, *universe)
+	return nil
+}
+
+func (universeStock *DummyEsUniverse) GetUniverses(username string) ([]es.Universe, error) {
+	return universeStock.Universes, nil
+}
+
+type ErrorEsUniverse struct {
+	Msg string
+}
+
+func (universeStock *ErrorEsUniverse) SetUniverse(universe *es.Universe) error {
+	return errors.New(universeStock.Msg)
+}
+
+func (universeStock *ErrorEsUniverse) GetUniverses(username string) ([]es.Universe, error) {
+	return nil, errors.New(universeStock.Msg)
+}
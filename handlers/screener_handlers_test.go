@@ -0,0 +1,151 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const maxInt = int(^uint(0) >> 1)
+
+const (
+	testScreenerURL    = "http://test.test/screener"
+	setUniverseData    = "{\"name\":\"tech\",\"symbols\":[\"AAPL\",\"MSFT\"]}"
+	testScreenerResult = "{\"total\":3,\"page\":1,\"size\":2,\"results\":[" +
+		"{\"symbol\":\"TEST3\",\"values\":{\"close\":9,\"sma(3)\":8}}," +
+		"{\"symbol\":\"TEST1\",\"values\":{\"close\":3,\"sma(3)\":2}}]}"
+	testScreenerPage2Result = "{\"total\":3,\"page\":2,\"size\":2,\"results\":[" +
+		"{\"symbol\":\"TEST4\",\"values\":{\"close\":1,\"sma(3)\":null}}]}"
+	testScreenerUniverseResult = "{\"total\":1,\"page\":1,\"size\":50,\"results\":[" +
+		"{\"symbol\":\"TEST1\",\"values\":{\"close\":3}}]}"
+)
+
+func createScreenerEsStock() *SymbolsEsStock {
+	return &SymbolsEsStock{BarsEsStock{bars: map[string][]es.StockBar{
+		"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 1, 2, 3),
+		"TEST2": createIndicatorBars("TEST2", getSeriesTestDate(), 3, 2, 1.8),
+		"TEST3": createIndicatorBars("TEST3", getSeriesTestDate(), 7, 8, 9),
+		"TEST4": createIndicatorBars("TEST4", getSeriesTestDate(), 1),
+	}}}
+}
+
+func TestScreen(t *testing.T) {
+	for _, tt := range []struct {
+		page     int
+		expected string
+	}{
+		{0, testScreenerResult},
+		{2, testScreenerPage2Result},
+		{maxInt, "{\"total\":3,\"page\":" + strconv.Itoa(maxInt) + ",\"size\":2,\"results\":[]}"},
+	} {
+		handlers := &ScreenerHandlers{
+			Context: &Context{
+				sh: &ScreenerSchemaDecoder{Params: ScreenerParams{
+					Filter: "close >= sma(3) or close < 1.5",
+					Sort:   "close",
+					Order:  "desc",
+					Page:   tt.page,
+					Size:   2,
+				}},
+				validator: &DummyStructValidator{},
+				esStock:   createScreenerEsStock(),
+			},
+			getDate: getTestDate,
+		}
+		req, err := http.NewRequest("GET", testScreenerURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		handlers.Screen(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		assert.Equal(t, tt.expected, resp.Body.String())
+	}
+}
+
+func TestPageBounds(t *testing.T) {
+	for _, tt := range []struct {
+		page, size, total int
+		first, last       int
+	}{
+		{1, 2, 3, 0, 2},
+		{2, 2, 3, 2, 3},
+		{3, 2, 3, 3, 3},
+		{1, 50, 0, 0, 0},
+		{maxInt, 500, 3, 3, 3},
+	} {
+		first, last := pageBounds(tt.page, tt.size, tt.total)
+		assert.Equal(t, tt.first, first)
+		assert.Equal(t, tt.last, last)
+	}
+}
+
+func TestScreenUniverse(t *testing.T) {
+	handlers := &ScreenerHandlers{
+		Context: &Context{
+			sh:         &ScreenerSchemaDecoder{Params: ScreenerParams{Filter: "close > 2", Universe: "mine"}},
+			validator:  &DummyStructValidator{},
+			esStock:    createScreenerEsStock(),
+			esUniverse: &DummyEsUniverse{Universes: []es.Universe{{Name: "mine", Symbols: []string{"TEST1", "TEST2", "NONE"}}}},
+		},
+		getDate: getTestDate,
+	}
+	req, err := http.NewRequest("GET", testScreenerURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.Screen(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, testScreenerUniverseResult, resp.Body.String())
+}
+
+func TestSetUniverse(t *testing.T) {
+	esUniverse := &DummyEsUniverse{}
+	handlers := &ScreenerHandlers{
+		Context: &Context{
+			esUniverse: esUniverse,
+			validator:  &DummyStructValidator{},
+		},
+	}
+	req, err := http.NewRequest("PUT", "http://test.test/screener/universes", bytes.NewBufferString(setUniverseData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c, resp := createEcho(req)
+	handlers.SetUniverse(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, []es.Universe{{Username: "tester", Name: "tech", Symbols: []string{"AAPL", "MSFT"}}}, esUniverse.Universes)
+}
+
+func TestGetUniverses(t *testing.T) {
+	handlers := &ScreenerHandlers{
+		Context: &Context{esUniverse: &DummyEsUniverse{Universes: []es.Universe{{Name: "tech", Symbols: []string{"AAPL"}}}}},
+	}
+	req, err := http.NewRequest("GET", "http://test.test/screener/universes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.GetUniverses(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Contains(t, resp.Body.String(), "\"name\":\"tech\"")
+}
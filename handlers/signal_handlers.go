@@ -0,0 +1,165 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/signals"
+	"github.com/labstack/echo"
+)
+
+// signalQueryDays is the number of days of signals listed when no start date is given
+const signalQueryDays = 365
+
+// SignalScanParams contains all the parameters for the signal scan route
+//
+// The periods and thresholds of the detectors take their usual value when they are 0.
+type SignalScanParams struct {
+	Symbols    []string `schema:"symbols" validate:"required"`
+	Days       int      `schema:"days" validate:"gt=0"`
+	Fast       int      `schema:"fast" validate:"gte=0"`
+	Slow       int      `schema:"slow" validate:"gte=0"`
+	RSI        int      `schema:"rsi" validate:"gte=0"`
+	RSIHigh    float64  `schema:"rsi_high" validate:"gte=0,lte=100"`
+	RSILow     float64  `schema:"rsi_low" validate:"gte=0,lte=100"`
+	Bands      int      `schema:"bands" validate:"gte=0"`
+	BandsWidth float64  `schema:"bands_width" validate:"gte=0"`
+	Year       int      `schema:"year" validate:"gte=0"`
+}
+
+func (params SignalScanParams) config() signals.Config {
+	config := signals.DefaultConfig()
+	for _, override := range []struct {
+		value  int
+		target *int
+	}{
+		{params.Fast, &config.FastPeriod},
+		{params.Slow, &config.SlowPeriod},
+		{params.RSI, &config.RSIPeriod},
+		{params.Bands, &config.BandsPeriod},
+		{params.Year, &config.YearPeriod},
+	} {
+		if override.value != 0 {
+			*override.target = override.value
+		}
+	}
+	if params.RSIHigh != 0 {
+		config.RSIHigh = params.RSIHigh
+	}
+	if params.RSILow != 0 {
+		config.RSILow = params.RSILow
+	}
+	if params.BandsWidth != 0 {
+		config.BandsWidth = params.BandsWidth
+	}
+	return config
+}
+
+// SignalQueryParams contains all the parameters for the signals route
+type SignalQueryParams struct {
+	DateRangeParams
+	Kind string `schema:"kind"`
+}
+
+// SignalHandlers handles all requests about the signals detected on stocks
+type SignalHandlers struct {
+	*Context
+	getDate      GetDateFunc
+	now          GetDateFunc
+	errorHandler errorHandlerFunc
+	indexStock   indexStockFunc
+}
+
+// NewSignalHandlers creates a new signal handlers object
+func NewSignalHandlers(context *Context) *SignalHandlers {
+	return &SignalHandlers{
+		Context:      context,
+		getDate:      getYesterDayDate,
+		now:          time.Now,
+		errorHandler: handleError,
+		indexStock:   indexStock,
+	}
+}
+
+// ScanSignals handles http request to detect the signals of stocks over the last days and store them
+//
+// The bars before the period are read for the warm-up of the detectors, a new scan of the same days replaces the signals.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *SignalHandlers) ScanSignals(c echo.Context) error {
+	var params SignalScanParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	config := params.config()
+	end := handlers.getDate().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -params.Days)
+	detected := handlers.now()
+	found := []es.Signal{}
+	for _, symbol := range params.Symbols {
+		// two calendar days per bar cover the week-ends and the holidays
+		bars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, symbol, start.AddDate(0, 0, -config.WarmUp()*2), end)
+		if httpErr != nil {
+			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+		}
+		symbolSignals, err := signals.Detect(bars, config)
+		if err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, err)
+		}
+		for _, signal := range signals.Since(symbolSignals, start) {
+			signal.Symbol = symbol
+			signal.Detected = detected
+			if err := handlers.esSignal.AddSignal(&signal); err != nil {
+				return handlers.errorHandler(c, http.StatusInternalServerError, err)
+			}
+			found = append(found, signal)
+		}
+	}
+	return c.JSON(http.StatusOK, found)
+}
+
+// GetSignals handles http request to list the stored signals of a stock between two dates
+//
+// The period ends yesterday, or on the as-of date, and lasts a year when its dates are not given, all kinds are
+// listed without kind.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *SignalHandlers) GetSignals(c echo.Context) error {
+	var params SignalQueryParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	start, end, httpErr := params.period(handlers.getDate(), signalQueryDays)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	if params.Kind != "" {
+		known := false
+		for _, kind := range signals.Kinds {
+			known = known || kind == params.Kind
+		}
+		if !known {
+			return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("kind", fmt.Errorf("unknown signal kind: %s", params.Kind)))
+		}
+	}
+	found, err := handlers.esSignal.GetSignals(c.Param("symbol"), params.Kind, start, end)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusInternalServerError, err)
+	}
+	return c.JSON(http.StatusOK, found)
+}
@@ -0,0 +1,155 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const signalErrorMsg = "signal_error"
+
+var scanSignalsErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: signalErrorMsg}},
+		http.StatusInternalServerError,
+		signalErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &SignalScanSchemaDecoder{Params: testSignalScanParams}, validator: &ErrorStructValidator{Msg: signalErrorMsg}},
+		http.StatusBadRequest,
+		signalErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &SignalScanSchemaDecoder{Params: testSignalScanParams}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		signalErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, signalErrorMsg),
+	},
+	{
+		&Context{
+			sh:        &SignalScanSchemaDecoder{Params: testSignalScanParams},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: signalErrorMsg},
+		},
+		http.StatusInternalServerError,
+		signalErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &SignalScanSchemaDecoder{Params: testSignalScanParams},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 5, 4, 3, 2, 3, 4, 5, 4, 3, 2),
+			}},
+			esSignal: &ErrorEsSignal{Msg: signalErrorMsg},
+		},
+		http.StatusInternalServerError,
+		signalErrorMsg,
+		testIndexStockNoError,
+	},
+}
+
+func TestScanSignalsErrors(t *testing.T) {
+	for _, tt := range scanSignalsErrorTests {
+		handlers := SignalHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			now:          getTestDetectedDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("POST", testScanSignalsURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		res := handlers.ScanSignals(c)
+		assert.NotNil(t, res)
+	}
+}
+
+var getSignalsErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: signalErrorMsg}},
+		http.StatusInternalServerError,
+		signalErrorMsg,
+	},
+	{
+		&Context{sh: &SignalQuerySchemaDecoder{Params: SignalQueryParams{DateRangeParams: DateRangeParams{To: "30/06/2016"}}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"bad date: 30/06/2016",
+	},
+	{
+		&Context{sh: &SignalQuerySchemaDecoder{Params: SignalQueryParams{DateRangeParams: DateRangeParams{From: "2016-13-01"}}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"bad date: 2016-13-01",
+	},
+	{
+		&Context{
+			sh:        &SignalQuerySchemaDecoder{Params: SignalQueryParams{DateRangeParams: DateRangeParams{From: "2016-07-01", To: "2016-06-30"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"start 2016-07-01 is after end 2016-06-30",
+	},
+	{
+		&Context{sh: &SignalQuerySchemaDecoder{Params: SignalQueryParams{Kind: "bad"}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unknown signal kind: bad",
+	},
+	{
+		&Context{
+			sh:        &SignalQuerySchemaDecoder{},
+			validator: &DummyStructValidator{},
+			esSignal:  &ErrorEsSignal{Msg: signalErrorMsg},
+		},
+		http.StatusInternalServerError,
+		signalErrorMsg,
+	},
+}
+
+func TestGetSignalsErrors(t *testing.T) {
+	for _, tt := range getSignalsErrorTests {
+		handlers := SignalHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+		}
+		req, err := http.NewRequest("GET", testGetSignalsURL, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST1")
+		res := handlers.GetSignals(c)
+		assert.NotNil(t, res)
+	}
+}
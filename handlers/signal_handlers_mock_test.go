@@ -0,0 +1,80 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+type SignalScanSchemaDecoder struct {
+	Params SignalScanParams
+}
+
+func (decoder *SignalScanSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*SignalScanParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for SignalScanSchemaDecoder")
+	}
+	return nil
+}
+
+type SignalQuerySchemaDecoder struct {
+	Params SignalQueryParams
+}
+
+func (decoder *SignalQuerySchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*SignalQueryParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for SignalQuerySchemaDecoder")
+	}
+	return nil
+}
+
+type DummyEsSignal struct {
+	Signals []es.Signal
+	Symbol  string
+	Kind    string
+	Start   time.Time
+	End     time.Time
+}
+
+func (signalStock *DummyEsSignal) AddSignal(signal *es.Signal) error {
+	signalStock.Signals = append(signalStock.Signals, *signal)
+	return nil
+}
+
+func (signalStock *DummyEsSignal) GetSignals(symbol string, kind string, start time.Time, end time.Time) ([]es.Signal, error) {
+	signalStock.Symbol = symbol
+	signalStock.Kind = kind
+	signalStock.Start = start
+	signalStock.End = end
+	return signalStock.Signals, nil
+}
+
+type ErrorEsSignal struct {
+	Msg string
+}
+
+func (signalStock *ErrorEsSignal) AddSignal(signal *es.Signal) error {
+	return errors.New(signalStock.Msg)
+}
+
+func (signalStock *ErrorEsSignal) GetSignals(symbol string, kind string, start time.Time, end time.Time) ([]es.Signal, error) {
+	return nil, errors.New(signalStock.Msg)
+}
@@ -0,0 +1,166 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/signals"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testScanSignalsURL = "http://test.test/signals/scan"
+	testGetSignalsURL  = "http://test.test/signals/TEST1"
+)
+
+var testSignalScanParams = SignalScanParams{
+	Symbols:    []string{"TEST1"},
+	Days:       4,
+	Fast:       2,
+	Slow:       3,
+	RSI:        2,
+	Bands:      3,
+	BandsWidth: 1,
+	Year:       3,
+}
+
+func getTestDetectedDate() time.Time {
+	return time.Date(2016, time.December, 14, 8, 0, 0, 0, time.UTC)
+}
+
+func TestSignalScanParamsConfig(t *testing.T) {
+	assert.Equal(t, signals.DefaultConfig(), SignalScanParams{}.config())
+	config := testSignalScanParams.config()
+	assert.Equal(t, 2, config.FastPeriod)
+	assert.Equal(t, 3, config.SlowPeriod)
+	assert.Equal(t, 2, config.RSIPeriod)
+	assert.Equal(t, 70.0, config.RSIHigh)
+	assert.Equal(t, 30.0, config.RSILow)
+	assert.Equal(t, 3, config.BandsPeriod)
+	assert.Equal(t, 1.0, config.BandsWidth)
+	assert.Equal(t, 3, config.YearPeriod)
+}
+
+func TestScanSignals(t *testing.T) {
+	esSignal := &DummyEsSignal{}
+	handlers := &SignalHandlers{
+		Context: &Context{
+			sh:        &SignalScanSchemaDecoder{Params: testSignalScanParams},
+			validator: &DummyStructValidator{},
+			esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+				"TEST1": createIndicatorBars("TEST1", getSeriesTestDate(), 5, 4, 3, 2, 3, 4, 5, 4, 3, 2),
+			}},
+			esSignal: esSignal,
+		},
+		getDate:    getTestDate,
+		now:        getTestDetectedDate,
+		indexStock: testIndexStockNoError,
+	}
+	req, err := http.NewRequest("POST", testScanSignalsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.ScanSignals(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var found []es.Signal
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &found))
+	expected := []struct {
+		days int
+		kind string
+	}{
+		{4, signals.KindGoldenCross},
+		{4, signals.KindRSIOverbought},
+		{4, signals.KindBreakoutUpper},
+		{4, signals.KindYearHigh},
+		{3, signals.KindYearHigh},
+		{1, signals.KindDeathCross},
+		{1, signals.KindRSIOversold},
+		{1, signals.KindBreakoutLower},
+		{1, signals.KindYearLow},
+		{0, signals.KindYearLow},
+	}
+	assert.Equal(t, len(expected), len(found))
+	assert.Equal(t, len(expected), len(esSignal.Signals))
+	for i, tt := range expected {
+		date := getSeriesTestDate().AddDate(0, 0, -tt.days)
+		assert.True(t, date.Equal(found[i].Date), tt.kind)
+		assert.Equal(t, tt.kind, found[i].Kind)
+		assert.Equal(t, "TEST1", found[i].Symbol)
+		assert.Equal(t, found[i].Kind, esSignal.Signals[i].Kind)
+		assert.Equal(t, getTestDetectedDate(), esSignal.Signals[i].Detected)
+	}
+}
+
+func TestGetSignals(t *testing.T) {
+	stored := []es.Signal{{Symbol: "TEST1", Date: getSeriesTestDate(), Kind: signals.KindYearHigh, Value: 10}}
+	var signalsTests = []struct {
+		params        SignalQueryParams
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{
+			SignalQueryParams{},
+			getSeriesTestDate().AddDate(0, 0, -signalQueryDays),
+			getSeriesTestDate(),
+		},
+		{
+			SignalQueryParams{DateRangeParams: DateRangeParams{From: "2016-01-04", To: "2016-06-30"}, Kind: signals.KindYearHigh},
+			time.Date(2016, time.January, 4, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.June, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			SignalQueryParams{DateRangeParams: DateRangeParams{To: "2016-06-30"}},
+			time.Date(2015, time.July, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.June, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			SignalQueryParams{DateRangeParams: DateRangeParams{To: "2016-12-01", AsOf: "2016-06-30"}},
+			time.Date(2015, time.July, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2016, time.June, 30, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range signalsTests {
+		esSignal := &DummyEsSignal{Signals: stored}
+		handlers := &SignalHandlers{
+			Context: &Context{
+				sh:        &SignalQuerySchemaDecoder{Params: tt.params},
+				validator: &DummyStructValidator{},
+				esSignal:  esSignal,
+			},
+			getDate: getTestDate,
+		}
+		req, err := http.NewRequest("GET", testGetSignalsURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST1")
+		handlers.GetSignals(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var found []es.Signal
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &found))
+		assert.Equal(t, 1, len(found))
+		assert.Equal(t, "TEST1", esSignal.Symbol)
+		assert.Equal(t, tt.params.Kind, esSignal.Kind)
+		assert.Equal(t, tt.expectedStart, esSignal.Start)
+		assert.Equal(t, tt.expectedEnd, esSignal.End)
+	}
+}
@@ -15,31 +15,304 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/ohlc"
 	"github.com/labstack/echo"
 )
 
-type errorDesc struct {
-	Status      string `json:"status"`
-	Description string `json:"description"`
-}
-
 // HistoryParams contains all the parameters for the history route
+//
+// The period is given by its dates or by its days. Model and its smoothing factors apply to the moving
+// average of Window days. Overlays are more moving averages written model:window[:option=value...], the
+// options being alpha, beta, gamma, period and seasonality, as in ewma:50:alpha=0.3 or
+// holt_winters:20:period=5:seasonality=mult.
 type HistoryParams struct {
-	Days   int `schema:"days" validate:"gt=0"`
-	Window int `schema:"window" validate:"gt=0"`
-	Step   int `schema:"step" validate:"gt=0"`
+	DateRangeParams
+	Days        int      `schema:"days" validate:"omitempty,gt=0"`
+	Window      int      `schema:"window" validate:"gt=0"`
+	Step        int      `schema:"step" validate:"gt=0"`
+	Model       string   `schema:"model" validate:"omitempty,eq=simple|eq=linear|eq=ewma|eq=holt|eq=holt_winters"`
+	Alpha       float64  `schema:"alpha" validate:"gte=0,lte=1"`
+	Beta        float64  `schema:"beta" validate:"gte=0,lte=1"`
+	Gamma       float64  `schema:"gamma" validate:"gte=0,lte=1"`
+	Period      int      `schema:"period" validate:"gte=0"`
+	Seasonality string   `schema:"seasonality" validate:"omitempty,eq=add|eq=mult"`
+	Overlays    []string `schema:"overlays"`
+}
+
+const seasonalityMult = "mult"
+
+// newMovAvg creates a moving average named after its model and window, mv_20 for a simple one
+func newMovAvg(model string, window int, alpha, beta, gamma float64, period int, seasonality string) (es.MovAvg, error) {
+	if model == "" {
+		model = es.MovAvgSimple
+	}
+	name := fmt.Sprintf("%s_%d", model, window)
+	if model == es.MovAvgSimple {
+		name = fmt.Sprintf("mv_%d", window)
+	}
+	if model == es.MovAvgHoltWinters && period <= 0 {
+		return es.MovAvg{}, fmt.Errorf("%s needs a period", name)
+	}
+	return es.MovAvg{
+		Name:           name,
+		Model:          model,
+		Window:         window,
+		Alpha:          alpha,
+		Beta:           beta,
+		Gamma:          gamma,
+		Period:         period,
+		Multiplicative: seasonality == seasonalityMult,
+	}, nil
+}
+
+// parseOverlay parses an overlay of the history parameters
+func parseOverlay(overlay string) (es.MovAvg, error) {
+	errBadOverlay := fmt.Errorf("bad overlay: %s", overlay)
+	fields := strings.Split(overlay, ":")
+	if len(fields) < 2 {
+		return es.MovAvg{}, errBadOverlay
+	}
+	model := fields[0]
+	switch model {
+	case es.MovAvgSimple, es.MovAvgLinear, es.MovAvgEWMA, es.MovAvgHolt, es.MovAvgHoltWinters:
+	default:
+		return es.MovAvg{}, errBadOverlay
+	}
+	window, err := strconv.Atoi(fields[1])
+	if err != nil || window <= 0 {
+		return es.MovAvg{}, errBadOverlay
+	}
+	var alpha, beta, gamma float64
+	var period int
+	var seasonality string
+	for _, option := range fields[2:] {
+		keyValue := strings.SplitN(option, "=", 2)
+		if len(keyValue) != 2 {
+			return es.MovAvg{}, errBadOverlay
+		}
+		switch keyValue[0] {
+		case "alpha", "beta", "gamma":
+			factor, err := strconv.ParseFloat(keyValue[1], 64)
+			if err != nil || factor < 0 || factor > 1 {
+				return es.MovAvg{}, errBadOverlay
+			}
+			switch keyValue[0] {
+			case "alpha":
+				alpha = factor
+			case "beta":
+				beta = factor
+			default:
+				gamma = factor
+			}
+		case "period":
+			period, err = strconv.Atoi(keyValue[1])
+			if err != nil || period < 0 {
+				return es.MovAvg{}, errBadOverlay
+			}
+		case "seasonality":
+			if keyValue[1] != "add" && keyValue[1] != seasonalityMult {
+				return es.MovAvg{}, errBadOverlay
+			}
+			seasonality = keyValue[1]
+		default:
+			return es.MovAvg{}, errBadOverlay
+		}
+	}
+	return newMovAvg(model, window, alpha, beta, gamma, period, seasonality)
+}
+
+// movAvgs returns the moving averages of the parameters, the one of the window first
+func (params HistoryParams) movAvgs() ([]es.MovAvg, error) {
+	movAvg, err := newMovAvg(params.Model, params.Window, params.Alpha, params.Beta, params.Gamma,
+		params.Period, params.Seasonality)
+	if err != nil {
+		return nil, invalidParam("period", err)
+	}
+	movAvgs := []es.MovAvg{movAvg}
+	names := map[string]bool{movAvg.Name: true}
+	for _, overlay := range params.Overlays {
+		movAvg, err := parseOverlay(overlay)
+		if err != nil {
+			return nil, invalidParam("overlays", err)
+		}
+		if names[movAvg.Name] {
+			return nil, invalidParam("overlays", fmt.Errorf("duplicate overlay: %s", movAvg.Name))
+		}
+		names[movAvg.Name] = true
+		movAvgs = append(movAvgs, movAvg)
+	}
+	return movAvgs, nil
+}
+
+// movAvgsWindow returns the largest window of moving averages, the days needed before the history
+func movAvgsWindow(movAvgs []es.MovAvg) int {
+	window := 0
+	for _, movAvg := range movAvgs {
+		if movAvg.Window > window {
+			window = movAvg.Window
+		}
+	}
+	return window
 }
 
 // HistoryListParams contains all the parameters for the history list route
+//
+// With Align, the histories share a date axis on which missing values are null, or carried forward with
+// the forward fill. Rebase, which implies Align, scales every series to 100 at the first common date.
 type HistoryListParams struct {
 	HistoryParams
 	Symbols []string `schema:"symbols"`
+	Align   bool     `schema:"align"`
+	Fill    string   `schema:"fill" validate:"omitempty,eq=none|eq=forward"`
+	Rebase  bool     `schema:"rebase"`
+}
+
+const (
+	fillForward = "forward"
+	rebaseValue = 100
+)
+
+var errNoCommonDate = errors.New("no common date to rebase on")
+
+// AlignedHistory is the history of several stocks on a shared date axis
+//
+// The series are indexed like Dates and null on the dates a stock has no value. Base is the date the
+// series are rebased on. The symbols which failed are left out of the series, their errors are in Errors
+// and Status is the one of a list route.
+type AlignedHistory struct {
+	Status    string                           `json:"status"`
+	Dates     []int64                          `json:"dates"`
+	Symbols   []string                         `json:"symbols"`
+	Closes    map[string][]*float64            `json:"closes"`
+	MovCloses map[string][]*float64            `json:"mv_closes"`
+	Overlays  map[string]map[string][]*float64 `json:"overlays,omitempty"`
+	Base      *int64                           `json:"base,omitempty"`
+	Errors    []SymbolResult                   `json:"errors,omitempty"`
+}
+
+// series returns all the series of a symbol
+func (history *AlignedHistory) series(symbol string) [][]*float64 {
+	series := [][]*float64{history.Closes[symbol], history.MovCloses[symbol]}
+	for _, overlay := range history.Overlays[symbol] {
+		series = append(series, overlay)
+	}
+	return series
+}
+
+func forwardFill(series []*float64) {
+	for i := 1; i < len(series); i++ {
+		if series[i] == nil {
+			series[i] = series[i-1]
+		}
+	}
+}
+
+func rebaseSeries(series []*float64, base float64) {
+	for i, value := range series {
+		if value != nil {
+			series[i] = nullable(*value * rebaseValue / base)
+		}
+	}
+}
+
+// alignHistory puts the aggregations of the symbols on the union of their dates
+func alignHistory(symbols []string, stocks [][]es.StocksAgg, fill string, rebase bool) (*AlignedHistory, error) {
+	indexes := map[int64]int{}
+	for _, stocksAgg := range stocks {
+		for _, stockAgg := range stocksAgg {
+			indexes[stockAgg.MsTime] = 0
+		}
+	}
+	history := &AlignedHistory{
+		Dates:     make([]int64, 0, len(indexes)),
+		Symbols:   symbols,
+		Closes:    map[string][]*float64{},
+		MovCloses: map[string][]*float64{},
+		Overlays:  map[string]map[string][]*float64{},
+	}
+	for date := range indexes {
+		history.Dates = append(history.Dates, date)
+	}
+	sort.Slice(history.Dates, func(i, j int) bool { return history.Dates[i] < history.Dates[j] })
+	for i, date := range history.Dates {
+		indexes[date] = i
+	}
+	for i, symbol := range symbols {
+		closes := make([]*float64, len(history.Dates))
+		movCloses := make([]*float64, len(history.Dates))
+		overlays := map[string][]*float64{}
+		for _, stockAgg := range stocks[i] {
+			index := indexes[stockAgg.MsTime]
+			closes[index] = nullable(stockAgg.AvgClose)
+			movCloses[index] = nullable(stockAgg.MovClose)
+			for name, value := range stockAgg.Overlays {
+				if _, ok := overlays[name]; !ok {
+					overlays[name] = make([]*float64, len(history.Dates))
+				}
+				overlays[name][index] = nullable(value)
+			}
+		}
+		history.Closes[symbol] = closes
+		history.MovCloses[symbol] = movCloses
+		if len(overlays) > 0 {
+			history.Overlays[symbol] = overlays
+		}
+		if fill == fillForward {
+			for _, series := range history.series(symbol) {
+				forwardFill(series)
+			}
+		}
+	}
+	if !rebase || len(symbols) == 0 {
+		return history, nil
+	}
+	for i, date := range history.Dates {
+		common := true
+		for _, symbol := range symbols {
+			if value := history.Closes[symbol][i]; value == nil || *value == 0 {
+				common = false
+				break
+			}
+		}
+		if !common {
+			continue
+		}
+		base := date
+		history.Base = &base
+		for _, symbol := range symbols {
+			baseClose := *history.Closes[symbol][i]
+			for _, series := range history.series(symbol) {
+				rebaseSeries(series, baseClose)
+			}
+		}
+		return history, nil
+	}
+	return nil, errNoCommonDate
 }
 
+// CandlesParams contains all the parameters for the candles route
+//
+// The period of the history is given by its dates or by its days. Period is the calendar period of the
+// candles and Size their number of trading days, the candles are the daily bars when neither is given.
+type CandlesParams struct {
+	DateRangeParams
+	Days   int    `schema:"days" validate:"omitempty,gt=0"`
+	Period string `schema:"period" validate:"omitempty,eq=day|eq=week|eq=month|eq=quarter|eq=year"`
+	Size   int    `schema:"size" validate:"gte=0"`
+}
+
+var errPeriodAndSize = errors.New("period and size cannot be both given")
+
 // HandlerERROR represents an error to send through http
 type HandlerERROR struct {
 	error
@@ -58,6 +331,7 @@ type StockHandlers struct {
 	*Context
 	getDate      GetDateFunc
 	errorHandler errorHandlerFunc
+	indexStock   indexStockFunc
 }
 
 // NewStockHandlers creates a new stock handlers object
@@ -66,15 +340,10 @@ func NewStockHandlers(context *Context) *StockHandlers {
 		Context:      context,
 		getDate:      getYesterDayDate,
 		errorHandler: handleError,
+		indexStock:   indexStock,
 	}
 }
 
-func (handlers *StockHandlers) getDates(from time.Time, days int) (time.Time, time.Time) {
-	end := from.Truncate(24 * time.Hour)
-	start := end.AddDate(0, 0, days*-1)
-	return start, end
-}
-
 // History retrieve stocks history
 //
 //  handlers.History(w, r)
@@ -85,40 +354,131 @@ func (handlers *StockHandlers) History(c echo.Context) error {
 	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
 		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
 	}
-	start, end := handlers.getDates(handlers.getDate(), params.Days)
-	httpErr := indexStock(handlers.Context, c.Param("symbol"), start.AddDate(0, 0, params.Window*-1), end)
+	movAvgs, err := params.movAvgs()
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	start, end, httpErr := params.period(handlers.getDate(), params.Days)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	httpErr = handlers.indexStock(handlers.Context, c.Param("symbol"), start.AddDate(0, 0, movAvgsWindow(movAvgs)*-1), end)
 	if httpErr != nil {
 		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
 	}
-	stocksAgg, err := handlers.Context.esStock.GetStocksAgg(c.Param("symbol"), params.Window, params.Step, start, end)
+	stocksAgg, err := handlers.Context.esStock.GetStocksAgg(c.Request().Context(), c.Param("symbol"), movAvgs, params.Step, start, end)
 	if err != nil {
 		return handlers.errorHandler(c, http.StatusInternalServerError, err)
 	}
 	return c.JSON(http.StatusOK, stocksAgg)
 }
 
+// symbolHistory retrieves the history of a symbol of the history list
+//
+// A symbol without any value in the period is unknown.
+func (handlers *StockHandlers) symbolHistory(ctx context.Context, symbol string, movAvgs []es.MovAvg, step int, start time.Time, end time.Time) SymbolResult {
+	httpErr := handlers.indexStock(handlers.Context, symbol, start.AddDate(0, 0, movAvgsWindow(movAvgs)*-1), end)
+	if httpErr != nil {
+		return symbolFailure(symbol, symbolErrorCode(httpErr.Status, httpErr.error), httpErr.error)
+	}
+	stocksAgg, err := handlers.Context.esStock.GetStocksAgg(ctx, symbol, movAvgs, step, start, end)
+	if err != nil {
+		return symbolFailure(symbol, symbolErrorCode(http.StatusInternalServerError, err), err)
+	}
+	if len(stocksAgg) == 0 {
+		return symbolFailure(symbol, ErrorCodeNotFound, fmt.Errorf("no history for %s", symbol))
+	}
+	return SymbolResult{Symbol: symbol, Data: stocksAgg}
+}
+
 // HistoryList retrieve a stock history list
 //
 //  handlers.History(w, r)
 //
+// The response is a ListResult with the history of each symbol, or an AlignedHistory of the symbols which
+// succeeded with the align or rebase parameters. The symbols are processed concurrently within the limits
+// of the fan-out of the context.
+//
 // This function is a handler for http server, it should not be called directly
 func (handlers *StockHandlers) HistoryList(c echo.Context) error {
 	var params HistoryListParams
 	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
 		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
 	}
-	start, end := handlers.getDates(handlers.getDate(), params.Days)
+	movAvgs, err := params.movAvgs()
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	start, end, httpErr := params.period(handlers.getDate(), params.Days)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	results := handlers.fanOut.forEachSymbol(c.Request().Context(), params.Symbols, func(ctx context.Context, symbol string) SymbolResult {
+		return handlers.symbolHistory(ctx, symbol, movAvgs, params.Step, start, end)
+	})
+	if !params.Align && !params.Rebase {
+		return c.JSON(http.StatusOK, newListResult(results))
+	}
+	symbols := []string{}
 	var stocks [][]es.StocksAgg
-	for _, symbol := range params.Symbols {
-		httpErr := indexStock(handlers.Context, symbol, start.AddDate(0, 0, params.Window*-1), end)
-		if httpErr != nil {
-			return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	var failures []SymbolResult
+	for _, result := range results {
+		if result.Error != nil {
+			failures = append(failures, result)
+			continue
 		}
-		stocksAgg, err := handlers.Context.esStock.GetStocksAgg(symbol, params.Window, params.Step, start, end)
-		if err != nil {
-			return handlers.errorHandler(c, http.StatusInternalServerError, err)
+		symbols = append(symbols, result.Symbol)
+		stocks = append(stocks, result.Data.([]es.StocksAgg))
+	}
+	history, err := alignHistory(symbols, stocks, params.Fill, params.Rebase)
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	history.Status = listStatus(results)
+	history.Errors = failures
+	return c.JSON(http.StatusOK, history)
+}
+
+// Candles retrieves the candlesticks of a stock over a period
+//
+// With a calendar period, the history starts at the beginning of the period of the first day so that the
+// first candle is complete.
+//
+// This function is a handler for http server, it should not be called directly
+func (handlers *StockHandlers) Candles(c echo.Context) error {
+	var params CandlesParams
+	if handlerErr := getQuery(c, handlers.Context, &params); handlerErr != nil {
+		return handlers.errorHandler(c, handlerErr.Status, handlerErr.error)
+	}
+	if params.Period != "" && params.Size > 0 {
+		return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("size", errPeriodAndSize))
+	}
+	if params.Period == "" {
+		params.Period = ohlc.PeriodDay
+	}
+	start, end, httpErr := params.period(handlers.getDate(), params.Days)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	if params.Size == 0 {
+		var err error
+		if start, err = ohlc.PeriodStart(start, params.Period); err != nil {
+			return handlers.errorHandler(c, http.StatusBadRequest, invalidParam("period", err))
 		}
-		stocks = append(stocks, stocksAgg)
 	}
-	return c.JSON(http.StatusOK, stocks)
+	bars, httpErr := loadBars(c.Request().Context(), handlers.Context, handlers.indexStock, c.Param("symbol"), start, end)
+	if httpErr != nil {
+		return handlers.errorHandler(c, httpErr.Status, httpErr.error)
+	}
+	var candles []es.StockBar
+	var err error
+	if params.Size > 0 {
+		candles, err = ohlc.ResampleBars(bars, params.Size)
+	} else {
+		candles, err = ohlc.Resample(bars, params.Period)
+	}
+	if err != nil {
+		return handlers.errorHandler(c, http.StatusBadRequest, err)
+	}
+	return c.JSON(http.StatusOK, candles)
 }
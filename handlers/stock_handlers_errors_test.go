@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/clebi/gofin/es"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,18 +31,21 @@ var errorTests = []struct {
 	getDate         GetDateFunc
 	expectedStatus  int
 	expectedMessage string
+	symbolCode      string
 }{
 	{
 		&Context{sh: &ErrorSchemaDecoder{Msg: genericErrorMsg}},
 		getTestDate,
 		http.StatusInternalServerError,
 		genericErrorMsg,
+		"",
 	},
 	{
 		&Context{sh: &DummySchemaDecoder{}, validator: &ErrorStructValidator{Msg: genericErrorMsg}},
 		getTestDate,
 		http.StatusBadRequest,
 		genericErrorMsg,
+		"",
 	},
 	{
 		&Context{
@@ -52,6 +56,7 @@ var errorTests = []struct {
 		getTestDate,
 		http.StatusBadRequest,
 		genericErrorMsg,
+		ErrorCodeProviderUnavailable,
 	},
 	{
 		&Context{
@@ -63,6 +68,7 @@ var errorTests = []struct {
 		getTestDate,
 		http.StatusInternalServerError,
 		genericErrorMsg,
+		ErrorCodeInternal,
 	},
 	{
 		&Context{
@@ -74,6 +80,65 @@ var errorTests = []struct {
 		getTestDate,
 		http.StatusInternalServerError,
 		genericErrorMsg,
+		ErrorCodeInternal,
+	},
+	{
+		&Context{
+			sh:        &HistorySchemaDecoder{Params: HistoryParams{Days: 3, Window: 2, Step: 1, Model: "holt_winters"}},
+			validator: &DummyStructValidator{},
+		},
+		getTestDate,
+		http.StatusBadRequest,
+		"holt_winters_2 needs a period",
+		"",
+	},
+	{
+		&Context{
+			sh:        &HistorySchemaDecoder{Params: HistoryParams{Days: 3, Window: 2, Step: 1, Overlays: []string{"ewma:x"}}},
+			validator: &DummyStructValidator{},
+		},
+		getTestDate,
+		http.StatusBadRequest,
+		"bad overlay: ewma:x",
+		"",
+	},
+	{
+		&Context{
+			sh:        &HistorySchemaDecoder{Params: HistoryParams{Days: 3, Window: 2, Step: 1, Overlays: []string{"simple:2"}}},
+			validator: &DummyStructValidator{},
+		},
+		getTestDate,
+		http.StatusBadRequest,
+		"duplicate overlay: mv_2",
+		"",
+	},
+	{
+		&Context{
+			sh: &HistorySchemaDecoder{Params: HistoryParams{
+				DateRangeParams: DateRangeParams{From: "2016-12-01", To: "2016-11-01"},
+				Window:          2,
+				Step:            1,
+			}},
+			validator: &DummyStructValidator{},
+		},
+		getTestDate,
+		http.StatusBadRequest,
+		"start 2016-12-01 is after end 2016-11-01",
+		"",
+	},
+	{
+		&Context{
+			sh: &HistorySchemaDecoder{Params: HistoryParams{
+				DateRangeParams: DateRangeParams{AsOf: "12/01/2016"},
+				Window:          2,
+				Step:            1,
+			}},
+			validator: &DummyStructValidator{},
+		},
+		getTestDate,
+		http.StatusBadRequest,
+		"bad date: 12/01/2016",
+		"",
 	},
 }
 
@@ -83,6 +148,7 @@ func TestHistoryErrors(t *testing.T) {
 			Context:      tt.context,
 			getDate:      tt.getDate,
 			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			indexStock:   indexStock,
 		}
 		req, err := http.NewRequest(testHistoryListMethod, testHistoryListRequest, nil)
 		if err != nil {
@@ -94,19 +160,145 @@ func TestHistoryErrors(t *testing.T) {
 	}
 }
 
+func TestHistoryIndexStockError(t *testing.T) {
+	handlers := StockHandlers{
+		Context:      &Context{sh: &DummySchemaDecoder{}, validator: &DummyStructValidator{}},
+		getDate:      getTestDate,
+		errorHandler: createErrorHandler(t, http.StatusBadRequest, genericErrorMsg),
+		indexStock:   createTestIndexStockError(http.StatusBadRequest, genericErrorMsg),
+	}
+	req, err := http.NewRequest(testHistoryMethod, testHistoryRequest, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c, _ := createEcho(req)
+	assert.NotNil(t, handlers.History(c))
+}
+
 func TestHistoryListErrors(t *testing.T) {
 	for _, tt := range errorTests {
 		handlers := StockHandlers{
 			Context:      tt.context,
 			getDate:      tt.getDate,
 			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			indexStock:   indexStock,
 		}
 		req, err := http.NewRequest(testHistoryMethod, testHistoryRequest, nil)
 		if err != nil {
 			t.Fatal(err.Error())
 		}
-		c, _ := createEcho(req)
+		c, resp := createEcho(req)
 		res := handlers.HistoryList(c)
+		// the failures of the symbols are in the results of the list
+		if tt.symbolCode != "" {
+			assertSymbolFailure(t, resp, tt.symbolCode, tt.expectedMessage)
+			continue
+		}
+		assert.NotNil(t, res)
+	}
+}
+
+func TestHistoryListAlignedErrors(t *testing.T) {
+	handlers := StockHandlers{
+		Context: &Context{
+			sh: &HistoryListSchemaDecoder{Params: HistoryListParams{
+				HistoryParams: HistoryParams{Days: 3, Window: 2, Step: 1},
+				Symbols:       []string{"TEST", "OTHER"},
+				Rebase:        true,
+			}},
+			historyAPI: &DummyFinanceAPI{},
+			esStock: &mockEsStock{stockAggs: map[string][]es.StocksAgg{
+				"TEST":  {{Symbol: "TEST", MsTime: 0, AvgClose: 1}},
+				"OTHER": {{Symbol: "OTHER", MsTime: 1, AvgClose: 1}},
+			}},
+			validator: &DummyStructValidator{},
+		},
+		getDate:      getTestDate,
+		errorHandler: createErrorHandler(t, http.StatusBadRequest, errNoCommonDate.Error()),
+		indexStock:   indexStock,
+	}
+	req, err := http.NewRequest(testHistoryListMethod, testHistoryListRequest, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	c, _ := createEcho(req)
+	res := handlers.HistoryList(c)
+	assert.NotNil(t, res)
+}
+
+var candlesErrorTests = []struct {
+	context         *Context
+	expectedStatus  int
+	expectedMessage string
+	indexStockFunc  indexStockFunc
+}{
+	{
+		&Context{sh: &ErrorSchemaDecoder{Msg: genericErrorMsg}},
+		http.StatusInternalServerError,
+		genericErrorMsg,
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &CandlesSchemaDecoder{Params: CandlesParams{Days: 10, Period: "week", Size: 5}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		errPeriodAndSize.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &CandlesSchemaDecoder{Params: CandlesParams{Period: "week"}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		errDaysOrFrom.Error(),
+		testIndexStockNoError,
+	},
+	{
+		&Context{
+			sh:        &CandlesSchemaDecoder{Params: CandlesParams{Days: 10, DateRangeParams: DateRangeParams{AsOf: "2016-12-14"}}},
+			validator: &DummyStructValidator{},
+		},
+		http.StatusBadRequest,
+		"2016-12-14 is after 2016-12-13",
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &CandlesSchemaDecoder{Params: CandlesParams{Days: 10, Period: "decade"}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		"unknown period: decade",
+		testIndexStockNoError,
+	},
+	{
+		&Context{sh: &CandlesSchemaDecoder{Params: CandlesParams{Days: 10}}, validator: &DummyStructValidator{}},
+		http.StatusBadRequest,
+		genericErrorMsg,
+		createTestIndexStockError(http.StatusBadRequest, genericErrorMsg),
+	},
+	{
+		&Context{
+			sh:        &CandlesSchemaDecoder{Params: CandlesParams{Days: 10}},
+			validator: &DummyStructValidator{},
+			esStock:   &ErrorBarsEsStock{Msg: genericErrorMsg},
+		},
+		http.StatusInternalServerError,
+		genericErrorMsg,
+		testIndexStockNoError,
+	},
+}
+
+func TestCandlesErrors(t *testing.T) {
+	for _, tt := range candlesErrorTests {
+		handlers := StockHandlers{
+			Context:      tt.context,
+			errorHandler: createErrorHandler(t, tt.expectedStatus, tt.expectedMessage),
+			getDate:      getTestDate,
+			indexStock:   tt.indexStockFunc,
+		}
+		req, err := http.NewRequest("GET", testCandlesRequest, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		c, _ := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST")
+		res := handlers.Candles(c)
 		assert.NotNil(t, res)
 	}
 }
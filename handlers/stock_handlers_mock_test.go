@@ -15,7 +15,9 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/clebi/gofin/es"
@@ -35,14 +37,19 @@ func (mock *mockHistoryAPI) GetHistory(symbol string, start time.Time, end time.
 
 type mockEsStock struct {
 	es.Stock
+	mutex     sync.Mutex
 	stockAggs map[string][]es.StocksAgg
+	movAvgs   []es.MovAvg
 }
 
 func (mock *mockEsStock) Index(stock finance.Stock) error {
 	return nil
 }
 
-func (mock *mockEsStock) GetStocksAgg(symbol string, movAvgWindow int, step int, startDate time.Time, endDate time.Time) ([]es.StocksAgg, error) {
+func (mock *mockEsStock) GetStocksAgg(ctx context.Context, symbol string, movAvgs []es.MovAvg, step int, startDate time.Time, endDate time.Time) ([]es.StocksAgg, error) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+	mock.movAvgs = movAvgs
 	return mock.stockAggs[symbol], nil
 }
 
@@ -50,12 +57,46 @@ type DummySchemaDecoder struct {
 }
 
 func (decoder *DummySchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
-	if params, ok := dst.(*HistoryListParams); ok {
+	switch params := dst.(type) {
+	case *HistoryParams:
+		params.Days = 10
+	case *HistoryListParams:
+		params.Days = 10
 		params.Symbols = append(params.Symbols, "TEST")
 	}
 	return nil
 }
 
+type HistorySchemaDecoder struct {
+	Params HistoryParams
+}
+
+func (decoder *HistorySchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	switch params := dst.(type) {
+	case *HistoryParams:
+		*params = decoder.Params
+	case *HistoryListParams:
+		params.HistoryParams = decoder.Params
+		params.Symbols = []string{"TEST"}
+	default:
+		return errors.New("bad type for HistorySchemaDecoder")
+	}
+	return nil
+}
+
+type HistoryListSchemaDecoder struct {
+	Params HistoryListParams
+}
+
+func (decoder *HistoryListSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*HistoryListParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for HistoryListSchemaDecoder")
+	}
+	return nil
+}
+
 type ErrorSchemaDecoder struct {
 	Msg string
 }
@@ -100,6 +141,19 @@ type esStockGetStockAggError struct {
 	Msg string
 }
 
-func (mock *esStockGetStockAggError) GetStocksAgg(symbol string, movAvgWindow int, step int, startDate time.Time, endDate time.Time) ([]es.StocksAgg, error) {
+func (mock *esStockGetStockAggError) GetStocksAgg(ctx context.Context, symbol string, movAvgs []es.MovAvg, step int, startDate time.Time, endDate time.Time) ([]es.StocksAgg, error) {
 	return nil, errors.New(mock.Msg)
 }
+
+type CandlesSchemaDecoder struct {
+	Params CandlesParams
+}
+
+func (decoder *CandlesSchemaDecoder) Decode(dst interface{}, src map[string][]string) error {
+	if params, ok := dst.(*CandlesParams); ok {
+		*params = decoder.Params
+	} else {
+		return errors.New("bad type for CandlesSchemaDecoder")
+	}
+	return nil
+}
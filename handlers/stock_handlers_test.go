@@ -67,7 +67,8 @@ func prepareHisotryCall(
 			esStock:    mockedStock,
 			validator:  &DummyStructValidator{},
 		},
-		getDate: getTestDate,
+		getDate:    getTestDate,
+		indexStock: indexStock,
 	}
 	return req, stocksAggsJSON, &handlers, nil
 }
@@ -99,6 +100,69 @@ func TestHistory(t *testing.T) {
 	handlers.History(c)
 	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
 	assert.Equal(t, string(stockAggJSON), resp.Body.String())
+	assert.Equal(t, []es.MovAvg{{Name: "mv_2", Model: es.MovAvgSimple, Window: 2}}, mockedEsStock.movAvgs)
+}
+
+func TestHistoryDates(t *testing.T) {
+	start := testBarDate("2016-11-01")
+	asOf := testBarDate("2016-12-10")
+	mockedHistoryAPI := mockHistoryAPI{}
+	mockedHistoryAPI.On("GetHistory", symbolTest, start.AddDate(0, 0, -2), asOf).Return([]finance.Stock{}, nil)
+	mockedEsStock := mockEsStock{stockAggs: map[string][]es.StocksAgg{}}
+	req, _, handlers, err := prepareHisotryCall(
+		testHistoryMethod,
+		"http://test.test/graph/TEST?window=2&step=2&from=2016-11-01&to=2016-12-31&asOf=2016-12-10",
+		nil,
+		&mockedHistoryAPI,
+		&mockedEsStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	c.SetParamNames("symbol")
+	c.SetParamValues(symbolTest)
+	handlers.History(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	mockedHistoryAPI.AssertExpectations(t)
+}
+
+func TestHistoryOverlays(t *testing.T) {
+	mockedHistoryAPI := mockHistoryAPI{}
+	mockedHistoryAPI.On("GetHistory", symbolTest, testStartDate.AddDate(0, 0, -4), testEndDate).Return([]finance.Stock{}, nil)
+	mockedEsStock := mockEsStock{
+		stockAggs: map[string][]es.StocksAgg{
+			symbolTest: {{
+				Symbol:   symbolTest,
+				MsTime:   testStartDate.Unix() * 1000,
+				AvgClose: 4.4,
+				MovClose: 4.1,
+				Overlays: map[string]float64{"mv_3": 4, "holt_winters_4": 4.2},
+			}},
+		},
+	}
+	resp := httptest.NewRecorder()
+	req, stockAggJSON, handlers, err := prepareHisotryCall(
+		testHistoryMethod,
+		testHistoryRequest+"&model=ewma&alpha=0.5&overlays=simple:3&overlays=holt_winters:4:period=2:gamma=0.2:seasonality=mult",
+		mockedEsStock.stockAggs[symbolTest],
+		&mockedHistoryAPI,
+		&mockedEsStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	c := e.NewContext(req, resp)
+	c.SetParamNames("symbol")
+	c.SetParamValues(symbolTest)
+	handlers.History(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, string(stockAggJSON), resp.Body.String())
+	assert.Equal(t, []es.MovAvg{
+		{Name: "ewma_2", Model: es.MovAvgEWMA, Window: 2, Alpha: 0.5},
+		{Name: "mv_3", Model: es.MovAvgSimple, Window: 3},
+		{Name: "holt_winters_4", Model: es.MovAvgHoltWinters, Window: 4, Gamma: 0.2, Period: 2, Multiplicative: true},
+	}, mockedEsStock.movAvgs)
+	mockedHistoryAPI.AssertExpectations(t)
 }
 
 func TestHistoryList(t *testing.T) {
@@ -106,7 +170,7 @@ func TestHistoryList(t *testing.T) {
 		{{Open: 1.1, High: 1.2, Low: 2.3, Close: 2.4, Volume: 111, Symbol: testHistoryListSymbol1, Date: finance.YTime{Time: testStartDate}}},
 		{{Open: 2.1, High: 2.2, Low: 2.3, Close: 2.4, Volume: 222, Symbol: testHistoryListSymbol2, Date: finance.YTime{Time: testStartDate}}},
 	}
-	var stocksAggs [][]es.StocksAgg
+	var results []SymbolResult
 	mapStocksAggs := map[string][]es.StocksAgg{}
 	mockedHistoryAPI := mockHistoryAPI{}
 	for _, stockList := range stocks {
@@ -121,14 +185,14 @@ func TestHistoryList(t *testing.T) {
 			},
 		}
 		mapStocksAggs[symbol] = stocksAgg
-		stocksAggs = append(stocksAggs, stocksAgg)
+		results = append(results, SymbolResult{Symbol: symbol, Data: stocksAgg})
 	}
 
 	mockedEsStock := mockEsStock{stockAggs: mapStocksAggs}
 	req, stocksAggsJSON, handlers, err := prepareHisotryCall(
 		testHistoryListMethod,
 		testHistoryListRequest,
-		stocksAggs,
+		ListResult{Status: ListStatusOK, Results: results},
 		&mockedHistoryAPI,
 		&mockedEsStock)
 	if err != nil {
@@ -141,3 +205,185 @@ func TestHistoryList(t *testing.T) {
 	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
 	assert.Equal(t, string(stocksAggsJSON), resp.Body.String())
 }
+
+func TestHistoryListPartial(t *testing.T) {
+	mockedHistoryAPI := mockHistoryAPI{}
+	mockedHistoryAPI.On("GetHistory", testHistoryListSymbol1, testStartMovDate, testEndDate).Return([]finance.Stock{}, nil)
+	mockedHistoryAPI.On("GetHistory", testHistoryListSymbol2, testStartMovDate, testEndDate).Return([]finance.Stock{}, nil)
+	stocksAgg := []es.StocksAgg{{Symbol: testHistoryListSymbol1, MsTime: testStartDate.Unix() * 1000, AvgClose: 2, MovClose: 1}}
+	mockedEsStock := mockEsStock{stockAggs: map[string][]es.StocksAgg{testHistoryListSymbol1: stocksAgg}}
+	req, resultJSON, handlers, err := prepareHisotryCall(
+		testHistoryListMethod,
+		testHistoryListRequest,
+		ListResult{Status: ListStatusPartial, Results: []SymbolResult{
+			{Symbol: testHistoryListSymbol1, Data: stocksAgg},
+			{Symbol: testHistoryListSymbol2, Error: &SymbolError{
+				Code:    ErrorCodeNotFound,
+				Message: "no history for " + testHistoryListSymbol2,
+			}},
+		}},
+		&mockedHistoryAPI,
+		&mockedEsStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.HistoryList(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	assert.Equal(t, string(resultJSON), resp.Body.String())
+	req, _, handlers, err = prepareHisotryCall(
+		testHistoryListMethod,
+		testHistoryListRequest+"&align=true",
+		nil,
+		&mockedHistoryAPI,
+		&mockedEsStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp = createEcho(req)
+	handlers.HistoryList(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var history AlignedHistory
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &history))
+	assert.Equal(t, ListStatusPartial, history.Status)
+	assert.Equal(t, []string{testHistoryListSymbol1}, history.Symbols)
+	assert.Equal(t, ErrorCodeNotFound, history.Errors[0].Error.Code)
+}
+
+func TestHistoryListAligned(t *testing.T) {
+	day := int64(24 * 3600 * 1000)
+	mockedHistoryAPI := mockHistoryAPI{}
+	mockedHistoryAPI.On("GetHistory", testHistoryListSymbol1, testStartMovDate, testEndDate).Return([]finance.Stock{}, nil)
+	mockedHistoryAPI.On("GetHistory", testHistoryListSymbol2, testStartMovDate, testEndDate).Return([]finance.Stock{}, nil)
+	mockedEsStock := mockEsStock{stockAggs: map[string][]es.StocksAgg{
+		testHistoryListSymbol1: {
+			{Symbol: testHistoryListSymbol1, MsTime: 0, AvgClose: 10, MovClose: 10},
+			{Symbol: testHistoryListSymbol1, MsTime: day, AvgClose: 20, MovClose: 15},
+			{Symbol: testHistoryListSymbol1, MsTime: 3 * day, AvgClose: 5, MovClose: 12.5},
+		},
+		testHistoryListSymbol2: {
+			{Symbol: testHistoryListSymbol2, MsTime: day, AvgClose: 50, MovClose: 50},
+			{Symbol: testHistoryListSymbol2, MsTime: 2 * day, AvgClose: 100, MovClose: 75},
+		},
+	}}
+	req, _, handlers, err := prepareHisotryCall(
+		testHistoryListMethod,
+		testHistoryListRequest+"&rebase=true&fill=forward",
+		nil,
+		&mockedHistoryAPI,
+		&mockedEsStock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, resp := createEcho(req)
+	handlers.HistoryList(c)
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var history AlignedHistory
+	if err := json.Unmarshal(resp.Body.Bytes(), &history); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int64{0, day, 2 * day, 3 * day}, history.Dates)
+	assert.Equal(t, []string{testHistoryListSymbol1, testHistoryListSymbol2}, history.Symbols)
+	assert.Equal(t, day, *history.Base)
+	assert.Equal(t, []*float64{nullable(50), nullable(100), nullable(100), nullable(25)}, history.Closes[testHistoryListSymbol1])
+	assert.Equal(t, []*float64{nil, nullable(100), nullable(200), nullable(200)}, history.Closes[testHistoryListSymbol2])
+	assert.Equal(t, []*float64{nil, nullable(100), nullable(150), nullable(150)}, history.MovCloses[testHistoryListSymbol2])
+}
+
+func TestAlignHistory(t *testing.T) {
+	stocks := [][]es.StocksAgg{
+		{{MsTime: 1, AvgClose: 2, MovClose: 2, Overlays: map[string]float64{"mv_3": 4}}, {MsTime: 3, AvgClose: 3, MovClose: 2.5}},
+		{{MsTime: 2, AvgClose: 8, MovClose: 8}},
+	}
+	history, err := alignHistory([]string{"A", "B"}, stocks, "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, history.Dates)
+	assert.Nil(t, history.Base)
+	assert.Equal(t, []*float64{nullable(2), nil, nullable(3)}, history.Closes["A"])
+	assert.Equal(t, []*float64{nil, nullable(8), nil}, history.Closes["B"])
+	assert.Equal(t, map[string]map[string][]*float64{"A": {"mv_3": {nullable(4), nil, nil}}}, history.Overlays)
+	_, err = alignHistory([]string{"A", "B"}, stocks, "", true)
+	assert.Equal(t, errNoCommonDate, err)
+	history, err = alignHistory([]string{"A", "B"}, stocks, fillForward, true)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), *history.Base)
+	assert.Equal(t, []*float64{nullable(100), nullable(100), nullable(150)}, history.Closes["A"])
+	assert.Equal(t, []*float64{nil, nullable(100), nullable(100)}, history.Closes["B"])
+	assert.Equal(t, []*float64{nullable(200), nullable(200), nullable(200)}, history.Overlays["A"]["mv_3"])
+}
+
+const testCandlesRequest = "http://test.test/history/TEST/candles"
+
+func TestCandles(t *testing.T) {
+	day := getSeriesTestDate()
+	var candlesTests = []struct {
+		params   CandlesParams
+		expected []es.StockBar
+	}{
+		{
+			CandlesParams{Days: 10, Period: "week"},
+			[]es.StockBar{
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -9), Open: 11, High: 12, Low: 10, Close: 11, Volume: 100},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -8), Open: 12, High: 19, Low: 11, Close: 18, Volume: 700},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -1), Open: 19, High: 21, Low: 18, Close: 20, Volume: 200},
+			},
+		},
+		{
+			CandlesParams{Days: 10, Size: 4},
+			[]es.StockBar{
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -9), Open: 11, High: 13, Low: 10, Close: 12, Volume: 200},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -7), Open: 13, High: 17, Low: 12, Close: 16, Volume: 400},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -3), Open: 17, High: 21, Low: 16, Close: 20, Volume: 400},
+			},
+		},
+		{
+			CandlesParams{Days: 2},
+			[]es.StockBar{
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -2), Open: 18, High: 19, Low: 17, Close: 18, Volume: 100},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -1), Open: 19, High: 20, Low: 18, Close: 19, Volume: 100},
+				{Symbol: "TEST", Date: day, Open: 20, High: 21, Low: 19, Close: 20, Volume: 100},
+			},
+		},
+		{
+			CandlesParams{DateRangeParams: DateRangeParams{
+				From: day.AddDate(0, 0, -3).Format(queryDateFormat),
+				To:   day.AddDate(0, 0, -1).Format(queryDateFormat),
+			}},
+			[]es.StockBar{
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -3), Open: 17, High: 18, Low: 16, Close: 17, Volume: 100},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -2), Open: 18, High: 19, Low: 17, Close: 18, Volume: 100},
+				{Symbol: "TEST", Date: day.AddDate(0, 0, -1), Open: 19, High: 20, Low: 18, Close: 19, Volume: 100},
+			},
+		},
+	}
+	for _, tt := range candlesTests {
+		handlers := &StockHandlers{
+			Context: &Context{
+				sh:        &CandlesSchemaDecoder{Params: tt.params},
+				validator: &DummyStructValidator{},
+				esStock: &BarsEsStock{bars: map[string][]es.StockBar{
+					"TEST": createIndicatorBars("TEST", day, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20),
+				}},
+			},
+			getDate:    getTestDate,
+			indexStock: testIndexStockNoError,
+		}
+		req, err := http.NewRequest("GET", testCandlesRequest, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, resp := createEcho(req)
+		c.SetParamNames("symbol")
+		c.SetParamValues("TEST")
+		handlers.Candles(c)
+		assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+		var candles []es.StockBar
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &candles))
+		assert.Equal(t, len(tt.expected), len(candles))
+		for i, candle := range tt.expected {
+			assert.True(t, candle.Date.Equal(candles[i].Date))
+			candles[i].Date = candle.Date
+			assert.Equal(t, candle, candles[i])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handlers
+
+import (
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// securityTrades keeps the buys and sells, dividends, fees and cash transfers are left out
+func securityTrades(positions []es.Position) []es.Position {
+	var trades []es.Position
+	for _, position := range positions {
+		if position.Kind == "" {
+			trades = append(trades, position)
+		}
+	}
+	return trades
+}
+
+// positionsAsOf aggregates by symbol the trades made until the end of a day, as GetPositions does with all of them
+func positionsAsOf(trades []es.Position, asOf time.Time) []es.PositionAgg {
+	end := asOf.AddDate(0, 0, 1)
+	positions := []es.PositionAgg{}
+	indexes := map[string]int{}
+	for _, trade := range trades {
+		if trade.Kind != "" || !trade.Date.Before(end) {
+			continue
+		}
+		i, ok := indexes[trade.Symbol]
+		if !ok {
+			i = len(positions)
+			indexes[trade.Symbol] = i
+			positions = append(positions, es.PositionAgg{Symbol: trade.Symbol})
+		}
+		positions[i].Number += trade.Number
+		positions[i].Cost += trade.Cost
+	}
+	return positions
+}
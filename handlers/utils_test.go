@@ -15,6 +15,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -46,15 +47,47 @@ func createErrorHandler(t *testing.T, expectedStatus int, expectedErrorMsg strin
 	}
 }
 
+// createInvalidParamHandler checks that the error is a bad request naming the parameter
+func createInvalidParamHandler(t *testing.T, name string, expectedErrorMsg string) errorHandlerFunc {
+	return func(c echo.Context, status int, err error) error {
+		assert.Equal(t, http.StatusBadRequest, status)
+		if assert.IsType(t, &InvalidParamError{}, err) {
+			assert.Equal(t, name, err.(*InvalidParamError).Params[0].Name)
+		}
+		assert.Equal(t, expectedErrorMsg, err.Error())
+		return err
+	}
+}
+
 func testIndexStockNoError(context *Context, symbol string, start time.Time, end time.Time) *HandlerERROR {
 	return nil
 }
 
 func createTestIndexStockError(status int, msg string) indexStockFunc {
 	return func(context *Context, symbol string, start time.Time, end time.Time) *HandlerERROR {
+		var err error = errors.New(msg)
+		// as indexStock, a failure of the provider is a bad request
+		if status == http.StatusBadRequest {
+			err = &ProviderError{Err: err}
+		}
 		return &HandlerERROR{
 			Status: status,
-			error:  errors.New(msg),
+			error:  err,
 		}
 	}
 }
+
+// assertSymbolFailure checks that a list route answered with the failure of its only symbol
+func assertSymbolFailure(t *testing.T, resp *httptest.ResponseRecorder, code string, message string) {
+	assert.Equal(t, http.StatusOK, resp.Result().StatusCode)
+	var result ListResult
+	assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &result))
+	assert.Equal(t, ListStatusFailed, result.Status)
+	// the message of the internal and storage failures is hidden
+	if !exposesDetail(code) {
+		message = ""
+	}
+	if assert.Equal(t, 1, len(result.Results)) {
+		assert.Equal(t, &SymbolError{Code: code, Message: message}, result.Results[0].Error)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/clebi/gofin/es"
+)
+
+const (
+	byteOrderMark  = "\ufeff"
+	currencyCutset = " \u00a0€$£"
+)
+
+// csvRow gives access to the fields of a row by column name
+type csvRow struct {
+	columns map[string]int
+	fields  []string
+}
+
+func (row csvRow) get(column string) (string, error) {
+	index, ok := row.columns[strings.ToLower(column)]
+	if !ok {
+		return "", fmt.Errorf("unknown column: %s", column)
+	}
+	if index >= len(row.fields) {
+		return "", fmt.Errorf("missing column: %s", column)
+	}
+	return strings.TrimSpace(row.fields[index]), nil
+}
+
+func (row csvRow) number(column string, profile es.ImportProfile) (float64, error) {
+	value, err := row.get(column)
+	if err != nil {
+		return 0, err
+	}
+	number, err := ParseNumber(value, profile.DecimalSeparator, profile.ThousandsSeparator)
+	if err != nil {
+		return 0, fmt.Errorf("bad number in %s: %s", column, value)
+	}
+	return number, nil
+}
+
+// ParseNumber parses a number written with locale separators, currency signs and accounting negatives
+//
+// 	ParseNumber("(1 234,50 €)", ",", " ")
+//
+// returns the number, -1234.5 in the example
+func ParseNumber(value string, decimalSeparator string, thousandsSeparator string) (float64, error) {
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	value = strings.Trim(value, currencyCutset)
+	negative := strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")")
+	if negative {
+		value = strings.Trim(value[1:len(value)-1], currencyCutset)
+	}
+	value = strings.Replace(value, " ", "", -1)
+	if thousandsSeparator != "" {
+		value = strings.Replace(value, thousandsSeparator, "", -1)
+	}
+	value = strings.Replace(value, decimalSeparator, ".", -1)
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		number = -number
+	}
+	return number, nil
+}
+
+func hasMarker(value string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.EqualFold(value, strings.TrimSpace(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePosition builds a position from a statement row
+func parsePosition(row csvRow, profile es.ImportProfile, username string, broker string) (*es.Position, error) {
+	dateValue, err := row.get(profile.DateColumn)
+	if err != nil {
+		return nil, err
+	}
+	date, err := time.Parse(profile.DateFormat, dateValue)
+	if err != nil {
+		return nil, fmt.Errorf("bad date: %s", dateValue)
+	}
+	symbol, err := row.get(profile.SymbolColumn)
+	if err != nil {
+		return nil, err
+	}
+	quantity, err := row.number(profile.QuantityColumn, profile)
+	if err != nil {
+		return nil, err
+	}
+	price, err := row.number(profile.PriceColumn, profile)
+	if err != nil {
+		return nil, err
+	}
+	sell := quantity < 0
+	if profile.ActionColumn != "" {
+		action, err := row.get(profile.ActionColumn)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case hasMarker(action, profile.BuyMarkers):
+			sell = false
+		case hasMarker(action, profile.SellMarkers):
+			sell = true
+		default:
+			return nil, fmt.Errorf("unknown action: %s", action)
+		}
+	}
+	quantity = math.Abs(quantity)
+	if quantity != math.Trunc(quantity) {
+		return nil, fmt.Errorf("fractional quantity: %g", quantity)
+	}
+	amount := quantity * math.Abs(price)
+	if profile.AmountColumn != "" {
+		if amount, err = row.number(profile.AmountColumn, profile); err != nil {
+			return nil, err
+		}
+		amount = math.Abs(amount)
+	}
+	var fees float64
+	for _, column := range profile.FeeColumns {
+		fee, err := row.number(column, profile)
+		if err != nil {
+			return nil, err
+		}
+		fees += math.Abs(fee)
+	}
+	position := &es.Position{
+		Username: username,
+		Broker:   broker,
+		Symbol:   symbol,
+		Date:     date,
+		Number:   int(quantity),
+		Value:    math.Abs(price),
+		Cost:     amount + fees,
+	}
+	if sell {
+		position.Number = -position.Number
+		position.Cost = -(amount - fees)
+	}
+	if profile.CurrencyColumn != "" {
+		currency, err := row.get(profile.CurrencyColumn)
+		if err != nil {
+			return nil, err
+		}
+		position.Currency = strings.ToUpper(strings.TrimSpace(currency))
+	}
+	return position, nil
+}
+
+// ParseCSV reads the trades of a broker CSV statement with a mapping profile
+//
+// 	ParseCSV(reader, profile, username, broker)
+//
+// Rows which cannot be read are reported as errors with their number, the header excluded.
+// returns one result per row
+func ParseCSV(reader io.Reader, profile es.ImportProfile, username string, broker string) ([]RowResult, error) {
+	buffered := bufio.NewReader(reader)
+	for i := 0; i < profile.SkipLines; i++ {
+		if _, err := buffered.ReadString('\n'); err != nil {
+			return nil, fmt.Errorf("cannot skip line %d: %s", i+1, err)
+		}
+	}
+	csvReader := csv.NewReader(buffered)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	if profile.Delimiter != "" {
+		csvReader.Comma, _ = utf8.DecodeRuneInString(profile.Delimiter)
+	}
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read header: %s", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, byteOrderMark)))] = i
+	}
+	var rows []RowResult
+	for number := 1; ; number++ {
+		fields, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rows = append(rows, rowError(number, err))
+			continue
+		}
+		position, err := parsePosition(csvRow{columns: columns, fields: fields}, profile, username, broker)
+		if err != nil {
+			rows = append(rows, rowError(number, err))
+			continue
+		}
+		rows = append(rows, RowResult{Row: number, Position: position})
+	}
+	return rows, nil
+}
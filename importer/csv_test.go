@@ -0,0 +1,136 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const testFrenchCSV = "Export du compte\n" +
+	"\ufeffDate;Sens;Valeur;Quantité;Cours;Montant;Frais;Taxe\n" +
+	"20/04/2017;Achat;CW8.PA;10;200,50;2 005,00;5,00;1,50\n" +
+	"21/04/2017;Vente;CW8.PA;4;210,00;840,00;3,00;0\n" +
+	"22/04/2017;Dividende;CW8.PA;0;0;12,00;0;0\n" +
+	"xx/04/2017;Achat;CW8.PA;1;200,00;200,00;0;0\n" +
+	"23/04/2017;Achat;CW8.PA;1,5;200,00;300,00;0;0\n"
+
+var testFrenchProfile = es.ImportProfile{
+	Name:               "french",
+	Delimiter:          ";",
+	SkipLines:          1,
+	DateColumn:         "date",
+	DateFormat:         "02/01/2006",
+	SymbolColumn:       "Valeur",
+	QuantityColumn:     "Quantité",
+	PriceColumn:        "Cours",
+	AmountColumn:       "Montant",
+	FeeColumns:         []string{"Frais", "Taxe"},
+	ActionColumn:       "Sens",
+	BuyMarkers:         []string{"achat"},
+	SellMarkers:        []string{"vente"},
+	DecimalSeparator:   ",",
+	ThousandsSeparator: " ",
+}
+
+func testDate(value string) time.Time {
+	date, _ := time.Parse("2006-01-02", value)
+	return date
+}
+
+func TestParseCSV(t *testing.T) {
+	rows, err := ParseCSV(strings.NewReader(testFrenchCSV), testFrenchProfile, "tester", "broker")
+	assert.Nil(t, err)
+	assert.Equal(t, []RowResult{
+		{Row: 1, Position: &es.Position{Username: "tester", Broker: "broker", Symbol: "CW8.PA",
+			Date: testDate("2017-04-20"), Number: 10, Value: 200.5, Cost: 2011.5}},
+		{Row: 2, Position: &es.Position{Username: "tester", Broker: "broker", Symbol: "CW8.PA",
+			Date: testDate("2017-04-21"), Number: -4, Value: 210, Cost: -837}},
+		{Row: 3, Status: StatusError, Error: "unknown action: Dividende"},
+		{Row: 4, Status: StatusError, Error: "bad date: xx/04/2017"},
+		{Row: 5, Status: StatusError, Error: "fractional quantity: 1.5"},
+	}, rows)
+}
+
+func TestParseCSVSignedQuantity(t *testing.T) {
+	data := "date,symbol,quantity,price,commission\n2017-04-20,AAPL,-3,150.25,1\n2017-04-21,MSFT,2,60,\n"
+	profile := es.ImportProfile{
+		DateColumn:     "date",
+		DateFormat:     "2006-01-02",
+		SymbolColumn:   "symbol",
+		QuantityColumn: "quantity",
+		PriceColumn:    "price",
+		FeeColumns:     []string{"commission", "missing"},
+	}
+	rows, err := ParseCSV(strings.NewReader(data), profile, "tester", "broker")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "unknown column: missing", rows[0].Error)
+	profile.FeeColumns = []string{"commission"}
+	rows, err = ParseCSV(strings.NewReader(data), profile, "tester", "broker")
+	assert.Nil(t, err)
+	assert.Equal(t, -3, rows[0].Position.Number)
+	assert.InDelta(t, -449.75, rows[0].Position.Cost, 1e-9)
+	assert.Equal(t, 2, rows[1].Position.Number)
+	assert.InDelta(t, 120, rows[1].Position.Cost, 1e-9)
+}
+
+func TestParseCSVCurrency(t *testing.T) {
+	data := "date,symbol,quantity,price,ccy\n2017-04-20,CW8.PA,1,200, eur\n"
+	profile := es.ImportProfile{
+		DateColumn:     "date",
+		DateFormat:     "2006-01-02",
+		SymbolColumn:   "symbol",
+		QuantityColumn: "quantity",
+		PriceColumn:    "price",
+		CurrencyColumn: "ccy",
+	}
+	rows, err := ParseCSV(strings.NewReader(data), profile, "tester", "broker")
+	assert.Nil(t, err)
+	assert.Equal(t, "EUR", rows[0].Position.Currency)
+}
+
+func TestParseCSVNoHeader(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader(""), es.ImportProfile{}, "tester", "broker")
+	assert.NotNil(t, err)
+}
+
+var parseNumberTests = []struct {
+	value     string
+	decimal   string
+	thousands string
+	expected  float64
+}{
+	{"1234.5", "", "", 1234.5},
+	{"1,234.5", ".", ",", 1234.5},
+	{"(1 234,50 €)", ",", " ", -1234.5},
+	{"-1.234,50", ",", ".", -1234.5},
+	{"$ 12", "", "", 12},
+	{"", "", "", 0},
+}
+
+func TestParseNumber(t *testing.T) {
+	for _, tt := range parseNumberTests {
+		number, err := ParseNumber(tt.value, tt.decimal, tt.thousands)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.expected, number)
+	}
+	_, err := ParseNumber("abc", "", "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Status of an imported row
+const (
+	StatusNew       = "new"
+	StatusImported  = "imported"
+	StatusDuplicate = "duplicate"
+	StatusError     = "error"
+)
+
+// Validator validates structures
+type Validator interface {
+	Struct(s interface{}) error
+}
+
+// RowResult is the outcome of the import of one row of a statement
+type RowResult struct {
+	Row      int          `json:"row"`
+	Status   string       `json:"status"`
+	Error    string       `json:"error,omitempty"`
+	Position *es.Position `json:"position,omitempty"`
+}
+
+// Report contains the outcome of the import of a statement
+type Report struct {
+	DryRun     bool        `json:"dry_run"`
+	Imported   int         `json:"imported"`
+	Duplicates int         `json:"duplicates"`
+	Errors     int         `json:"errors"`
+	Rows       []RowResult `json:"rows"`
+}
+
+// SymbolResolver finds the symbol of a security from the identifiers found in statements
+type SymbolResolver map[string]string
+
+// NewSymbolResolver creates a resolver from the user's symbol mappings
+func NewSymbolResolver(mappings []es.SymbolMapping) SymbolResolver {
+	resolver := SymbolResolver{}
+	for _, mapping := range mappings {
+		resolver[strings.ToUpper(strings.TrimSpace(mapping.Identifier))] = mapping.Symbol
+	}
+	return resolver
+}
+
+// Resolve returns the symbol mapped to the first known identifier
+func (resolver SymbolResolver) Resolve(identifiers ...string) (string, bool) {
+	for _, identifier := range identifiers {
+		if identifier == "" {
+			continue
+		}
+		if symbol, ok := resolver[strings.ToUpper(strings.TrimSpace(identifier))]; ok {
+			return symbol, true
+		}
+	}
+	return "", false
+}
+
+func rowError(row int, err error) RowResult {
+	return RowResult{Row: row, Status: StatusError, Error: err.Error()}
+}
+
+// Import validates parsed rows, detects duplicates and writes the new positions
+//
+// 	Import(rows, existingTrades, esPosition, validator, dryRun)
+//
+// A row is a duplicate when a trade with the same identifier is already stored or earlier in the statement.
+// Nothing is written in dry run mode, new rows are reported with the new status.
+// returns the import report
+func Import(rows []RowResult, existing []es.Position, store es.IPositionStock, validator Validator, dryRun bool) Report {
+	report := Report{DryRun: dryRun, Rows: make([]RowResult, len(rows))}
+	seen := map[string]bool{}
+	for _, trade := range existing {
+		seen[trade.ID()] = true
+	}
+	for i, row := range rows {
+		if row.Status != StatusError {
+			row = importRow(row, seen, store, validator, dryRun)
+		}
+		switch row.Status {
+		case StatusNew, StatusImported:
+			report.Imported++
+		case StatusDuplicate:
+			report.Duplicates++
+		case StatusError:
+			report.Errors++
+		}
+		report.Rows[i] = row
+	}
+	return report
+}
+
+// validateCashMovement checks dividends, fees and transfers which have no number of shares nor price
+func validateCashMovement(position *es.Position) error {
+	if position.Kind != es.KindTransfer && position.Symbol == "" {
+		return errors.New("missing symbol")
+	}
+	if position.Cost == 0 {
+		return errors.New("missing amount")
+	}
+	return nil
+}
+
+func importRow(row RowResult, seen map[string]bool, store es.IPositionStock, validator Validator, dryRun bool) RowResult {
+	var err error
+	if row.Position.Kind == "" {
+		err = validator.Struct(row.Position)
+	} else {
+		err = validateCashMovement(row.Position)
+	}
+	if err != nil {
+		return RowResult{Row: row.Row, Status: StatusError, Error: err.Error(), Position: row.Position}
+	}
+	id := row.Position.ID()
+	if seen[id] {
+		row.Status = StatusDuplicate
+		return row
+	}
+	seen[id] = true
+	if dryRun {
+		row.Status = StatusNew
+		return row
+	}
+	if err := store.AddPosition(row.Position); err != nil {
+		return RowResult{Row: row.Row, Status: StatusError, Error: err.Error(), Position: row.Position}
+	}
+	row.Status = StatusImported
+	return row
+}
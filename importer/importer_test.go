@@ -0,0 +1,86 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordPositionStock struct {
+	added []es.Position
+	err   error
+}
+
+func (store *recordPositionStock) AddPosition(position *es.Position) error {
+	if store.err != nil {
+		return store.err
+	}
+	store.added = append(store.added, *position)
+	return nil
+}
+
+func (store *recordPositionStock) GetPositions(username string) ([]es.PositionAgg, error) {
+	return nil, nil
+}
+
+func (store *recordPositionStock) GetTrades(username string) ([]es.Position, error) {
+	return nil, nil
+}
+
+type nilValidator struct{}
+
+func (validator nilValidator) Struct(s interface{}) error {
+	return nil
+}
+
+var importRows = []RowResult{
+	{Row: 1, Position: &es.Position{Broker: "B", Symbol: "A", Date: testDate("2017-01-01"), Number: 1}},
+	{Row: 2, Position: &es.Position{Broker: "B", Symbol: "A", Date: testDate("2017-01-02"), Number: 1}},
+	{Row: 3, Position: &es.Position{Broker: "B", Symbol: "A", Date: testDate("2017-01-02"), Number: 1}},
+	{Row: 4, Status: StatusError, Error: "bad date"},
+}
+
+var importExisting = []es.Position{{Broker: "B", Symbol: "A", Date: testDate("2017-01-01"), Number: 1}}
+
+func TestImport(t *testing.T) {
+	store := &recordPositionStock{}
+	report := Import(importRows, importExisting, store, nilValidator{}, false)
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, 2, report.Duplicates)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, []string{StatusDuplicate, StatusImported, StatusDuplicate, StatusError},
+		[]string{report.Rows[0].Status, report.Rows[1].Status, report.Rows[2].Status, report.Rows[3].Status})
+	assert.Equal(t, []es.Position{*importRows[1].Position}, store.added)
+}
+
+func TestImportDryRun(t *testing.T) {
+	store := &recordPositionStock{}
+	report := Import(importRows, nil, store, nilValidator{}, true)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, StatusNew, report.Rows[0].Status)
+	assert.Nil(t, store.added)
+}
+
+func TestImportStoreError(t *testing.T) {
+	store := &recordPositionStock{err: errors.New("store_error")}
+	report := Import(importRows[:1], nil, store, nilValidator{}, false)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, "store_error", report.Rows[0].Error)
+}
@@ -0,0 +1,377 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// ErrNoOFX is returned when a statement does not contain an OFX document
+var ErrNoOFX = errors.New("no OFX document")
+
+// ofxNode is an element of an OFX document, leaves have a value and aggregates have children
+type ofxNode struct {
+	name     string
+	value    string
+	children []*ofxNode
+}
+
+func (node *ofxNode) child(name string) *ofxNode {
+	if node == nil {
+		return nil
+	}
+	for _, child := range node.children {
+		if child.name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// get returns the value of a leaf following a path of element names
+func (node *ofxNode) get(path ...string) string {
+	for _, name := range path {
+		node = node.child(name)
+	}
+	if node == nil {
+		return ""
+	}
+	return node.value
+}
+
+// findAll returns all the elements with a name in the tree
+func (node *ofxNode) findAll(name string) []*ofxNode {
+	var found []*ofxNode
+	for _, child := range node.children {
+		if child.name == name {
+			found = append(found, child)
+		}
+		found = append(found, child.findAll(name)...)
+	}
+	return found
+}
+
+// parseOFXTree reads OFX 1.x SGML, where leaves are not closed, as well as OFX 2.x XML
+func parseOFXTree(data string) (*ofxNode, error) {
+	start := strings.Index(strings.ToUpper(data), "<OFX>")
+	if start < 0 {
+		return nil, ErrNoOFX
+	}
+	data = data[start:]
+	root := &ofxNode{}
+	stack := []*ofxNode{root}
+	for len(data) > 0 {
+		open := strings.Index(data, "<")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(data[open:], ">")
+		if end < 0 {
+			return nil, errors.New("unterminated OFX tag")
+		}
+		tag := strings.ToUpper(strings.TrimSpace(data[open+1 : open+end]))
+		data = data[open+end+1:]
+		if strings.HasPrefix(tag, "/") {
+			name := tag[1:]
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!") {
+			continue
+		}
+		node := &ofxNode{name: tag}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, node)
+		next := strings.Index(data, "<")
+		if next < 0 {
+			next = len(data)
+		}
+		if value := strings.TrimSpace(data[:next]); value != "" {
+			node.value = html.UnescapeString(value)
+			data = data[next:]
+			if strings.HasPrefix(strings.ToUpper(data), "</"+tag+">") {
+				data = data[len(tag)+3:]
+			}
+			continue
+		}
+		stack = append(stack, node)
+	}
+	ofx := root.child("OFX")
+	if ofx == nil {
+		return nil, ErrNoOFX
+	}
+	return ofx, nil
+}
+
+// parseOFXDate reads the day of an OFX date time such as 20170420120000.000[-5:EST]
+func parseOFXDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("bad date: %s", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad date: %s", value)
+	}
+	return date, nil
+}
+
+func parseOFXNumber(value string) (float64, error) {
+	if strings.Contains(value, ",") && !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", -1)
+	}
+	number, err := ParseNumber(value, ".", "")
+	if err != nil {
+		return 0, fmt.Errorf("bad number: %s", value)
+	}
+	return number, nil
+}
+
+// ofxTransaction reads the values common to all investment transactions
+type ofxTransaction struct {
+	node     *ofxNode
+	resolver SymbolResolver
+	tickers  map[string]string
+	username string
+	broker   string
+	currency string
+}
+
+func (tx ofxTransaction) number(path ...string) (float64, error) {
+	value := tx.node.get(path...)
+	if value == "" {
+		return 0, nil
+	}
+	return parseOFXNumber(value)
+}
+
+func (tx ofxTransaction) symbol(secID *ofxNode) (string, error) {
+	uniqueID := secID.get("UNIQUEID")
+	ticker := tx.tickers[uniqueID]
+	if symbol, ok := tx.resolver.Resolve(uniqueID, ticker); ok {
+		return symbol, nil
+	}
+	if ticker != "" {
+		return ticker, nil
+	}
+	return "", fmt.Errorf("unknown security: %s %s", secID.get("UNIQUEIDTYPE"), uniqueID)
+}
+
+// currencyOf returns the currency of the amounts of a transaction, the default one of the statement
+// unless the transaction has its own
+func (tx ofxTransaction) currencyOf(base *ofxNode) string {
+	for _, aggregate := range []string{"CURRENCY", "ORIGCURRENCY"} {
+		if symbol := base.get(aggregate, "CURSYM"); symbol != "" {
+			return strings.ToUpper(symbol)
+		}
+	}
+	return tx.currency
+}
+
+// position builds the common part of a position from the INVTRAN aggregate found under base
+func (tx ofxTransaction) position(base *ofxNode, kind string) (*es.Position, error) {
+	invTran := base.child("INVTRAN")
+	date, err := parseOFXDate(invTran.get("DTTRADE"))
+	if err != nil {
+		return nil, err
+	}
+	position := &es.Position{
+		Username:  tx.username,
+		Broker:    tx.broker,
+		Date:      date,
+		Kind:      kind,
+		Reference: invTran.get("FITID"),
+		Currency:  tx.currencyOf(base),
+	}
+	if secID := base.child("SECID"); secID != nil {
+		if position.Symbol, err = tx.symbol(secID); err != nil {
+			return nil, err
+		}
+	}
+	return position, nil
+}
+
+// trade reads a buy or a sell, the total is negative when buying and includes all fees
+func (tx ofxTransaction) trade(base *ofxNode, sell bool) (*es.Position, error) {
+	position, err := tx.position(base, "")
+	if err != nil {
+		return nil, err
+	}
+	child := ofxTransaction{node: base}
+	units, err := child.number("UNITS")
+	if err != nil {
+		return nil, err
+	}
+	price, err := child.number("UNITPRICE")
+	if err != nil {
+		return nil, err
+	}
+	total, err := child.number("TOTAL")
+	if err != nil {
+		return nil, err
+	}
+	units = math.Abs(units)
+	if units != math.Trunc(units) {
+		return nil, fmt.Errorf("fractional quantity: %g", units)
+	}
+	position.Number = int(units)
+	position.Value = price
+	position.Cost = -total
+	if sell {
+		position.Number = -position.Number
+	}
+	return position, nil
+}
+
+// cash reads a dividend or a fee whose total is positive when received
+func (tx ofxTransaction) cash(kind string) (*es.Position, error) {
+	position, err := tx.position(tx.node, kind)
+	if err != nil {
+		return nil, err
+	}
+	total, err := tx.number("TOTAL")
+	if err != nil {
+		return nil, err
+	}
+	position.Cost = -total
+	if kind == es.KindFee {
+		position.Cost = math.Abs(total)
+	}
+	return position, nil
+}
+
+// transfer reads a cash movement of the brokerage account, deposits are paid in by the user
+func (tx ofxTransaction) transfer() (*es.Position, error) {
+	stmtTrn := tx.node.child("STMTTRN")
+	date, err := parseOFXDate(stmtTrn.get("DTPOSTED"))
+	if err != nil {
+		return nil, err
+	}
+	amount, err := parseOFXNumber(stmtTrn.get("TRNAMT"))
+	if err != nil {
+		return nil, err
+	}
+	return &es.Position{
+		Username:  tx.username,
+		Broker:    tx.broker,
+		Date:      date,
+		Cost:      amount,
+		Kind:      es.KindTransfer,
+		Reference: stmtTrn.get("FITID"),
+		Currency:  tx.currencyOf(stmtTrn),
+	}, nil
+}
+
+func (tx ofxTransaction) positions() ([]*es.Position, error) {
+	name := tx.node.name
+	switch {
+	case strings.HasPrefix(name, "BUY"):
+		position, err := tx.trade(tx.node.child("INVBUY"), false)
+		return []*es.Position{position}, err
+	case strings.HasPrefix(name, "SELL"):
+		position, err := tx.trade(tx.node.child("INVSELL"), true)
+		return []*es.Position{position}, err
+	case name == "INCOME":
+		position, err := tx.cash(es.KindDividend)
+		return []*es.Position{position}, err
+	case name == "INVEXPENSE":
+		position, err := tx.cash(es.KindFee)
+		return []*es.Position{position}, err
+	case name == "INVBANKTRAN":
+		position, err := tx.transfer()
+		return []*es.Position{position}, err
+	case name == "REINVEST":
+		income, err := tx.cash(es.KindDividend)
+		if err != nil {
+			return nil, err
+		}
+		income.Reference += ":income"
+		buy, err := tx.trade(tx.node, false)
+		if err != nil {
+			return nil, err
+		}
+		income.Cost = -buy.Cost
+		return []*es.Position{income, buy}, nil
+	}
+	return nil, fmt.Errorf("unsupported transaction: %s", name)
+}
+
+// ParseOFX reads the investment transactions of an OFX or QFX statement
+//
+// 	ParseOFX(reader, resolver, username, broker)
+//
+// Securities are matched on their CUSIP or ISIN then on their ticker through the resolver,
+// the ticker of the statement is used when there is no mapping. Amounts are in the default
+// currency of the statement unless a transaction gives its own.
+// returns one result per position, a reinvested dividend gives a dividend and a buy
+func ParseOFX(reader io.Reader, resolver SymbolResolver, username string, broker string) ([]RowResult, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	ofx, err := parseOFXTree(string(data))
+	if err != nil {
+		return nil, err
+	}
+	tickers := map[string]string{}
+	for _, secInfo := range ofx.findAll("SECINFO") {
+		tickers[secInfo.get("SECID", "UNIQUEID")] = secInfo.get("TICKER")
+	}
+	var rows []RowResult
+	number := 0
+	for _, statement := range ofx.findAll("INVSTMTRS") {
+		tranList := statement.child("INVTRANLIST")
+		if tranList == nil {
+			continue
+		}
+		currency := strings.ToUpper(statement.get("CURDEF"))
+		for _, node := range tranList.children {
+			if node.name == "DTSTART" || node.name == "DTEND" {
+				continue
+			}
+			number++
+			tx := ofxTransaction{
+				node:     node,
+				resolver: resolver,
+				tickers:  tickers,
+				username: username,
+				broker:   broker,
+				currency: currency,
+			}
+			positions, err := tx.positions()
+			if err != nil {
+				rows = append(rows, rowError(number, err))
+				continue
+			}
+			for _, position := range positions {
+				rows = append(rows, RowResult{Row: number, Position: position})
+			}
+		}
+	}
+	return rows, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const testOFX = "OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\n\n<OFX>\n<INVSTMTMSGSRSV1><INVSTMTTRNRS><INVSTMTRS>\n" +
+	"<INVTRANLIST><DTSTART>20170101<DTEND>20171231\n" +
+	"<BUYSTOCK><INVBUY><INVTRAN><FITID>1001<DTTRADE>20170420120000.000[-5:EST]</INVTRAN>" +
+	"<SECID><UNIQUEID>037833100<UNIQUEIDTYPE>CUSIP</SECID>" +
+	"<UNITS>10<UNITPRICE>140.50<COMMISSION>5<TOTAL>-1410.00<SUBACCTSEC>CASH<SUBACCTFUND>CASH</INVBUY>" +
+	"<BUYTYPE>BUY</BUYSTOCK>\n" +
+	"<SELLSTOCK><INVSELL><INVTRAN><FITID>1002<DTTRADE>20170501</INVTRAN>" +
+	"<SECID><UNIQUEID>FR0010315770<UNIQUEIDTYPE>ISIN</SECID>" +
+	"<UNITS>-4<UNITPRICE>210<COMMISSION>3<TOTAL>837<CURRENCY><CURRATE>1.05<CURSYM>EUR</CURRENCY></INVSELL><SELLTYPE>SELL</SELLSTOCK>\n" +
+	"<INCOME><INVTRAN><FITID>1003<DTTRADE>20170515</INVTRAN>" +
+	"<SECID><UNIQUEID>037833100<UNIQUEIDTYPE>CUSIP</SECID><INCOMETYPE>DIV<TOTAL>6.30</INCOME>\n" +
+	"<INVEXPENSE><INVTRAN><FITID>1004<DTTRADE>20170516</INVTRAN>" +
+	"<SECID><UNIQUEID>037833100<UNIQUEIDTYPE>CUSIP</SECID><TOTAL>-2.5</INVEXPENSE>\n" +
+	"<REINVEST><INVTRAN><FITID>1005<DTTRADE>20170601</INVTRAN>" +
+	"<SECID><UNIQUEID>037833100<UNIQUEIDTYPE>CUSIP</SECID><INCOMETYPE>DIV" +
+	"<TOTAL>-150<UNITS>1<UNITPRICE>150</REINVEST>\n" +
+	"<INVBANKTRAN><STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20170102<TRNAMT>5000<FITID>1006</STMTTRN>" +
+	"<SUBACCTFUND>CASH</INVBANKTRAN>\n" +
+	"<BUYSTOCK><INVBUY><INVTRAN><FITID>1007<DTTRADE>20170701</INVTRAN>" +
+	"<SECID><UNIQUEID>999999999<UNIQUEIDTYPE>CUSIP</SECID><UNITS>1<UNITPRICE>1<TOTAL>-1</INVBUY></BUYSTOCK>\n" +
+	"<TRANSFER><INVTRAN><FITID>1008<DTTRADE>20170702</INVTRAN></TRANSFER>\n" +
+	"</INVTRANLIST></INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1>\n" +
+	"<SECLISTMSGSRSV1><SECLIST>" +
+	"<STOCKINFO><SECINFO><SECID><UNIQUEID>037833100<UNIQUEIDTYPE>CUSIP</SECID>" +
+	"<SECNAME>Apple Inc.<TICKER>AAPL</SECINFO></STOCKINFO>" +
+	"<STOCKINFO><SECINFO><SECID><UNIQUEID>FR0010315770<UNIQUEIDTYPE>ISIN</SECID>" +
+	"<SECNAME>Amundi MSCI World &amp; Co</SECINFO></STOCKINFO>" +
+	"</SECLIST></SECLISTMSGSRSV1>\n</OFX>\n"
+
+func testOFXPosition(symbol string, date string, number int, value float64, cost float64, kind string, reference string) *es.Position {
+	return &es.Position{Username: "tester", Broker: "broker", Symbol: symbol, Date: testDate(date),
+		Number: number, Value: value, Cost: cost, Kind: kind, Reference: reference}
+}
+
+func TestParseOFX(t *testing.T) {
+	resolver := NewSymbolResolver([]es.SymbolMapping{{Identifier: "fr0010315770", Symbol: "CW8.PA"}})
+	rows, err := ParseOFX(strings.NewReader(testOFX), resolver, "tester", "broker")
+	assert.Nil(t, err)
+	sell := testOFXPosition("CW8.PA", "2017-05-01", -4, 210, -837, "", "1002")
+	sell.Currency = "EUR"
+	assert.Equal(t, []RowResult{
+		{Row: 1, Position: testOFXPosition("AAPL", "2017-04-20", 10, 140.5, 1410, "", "1001")},
+		{Row: 2, Position: sell},
+		{Row: 3, Position: testOFXPosition("AAPL", "2017-05-15", 0, 0, -6.3, es.KindDividend, "1003")},
+		{Row: 4, Position: testOFXPosition("AAPL", "2017-05-16", 0, 0, 2.5, es.KindFee, "1004")},
+		{Row: 5, Position: testOFXPosition("AAPL", "2017-06-01", 0, 0, -150, es.KindDividend, "1005:income")},
+		{Row: 5, Position: testOFXPosition("AAPL", "2017-06-01", 1, 150, 150, "", "1005")},
+		{Row: 6, Position: testOFXPosition("", "2017-01-02", 0, 0, 5000, es.KindTransfer, "1006")},
+		{Row: 7, Status: StatusError, Error: "unknown security: CUSIP 999999999"},
+		{Row: 8, Status: StatusError, Error: "unsupported transaction: TRANSFER"},
+	}, rows)
+}
+
+func TestParseOFXXML(t *testing.T) {
+	data := "<?xml version=\"1.0\"?><?OFX OFXHEADER=\"200\"?><OFX><INVSTMTMSGSRSV1><INVSTMTTRNRS><INVSTMTRS>" +
+		"<CURDEF>eur</CURDEF>" +
+		"<INVTRANLIST><DTSTART>20170101</DTSTART><DTEND>20171231</DTEND>" +
+		"<SELLSTOCK><INVSELL><INVTRAN><FITID>2001</FITID><DTTRADE>20170420</DTTRADE></INVTRAN>" +
+		"<SECID><UNIQUEID>MSFT</UNIQUEID><UNIQUEIDTYPE>TICKER</UNIQUEIDTYPE></SECID>" +
+		"<UNITS>-2</UNITS><UNITPRICE>65,5</UNITPRICE><TOTAL>130</TOTAL></INVSELL>" +
+		"<SELLTYPE>SELL</SELLTYPE></SELLSTOCK></INVTRANLIST></INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1></OFX>"
+	rows, err := ParseOFX(strings.NewReader(data), NewSymbolResolver([]es.SymbolMapping{{Identifier: "MSFT", Symbol: "MSFT"}}), "tester", "broker")
+	assert.Nil(t, err)
+	expected := testOFXPosition("MSFT", "2017-04-20", -2, 65.5, -130, "", "2001")
+	expected.Currency = "EUR"
+	assert.Equal(t, []RowResult{{Row: 1, Position: expected}}, rows)
+}
+
+func TestParseOFXNoDocument(t *testing.T) {
+	_, err := ParseOFX(strings.NewReader("date,symbol\n"), SymbolResolver{}, "tester", "broker")
+	assert.Equal(t, ErrNoOFX, err)
+}
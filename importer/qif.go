@@ -0,0 +1,214 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// ErrNoInvestmentQIF is returned when a QIF file does not contain an investment account
+var ErrNoInvestmentQIF = errors.New("no investment transactions in QIF")
+
+var qifDateLayouts = []string{"1/2/2006", "1/2'2006", "1/2'06", "1/2/06", "2006-01-02"}
+
+// qifRecord contains the fields of a QIF transaction by their code letter
+type qifRecord map[byte]string
+
+func (record qifRecord) number(code byte) (float64, error) {
+	value := record[code]
+	if value == "" {
+		return 0, nil
+	}
+	number, err := ParseNumber(value, ".", ",")
+	if err != nil {
+		return 0, fmt.Errorf("bad number: %s", value)
+	}
+	return number, nil
+}
+
+func parseQIFDate(value string) (time.Time, error) {
+	value = strings.Replace(strings.TrimSpace(value), " ", "", -1)
+	for _, layout := range qifDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad date: %s", value)
+}
+
+func qifSymbol(security string, resolver SymbolResolver) (string, error) {
+	if symbol, ok := resolver.Resolve(security); ok {
+		return symbol, nil
+	}
+	if security != "" && !strings.ContainsAny(security, " \t") {
+		return security, nil
+	}
+	return "", fmt.Errorf("unknown security: %s", security)
+}
+
+// qifTrade builds a buy or a sell, the amount includes the commission
+func qifTrade(record qifRecord, position *es.Position, sell bool) (*es.Position, error) {
+	quantity, err := record.number('Q')
+	if err != nil {
+		return nil, err
+	}
+	price, err := record.number('I')
+	if err != nil {
+		return nil, err
+	}
+	amount, err := record.number('T')
+	if err != nil {
+		return nil, err
+	}
+	commission, err := record.number('O')
+	if err != nil {
+		return nil, err
+	}
+	quantity = math.Abs(quantity)
+	if quantity != math.Trunc(quantity) {
+		return nil, fmt.Errorf("fractional quantity: %g", quantity)
+	}
+	if amount == 0 {
+		amount = quantity * price
+		if sell {
+			amount -= commission
+		} else {
+			amount += commission
+		}
+	}
+	position.Number = int(quantity)
+	position.Value = price
+	position.Cost = math.Abs(amount)
+	if sell {
+		position.Number = -position.Number
+		position.Cost = -position.Cost
+	}
+	return position, nil
+}
+
+func qifPositions(record qifRecord, resolver SymbolResolver, username string, broker string) ([]*es.Position, error) {
+	date, err := parseQIFDate(record['D'])
+	if err != nil {
+		return nil, err
+	}
+	position := &es.Position{Username: username, Broker: broker, Date: date}
+	action := strings.ToLower(record['N'])
+	if action != "xin" && action != "xout" {
+		if position.Symbol, err = qifSymbol(record['Y'], resolver); err != nil {
+			return nil, err
+		}
+	}
+	amount, err := record.number('T')
+	if err != nil {
+		return nil, err
+	}
+	amount = math.Abs(amount)
+	switch action {
+	case "buy", "buyx":
+		position, err = qifTrade(record, position, false)
+		return []*es.Position{position}, err
+	case "sell", "sellx":
+		position, err = qifTrade(record, position, true)
+		return []*es.Position{position}, err
+	case "div", "divx", "intinc", "intincx", "cglong", "cglongx", "cgmid", "cgmidx", "cgshort", "cgshortx":
+		position.Kind = es.KindDividend
+		position.Cost = -amount
+		return []*es.Position{position}, nil
+	case "miscexp", "miscexpx":
+		position.Kind = es.KindFee
+		position.Cost = amount
+		return []*es.Position{position}, nil
+	case "xin":
+		position.Kind = es.KindTransfer
+		position.Cost = amount
+		return []*es.Position{position}, nil
+	case "xout":
+		position.Kind = es.KindTransfer
+		position.Cost = -amount
+		return []*es.Position{position}, nil
+	case "reinvdiv", "reinvint", "reinvlg", "reinvmd", "reinvsh":
+		buy, err := qifTrade(record, position, false)
+		if err != nil {
+			return nil, err
+		}
+		income := *buy
+		income.Kind = es.KindDividend
+		income.Number = 0
+		income.Value = 0
+		income.Cost = -buy.Cost
+		return []*es.Position{&income, buy}, nil
+	}
+	return nil, fmt.Errorf("unsupported transaction: %s", record['N'])
+}
+
+// ParseQIF reads the transactions of the investment accounts of a QIF file
+//
+// 	ParseQIF(reader, resolver, username, broker)
+//
+// Securities are matched on their name through the resolver, a name without space is taken as the symbol.
+// returns one result per position, a reinvested dividend gives a dividend and a buy
+func ParseQIF(reader io.Reader, resolver SymbolResolver, username string, broker string) ([]RowResult, error) {
+	scanner := bufio.NewScanner(reader)
+	var rows []RowResult
+	investment, found := false, false
+	record := qifRecord{}
+	number := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			header := strings.ToLower(line)
+			if strings.HasPrefix(header, "!type:") {
+				investment = header == "!type:invst"
+				found = found || investment
+			}
+			continue
+		}
+		if !investment {
+			continue
+		}
+		if line[0] != '^' {
+			record[line[0]] = strings.TrimSpace(line[1:])
+			continue
+		}
+		number++
+		positions, err := qifPositions(record, resolver, username, broker)
+		record = qifRecord{}
+		if err != nil {
+			rows = append(rows, rowError(number, err))
+			continue
+		}
+		for _, position := range positions {
+			rows = append(rows, RowResult{Row: number, Position: position})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNoInvestmentQIF
+	}
+	return rows, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+const testQIF = "!Type:Bank\nD4/1'17\nT100.00\n^\n" +
+	"!Type:Invst\n" +
+	"D4/20'17\nNBuy\nYApple Inc\nI140.50\nQ10\nO5.00\nT1,410.00\n^\n" +
+	"D5/1/2017\nNSellX\nYCW8.PA\nI210\nQ4\nO3\n^\n" +
+	"D5/15'17\nNDiv\nYApple Inc\nT6.30\n^\n" +
+	"D5/16'17\nNMiscExp\nYApple Inc\nT2.50\n^\n" +
+	"D6/1'17\nNReinvDiv\nYApple Inc\nI150\nQ1\nT150\n^\n" +
+	"D1/2'17\nNXIn\nT5,000.00\n^\n" +
+	"D1/3'17\nNXOut\nT200\n^\n" +
+	"D7/1'17\nNBuy\nYUnknown Fund\nI1\nQ1\n^\n" +
+	"D7/2'17\nNShrsIn\nYCW8.PA\nQ1\n^\n"
+
+func testQIFPosition(symbol string, date string, number int, value float64, cost float64, kind string) *es.Position {
+	return &es.Position{Username: "tester", Broker: "broker", Symbol: symbol, Date: testDate(date),
+		Number: number, Value: value, Cost: cost, Kind: kind}
+}
+
+func TestParseQIF(t *testing.T) {
+	resolver := NewSymbolResolver([]es.SymbolMapping{{Identifier: "Apple Inc", Symbol: "AAPL"}})
+	rows, err := ParseQIF(strings.NewReader(testQIF), resolver, "tester", "broker")
+	assert.Nil(t, err)
+	assert.Equal(t, []RowResult{
+		{Row: 1, Position: testQIFPosition("AAPL", "2017-04-20", 10, 140.5, 1410, "")},
+		{Row: 2, Position: testQIFPosition("CW8.PA", "2017-05-01", -4, 210, -837, "")},
+		{Row: 3, Position: testQIFPosition("AAPL", "2017-05-15", 0, 0, -6.3, es.KindDividend)},
+		{Row: 4, Position: testQIFPosition("AAPL", "2017-05-16", 0, 0, 2.5, es.KindFee)},
+		{Row: 5, Position: testQIFPosition("AAPL", "2017-06-01", 0, 0, -150, es.KindDividend)},
+		{Row: 5, Position: testQIFPosition("AAPL", "2017-06-01", 1, 150, 150, "")},
+		{Row: 6, Position: testQIFPosition("", "2017-01-02", 0, 0, 5000, es.KindTransfer)},
+		{Row: 7, Position: testQIFPosition("", "2017-01-03", 0, 0, -200, es.KindTransfer)},
+		{Row: 8, Status: StatusError, Error: "unknown security: Unknown Fund"},
+		{Row: 9, Status: StatusError, Error: "unsupported transaction: ShrsIn"},
+	}, rows)
+}
+
+func TestParseQIFNoInvestment(t *testing.T) {
+	_, err := ParseQIF(strings.NewReader("!Type:Bank\nD4/1'17\nT100.00\n^\n"), SymbolResolver{}, "tester", "broker")
+	assert.Equal(t, ErrNoInvestmentQIF, err)
+}
@@ -0,0 +1,384 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/clebi/gofin/es"
+)
+
+// OutputValue is the output name of the indicators giving a single value
+const OutputValue = "value"
+
+// MaxPeriod is the largest number of bars of the periods of the indicators
+const MaxPeriod = 1000
+
+// Indicator is an indicator of the catalog, updated with one bar at a time
+type Indicator interface {
+	// Update adds a bar and returns the values of the outputs of the indicator, NaN during the warm-up
+	Update(bar es.StockBar) []float64
+}
+
+type indicatorFunc func(bar es.StockBar) []float64
+
+func (fn indicatorFunc) Update(bar es.StockBar) []float64 {
+	return fn(bar)
+}
+
+// Param describes a parameter of an indicator, it has no upper bound when Max is 0
+type Param struct {
+	Name    string  `json:"name"`
+	Default float64 `json:"default"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max,omitempty"`
+	Integer bool    `json:"integer"`
+}
+
+// ParamError is returned by ParseSpec for a parameter of an indicator with a bad value
+type ParamError struct {
+	Indicator string
+	Param     string
+	msg       string
+}
+
+func (err *ParamError) Error() string {
+	return err.msg
+}
+
+func paramError(indicator string, param string, format string, args ...interface{}) *ParamError {
+	return &ParamError{Indicator: indicator, Param: param, msg: fmt.Sprintf(format, args...)}
+}
+
+// Definition describes an indicator of the catalog
+type Definition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Params      []Param  `json:"params"`
+	Outputs     []string `json:"outputs"`
+	build       func(params []float64) (Indicator, error)
+	warmUp      func(params []float64) int
+}
+
+func closeIndicator(update func(value float64) float64) Indicator {
+	return indicatorFunc(func(bar es.StockBar) []float64 { return []float64{update(bar.Close)} })
+}
+
+func periodParam(params []float64) int {
+	return int(params[0])
+}
+
+var catalog = []Definition{
+	{
+		Name:        "sma",
+		Description: "Simple moving average of the close",
+		Params:      []Param{{Name: "period", Default: 20, Min: 1, Max: MaxPeriod, Integer: true}},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			sma, err := NewSMA(periodParam(params))
+			if err != nil {
+				return nil, err
+			}
+			return closeIndicator(sma.Update), nil
+		},
+		warmUp: periodParam,
+	},
+	{
+		Name:        "ema",
+		Description: "Exponential moving average of the close",
+		Params:      []Param{{Name: "period", Default: 20, Min: 1, Max: MaxPeriod, Integer: true}},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			ema, err := NewEMA(periodParam(params))
+			if err != nil {
+				return nil, err
+			}
+			return closeIndicator(ema.Update), nil
+		},
+		warmUp: periodParam,
+	},
+	{
+		Name:        "wma",
+		Description: "Weighted moving average of the close",
+		Params:      []Param{{Name: "period", Default: 20, Min: 1, Max: MaxPeriod, Integer: true}},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			wma, err := NewWMA(periodParam(params))
+			if err != nil {
+				return nil, err
+			}
+			return closeIndicator(wma.Update), nil
+		},
+		warmUp: periodParam,
+	},
+	{
+		Name:        "rsi",
+		Description: "Relative strength index of the close with the smoothing of Wilder",
+		Params:      []Param{{Name: "period", Default: 14, Min: 1, Max: MaxPeriod, Integer: true}},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			rsi, err := NewRSI(periodParam(params))
+			if err != nil {
+				return nil, err
+			}
+			return closeIndicator(rsi.Update), nil
+		},
+		warmUp: func(params []float64) int { return periodParam(params) + 1 },
+	},
+	{
+		Name:        "macd",
+		Description: "Moving average convergence divergence of the close",
+		Params: []Param{
+			{Name: "fast", Default: 12, Min: 1, Max: MaxPeriod, Integer: true},
+			{Name: "slow", Default: 26, Min: 1, Max: MaxPeriod, Integer: true},
+			{Name: "signal", Default: 9, Min: 1, Max: MaxPeriod, Integer: true},
+		},
+		Outputs: []string{"macd", "signal", "histogram"},
+		build: func(params []float64) (Indicator, error) {
+			macd, err := NewMACD(int(params[0]), int(params[1]), int(params[2]))
+			if err != nil {
+				return nil, err
+			}
+			return indicatorFunc(func(bar es.StockBar) []float64 {
+				line, signal, histogram := macd.Update(bar.Close)
+				return []float64{line, signal, histogram}
+			}), nil
+		},
+		warmUp: func(params []float64) int { return int(math.Max(params[0], params[1]) + params[2] - 1) },
+	},
+	{
+		Name:        "bb",
+		Description: "Bollinger bands of the close, width is a number of standard deviations",
+		Params: []Param{
+			{Name: "period", Default: 20, Min: 1, Max: MaxPeriod, Integer: true},
+			{Name: "width", Default: 2},
+		},
+		Outputs: []string{"middle", "upper", "lower"},
+		build: func(params []float64) (Indicator, error) {
+			bollinger, err := NewBollinger(periodParam(params), params[1])
+			if err != nil {
+				return nil, err
+			}
+			return indicatorFunc(func(bar es.StockBar) []float64 {
+				middle, upper, lower := bollinger.Update(bar.Close)
+				return []float64{middle, upper, lower}
+			}), nil
+		},
+		warmUp: periodParam,
+	},
+	{
+		Name:        "atr",
+		Description: "Average true range with the smoothing of Wilder",
+		Params:      []Param{{Name: "period", Default: 14, Min: 1, Max: MaxPeriod, Integer: true}},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			atr, err := NewATR(periodParam(params))
+			if err != nil {
+				return nil, err
+			}
+			return indicatorFunc(func(bar es.StockBar) []float64 { return []float64{atr.Update(bar)} }), nil
+		},
+		warmUp: periodParam,
+	},
+	{
+		Name:        "stoch",
+		Description: "Stochastic oscillator, %K over the k period and its average %D over the d period",
+		Params: []Param{
+			{Name: "k", Default: 14, Min: 1, Max: MaxPeriod, Integer: true},
+			{Name: "d", Default: 3, Min: 1, Max: MaxPeriod, Integer: true},
+		},
+		Outputs: []string{"k", "d"},
+		build: func(params []float64) (Indicator, error) {
+			stochastic, err := NewStochastic(int(params[0]), int(params[1]))
+			if err != nil {
+				return nil, err
+			}
+			return indicatorFunc(func(bar es.StockBar) []float64 {
+				k, d := stochastic.Update(bar)
+				return []float64{k, d}
+			}), nil
+		},
+		warmUp: func(params []float64) int { return int(params[0] + params[1] - 1) },
+	},
+	{
+		Name:        "obv",
+		Description: "On balance volume",
+		Params:      []Param{},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			obv := NewOBV()
+			return indicatorFunc(func(bar es.StockBar) []float64 { return []float64{obv.Update(bar)} }), nil
+		},
+		warmUp: func(params []float64) int { return 1 },
+	},
+	{
+		Name:        "vwap",
+		Description: "Volume weighted average of the typical price over the period, since the first bar when it is 0",
+		Params:      []Param{{Name: "period", Default: 0, Min: 0, Max: MaxPeriod, Integer: true}},
+		Outputs:     []string{OutputValue},
+		build: func(params []float64) (Indicator, error) {
+			vwap, err := NewVWAP(periodParam(params))
+			if err != nil {
+				return nil, err
+			}
+			return indicatorFunc(func(bar es.StockBar) []float64 { return []float64{vwap.Update(bar)} }), nil
+		},
+		warmUp: func(params []float64) int { return int(math.Max(params[0], 1)) },
+	},
+}
+
+// Catalog lists the indicators which can be requested with a spec
+func Catalog() []Definition {
+	return catalog
+}
+
+func lookup(name string) *Definition {
+	for i := range catalog {
+		if catalog[i].Name == name {
+			return &catalog[i]
+		}
+	}
+	return nil
+}
+
+// Spec is an indicator of the catalog with its parameters, written name:param:param as in rsi:14 or bb:20:2
+type Spec struct {
+	Key        string
+	Definition *Definition
+	Params     []float64
+}
+
+// New creates the indicator of the spec
+func (spec Spec) New() (Indicator, error) {
+	return spec.Definition.build(spec.Params)
+}
+
+// Outputs returns the names of the values given by the indicator
+func (spec Spec) Outputs() []string {
+	return spec.Definition.Outputs
+}
+
+// WarmUp returns the number of bars needed before the indicator gives its first values
+func (spec Spec) WarmUp() int {
+	return spec.Definition.warmUp(spec.Params)
+}
+
+// ParseSpec reads an indicator spec, missing parameters take their default value
+//
+// 	ParseSpec("bb:20:2")
+//
+// returns the spec keyed by its text or an error for an unknown indicator, a ParamError for a parameter out of
+// its bounds
+func ParseSpec(text string) (*Spec, error) {
+	text = strings.TrimSpace(text)
+	parts := strings.Split(text, ":")
+	name := strings.ToLower(parts[0])
+	definition := lookup(name)
+	if definition == nil {
+		return nil, fmt.Errorf("unknown indicator: %s", parts[0])
+	}
+	if len(parts)-1 > len(definition.Params) {
+		return nil, fmt.Errorf("too many parameters for %s: %s", name, text)
+	}
+	spec := &Spec{Key: text, Definition: definition, Params: make([]float64, len(definition.Params))}
+	for i, param := range definition.Params {
+		spec.Params[i] = param.Default
+		if i+1 >= len(parts) || parts[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(parts[i+1], 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, paramError(name, param.Name, "bad %s of %s: %s", param.Name, name, parts[i+1])
+		}
+		if param.Integer && value != math.Trunc(value) {
+			return nil, paramError(name, param.Name, "%s of %s must be an integer: %s", param.Name, name, parts[i+1])
+		}
+		if value < param.Min {
+			return nil, paramError(name, param.Name, "%s of %s must be at least %g: %s", param.Name, name, param.Min,
+				parts[i+1])
+		}
+		if param.Max != 0 && value > param.Max {
+			return nil, paramError(name, param.Name, "%s of %s must be at most %g: %s", param.Name, name, param.Max,
+				parts[i+1])
+		}
+		spec.Params[i] = value
+	}
+	return spec, nil
+}
+
+// ParseSpecs reads a comma separated list of indicator specs
+//
+// 	ParseSpecs("rsi:14,ema:20,bb:20:2")
+//
+// returns the specs in the order of the list
+func ParseSpecs(text string) ([]*Spec, error) {
+	var specs []*Spec
+	for _, part := range strings.Split(text, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		spec, err := ParseSpec(part)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// Series runs the indicator of a spec over bars
+//
+// 	Series(spec, bars)
+//
+// returns one series by output name aligned with the bars, with NaN during the warm-up
+func Series(spec *Spec, bars []es.StockBar) (map[string][]float64, error) {
+	indicator, err := spec.New()
+	if err != nil {
+		return nil, err
+	}
+	outputs := spec.Outputs()
+	series := make(map[string][]float64, len(outputs))
+	for _, output := range outputs {
+		series[output] = make([]float64, len(bars))
+	}
+	for i, bar := range bars {
+		for j, value := range indicator.Update(bar) {
+			series[outputs[j]][i] = value
+		}
+	}
+	return series, nil
+}
+
+// Last runs the indicator of a spec over bars and returns its last values by output name
+//
+// 	Last(spec, bars)
+//
+// returns NaN for the outputs which are still warming up
+func Last(spec *Spec, bars []es.StockBar) (map[string]float64, error) {
+	series, err := Series(spec, bars)
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[string]float64, len(series))
+	for output, values := range series {
+		last[output] = math.NaN()
+		if len(values) > 0 {
+			last[output] = values[len(values)-1]
+		}
+	}
+	return last, nil
+}
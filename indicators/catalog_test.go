@@ -0,0 +1,107 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpecs(t *testing.T) {
+	specs, err := ParseSpecs("rsi:14, EMA:20,bb:20:2.5,macd::30,obv,")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(specs))
+	assert.Equal(t, "rsi:14", specs[0].Key)
+	assert.Equal(t, []float64{14}, specs[0].Params)
+	assert.Equal(t, "ema", specs[1].Definition.Name)
+	assert.Equal(t, []float64{20, 2.5}, specs[2].Params)
+	assert.Equal(t, []string{"middle", "upper", "lower"}, specs[2].Outputs())
+	assert.Equal(t, []float64{12, 30, 9}, specs[3].Params)
+	assert.Equal(t, 38, specs[3].WarmUp())
+	assert.Equal(t, []float64{}, specs[4].Params)
+}
+
+var parseSpecErrorTests = []struct {
+	spec string
+	msg  string
+}{
+	{"foo:3", "unknown indicator: foo"},
+	{"rsi:14:2", "too many parameters for rsi: rsi:14:2"},
+	{"ema:abc", "bad period of ema: abc"},
+	{"sma:2.5", "period of sma must be an integer: 2.5"},
+	{"sma:0", "period of sma must be at least 1: 0"},
+	{"vwap:-1", "period of vwap must be at least 0: -1"},
+	{"sma:1e12", "period of sma must be at most 1000: 1e12"},
+	{"macd:12:1001", "slow of macd must be at most 1000: 1001"},
+	{"bb:20:NaN", "bad width of bb: NaN"},
+}
+
+func TestParseSpecErrors(t *testing.T) {
+	for _, tt := range parseSpecErrorTests {
+		_, err := ParseSpecs("rsi," + tt.spec)
+		assert.EqualError(t, err, tt.msg)
+	}
+}
+
+func TestParseSpecParamError(t *testing.T) {
+	_, err := ParseSpec("stoch:14:1e12")
+	assert.Equal(t, &ParamError{Indicator: "stoch", Param: "d", msg: "d of stoch must be at most 1000: 1e12"}, err)
+}
+
+func TestCatalog(t *testing.T) {
+	for _, definition := range Catalog() {
+		spec, err := ParseSpec(definition.Name)
+		assert.Nil(t, err)
+		indicator, err := spec.New()
+		assert.Nil(t, err)
+		values := indicator.Update(es.StockBar{High: 2, Low: 1, Close: 1.5, Volume: 10})
+		assert.Equal(t, len(definition.Outputs), len(values), definition.Name)
+	}
+}
+
+func TestLast(t *testing.T) {
+	bars := make([]es.StockBar, 5)
+	for i := range bars {
+		bars[i] = es.StockBar{High: float64(i + 2), Low: float64(i), Close: float64(i + 1), Volume: 1}
+	}
+	spec, _ := ParseSpec("sma:3")
+	last, err := Last(spec, bars)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]float64{OutputValue: 4}, last)
+	spec, _ = ParseSpec("stoch:5:2")
+	last, err = Last(spec, bars)
+	assert.Nil(t, err)
+	assert.InDelta(t, 5.0/6*100, last["k"], 1e-9)
+	assert.True(t, math.IsNaN(last["d"]))
+	last, err = Last(spec, nil)
+	assert.Nil(t, err)
+	assert.True(t, math.IsNaN(last["k"]))
+}
+
+func TestSeries(t *testing.T) {
+	bars := make([]es.StockBar, 4)
+	for i := range bars {
+		bars[i] = es.StockBar{Close: float64(i + 1)}
+	}
+	spec, _ := ParseSpec("macd:1:2:2")
+	series, err := Series(spec, bars)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(series))
+	assertSeries(t, warmUp(1, 0.5, 0.5, 0.5), series["macd"], 1e-9)
+	assertSeries(t, warmUp(2, 0.5, 0.5), series["signal"], 1e-9)
+	assertSeries(t, warmUp(2, 0, 0), series["histogram"], 1e-9)
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+
+	"github.com/clebi/gofin/es"
+)
+
+// RSI is the relative strength index with the smoothing of Wilder
+type RSI struct {
+	period  int
+	count   int
+	last    float64
+	avgGain float64
+	avgLoss float64
+}
+
+// NewRSI creates a relative strength index over a number of changes
+func NewRSI(period int) (*RSI, error) {
+	if err := checkPeriods(period); err != nil {
+		return nil, err
+	}
+	return &RSI{period: period}, nil
+}
+
+// Update adds a value and returns the index between 0 and 100, NaN until period changes have been seen
+func (rsi *RSI) Update(value float64) float64 {
+	rsi.count++
+	last := rsi.last
+	rsi.last = value
+	if rsi.count == 1 {
+		return math.NaN()
+	}
+	gain, loss := math.Max(value-last, 0), math.Max(last-value, 0)
+	period := float64(rsi.period)
+	if rsi.count <= rsi.period+1 {
+		rsi.avgGain += gain / period
+		rsi.avgLoss += loss / period
+		if rsi.count <= rsi.period {
+			return math.NaN()
+		}
+	} else {
+		rsi.avgGain = (rsi.avgGain*(period-1) + gain) / period
+		rsi.avgLoss = (rsi.avgLoss*(period-1) + loss) / period
+	}
+	if rsi.avgLoss == 0 {
+		if rsi.avgGain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+rsi.avgGain/rsi.avgLoss)
+}
+
+// MACD is the moving average convergence divergence with its signal line and histogram
+type MACD struct {
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+}
+
+// NewMACD creates a moving average convergence divergence, usually 12, 26 and 9
+func NewMACD(fast int, slow int, signal int) (*MACD, error) {
+	if err := checkPeriods(fast, slow, signal); err != nil {
+		return nil, err
+	}
+	macd := &MACD{}
+	macd.fast, _ = NewEMA(fast)
+	macd.slow, _ = NewEMA(slow)
+	macd.signal, _ = NewEMA(signal)
+	return macd, nil
+}
+
+// Update adds a value and returns the difference of the averages, its signal line and the histogram
+//
+// The signal line and the histogram are NaN until the signal period has been seen on the difference.
+func (macd *MACD) Update(value float64) (float64, float64, float64) {
+	line := macd.fast.Update(value) - macd.slow.Update(value)
+	if math.IsNaN(line) {
+		return line, math.NaN(), math.NaN()
+	}
+	signal := macd.signal.Update(line)
+	return line, signal, line - signal
+}
+
+// Stochastic is the stochastic oscillator, %K compares the close to the range of the period and %D is its average
+type Stochastic struct {
+	highs *window
+	lows  *window
+	d     *SMA
+}
+
+// NewStochastic creates a stochastic oscillator, usually 14 and 3
+func NewStochastic(kPeriod int, dPeriod int) (*Stochastic, error) {
+	if err := checkPeriods(kPeriod, dPeriod); err != nil {
+		return nil, err
+	}
+	d, _ := NewSMA(dPeriod)
+	return &Stochastic{highs: newWindow(kPeriod), lows: newWindow(kPeriod), d: d}, nil
+}
+
+// Update adds a bar and returns %K and %D between 0 and 100
+//
+// %K is 50 when the range of the period is flat.
+func (stochastic *Stochastic) Update(bar es.StockBar) (float64, float64) {
+	stochastic.highs.push(bar.High)
+	stochastic.lows.push(bar.Low)
+	if !stochastic.highs.full {
+		return math.NaN(), math.NaN()
+	}
+	high, low := math.Inf(-1), math.Inf(1)
+	stochastic.highs.each(func(_ int, value float64) { high = math.Max(high, value) })
+	stochastic.lows.each(func(_ int, value float64) { low = math.Min(low, value) })
+	k := 50.0
+	if high > low {
+		k = 100 * (bar.Close - low) / (high - low)
+	}
+	return k, stochastic.d.Update(k)
+}
+
+// RSISeries computes the relative strength index of a series
+func RSISeries(values []float64, period int) ([]float64, error) {
+	rsi, err := NewRSI(period)
+	if err != nil {
+		return nil, err
+	}
+	return runValues(rsi, values), nil
+}
+
+// MACDSeries computes the moving average convergence divergence of a series
+//
+// 	MACDSeries(values, 12, 26, 9)
+//
+// returns the difference of the averages, the signal line and the histogram
+func MACDSeries(values []float64, fast int, slow int, signal int) ([]float64, []float64, []float64, error) {
+	macd, err := NewMACD(fast, slow, signal)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lines, signals, histogram := make([]float64, len(values)), make([]float64, len(values)), make([]float64, len(values))
+	for i, value := range values {
+		lines[i], signals[i], histogram[i] = macd.Update(value)
+	}
+	return lines, signals, histogram, nil
+}
+
+// StochasticSeries computes the stochastic oscillator of bars
+//
+// 	StochasticSeries(bars, 14, 3)
+//
+// returns %K and %D
+func StochasticSeries(bars []es.StockBar, kPeriod int, dPeriod int) ([]float64, []float64, error) {
+	stochastic, err := NewStochastic(kPeriod, dPeriod)
+	if err != nil {
+		return nil, nil, err
+	}
+	k, d := make([]float64, len(bars)), make([]float64, len(bars))
+	for i, bar := range bars {
+		k[i], d[i] = stochastic.Update(bar)
+	}
+	return k, d, nil
+}
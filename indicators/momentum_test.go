@@ -0,0 +1,90 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+// rsiCloses is the 14 days RSI example of StockCharts, computed on closes rounded to the cent
+var rsiCloses = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61, 46.28,
+	46.28, 46.00, 46.03, 46.41, 46.22, 45.64, 46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18,
+	44.22, 44.57, 43.42, 42.66, 43.13,
+}
+
+func TestRSISeries(t *testing.T) {
+	rsi, err := RSISeries(rsiCloses, 14)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(14,
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+	), rsi, 0.1)
+}
+
+func TestRSIFlat(t *testing.T) {
+	rsi, _ := RSISeries([]float64{10, 10, 10, 11}, 2)
+	assertSeries(t, warmUp(2, 50, 100), rsi, 1e-9)
+}
+
+func TestMACDSeries(t *testing.T) {
+	lines, signals, histogram, err := MACDSeries(emaCloses, 3, 6, 4)
+	assert.Nil(t, err)
+	fast, _ := EMASeries(emaCloses, 3)
+	slow, _ := EMASeries(emaCloses, 6)
+	signal, _ := EMASeries(lines[5:], 4)
+	for i := range emaCloses {
+		if i < 5 {
+			assert.True(t, math.IsNaN(lines[i]))
+			assert.True(t, math.IsNaN(signals[i]))
+			continue
+		}
+		assert.InDelta(t, fast[i]-slow[i], lines[i], 1e-9)
+		if i < 8 {
+			assert.True(t, math.IsNaN(signals[i]))
+			assert.True(t, math.IsNaN(histogram[i]))
+			continue
+		}
+		assert.InDelta(t, signal[i-5], signals[i], 1e-9)
+		assert.InDelta(t, lines[i]-signals[i], histogram[i], 1e-9)
+	}
+	_, _, _, err = MACDSeries(emaCloses, 12, 0, 9)
+	assert.Equal(t, ErrBadPeriod, err)
+}
+
+func TestStochasticSeries(t *testing.T) {
+	bars := []es.StockBar{
+		{High: 10, Low: 8, Close: 9},
+		{High: 12, Low: 9, Close: 11},
+		{High: 11, Low: 7, Close: 8},
+		{High: 13, Low: 10, Close: 13},
+		{High: 13, Low: 13, Close: 13},
+	}
+	k, d, err := StochasticSeries(bars, 3, 2)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(2, 20, 100, 100), k, 1e-9)
+	assertSeries(t, warmUp(3, 60, 100), d, 1e-9)
+	_, _, err = StochasticSeries(bars, 0, 3)
+	assert.Equal(t, ErrBadPeriod, err)
+}
+
+func TestStochasticFlat(t *testing.T) {
+	stochastic, _ := NewStochastic(1, 1)
+	k, d := stochastic.Update(es.StockBar{High: 13, Low: 13, Close: 13})
+	assert.Equal(t, []float64{50, 50}, []float64{k, d})
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import "math"
+
+// SMA is the simple moving average of the last values
+type SMA struct {
+	period int
+	values *window
+	sum    float64
+}
+
+// NewSMA creates a simple moving average over a number of values
+func NewSMA(period int) (*SMA, error) {
+	if err := checkPeriods(period); err != nil {
+		return nil, err
+	}
+	return &SMA{period: period, values: newWindow(period)}, nil
+}
+
+// Update adds a value and returns the average, NaN until period values have been seen
+func (sma *SMA) Update(value float64) float64 {
+	old, evicted := sma.values.push(value)
+	sma.sum += value
+	if evicted {
+		sma.sum -= old
+	}
+	if !sma.values.full {
+		return math.NaN()
+	}
+	return sma.sum / float64(sma.period)
+}
+
+// EMA is the exponential moving average, seeded with the simple average of the first values
+type EMA struct {
+	period int
+	alpha  float64
+	count  int
+	value  float64
+}
+
+// NewEMA creates an exponential moving average with a smoothing of 2 / (period + 1)
+func NewEMA(period int) (*EMA, error) {
+	if err := checkPeriods(period); err != nil {
+		return nil, err
+	}
+	return &EMA{period: period, alpha: 2 / float64(period+1)}, nil
+}
+
+// Update adds a value and returns the average, NaN until period values have been seen
+func (ema *EMA) Update(value float64) float64 {
+	ema.count++
+	if ema.count <= ema.period {
+		ema.value += value / float64(ema.period)
+		if ema.count < ema.period {
+			return math.NaN()
+		}
+		return ema.value
+	}
+	ema.value += ema.alpha * (value - ema.value)
+	return ema.value
+}
+
+// WMA is the linearly weighted moving average, the last value has the highest weight
+type WMA struct {
+	period int
+	values *window
+}
+
+// NewWMA creates a weighted moving average over a number of values
+func NewWMA(period int) (*WMA, error) {
+	if err := checkPeriods(period); err != nil {
+		return nil, err
+	}
+	return &WMA{period: period, values: newWindow(period)}, nil
+}
+
+// Update adds a value and returns the average, NaN until period values have been seen
+func (wma *WMA) Update(value float64) float64 {
+	wma.values.push(value)
+	if !wma.values.full {
+		return math.NaN()
+	}
+	var sum float64
+	wma.values.each(func(i int, value float64) {
+		sum += float64(i+1) * value
+	})
+	return sum / float64(wma.period*(wma.period+1)/2)
+}
+
+// valueStream is an indicator updated with one value at a time
+type valueStream interface {
+	Update(value float64) float64
+}
+
+func runValues(stream valueStream, values []float64) []float64 {
+	series := make([]float64, len(values))
+	for i, value := range values {
+		series[i] = stream.Update(value)
+	}
+	return series
+}
+
+// SMASeries computes the simple moving average of a series
+func SMASeries(values []float64, period int) ([]float64, error) {
+	sma, err := NewSMA(period)
+	if err != nil {
+		return nil, err
+	}
+	return runValues(sma, values), nil
+}
+
+// EMASeries computes the exponential moving average of a series
+func EMASeries(values []float64, period int) ([]float64, error) {
+	ema, err := NewEMA(period)
+	if err != nil {
+		return nil, err
+	}
+	return runValues(ema, values), nil
+}
+
+// WMASeries computes the weighted moving average of a series
+func WMASeries(values []float64, period int) ([]float64, error) {
+	wma, err := NewWMA(period)
+	if err != nil {
+		return nil, err
+	}
+	return runValues(wma, values), nil
+}
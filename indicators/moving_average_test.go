@@ -0,0 +1,93 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// emaCloses is the 10 days EMA example of StockCharts
+var emaCloses = []float64{
+	22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+	22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+	23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17,
+}
+
+func assertSeries(t *testing.T, expected []float64, actual []float64, delta float64) {
+	assert.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		if math.IsNaN(expected[i]) {
+			assert.True(t, math.IsNaN(actual[i]), "value %d: %f is not NaN", i, actual[i])
+			continue
+		}
+		assert.InDelta(t, expected[i], actual[i], delta, "value %d", i)
+	}
+}
+
+func warmUp(size int, values ...float64) []float64 {
+	series := make([]float64, size, size+len(values))
+	for i := range series {
+		series[i] = math.NaN()
+	}
+	return append(series, values...)
+}
+
+func TestSMASeries(t *testing.T) {
+	sma, err := SMASeries([]float64{1, 2, 3, 4, 5, 6}, 3)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(2, 2, 3, 4, 5), sma, 1e-9)
+	sma, err = SMASeries(emaCloses, 10)
+	assert.Nil(t, err)
+	assert.InDelta(t, 22.22, sma[9], 0.005)
+}
+
+func TestEMASeries(t *testing.T) {
+	ema, err := EMASeries(emaCloses, 10)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(9,
+		22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28, 23.34,
+		23.43, 23.51, 23.53, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92,
+	), ema, 0.006)
+}
+
+func TestWMASeries(t *testing.T) {
+	wma, err := WMASeries([]float64{1, 2, 3, 4, 10}, 3)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(2, 14.0/6, 20.0/6, 41.0/6), wma, 1e-9)
+}
+
+func TestMovingAverageStream(t *testing.T) {
+	batch, _ := EMASeries(emaCloses, 10)
+	ema, _ := NewEMA(10)
+	for i, value := range emaCloses {
+		actual := ema.Update(value)
+		if i < 9 {
+			assert.True(t, math.IsNaN(actual))
+			continue
+		}
+		assert.Equal(t, batch[i], actual)
+	}
+}
+
+func TestMovingAverageBadPeriod(t *testing.T) {
+	_, err := NewSMA(0)
+	assert.Equal(t, ErrBadPeriod, err)
+	_, err = EMASeries(emaCloses, -1)
+	assert.Equal(t, ErrBadPeriod, err)
+	_, err = WMASeries(emaCloses, 0)
+	assert.Equal(t, ErrBadPeriod, err)
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Bollinger is the Bollinger bands, a moving average with bands at a number of standard deviations
+type Bollinger struct {
+	period int
+	width  float64
+	values *window
+}
+
+// NewBollinger creates Bollinger bands, usually 20 and 2
+func NewBollinger(period int, width float64) (*Bollinger, error) {
+	if err := checkPeriods(period); err != nil {
+		return nil, err
+	}
+	return &Bollinger{period: period, width: width, values: newWindow(period)}, nil
+}
+
+// Update adds a value and returns the middle, upper and lower bands, NaN until period values have been seen
+//
+// The standard deviation is the one of the population of the period.
+func (bollinger *Bollinger) Update(value float64) (float64, float64, float64) {
+	bollinger.values.push(value)
+	if !bollinger.values.full {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	var sum, squares float64
+	bollinger.values.each(func(_ int, value float64) { sum += value })
+	middle := sum / float64(bollinger.period)
+	bollinger.values.each(func(_ int, value float64) { squares += (value - middle) * (value - middle) })
+	deviation := math.Sqrt(squares/float64(bollinger.period)) * bollinger.width
+	return middle, middle + deviation, middle - deviation
+}
+
+// ATR is the average true range with the smoothing of Wilder
+type ATR struct {
+	period int
+	count  int
+	close  float64
+	value  float64
+}
+
+// NewATR creates an average true range over a number of bars
+func NewATR(period int) (*ATR, error) {
+	if err := checkPeriods(period); err != nil {
+		return nil, err
+	}
+	return &ATR{period: period}, nil
+}
+
+// Update adds a bar and returns the average, NaN until period bars have been seen
+//
+// The true range of the first bar is its high minus its low.
+func (atr *ATR) Update(bar es.StockBar) float64 {
+	trueRange := bar.High - bar.Low
+	if atr.count > 0 {
+		trueRange = math.Max(trueRange, math.Max(math.Abs(bar.High-atr.close), math.Abs(bar.Low-atr.close)))
+	}
+	atr.close = bar.Close
+	atr.count++
+	period := float64(atr.period)
+	if atr.count <= atr.period {
+		atr.value += trueRange / period
+		if atr.count < atr.period {
+			return math.NaN()
+		}
+		return atr.value
+	}
+	atr.value = (atr.value*(period-1) + trueRange) / period
+	return atr.value
+}
+
+// BollingerSeries computes the Bollinger bands of a series
+//
+// 	BollingerSeries(values, 20, 2)
+//
+// returns the middle, upper and lower bands
+func BollingerSeries(values []float64, period int, width float64) ([]float64, []float64, []float64, error) {
+	bollinger, err := NewBollinger(period, width)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	middle, upper, lower := make([]float64, len(values)), make([]float64, len(values)), make([]float64, len(values))
+	for i, value := range values {
+		middle[i], upper[i], lower[i] = bollinger.Update(value)
+	}
+	return middle, upper, lower, nil
+}
+
+// ATRSeries computes the average true range of bars
+func ATRSeries(bars []es.StockBar, period int) ([]float64, error) {
+	atr, err := NewATR(period)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]float64, len(bars))
+	for i, bar := range bars {
+		series[i] = atr.Update(bar)
+	}
+	return series, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBollingerSeries(t *testing.T) {
+	middle, upper, lower, err := BollingerSeries([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(7, 5), middle, 1e-9)
+	assertSeries(t, warmUp(7, 9), upper, 1e-9)
+	assertSeries(t, warmUp(7, 1), lower, 1e-9)
+	_, _, _, err = BollingerSeries([]float64{1}, 0, 2)
+	assert.Equal(t, ErrBadPeriod, err)
+}
+
+func TestBollingerStream(t *testing.T) {
+	bollinger, _ := NewBollinger(2, 1)
+	middle, _, _ := bollinger.Update(1)
+	assert.True(t, math.IsNaN(middle))
+	middle, upper, lower := bollinger.Update(3)
+	assert.Equal(t, []float64{2, 3, 1}, []float64{middle, upper, lower})
+	middle, upper, lower = bollinger.Update(3)
+	assert.Equal(t, []float64{3, 3, 3}, []float64{middle, upper, lower})
+}
+
+func TestATRSeries(t *testing.T) {
+	bars := []es.StockBar{
+		{High: 10, Low: 8, Close: 9},
+		{High: 12, Low: 10, Close: 11},
+		{High: 11, Low: 10.5, Close: 10.8},
+		{High: 10, Low: 9, Close: 9.5},
+	}
+	atr, err := ATRSeries(bars, 2)
+	assert.Nil(t, err)
+	// true ranges are 2, 3, 0.5 and 1.8
+	assertSeries(t, warmUp(1, 2.5, 1.5, 1.65), atr, 1e-9)
+	_, err = ATRSeries(bars, 0)
+	assert.Equal(t, ErrBadPeriod, err)
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"math"
+
+	"github.com/clebi/gofin/es"
+)
+
+// OBV is the on balance volume, the volume is added on up days and subtracted on down days
+type OBV struct {
+	started bool
+	close   float64
+	value   float64
+}
+
+// NewOBV creates an on balance volume starting at zero
+func NewOBV() *OBV {
+	return &OBV{}
+}
+
+// Update adds a bar and returns the cumulated volume
+func (obv *OBV) Update(bar es.StockBar) float64 {
+	if obv.started {
+		if bar.Close > obv.close {
+			obv.value += bar.Volume
+		} else if bar.Close < obv.close {
+			obv.value -= bar.Volume
+		}
+	}
+	obv.started = true
+	obv.close = bar.Close
+	return obv.value
+}
+
+// VWAP is the volume weighted average of the typical price, (high + low + close) / 3
+type VWAP struct {
+	prices  *window
+	volumes *window
+	price   float64
+	volume  float64
+}
+
+// NewVWAP creates a volume weighted average price over a number of bars, or since the first bar when period is 0
+func NewVWAP(period int) (*VWAP, error) {
+	if period < 0 {
+		return nil, ErrBadPeriod
+	}
+	vwap := &VWAP{}
+	if period > 0 {
+		vwap.prices, vwap.volumes = newWindow(period), newWindow(period)
+	}
+	return vwap, nil
+}
+
+// Update adds a bar and returns the average price, NaN until period bars have been seen or while there is no volume
+func (vwap *VWAP) Update(bar es.StockBar) float64 {
+	price := (bar.High + bar.Low + bar.Close) / 3 * bar.Volume
+	vwap.price += price
+	vwap.volume += bar.Volume
+	if vwap.prices != nil {
+		if old, evicted := vwap.prices.push(price); evicted {
+			vwap.price -= old
+		}
+		if old, evicted := vwap.volumes.push(bar.Volume); evicted {
+			vwap.volume -= old
+		}
+		if !vwap.prices.full {
+			return math.NaN()
+		}
+	}
+	if vwap.volume == 0 {
+		return math.NaN()
+	}
+	return vwap.price / vwap.volume
+}
+
+// OBVSeries computes the on balance volume of bars
+func OBVSeries(bars []es.StockBar) []float64 {
+	obv := NewOBV()
+	series := make([]float64, len(bars))
+	for i, bar := range bars {
+		series[i] = obv.Update(bar)
+	}
+	return series
+}
+
+// VWAPSeries computes the volume weighted average price of bars, since the first bar when period is 0
+func VWAPSeries(bars []es.StockBar, period int) ([]float64, error) {
+	vwap, err := NewVWAP(period)
+	if err != nil {
+		return nil, err
+	}
+	series := make([]float64, len(bars))
+	for i, bar := range bars {
+		series[i] = vwap.Update(bar)
+	}
+	return series, nil
+}
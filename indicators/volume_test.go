@@ -0,0 +1,49 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package indicators
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+var volumeBars = []es.StockBar{
+	{High: 11, Low: 9, Close: 10, Volume: 100},
+	{High: 12, Low: 10, Close: 11, Volume: 200},
+	{High: 12, Low: 10, Close: 11, Volume: 50},
+	{High: 10, Low: 8, Close: 9, Volume: 300},
+	{High: 9, Low: 9, Close: 9, Volume: 0},
+}
+
+func TestOBVSeries(t *testing.T) {
+	assert.Equal(t, []float64{0, 200, 200, -100, -100}, OBVSeries(volumeBars))
+}
+
+func TestVWAPSeries(t *testing.T) {
+	vwap, err := VWAPSeries(volumeBars, 0)
+	assert.Nil(t, err)
+	assertSeries(t, []float64{10, 3200.0 / 300, 3750.0 / 350, 6450.0 / 650, 6450.0 / 650}, vwap, 1e-9)
+	vwap, err = VWAPSeries(volumeBars, 2)
+	assert.Nil(t, err)
+	assertSeries(t, warmUp(1, 3200.0/300, 11, 3250.0/350, 9), vwap, 1e-9)
+	_, err = VWAPSeries(volumeBars, -1)
+	assert.Equal(t, ErrBadPeriod, err)
+}
+
+func TestVWAPNoVolume(t *testing.T) {
+	vwap, _ := NewVWAP(1)
+	assertSeries(t, warmUp(1), []float64{vwap.Update(volumeBars[4])}, 0)
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package indicators computes technical indicators on daily bars, value by value or on whole series
+//
+// Every indicator is a stream updated with one value or bar at a time which returns NaN until it has
+// seen enough values. The series functions run a stream over a slice and return a slice of the same
+// length, with NaN during the warm-up.
+package indicators
+
+import "errors"
+
+// ErrBadPeriod is returned when an indicator is created with a period lower than one
+var ErrBadPeriod = errors.New("period must be positive")
+
+// window keeps the last values of a stream
+type window struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func newWindow(size int) *window {
+	return &window{values: make([]float64, size)}
+}
+
+// push adds a value and returns the one which left the window, if the window was full
+func (w *window) push(value float64) (float64, bool) {
+	old, evicted := w.values[w.next], w.full
+	w.values[w.next] = value
+	w.next++
+	if w.next == len(w.values) {
+		w.next = 0
+		w.full = true
+	}
+	return old, evicted
+}
+
+// each calls a function on the values of the window from the oldest to the newest
+func (w *window) each(fn func(i int, value float64)) {
+	if !w.full {
+		for i := 0; i < w.next; i++ {
+			fn(i, w.values[i])
+		}
+		return
+	}
+	for i := 0; i < len(w.values); i++ {
+		fn(i, w.values[(w.next+i)%len(w.values)])
+	}
+}
+
+func checkPeriods(periods ...int) error {
+	for _, period := range periods {
+		if period < 1 {
+			return ErrBadPeriod
+		}
+	}
+	return nil
+}
@@ -15,10 +15,18 @@
 package main
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/clebi/gofin/alerts"
 	"github.com/clebi/gofin/es"
 	"github.com/clebi/gofin/handlers"
 	"github.com/clebi/yfinance"
@@ -32,8 +40,68 @@ import (
 
 const (
 	defaultServerURL = ":9000"
+	// shutdownTimeout is the time given to the requests in progress to finish when the server stops
+	shutdownTimeout = 30 * time.Second
 )
 
+// smtpServer reads the server sending the email alerts from the environment, emails are disabled
+// when GOFIN_SMTP_ADDR is not set
+func smtpServer() *alerts.SMTPServer {
+	addr := os.Getenv("GOFIN_SMTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+	server := &alerts.SMTPServer{Addr: addr, From: os.Getenv("GOFIN_SMTP_FROM")}
+	if username := os.Getenv("GOFIN_SMTP_USERNAME"); username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		server.Auth = smtp.PlainAuth("", username, os.Getenv("GOFIN_SMTP_PASSWORD"), host)
+	}
+	return server
+}
+
+// fanOut reads from the environment the limits of the requests processing several symbols concurrently,
+// GOFIN_FANOUT_WORKERS is the number of symbols processed at once and GOFIN_FANOUT_TIMEOUT the deadline
+// of a request, such as 20s
+func fanOut() handlers.FanOut {
+	var fanOut handlers.FanOut
+	if workers := os.Getenv("GOFIN_FANOUT_WORKERS"); workers != "" {
+		value, err := strconv.Atoi(workers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fanOut.Workers = value
+	}
+	if timeout := os.Getenv("GOFIN_FANOUT_TIMEOUT"); timeout != "" {
+		value, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fanOut.Timeout = value
+	}
+	return fanOut
+}
+
+// serve runs the server until it fails or an interrupt or a termination signal shuts it down, it returns once
+// the requests in progress are finished
+func serve(server *http.Server) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Error(err)
+		}
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-shutdown
+}
+
 func main() {
 	// Initialize logger
 	log.SetOutput(os.Stdout)
@@ -47,27 +115,90 @@ func main() {
 
 	sh := schema.NewDecoder()
 	sh.IgnoreUnknownKeys(true)
+	validate := validator.New()
+	validate.RegisterTagNameFunc(handlers.ParamName)
 	// Initialize app context
 	context := handlers.NewContext(
 		esClient,
 		sh,
-		validator.New(),
+		validate,
 		finance.NewHistory(),
 		finance.NewQuotes(),
 		es.NewStock(esClient),
 		es.NewPosition(esClient),
+		es.NewAllocation(esClient),
+		es.NewImportProfile(esClient),
+		es.NewSymbolMapping(esClient),
+		es.NewFx(esClient),
+		es.NewUniverse(esClient),
+		es.NewSignal(esClient),
+		es.NewAlert(esClient),
+		alerts.NewDispatcher(smtpServer()),
+		es.NewOptimization(esClient),
+		fanOut(),
 	)
 
 	stockHandlers := handlers.NewStockHandlers(context)
 	positionHandlers := handlers.NewPositionHandlers(context)
 	indicatorsHandlers := handlers.NewIndicatorHandlers(context)
+	performanceHandlers := handlers.NewPerformanceHandlers(context)
+	allocationHandlers := handlers.NewAllocationHandlers(context)
+	importHandlers := handlers.NewImportHandlers(context)
+	reportHandlers := handlers.NewReportHandlers(context)
+	fxHandlers := handlers.NewFxHandlers(context)
+	analyticsHandlers := handlers.NewAnalyticsHandlers(context)
+	screenerHandlers := handlers.NewScreenerHandlers(context)
+	signalHandlers := handlers.NewSignalHandlers(context)
+	alertHandlers := handlers.NewAlertHandlers(context)
+	backtestHandlers := handlers.NewBacktestHandlers(context)
 	router := echo.New()
 	router.GET("/history/:symbol", stockHandlers.History)
 	router.GET("/history/list", stockHandlers.HistoryList)
+	router.GET("/history/:symbol/candles", stockHandlers.Candles)
 	router.POST("/position", positionHandlers.AddPosition)
 	router.GET("/position", positionHandlers.GetPositions)
 	router.GET("/indicators", indicatorsHandlers.GetStocks)
+	router.GET("/indicators/catalog", indicatorsHandlers.GetCatalog)
+	router.GET("/indicators/:symbol/series", indicatorsHandlers.GetSeries)
+	router.GET("/performance", performanceHandlers.GetPerformance)
+	router.PUT("/allocation", allocationHandlers.SetAllocation)
+	router.GET("/allocation", allocationHandlers.GetAllocation)
+	router.GET("/allocation/rebalance", allocationHandlers.Rebalance)
+	router.PUT("/import/profiles", importHandlers.SetProfile)
+	router.GET("/import/profiles", importHandlers.GetProfiles)
+	router.POST("/import/csv", importHandlers.ImportCSV)
+	router.PUT("/import/symbols", importHandlers.SetSymbolMapping)
+	router.GET("/import/symbols", importHandlers.GetSymbolMappings)
+	router.POST("/import/ofx", importHandlers.ImportOFX)
+	router.POST("/import/qif", importHandlers.ImportQIF)
+	router.GET("/report/gains", reportHandlers.GetGains)
+	router.POST("/fx/rates", fxHandlers.ImportRates)
+	router.PUT("/fx/symbols", fxHandlers.SetSymbolCurrency)
+	router.GET("/analytics/correlation", analyticsHandlers.GetCorrelation)
+	router.GET("/analytics/simulation", analyticsHandlers.Simulate)
+	router.GET("/analytics/frontier", analyticsHandlers.GetFrontier)
+	router.GET("/analytics/:symbol", analyticsHandlers.GetAnalytics)
+	router.GET("/screener", screenerHandlers.Screen)
+	router.PUT("/screener/universes", screenerHandlers.SetUniverse)
+	router.GET("/screener/universes", screenerHandlers.GetUniverses)
+	router.POST("/signals/scan", signalHandlers.ScanSignals)
+	router.GET("/signals/:symbol", signalHandlers.GetSignals)
+	router.PUT("/alerts", alertHandlers.SetAlert)
+	router.GET("/alerts", alertHandlers.GetAlerts)
+	router.GET("/alerts/history", alertHandlers.GetAlertHistory)
+	router.POST("/alerts/evaluate", alertHandlers.EvaluateAlerts)
+	router.GET("/alerts/:name", alertHandlers.GetAlert)
+	router.DELETE("/alerts/:name", alertHandlers.DeleteAlert)
+	router.PUT("/alerts/:name/mute", alertHandlers.MuteAlert)
+	router.DELETE("/alerts/:name/mute", alertHandlers.UnmuteAlert)
+	router.POST("/backtest", backtestHandlers.Backtest)
+	router.POST("/backtest/optimize", backtestHandlers.Optimize)
+	router.GET("/backtest/optimizations", backtestHandlers.GetOptimizations)
+	router.GET("/backtest/optimizations/:name", backtestHandlers.GetOptimization)
+	router.DELETE("/backtest/optimizations/:name", backtestHandlers.DeleteOptimization)
 	handler := cors.Default().Handler(router)
 	log.WithFields(log.Fields{"url": defaultServerURL}).Info("Start server")
-	log.Fatal(http.ListenAndServe(defaultServerURL, handler))
+	serve(&http.Server{Addr: defaultServerURL, Handler: handler})
+	context.Close()
+	log.Info("Server stopped")
 }
@@ -0,0 +1,132 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package ohlc resamples daily bars into candlesticks of longer periods
+//
+// A candle opens at the open of its first bar, closes at the close of its last one, its high and low are
+// the extremes of its bars and its volume their sum. It is dated with its first bar.
+package ohlc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Calendar periods of the candles
+const (
+	PeriodDay     = "day"
+	PeriodWeek    = "week"
+	PeriodMonth   = "month"
+	PeriodQuarter = "quarter"
+	PeriodYear    = "year"
+)
+
+// ErrBadSize is returned when the number of trading days of a candle is not positive
+var ErrBadSize = errors.New("candle size must be positive")
+
+// PeriodStart returns the first day of the calendar period containing a date, weeks start on Monday
+//
+// 	PeriodStart(date, PeriodMonth)
+func PeriodStart(date time.Time, period string) (time.Time, error) {
+	year, month, day := date.Date()
+	switch period {
+	case PeriodDay:
+	case PeriodWeek:
+		day -= (int(date.Weekday()) + 6) % 7
+	case PeriodMonth:
+		day = 1
+	case PeriodQuarter:
+		month, day = month-(month-1)%3, 1
+	case PeriodYear:
+		month, day = time.January, 1
+	default:
+		return time.Time{}, fmt.Errorf("unknown period: %s", period)
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, date.Location()), nil
+}
+
+// Resample groups bars sorted by date into candles of a calendar period
+//
+// 	Resample(bars, PeriodWeek)
+//
+// returns the candles sorted by date, the first and the last ones only cover the bars of their period
+func Resample(bars []es.StockBar, period string) ([]es.StockBar, error) {
+	if _, err := PeriodStart(time.Time{}, period); err != nil {
+		return nil, err
+	}
+	candles := []es.StockBar{}
+	var current time.Time
+	first := 0
+	for i, bar := range bars {
+		start, _ := PeriodStart(bar.Date, period)
+		if i > 0 && !start.Equal(current) {
+			candles = append(candles, Candle(bars[first:i]))
+			first = i
+		}
+		current = start
+	}
+	if len(bars) > 0 {
+		candles = append(candles, Candle(bars[first:]))
+	}
+	return candles, nil
+}
+
+// ResampleBars groups bars sorted by date into candles of a number of trading days
+//
+// 	ResampleBars(bars, 5)
+//
+// The candles are counted from the last bar, so that only the first one may have fewer bars.
+// returns the candles sorted by date
+func ResampleBars(bars []es.StockBar, size int) ([]es.StockBar, error) {
+	if size <= 0 {
+		return nil, ErrBadSize
+	}
+	candles := []es.StockBar{}
+	first := len(bars) % size
+	if first > 0 {
+		candles = append(candles, Candle(bars[:first]))
+	}
+	for ; first < len(bars); first += size {
+		candles = append(candles, Candle(bars[first:first+size]))
+	}
+	return candles, nil
+}
+
+// Candle aggregates bars sorted by date into one
+//
+// A bar without open opens at its close, and its high and low include its open and its close.
+func Candle(bars []es.StockBar) es.StockBar {
+	candle := es.StockBar{Symbol: bars[0].Symbol, Date: bars[0].Date, Low: math.Inf(1)}
+	for i, bar := range bars {
+		open := bar.Open
+		if open <= 0 {
+			open = bar.Close
+		}
+		low := bar.Low
+		if low <= 0 {
+			low = math.Inf(1)
+		}
+		if i == 0 {
+			candle.Open = open
+		}
+		candle.High = math.Max(candle.High, math.Max(bar.High, math.Max(open, bar.Close)))
+		candle.Low = math.Min(candle.Low, math.Min(low, math.Min(open, bar.Close)))
+		candle.Close = bar.Close
+		candle.Volume += bar.Volume
+	}
+	return candle
+}
@@ -0,0 +1,120 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ohlc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBar(date string, open float64, high float64, low float64, close float64, volume float64) es.StockBar {
+	day, _ := time.Parse("2006-01-02", date)
+	return es.StockBar{Symbol: "TEST", Date: day, Open: open, High: high, Low: low, Close: close, Volume: volume}
+}
+
+func testDay(date string) time.Time {
+	day, _ := time.Parse("2006-01-02", date)
+	return day
+}
+
+// testBars are the trading days from Thursday 2016-12-29 to Tuesday 2017-01-10
+var testBars = []es.StockBar{
+	testBar("2016-12-29", 10, 12, 9, 11, 100),
+	testBar("2016-12-30", 11, 13, 10, 12, 200),
+	testBar("2017-01-02", 12, 12.5, 8, 9, 300),
+	testBar("2017-01-03", 9, 10, 8.5, 9.5, 100),
+	testBar("2017-01-04", 9.5, 14, 9, 13, 100),
+	testBar("2017-01-05", 13, 13, 12, 12.5, 100),
+	testBar("2017-01-06", 12.5, 13, 12, 12, 100),
+	testBar("2017-01-09", 12, 15, 11, 14, 50),
+	testBar("2017-01-10", 14, 14.5, 13, 13.5, 50),
+}
+
+func TestPeriodStart(t *testing.T) {
+	var periodTests = []struct {
+		period   string
+		date     string
+		expected string
+	}{
+		{PeriodDay, "2017-01-05", "2017-01-05"},
+		{PeriodWeek, "2017-01-05", "2017-01-02"},
+		{PeriodWeek, "2017-01-08", "2017-01-02"},
+		{PeriodWeek, "2017-01-02", "2017-01-02"},
+		{PeriodWeek, "2017-01-01", "2016-12-26"},
+		{PeriodMonth, "2017-02-28", "2017-02-01"},
+		{PeriodQuarter, "2017-06-30", "2017-04-01"},
+		{PeriodQuarter, "2017-12-31", "2017-10-01"},
+		{PeriodYear, "2017-06-30", "2017-01-01"},
+	}
+	for _, tt := range periodTests {
+		start, err := PeriodStart(testDay(tt.date), tt.period)
+		assert.Nil(t, err)
+		assert.Equal(t, testDay(tt.expected), start, tt.period+" "+tt.date)
+	}
+	_, err := PeriodStart(testDay("2017-01-01"), "decade")
+	assert.EqualError(t, err, "unknown period: decade")
+}
+
+func TestResample(t *testing.T) {
+	candles, err := Resample(testBars, PeriodWeek)
+	assert.Nil(t, err)
+	assert.Equal(t, []es.StockBar{
+		testBar("2016-12-29", 10, 13, 9, 12, 300),
+		testBar("2017-01-02", 12, 14, 8, 12, 700),
+		testBar("2017-01-09", 12, 15, 11, 13.5, 100),
+	}, candles)
+	candles, err = Resample(testBars, PeriodMonth)
+	assert.Nil(t, err)
+	assert.Equal(t, []es.StockBar{
+		testBar("2016-12-29", 10, 13, 9, 12, 300),
+		testBar("2017-01-02", 12, 15, 8, 13.5, 800),
+	}, candles)
+	candles, err = Resample(testBars, PeriodQuarter)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(candles))
+	candles, err = Resample(testBars, PeriodDay)
+	assert.Nil(t, err)
+	assert.Equal(t, testBars, candles)
+	candles, err = Resample(nil, PeriodYear)
+	assert.Nil(t, err)
+	assert.Equal(t, []es.StockBar{}, candles)
+	_, err = Resample(testBars, "decade")
+	assert.EqualError(t, err, "unknown period: decade")
+}
+
+func TestResampleBars(t *testing.T) {
+	candles, err := ResampleBars(testBars, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []es.StockBar{
+		testBar("2016-12-29", 10, 12, 9, 11, 100),
+		testBar("2016-12-30", 11, 14, 8, 13, 700),
+		testBar("2017-01-05", 13, 15, 11, 13.5, 300),
+	}, candles)
+	candles, err = ResampleBars(testBars[:4], 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(candles))
+	_, err = ResampleBars(testBars, 0)
+	assert.Equal(t, ErrBadSize, err)
+}
+
+func TestCandleWithoutOpen(t *testing.T) {
+	candle := Candle([]es.StockBar{
+		{Date: testDay("2017-01-02"), Close: 10, Volume: 1},
+		{Date: testDay("2017-01-03"), High: 12, Low: 9.5, Close: 11, Volume: 2},
+	})
+	assert.Equal(t, es.StockBar{Date: testDay("2017-01-02"), Open: 10, High: 12, Low: 9.5, Close: 11, Volume: 3}, candle)
+}
@@ -0,0 +1,76 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Converter gives the value of one unit of a currency in the base currency on a day
+type Converter interface {
+	Rate(currency string, date time.Time) (float64, error)
+}
+
+// Exchange converts the prices of the symbols and the amounts of the trades into a base currency
+//
+// A nil exchange leaves all amounts in their own currency.
+type Exchange struct {
+	Currencies map[string]string
+	Converter  Converter
+}
+
+// SymbolRate returns the rate to convert a price of a symbol on a day
+func (exchange *Exchange) SymbolRate(symbol string, date time.Time) (float64, error) {
+	if exchange == nil {
+		return 1, nil
+	}
+	currency, ok := exchange.Currencies[symbol]
+	if !ok {
+		return 0, fmt.Errorf("unknown currency of %s", symbol)
+	}
+	return exchange.Converter.Rate(currency, date)
+}
+
+// TradeRate returns the rate to convert the amounts of a trade on a given day
+func (exchange *Exchange) TradeRate(trade es.Position, date time.Time) (float64, error) {
+	if exchange == nil {
+		return 1, nil
+	}
+	if trade.Currency != "" {
+		return exchange.Converter.Rate(trade.Currency, date)
+	}
+	return exchange.SymbolRate(trade.Symbol, date)
+}
+
+// ConvertTrades converts the value and the cost of trades into the base currency at the rate of their day
+func ConvertTrades(trades []es.Position, exchange *Exchange, base string) ([]es.Position, error) {
+	if exchange == nil {
+		return trades, nil
+	}
+	converted := make([]es.Position, len(trades))
+	for i, trade := range trades {
+		rate, err := exchange.TradeRate(trade, trade.Date)
+		if err != nil {
+			return nil, err
+		}
+		trade.Value *= rate
+		trade.Cost *= rate
+		trade.Currency = base
+		converted[i] = trade
+	}
+	return converted, nil
+}
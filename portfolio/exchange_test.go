@@ -0,0 +1,50 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/fx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertTrades(t *testing.T) {
+	trades := []es.Position{
+		{Symbol: "CW8.PA", Date: testDate("2017-01-02"), Number: 1, Value: 200, Cost: 205},
+		{Symbol: "AAPL", Date: testDate("2017-01-02"), Number: 2, Value: 100, Cost: 200, Currency: "GBP"},
+		{Symbol: "MSFT", Date: testDate("2017-01-02"), Number: 1, Value: 60, Cost: 60},
+	}
+	exchange := &Exchange{
+		Currencies: map[string]string{"CW8.PA": "EUR", "AAPL": "USD", "MSFT": "USD"},
+		Converter: fx.NewConverter("USD", []es.FxRate{
+			{From: "EUR", To: "USD", Date: testDate("2017-01-01"), Rate: 1.05},
+			{From: "USD", To: "GBP", Date: testDate("2017-01-01"), Rate: 0.8},
+		}),
+	}
+	converted, err := ConvertTrades(trades, exchange, "USD")
+	assert.Nil(t, err)
+	assert.InDelta(t, 210, converted[0].Value, 1e-9)
+	assert.InDelta(t, 215.25, converted[0].Cost, 1e-9)
+	assert.InDelta(t, 250, converted[1].Cost, 1e-9)
+	assert.Equal(t, 60.0, converted[2].Cost)
+	assert.Equal(t, "USD", converted[0].Currency)
+	assert.Equal(t, 205.0, trades[0].Cost)
+	_, err = ConvertTrades(trades, &Exchange{Currencies: exchange.Currencies, Converter: fx.NewConverter("CHF", nil)}, "CHF")
+	assert.EqualError(t, err, "no EUR/CHF rate on 2017-01-02")
+	same, err := ConvertTrades(trades, nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, trades, same)
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+// Holding terms of a closed lot, a lot is long term when held more than one year
+const (
+	TermShort = "short"
+	TermLong  = "long"
+)
+
+// ClosedLot is a part of a buy which has been sold
+type ClosedLot struct {
+	Broker    string    `json:"broker"`
+	Symbol    string    `json:"symbol"`
+	Acquired  time.Time `json:"acquired"`
+	Disposed  time.Time `json:"disposed"`
+	Number    int       `json:"number"`
+	CostBasis float64   `json:"cost_basis"`
+	Proceeds  float64   `json:"proceeds"`
+	Gain      float64   `json:"gain"`
+	Term      string    `json:"term"`
+}
+
+// GainsSummary contains the totals of a set of closed lots
+type GainsSummary struct {
+	CostBasis float64 `json:"cost_basis"`
+	Proceeds  float64 `json:"proceeds"`
+	Gain      float64 `json:"gain"`
+}
+
+func (summary *GainsSummary) add(lot ClosedLot) {
+	summary.CostBasis += lot.CostBasis
+	summary.Proceeds += lot.Proceeds
+	summary.Gain += lot.Gain
+}
+
+// GainsReport contains the lots closed during a tax year
+type GainsReport struct {
+	Year      int          `json:"year"`
+	Currency  string       `json:"currency,omitempty"`
+	Lots      []ClosedLot  `json:"lots"`
+	ShortTerm GainsSummary `json:"short_term"`
+	LongTerm  GainsSummary `json:"long_term"`
+	Total     GainsSummary `json:"total"`
+}
+
+// openLot is what is left of a buy, its cost is reduced as shares are sold
+type openLot struct {
+	date   time.Time
+	number int
+	cost   float64
+}
+
+// ClosedLots matches the sells with the buys of the same symbol at the same broker, first in first out
+//
+// 	ClosedLots(trades)
+//
+// The cost basis includes the buy fees and the proceeds are net of the sell fees, both are shared
+// among the shares of a trade. Dividends, fees and cash transfers are left out.
+// returns the closed lots sorted by disposal date
+func ClosedLots(trades []es.Position) ([]ClosedLot, error) {
+	sorted := make([]es.Position, 0, len(trades))
+	for _, trade := range trades {
+		if trade.Kind == "" && trade.Number != 0 {
+			sorted = append(sorted, trade)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	open := map[string][]*openLot{}
+	lots := []ClosedLot{}
+	for _, trade := range sorted {
+		key := trade.Broker + "\x00" + trade.Symbol
+		if trade.Number > 0 {
+			open[key] = append(open[key], &openLot{date: trade.Date, number: trade.Number, cost: trade.Cost})
+			continue
+		}
+		sold := -trade.Number
+		proceeds := -trade.Cost
+		for sold > 0 {
+			if len(open[key]) == 0 {
+				return nil, fmt.Errorf("sell of %s at %s on %s exceeds held shares",
+					trade.Symbol, trade.Broker, trade.Date.Format("2006-01-02"))
+			}
+			lot := open[key][0]
+			number := lot.number
+			if sold < number {
+				number = sold
+			}
+			basis := lot.cost * float64(number) / float64(lot.number)
+			lotProceeds := proceeds * float64(number) / float64(sold)
+			closed := ClosedLot{
+				Broker:    trade.Broker,
+				Symbol:    trade.Symbol,
+				Acquired:  lot.date,
+				Disposed:  trade.Date,
+				Number:    number,
+				CostBasis: basis,
+				Proceeds:  lotProceeds,
+				Gain:      lotProceeds - basis,
+				Term:      TermShort,
+			}
+			if trade.Date.After(lot.date.AddDate(1, 0, 0)) {
+				closed.Term = TermLong
+			}
+			lots = append(lots, closed)
+			lot.number -= number
+			lot.cost -= basis
+			sold -= number
+			proceeds -= lotProceeds
+			if lot.number == 0 {
+				open[key] = open[key][1:]
+			}
+		}
+	}
+	return lots, nil
+}
+
+// Gains lists the lots closed during a tax year with their short and long term totals
+//
+// 	Gains(trades, 2017)
+//
+// returns the gains report of the year
+func Gains(trades []es.Position, year int) (*GainsReport, error) {
+	lots, err := ClosedLots(trades)
+	if err != nil {
+		return nil, err
+	}
+	report := &GainsReport{Year: year, Lots: []ClosedLot{}}
+	for _, lot := range lots {
+		if lot.Disposed.Year() != year {
+			continue
+		}
+		report.Lots = append(report.Lots, lot)
+		if lot.Term == TermLong {
+			report.LongTerm.add(lot)
+		} else {
+			report.ShortTerm.add(lot)
+		}
+		report.Total.add(lot)
+	}
+	return report, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+var lotsTrades = []es.Position{
+	{Broker: "B1", Symbol: "A", Date: testDate("2017-06-01"), Number: -15, Value: 130, Cost: -1940},
+	{Broker: "B1", Symbol: "A", Date: testDate("2016-01-10"), Number: 10, Value: 100, Cost: 1010},
+	{Broker: "B1", Symbol: "A", Date: testDate("2017-03-01"), Number: 10, Value: 120, Cost: 1205},
+	{Broker: "B2", Symbol: "A", Date: testDate("2017-01-01"), Number: 5, Value: 110, Cost: 550},
+	{Broker: "B1", Symbol: "A", Date: testDate("2017-07-01"), Cost: -12, Kind: es.KindDividend},
+	{Broker: "B1", Symbol: "A", Date: testDate("2018-01-05"), Number: -5, Value: 141, Cost: -700},
+}
+
+func TestClosedLots(t *testing.T) {
+	lots, err := ClosedLots(lotsTrades)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(lots))
+	assert.Equal(t, testDate("2016-01-10"), lots[0].Acquired)
+	assert.Equal(t, 10, lots[0].Number)
+	assert.InDelta(t, 1010, lots[0].CostBasis, 1e-9)
+	assert.InDelta(t, 1940.0*10/15, lots[0].Proceeds, 1e-9)
+	assert.Equal(t, TermLong, lots[0].Term)
+	assert.Equal(t, testDate("2017-03-01"), lots[1].Acquired)
+	assert.Equal(t, 5, lots[1].Number)
+	assert.InDelta(t, 602.5, lots[1].CostBasis, 1e-9)
+	assert.InDelta(t, 1940.0*5/15, lots[1].Proceeds, 1e-9)
+	assert.Equal(t, TermShort, lots[1].Term)
+	assert.Equal(t, testDate("2018-01-05"), lots[2].Disposed)
+	assert.InDelta(t, 602.5, lots[2].CostBasis, 1e-9)
+	assert.InDelta(t, 97.5, lots[2].Gain, 1e-9)
+}
+
+func TestClosedLotsOneYear(t *testing.T) {
+	lots, err := ClosedLots([]es.Position{
+		{Broker: "B", Symbol: "A", Date: testDate("2016-06-01"), Number: 1, Value: 10, Cost: 10},
+		{Broker: "B", Symbol: "A", Date: testDate("2017-06-01"), Number: -1, Value: 12, Cost: -12},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, TermShort, lots[0].Term)
+}
+
+func TestClosedLotsOversold(t *testing.T) {
+	_, err := ClosedLots([]es.Position{
+		{Broker: "B", Symbol: "A", Date: testDate("2017-01-01"), Number: 1, Value: 10, Cost: 10},
+		{Broker: "B", Symbol: "A", Date: testDate("2017-02-01"), Number: -2, Value: 10, Cost: -20},
+	})
+	assert.EqualError(t, err, "sell of A at B on 2017-02-01 exceeds held shares")
+}
+
+func TestGains(t *testing.T) {
+	report, err := Gains(lotsTrades, 2017)
+	assert.Nil(t, err)
+	assert.Equal(t, 2017, report.Year)
+	assert.Equal(t, 2, len(report.Lots))
+	assert.InDelta(t, 1940.0*10/15-1010, report.LongTerm.Gain, 1e-9)
+	assert.InDelta(t, 1940.0*5/15-602.5, report.ShortTerm.Gain, 1e-9)
+	assert.InDelta(t, 1612.5, report.Total.CostBasis, 1e-9)
+	assert.InDelta(t, 1940, report.Total.Proceeds, 1e-9)
+	empty, err := Gains(lotsTrades, 2015)
+	assert.Nil(t, err)
+	assert.Equal(t, []ClosedLot{}, empty.Lots)
+}
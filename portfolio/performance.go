@@ -0,0 +1,221 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clebi/gofin/es"
+)
+
+const day = 24 * time.Hour
+
+// Periods which can be used to compute performances
+const (
+	PeriodYTD       = "ytd"
+	Period1Y        = "1y"
+	Period3Y        = "3y"
+	PeriodInception = "inception"
+)
+
+// ErrNoPrices is returned when there is no price to value the positions during a period
+var ErrNoPrices = errors.New("no prices for the period")
+
+// Performance contains the returns of a set of trades over a period
+type Performance struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	StartValue    float64   `json:"start_value"`
+	EndValue      float64   `json:"end_value"`
+	NetFlows      float64   `json:"net_flows"`
+	TWR           float64   `json:"twr"`
+	AnnualizedTWR float64   `json:"annualized_twr"`
+	PriceReturn   float64   `json:"price_return"`
+	FXReturn      float64   `json:"fx_return"`
+	MWR           *float64  `json:"mwr"`
+}
+
+// PeriodStart computes the start date of a named period
+//
+// 	PeriodStart("ytd", endDate, inceptionDate)
+//
+// returns the start date, never before the inception date
+func PeriodStart(period string, end time.Time, inception time.Time) (time.Time, error) {
+	var start time.Time
+	switch strings.ToLower(period) {
+	case PeriodYTD:
+		start = time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, end.Location())
+	case Period1Y:
+		start = end.AddDate(-1, 0, 0)
+	case Period3Y:
+		start = end.AddDate(-3, 0, 0)
+	case PeriodInception, "":
+		start = inception
+	default:
+		return start, fmt.Errorf("unknown period: %s", period)
+	}
+	if start.Before(inception) {
+		start = inception
+	}
+	return start, nil
+}
+
+// Annualize converts a return over a number of days into a yearly return
+func Annualize(value float64, days float64) float64 {
+	if days <= 0 {
+		return value
+	}
+	return math.Pow(1+value, daysPerYear/days) - 1
+}
+
+// valuationDates returns the sorted days where at least one symbol has a price in the period
+func valuationDates(bars map[string][]es.StockBar, start time.Time, end time.Time) []time.Time {
+	seen := map[int64]bool{}
+	var dates []time.Time
+	for _, symbolBars := range bars {
+		for _, bar := range symbolBars {
+			date := bar.Date.Truncate(day)
+			if date.Before(start) || date.After(end) || seen[date.Unix()] {
+				continue
+			}
+			seen[date.Unix()] = true
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// Compute computes the time-weighted and money-weighted returns of trades over a period
+//
+// 	Compute(trades, bars, startDate, endDate, exchange)
+//
+// Positions are valued every day with the last known close, trades are applied at the end of their day.
+// Dividends are income: they leave the positions as flows on their day, so the returns are total returns.
+// With an exchange, values are converted into its base currency and the time-weighted return is split
+// between the prices, at the exchange rates of the previous day, and the exchange rates: the two returns
+// compound into the time-weighted return.
+// returns the performance over the period
+func Compute(
+	trades []es.Position,
+	bars map[string][]es.StockBar,
+	start time.Time,
+	end time.Time,
+	exchange *Exchange) (*Performance, error) {
+	start, end = start.Truncate(day), end.Truncate(day)
+	dates := valuationDates(bars, start, end)
+	if len(dates) == 0 {
+		return nil, ErrNoPrices
+	}
+	sorted := make([]es.Position, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	holdings := map[string]int{}
+	prices := map[string]float64{}
+	barIndexes := map[string]int{}
+	tradeIndex := 0
+	growth, priceGrowth := 1.0, 1.0
+	var flows []CashFlow
+	perf := &Performance{Start: dates[0], End: dates[len(dates)-1]}
+	var prevValue float64
+	for i, date := range dates {
+		var dayFlow, prevRateFlow float64
+		for ; tradeIndex < len(sorted) && !sorted[tradeIndex].Date.Truncate(day).After(date); tradeIndex++ {
+			trade := sorted[tradeIndex]
+			holdings[trade.Symbol] += trade.Number
+			rate, err := exchange.TradeRate(trade, date)
+			if err != nil {
+				return nil, err
+			}
+			dayFlow += trade.Cost * rate
+			if i > 0 {
+				prevRate, err := exchange.TradeRate(trade, dates[i-1])
+				if err != nil {
+					return nil, err
+				}
+				prevRateFlow += trade.Cost * prevRate
+			}
+			if _, ok := prices[trade.Symbol]; !ok && trade.Kind == "" {
+				// the price of a trade is in its currency, the closes in the currency of the symbol
+				symbolRate, err := exchange.SymbolRate(trade.Symbol, date)
+				if err != nil {
+					return nil, err
+				}
+				prices[trade.Symbol] = trade.Value * rate / symbolRate
+			}
+		}
+		for symbol, symbolBars := range bars {
+			index := barIndexes[symbol]
+			for ; index < len(symbolBars) && !symbolBars[index].Date.Truncate(day).After(date); index++ {
+				prices[symbol] = symbolBars[index].Close
+			}
+			barIndexes[symbol] = index
+		}
+		var value, prevRateValue float64
+		for symbol, number := range holdings {
+			if number == 0 {
+				continue
+			}
+			rate, err := exchange.SymbolRate(symbol, date)
+			if err != nil {
+				return nil, err
+			}
+			value += float64(number) * prices[symbol] * rate
+			if i > 0 {
+				prevRate, err := exchange.SymbolRate(symbol, dates[i-1])
+				if err != nil {
+					return nil, err
+				}
+				prevRateValue += float64(number) * prices[symbol] * prevRate
+			}
+		}
+		if i == 0 {
+			perf.StartValue = value
+			if value != 0 {
+				flows = append(flows, CashFlow{Date: date, Amount: -value})
+			}
+		} else {
+			if prevValue > 0 {
+				growth *= (value - dayFlow) / prevValue
+				priceGrowth *= (prevRateValue - prevRateFlow) / prevValue
+			}
+			perf.NetFlows += dayFlow
+			if dayFlow != 0 {
+				flows = append(flows, CashFlow{Date: date, Amount: -dayFlow})
+			}
+		}
+		prevValue = value
+	}
+	perf.EndValue = prevValue
+	if prevValue != 0 {
+		flows = append(flows, CashFlow{Date: perf.End, Amount: prevValue})
+	}
+	perf.TWR = growth - 1
+	perf.PriceReturn = priceGrowth - 1
+	if priceGrowth != 0 {
+		perf.FXReturn = growth/priceGrowth - 1
+	}
+	perf.AnnualizedTWR = Annualize(perf.TWR, perf.End.Sub(perf.Start).Hours()/24)
+	if mwr, err := XIRR(flows); err == nil {
+		perf.MWR = &mwr
+	}
+	return perf, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/fx"
+	"github.com/stretchr/testify/assert"
+)
+
+var performanceBars = map[string][]es.StockBar{
+	"TEST": {
+		{Symbol: "TEST", Date: testDate("2017-01-01"), Close: 100},
+		{Symbol: "TEST", Date: testDate("2018-01-01"), Close: 110},
+		{Symbol: "TEST", Date: testDate("2019-01-01"), Close: 121},
+	},
+}
+
+func TestCompute(t *testing.T) {
+	trades := []es.Position{
+		{Symbol: "TEST", Date: testDate("2018-01-01"), Number: 10, Value: 110, Cost: 1100},
+		{Symbol: "TEST", Date: testDate("2017-01-01"), Number: 10, Value: 100, Cost: 1000},
+	}
+	perf, err := Compute(trades, performanceBars, testDate("2017-01-01"), testDate("2019-01-01"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000.0, perf.StartValue)
+	assert.Equal(t, 2420.0, perf.EndValue)
+	assert.Equal(t, 1100.0, perf.NetFlows)
+	assert.InDelta(t, 0.21, perf.TWR, 1e-9)
+	assert.InDelta(t, 0.1, perf.AnnualizedTWR, 1e-9)
+	assert.InDelta(t, 0.21, perf.PriceReturn, 1e-9)
+	assert.Equal(t, 0.0, perf.FXReturn)
+	assert.InDelta(t, 0.1, *perf.MWR, 1e-6)
+}
+
+func TestComputeDividend(t *testing.T) {
+	trades := []es.Position{
+		{Symbol: "TEST", Date: testDate("2017-01-01"), Number: 10, Value: 100, Cost: 1000},
+		{Symbol: "TEST", Date: testDate("2018-01-01"), Cost: -50, Kind: es.KindDividend},
+	}
+	perf, err := Compute(trades, performanceBars, testDate("2017-01-01"), testDate("2019-01-01"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1210.0, perf.EndValue)
+	assert.Equal(t, -50.0, perf.NetFlows)
+	assert.InDelta(t, 1.15*1.1-1, perf.TWR, 1e-9)
+	assert.InDelta(t, perf.TWR, perf.PriceReturn, 1e-9)
+	assert.True(t, *perf.MWR > 0.1)
+}
+
+func TestComputeDividendFirst(t *testing.T) {
+	trades := []es.Position{
+		{Symbol: "TEST", Date: testDate("2017-01-01"), Cost: -5, Kind: es.KindDividend},
+		{Symbol: "TEST", Date: testDate("2017-01-01"), Number: 10, Value: 100, Cost: 1000},
+	}
+	perf, err := Compute(trades, performanceBars, testDate("2017-01-01"), testDate("2019-01-01"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000.0, perf.StartValue)
+}
+
+func TestComputeSellAll(t *testing.T) {
+	trades := []es.Position{
+		{Symbol: "TEST", Date: testDate("2017-01-01"), Number: 10, Value: 100, Cost: 1000},
+		{Symbol: "TEST", Date: testDate("2018-01-01"), Number: -10, Value: 110, Cost: -1100},
+	}
+	perf, err := Compute(trades, performanceBars, testDate("2017-01-01"), testDate("2019-01-01"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, perf.EndValue)
+	assert.InDelta(t, 0.1, perf.TWR, 1e-9)
+	assert.InDelta(t, 0.1, *perf.MWR, 1e-6)
+}
+
+func TestComputeExchange(t *testing.T) {
+	trades := []es.Position{{Symbol: "TEST", Date: testDate("2017-01-01"), Number: 10, Value: 100, Cost: 1000}}
+	exchange := &Exchange{
+		Currencies: map[string]string{"TEST": "EUR"},
+		Converter: fx.NewConverter("USD", []es.FxRate{
+			{From: "EUR", To: "USD", Date: testDate("2017-01-01"), Rate: 1},
+			{From: "EUR", To: "USD", Date: testDate("2018-01-01"), Rate: 1.1},
+			{From: "EUR", To: "USD", Date: testDate("2019-01-01"), Rate: 1.2},
+		}),
+	}
+	perf, err := Compute(trades, performanceBars, testDate("2017-01-01"), testDate("2019-01-01"), exchange)
+	assert.Nil(t, err)
+	assert.InDelta(t, 1452, perf.EndValue, 1e-9)
+	assert.InDelta(t, 0.452, perf.TWR, 1e-9)
+	assert.InDelta(t, 0.21, perf.PriceReturn, 1e-9)
+	assert.InDelta(t, 0.2, perf.FXReturn, 1e-9)
+	assert.InDelta(t, (1+perf.PriceReturn)*(1+perf.FXReturn)-1, perf.TWR, 1e-9)
+	exchange.Currencies = map[string]string{}
+	_, err = Compute(trades, performanceBars, testDate("2017-01-01"), testDate("2019-01-01"), exchange)
+	assert.EqualError(t, err, "unknown currency of TEST")
+}
+
+func TestComputeExchangeTradeCurrency(t *testing.T) {
+	bars := map[string][]es.StockBar{
+		"TEST": performanceBars["TEST"],
+		"LATE": {{Symbol: "LATE", Date: testDate("2019-01-01"), Close: 10}},
+	}
+	// the trade is paid in USD before the first close of the symbol, which is in EUR
+	trades := []es.Position{{Symbol: "LATE", Date: testDate("2018-01-01"), Number: 10, Value: 11, Cost: 110, Currency: "USD"}}
+	exchange := &Exchange{
+		Currencies: map[string]string{"TEST": "EUR", "LATE": "EUR"},
+		Converter: fx.NewConverter("USD", []es.FxRate{
+			{From: "EUR", To: "USD", Date: testDate("2017-01-01"), Rate: 1},
+			{From: "EUR", To: "USD", Date: testDate("2018-01-01"), Rate: 1.1},
+			{From: "EUR", To: "USD", Date: testDate("2019-01-01"), Rate: 1.21},
+		}),
+	}
+	perf, err := Compute(trades, bars, testDate("2017-01-01"), testDate("2019-01-01"), exchange)
+	assert.Nil(t, err)
+	assert.InDelta(t, 121, perf.EndValue, 1e-9)
+	assert.InDelta(t, 0.1, perf.TWR, 1e-9)
+	assert.InDelta(t, 0, perf.PriceReturn, 1e-9)
+	assert.InDelta(t, 0.1, perf.FXReturn, 1e-9)
+}
+
+func TestComputeNoPrices(t *testing.T) {
+	_, err := Compute(nil, performanceBars, testDate("2020-01-01"), testDate("2021-01-01"), nil)
+	assert.Equal(t, ErrNoPrices, err)
+}
+
+var periodStartTests = []struct {
+	period   string
+	expected string
+}{
+	{"ytd", "2019-01-01"},
+	{"1Y", "2018-06-15"},
+	{"3y", "2016-06-15"},
+	{"inception", "2015-03-02"},
+}
+
+func TestPeriodStart(t *testing.T) {
+	for _, tt := range periodStartTests {
+		start, err := PeriodStart(tt.period, testDate("2019-06-15"), testDate("2015-03-02"))
+		assert.Nil(t, err)
+		assert.Equal(t, testDate(tt.expected), start)
+	}
+	start, err := PeriodStart("3y", testDate("2019-06-15"), testDate("2018-03-02"))
+	assert.Nil(t, err)
+	assert.Equal(t, testDate("2018-03-02"), start)
+	_, err = PeriodStart("10y", testDate("2019-06-15"), testDate("2018-03-02"))
+	assert.NotNil(t, err)
+}
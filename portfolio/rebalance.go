@@ -0,0 +1,244 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/clebi/gofin/es"
+)
+
+const weightTolerance = 1e-6
+
+// ErrBadWeights is returned when the target weights do not sum to one
+var ErrBadWeights = errors.New("target weights must sum to 1")
+
+// Holding is a number of shares of a symbol
+type Holding struct {
+	Symbol string
+	Number int
+}
+
+// RebalanceOptions contains the constraints of a rebalancing
+type RebalanceOptions struct {
+	Cash     float64
+	MinTrade float64
+	NoSell   bool
+}
+
+// Order is a trade proposed to reach the target allocation, Number is negative for a sell
+type Order struct {
+	Symbol string  `json:"symbol"`
+	Number int     `json:"number"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+}
+
+// TargetStatus compares a target to the holdings before and after the rebalancing
+type TargetStatus struct {
+	Name          string  `json:"name"`
+	Weight        float64 `json:"weight"`
+	CurrentValue  float64 `json:"current_value"`
+	CurrentWeight float64 `json:"current_weight"`
+	TargetValue   float64 `json:"target_value"`
+	FinalValue    float64 `json:"final_value"`
+	FinalWeight   float64 `json:"final_weight"`
+}
+
+// Rebalancing contains the orders to pass to get closer to a target allocation
+type Rebalancing struct {
+	Total    float64        `json:"total"`
+	Targets  []TargetStatus `json:"targets"`
+	Orders   []Order        `json:"orders"`
+	CashLeft float64        `json:"cash_left"`
+}
+
+type bucket struct {
+	status  TargetStatus
+	symbols []string
+}
+
+// buildBuckets groups the holdings by target, symbols held without target get a zero weight
+func buildBuckets(holdings map[string]int, prices map[string]float64, targets []es.AllocationTarget) ([]*bucket, error) {
+	var buckets []*bucket
+	owners := map[string]*bucket{}
+	var weights float64
+	for _, target := range targets {
+		b := &bucket{status: TargetStatus{Name: target.Name, Weight: target.Weight}, symbols: target.Symbols}
+		for _, symbol := range target.Symbols {
+			if _, ok := owners[symbol]; ok {
+				return nil, fmt.Errorf("symbol %s is in several targets", symbol)
+			}
+			owners[symbol] = b
+		}
+		weights += target.Weight
+		buckets = append(buckets, b)
+	}
+	if math.Abs(weights-1) > weightTolerance {
+		return nil, ErrBadWeights
+	}
+	var others []string
+	for symbol := range holdings {
+		if _, ok := owners[symbol]; !ok {
+			others = append(others, symbol)
+		}
+	}
+	sort.Strings(others)
+	for _, symbol := range others {
+		b := &bucket{status: TargetStatus{Name: symbol}, symbols: []string{symbol}}
+		owners[symbol] = b
+		buckets = append(buckets, b)
+	}
+	for _, b := range buckets {
+		for _, symbol := range b.symbols {
+			if prices[symbol] <= 0 {
+				return nil, fmt.Errorf("no price for %s", symbol)
+			}
+			b.status.CurrentValue += float64(holdings[symbol]) * prices[symbol]
+		}
+	}
+	return buckets, nil
+}
+
+// sellBucket sells whole shares of a bucket, biggest holdings first, without selling more than amount
+func sellBucket(b *bucket, amount float64, holdings map[string]int, prices map[string]float64, minTrade float64) []Order {
+	symbols := make([]string, len(b.symbols))
+	copy(symbols, b.symbols)
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return float64(holdings[symbols[i]])*prices[symbols[i]] > float64(holdings[symbols[j]])*prices[symbols[j]]
+	})
+	var orders []Order
+	for _, symbol := range symbols {
+		number := int(math.Min(float64(holdings[symbol]), math.Floor(amount/prices[symbol])))
+		value := float64(number) * prices[symbol]
+		if number <= 0 || value < minTrade {
+			continue
+		}
+		orders = append(orders, Order{Symbol: symbol, Number: -number, Price: prices[symbol], Amount: -value})
+		amount -= value
+	}
+	return orders
+}
+
+// Rebalance proposes whole share orders to move holdings towards target weights
+//
+// 	Rebalance(holdings, prices, targets, options)
+//
+// Sells are made first and their proceeds are added to the cash budget to buy.
+// returns the orders and the weights before and after them
+func Rebalance(
+	holdingList []Holding,
+	prices map[string]float64,
+	targets []es.AllocationTarget,
+	options RebalanceOptions) (*Rebalancing, error) {
+	holdings := map[string]int{}
+	for _, holding := range holdingList {
+		if holding.Number != 0 {
+			holdings[holding.Symbol] += holding.Number
+		}
+	}
+	buckets, err := buildBuckets(holdings, prices, targets)
+	if err != nil {
+		return nil, err
+	}
+	result := &Rebalancing{Total: options.Cash, Orders: []Order{}}
+	for _, b := range buckets {
+		result.Total += b.status.CurrentValue
+	}
+	var buys float64
+	for _, b := range buckets {
+		b.status.TargetValue = b.status.Weight * result.Total
+		b.status.FinalValue = b.status.CurrentValue
+		if delta := b.status.TargetValue - b.status.CurrentValue; delta > 0 {
+			buys += delta
+		}
+	}
+	cash := options.Cash
+	if !options.NoSell {
+		for _, b := range buckets {
+			if delta := b.status.TargetValue - b.status.CurrentValue; delta < 0 {
+				for _, order := range sellBucket(b, -delta, holdings, prices, options.MinTrade) {
+					result.Orders = append(result.Orders, order)
+					b.status.FinalValue += order.Amount
+					cash -= order.Amount
+				}
+			}
+		}
+	}
+	scale := 1.0
+	if buys > cash && buys > 0 {
+		scale = cash / buys
+	}
+	buyOrders := map[string]*Order{}
+	for _, b := range buckets {
+		delta := (b.status.TargetValue - b.status.CurrentValue) * scale
+		symbol := b.symbols[0]
+		number := math.Floor(delta / prices[symbol])
+		if delta <= 0 || number <= 0 || number*prices[symbol] < options.MinTrade {
+			continue
+		}
+		buyOrders[symbol] = &Order{Symbol: symbol, Number: int(number), Price: prices[symbol], Amount: number * prices[symbol]}
+		b.status.FinalValue += buyOrders[symbol].Amount
+		cash -= buyOrders[symbol].Amount
+	}
+	// spend what is left by rounding down on the most underweight targets
+	for {
+		var best *bucket
+		for _, b := range buckets {
+			symbol := b.symbols[0]
+			gap := b.status.TargetValue - b.status.FinalValue
+			if gap < prices[symbol]/2 || prices[symbol] > cash+weightTolerance {
+				continue
+			}
+			if _, ok := buyOrders[symbol]; !ok && prices[symbol] < options.MinTrade {
+				continue
+			}
+			if best == nil || gap > best.status.TargetValue-best.status.FinalValue {
+				best = b
+			}
+		}
+		if best == nil {
+			break
+		}
+		symbol := best.symbols[0]
+		if _, ok := buyOrders[symbol]; !ok {
+			buyOrders[symbol] = &Order{Symbol: symbol, Price: prices[symbol]}
+		}
+		buyOrders[symbol].Number++
+		buyOrders[symbol].Amount += prices[symbol]
+		best.status.FinalValue += prices[symbol]
+		cash -= prices[symbol]
+	}
+	var buySymbols []string
+	for symbol := range buyOrders {
+		buySymbols = append(buySymbols, symbol)
+	}
+	sort.Strings(buySymbols)
+	for _, symbol := range buySymbols {
+		result.Orders = append(result.Orders, *buyOrders[symbol])
+	}
+	result.CashLeft = cash
+	for _, b := range buckets {
+		if result.Total > 0 {
+			b.status.CurrentWeight = b.status.CurrentValue / result.Total
+			b.status.FinalWeight = b.status.FinalValue / result.Total
+		}
+		result.Targets = append(result.Targets, b.status)
+	}
+	return result, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+var rebalanceTargets = []es.AllocationTarget{
+	{Name: "A", Symbols: []string{"A"}, Weight: 0.5},
+	{Name: "B", Symbols: []string{"B", "B2"}, Weight: 0.5},
+}
+
+var rebalanceTests = []struct {
+	holdings []Holding
+	prices   map[string]float64
+	options  RebalanceOptions
+	orders   []Order
+	cashLeft float64
+}{
+	{
+		[]Holding{{Symbol: "A", Number: 10}},
+		map[string]float64{"A": 100, "B": 50, "B2": 10},
+		RebalanceOptions{},
+		[]Order{{Symbol: "A", Number: -5, Price: 100, Amount: -500}, {Symbol: "B", Number: 10, Price: 50, Amount: 500}},
+		0,
+	},
+	{
+		[]Holding{{Symbol: "A", Number: 10}},
+		map[string]float64{"A": 100, "B": 50, "B2": 10},
+		RebalanceOptions{Cash: 300, NoSell: true},
+		[]Order{{Symbol: "B", Number: 6, Price: 50, Amount: 300}},
+		0,
+	},
+	{
+		[]Holding{{Symbol: "A", Number: 10}},
+		map[string]float64{"A": 100, "B": 50, "B2": 10},
+		RebalanceOptions{MinTrade: 600},
+		[]Order{},
+		0,
+	},
+	{
+		nil,
+		map[string]float64{"A": 30, "B": 70, "B2": 10},
+		RebalanceOptions{Cash: 1000},
+		[]Order{{Symbol: "A", Number: 17, Price: 30, Amount: 510}, {Symbol: "B", Number: 7, Price: 70, Amount: 490}},
+		0,
+	},
+	{
+		[]Holding{{Symbol: "B2", Number: 100}, {Symbol: "C", Number: 1}},
+		map[string]float64{"A": 100, "B": 50, "B2": 10, "C": 100},
+		RebalanceOptions{},
+		[]Order{
+			{Symbol: "B2", Number: -45, Price: 10, Amount: -450},
+			{Symbol: "C", Number: -1, Price: 100, Amount: -100},
+			{Symbol: "A", Number: 5, Price: 100, Amount: 500},
+		},
+		50,
+	},
+}
+
+func TestRebalance(t *testing.T) {
+	for _, tt := range rebalanceTests {
+		result, err := Rebalance(tt.holdings, tt.prices, rebalanceTargets, tt.options)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.orders, result.Orders)
+		assert.InDelta(t, tt.cashLeft, result.CashLeft, 1e-9)
+	}
+}
+
+func TestRebalanceTargets(t *testing.T) {
+	result, err := Rebalance([]Holding{{Symbol: "A", Number: 10}}, map[string]float64{"A": 100, "B": 50, "B2": 10},
+		rebalanceTargets, RebalanceOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []TargetStatus{
+		{Name: "A", Weight: 0.5, CurrentValue: 1000, CurrentWeight: 1, TargetValue: 500, FinalValue: 500, FinalWeight: 0.5},
+		{Name: "B", Weight: 0.5, CurrentValue: 0, CurrentWeight: 0, TargetValue: 500, FinalValue: 500, FinalWeight: 0.5},
+	}, result.Targets)
+}
+
+func TestRebalanceErrors(t *testing.T) {
+	_, err := Rebalance(nil, map[string]float64{"A": 100}, []es.AllocationTarget{{Name: "A", Symbols: []string{"A"}, Weight: 0.8}},
+		RebalanceOptions{})
+	assert.Equal(t, ErrBadWeights, err)
+	_, err = Rebalance(nil, map[string]float64{"A": 100}, rebalanceTargets, RebalanceOptions{})
+	assert.EqualError(t, err, "no price for B")
+	_, err = Rebalance(nil, map[string]float64{"A": 100}, []es.AllocationTarget{
+		{Name: "A", Symbols: []string{"A"}, Weight: 0.5},
+		{Name: "AA", Symbols: []string{"A"}, Weight: 0.5},
+	}, RebalanceOptions{})
+	assert.EqualError(t, err, "symbol A is in several targets")
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	xirrTolerance     = 1e-9
+	xirrMaxIterations = 100
+	xirrMinRate       = -0.999999
+	xirrMaxRate       = 1e6
+	daysPerYear       = 365.0
+)
+
+// ErrNoSolution is returned when the internal rate of return cannot be found
+var ErrNoSolution = errors.New("xirr: no solution")
+
+// CashFlow is an amount of money at a date, negative when invested and positive when withdrawn
+type CashFlow struct {
+	Date   time.Time
+	Amount float64
+}
+
+func xnpv(rate float64, flows []CashFlow) float64 {
+	var npv float64
+	for _, flow := range flows {
+		years := flow.Date.Sub(flows[0].Date).Hours() / 24 / daysPerYear
+		npv += flow.Amount / math.Pow(1+rate, years)
+	}
+	return npv
+}
+
+func xnpvDerivative(rate float64, flows []CashFlow) float64 {
+	var derivative float64
+	for _, flow := range flows {
+		years := flow.Date.Sub(flows[0].Date).Hours() / 24 / daysPerYear
+		derivative -= years * flow.Amount / math.Pow(1+rate, years+1)
+	}
+	return derivative
+}
+
+// XIRR computes the annual internal rate of return of irregular cash flows
+//
+// 	XIRR(flows)
+//
+// flows must be sorted by date and contain at least one negative and one positive amount
+func XIRR(flows []CashFlow) (float64, error) {
+	var hasNegative, hasPositive bool
+	for _, flow := range flows {
+		hasNegative = hasNegative || flow.Amount < 0
+		hasPositive = hasPositive || flow.Amount > 0
+	}
+	if !hasNegative || !hasPositive {
+		return 0, ErrNoSolution
+	}
+	rate := 0.1
+	for i := 0; i < xirrMaxIterations; i++ {
+		derivative := xnpvDerivative(rate, flows)
+		if derivative == 0 {
+			break
+		}
+		next := rate - xnpv(rate, flows)/derivative
+		if math.IsNaN(next) || next <= xirrMinRate || next > xirrMaxRate {
+			break
+		}
+		if math.Abs(next-rate) < xirrTolerance {
+			return next, nil
+		}
+		rate = next
+	}
+	return xirrBisect(flows)
+}
+
+// xirrBisect is the slow but safe fallback when newton's method does not converge
+func xirrBisect(flows []CashFlow) (float64, error) {
+	low, high := xirrMinRate, 1.0
+	lowNpv := xnpv(low, flows)
+	for xnpv(high, flows)*lowNpv > 0 {
+		high *= 10
+		if high > xirrMaxRate {
+			return 0, ErrNoSolution
+		}
+	}
+	for i := 0; i < 1000 && high-low > xirrTolerance; i++ {
+		middle := (low + high) / 2
+		middleNpv := xnpv(middle, flows)
+		if middleNpv*lowNpv > 0 {
+			low, lowNpv = middle, middleNpv
+		} else {
+			high = middle
+		}
+	}
+	return (low + high) / 2, nil
+}
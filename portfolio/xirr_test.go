@@ -0,0 +1,55 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDate(value string) time.Time {
+	date, _ := time.Parse("2006-01-02", value)
+	return date
+}
+
+func TestXIRR(t *testing.T) {
+	flows := []CashFlow{
+		{Date: testDate("2008-01-01"), Amount: -10000},
+		{Date: testDate("2008-03-01"), Amount: 2750},
+		{Date: testDate("2008-10-30"), Amount: 4250},
+		{Date: testDate("2009-02-15"), Amount: 3250},
+		{Date: testDate("2009-04-01"), Amount: 2750},
+	}
+	rate, err := XIRR(flows)
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.373362535, rate, 1e-6)
+}
+
+func TestXIRRLoss(t *testing.T) {
+	flows := []CashFlow{
+		{Date: testDate("2017-01-01"), Amount: -1000},
+		{Date: testDate("2018-01-01"), Amount: 500},
+	}
+	rate, err := XIRR(flows)
+	assert.Nil(t, err)
+	assert.InDelta(t, -0.5, rate, 1e-6)
+}
+
+func TestXIRRNoSolution(t *testing.T) {
+	_, err := XIRR([]CashFlow{{Date: testDate("2017-01-01"), Amount: -1000}})
+	assert.Equal(t, ErrNoSolution, err)
+}
@@ -0,0 +1,561 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package screener filters symbols with expressions on their last prices and indicators
+//
+// An expression compares prices and indicators of the catalog with numbers, as in
+// rsi(14) < 30 and close > sma(200) and volume > 1e6. The outputs of an indicator giving
+// several values are selected with a dot, as in bb(20, 2).lower or macd.signal.
+package screener
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/indicators"
+)
+
+// fields are the values of the last bar which can be used in expressions
+var fields = map[string]func(bar es.StockBar) float64{
+	"open":   func(bar es.StockBar) float64 { return bar.Open },
+	"high":   func(bar es.StockBar) float64 { return bar.High },
+	"low":    func(bar es.StockBar) float64 { return bar.Low },
+	"close":  func(bar es.StockBar) float64 { return bar.Close },
+	"volume": func(bar es.StockBar) float64 { return bar.Volume },
+}
+
+const (
+	tokenEnd = iota
+	tokenNumber
+	tokenName
+	tokenOperator
+)
+
+type token struct {
+	kind  int
+	text  string
+	value float64
+	pos   int
+}
+
+func (tok token) String() string {
+	if tok.kind == tokenEnd {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at %d", tok.text, tok.pos+1)
+}
+
+var operators = []string{"<=", ">=", "==", "!=", "<", ">", "+", "-", "*", "/", "(", ")", ",", "."}
+
+func isNameRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
+
+// scan splits an expression in tokens
+func scan(text string) ([]token, error) {
+	var tokens []token
+	runes := []rune(text)
+	for pos := 0; pos < len(runes); {
+		r := runes[pos]
+		switch {
+		case unicode.IsSpace(r):
+			pos++
+		case unicode.IsDigit(r):
+			end := pos
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.') {
+				end++
+			}
+			if end < len(runes) && (runes[end] == 'e' || runes[end] == 'E') {
+				end++
+				if end < len(runes) && (runes[end] == '+' || runes[end] == '-') {
+					end++
+				}
+				for end < len(runes) && unicode.IsDigit(runes[end]) {
+					end++
+				}
+			}
+			value, err := strconv.ParseFloat(string(runes[pos:end]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q at %d", string(runes[pos:end]), pos+1)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[pos:end]), value: value, pos: pos})
+			pos = end
+		case isNameRune(r, true):
+			end := pos
+			for end < len(runes) && isNameRune(runes[end], false) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenName, text: strings.ToLower(string(runes[pos:end])), pos: pos})
+			pos = end
+		default:
+			found := false
+			for _, operator := range operators {
+				if strings.HasPrefix(string(runes[pos:]), operator) {
+					tokens = append(tokens, token{kind: tokenOperator, text: operator, pos: pos})
+					pos += len([]rune(operator))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected %q at %d", string(r), pos+1)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, pos: len(runes)}), nil
+}
+
+// node is a part of an expression, booleans are 1 for true and 0 for false
+type node interface {
+	eval(values map[string]float64) float64
+}
+
+type numberNode float64
+
+func (number numberNode) eval(values map[string]float64) float64 {
+	return float64(number)
+}
+
+// term is a price or an indicator whose value is computed on the bars of a symbol, or a variable
+// whose value is given by the caller
+type term struct {
+	key      string
+	field    string
+	spec     *indicators.Spec
+	output   string
+	variable bool
+}
+
+func (term *term) eval(values map[string]float64) float64 {
+	if value, ok := values[term.key]; ok {
+		return value
+	}
+	return math.NaN()
+}
+
+func truth(value float64) bool {
+	return !math.IsNaN(value) && value != 0
+}
+
+func boolean(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+type unaryNode struct {
+	operator string
+	operand  node
+}
+
+func (unary *unaryNode) eval(values map[string]float64) float64 {
+	value := unary.operand.eval(values)
+	if unary.operator == "not" {
+		return boolean(!truth(value))
+	}
+	return -value
+}
+
+type binaryNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+// eval computes the operation, a comparison with a value which is not available is false
+func (binary *binaryNode) eval(values map[string]float64) float64 {
+	left := binary.left.eval(values)
+	switch binary.operator {
+	case "and":
+		return boolean(truth(left) && truth(binary.right.eval(values)))
+	case "or":
+		return boolean(truth(left) || truth(binary.right.eval(values)))
+	}
+	right := binary.right.eval(values)
+	switch binary.operator {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	case "*":
+		return left * right
+	case "/":
+		return left / right
+	}
+	if math.IsNaN(left) || math.IsNaN(right) {
+		return 0
+	}
+	switch binary.operator {
+	case "<":
+		return boolean(left < right)
+	case "<=":
+		return boolean(left <= right)
+	case ">":
+		return boolean(left > right)
+	case ">=":
+		return boolean(left >= right)
+	case "==":
+		return boolean(left == right)
+	}
+	return boolean(left != right)
+}
+
+// parser reads tokens by recursive descent, from the lowest precedence: or, and, not, comparisons,
+// additions then multiplications
+type parser struct {
+	tokens    []token
+	pos       int
+	terms     []*term
+	variables []string
+}
+
+func (parser *parser) peek() token {
+	return parser.tokens[parser.pos]
+}
+
+func (parser *parser) next() token {
+	tok := parser.tokens[parser.pos]
+	if tok.kind != tokenEnd {
+		parser.pos++
+	}
+	return tok
+}
+
+func (parser *parser) accept(kind int, texts ...string) (token, bool) {
+	tok := parser.peek()
+	if tok.kind != kind {
+		return tok, false
+	}
+	for _, text := range texts {
+		if tok.text == text {
+			return parser.next(), true
+		}
+	}
+	return tok, false
+}
+
+func (parser *parser) expect(text string) error {
+	if _, ok := parser.accept(tokenOperator, text); !ok {
+		return fmt.Errorf("expected %q instead of %s", text, parser.peek())
+	}
+	return nil
+}
+
+type parseFunc func() (node, error)
+
+func (parser *parser) binary(kind int, operand parseFunc, operators ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := parser.accept(kind, operators...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: tok.text, left: left, right: right}
+	}
+}
+
+func (parser *parser) or() (node, error) {
+	return parser.binary(tokenName, parser.and, "or")
+}
+
+func (parser *parser) and() (node, error) {
+	return parser.binary(tokenName, parser.not, "and")
+}
+
+func (parser *parser) not() (node, error) {
+	if _, ok := parser.accept(tokenName, "not"); ok {
+		operand, err := parser.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operator: "not", operand: operand}, nil
+	}
+	return parser.comparison()
+}
+
+func (parser *parser) comparison() (node, error) {
+	left, err := parser.sum()
+	if err != nil {
+		return nil, err
+	}
+	tok, ok := parser.accept(tokenOperator, "<", "<=", ">", ">=", "==", "!=")
+	if !ok {
+		return left, nil
+	}
+	right, err := parser.sum()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{operator: tok.text, left: left, right: right}, nil
+}
+
+func (parser *parser) sum() (node, error) {
+	return parser.binary(tokenOperator, parser.product, "+", "-")
+}
+
+func (parser *parser) product() (node, error) {
+	return parser.binary(tokenOperator, parser.unary, "*", "/")
+}
+
+func (parser *parser) unary() (node, error) {
+	if _, ok := parser.accept(tokenOperator, "-"); ok {
+		operand, err := parser.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{operator: "-", operand: operand}, nil
+	}
+	return parser.primary()
+}
+
+func (parser *parser) primary() (node, error) {
+	tok := parser.next()
+	switch {
+	case tok.kind == tokenNumber:
+		return numberNode(tok.value), nil
+	case tok.kind == tokenOperator && tok.text == "(":
+		inner, err := parser.or()
+		if err != nil {
+			return nil, err
+		}
+		return inner, parser.expect(")")
+	case tok.kind == tokenName && tok.text != "and" && tok.text != "or" && tok.text != "not":
+		return parser.term(tok)
+	}
+	return nil, fmt.Errorf("unexpected %s", tok)
+}
+
+// term reads a price or an indicator with its parameters and its output: name[(params)][.output]
+func (parser *parser) term(name token) (node, error) {
+	if _, ok := fields[name.text]; ok {
+		return parser.addTerm(&term{key: name.text, field: name.text}), nil
+	}
+	for _, variable := range parser.variables {
+		if variable == name.text {
+			return parser.addTerm(&term{key: name.text, variable: true}), nil
+		}
+	}
+	var params []string
+	if _, ok := parser.accept(tokenOperator, "("); ok {
+		if _, ok := parser.accept(tokenOperator, ")"); !ok {
+			for {
+				tok := parser.next()
+				if tok.kind != tokenNumber {
+					return nil, fmt.Errorf("expected a number instead of %s", tok)
+				}
+				params = append(params, tok.text)
+				if _, ok := parser.accept(tokenOperator, ","); !ok {
+					break
+				}
+			}
+			if err := parser.expect(")"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	spec, err := indicators.ParseSpec(strings.Join(append([]string{name.text}, params...), ":"))
+	if err != nil {
+		return nil, err
+	}
+	key := name.text
+	if len(params) > 0 {
+		key += "(" + strings.Join(params, ",") + ")"
+	}
+	output := spec.Outputs()[0]
+	if _, ok := parser.accept(tokenOperator, "."); ok {
+		tok := parser.next()
+		if tok.kind != tokenName {
+			return nil, fmt.Errorf("expected an output of %s instead of %s", name.text, tok)
+		}
+		output = ""
+		for _, candidate := range spec.Outputs() {
+			if candidate == tok.text {
+				output = candidate
+			}
+		}
+		if output == "" {
+			return nil, fmt.Errorf("unknown output of %s: %s", name.text, tok.text)
+		}
+		key += "." + output
+	}
+	return parser.addTerm(&term{key: key, spec: spec, output: output}), nil
+}
+
+func (parser *parser) addTerm(newTerm *term) *term {
+	for _, known := range parser.terms {
+		if known.key == newTerm.key {
+			return known
+		}
+	}
+	parser.terms = append(parser.terms, newTerm)
+	return newTerm
+}
+
+// Expression is a parsed screening expression
+type Expression struct {
+	text  string
+	root  node
+	terms []*term
+}
+
+// Parse reads a screening expression
+//
+// 	Parse("rsi(14) < 30 and close > sma(200)")
+//
+// returns the expression or an error for a syntax error, an unknown name or a bad indicator parameter
+func Parse(text string) (*Expression, error) {
+	return ParseWithVariables(text)
+}
+
+// ParseWithVariables reads a screening expression which can also use variables given by the caller
+//
+// 	ParseWithVariables("pnl < -10", "pnl")
+//
+// The values of the variables are set before calling Values, a missing variable is NaN.
+// returns the expression or an error for a syntax error, an unknown name or a bad indicator parameter
+func ParseWithVariables(text string, variables ...string) (*Expression, error) {
+	tokens, err := scan(text)
+	if err != nil {
+		return nil, err
+	}
+	parser := &parser{tokens: tokens, variables: variables}
+	root, err := parser.or()
+	if err != nil {
+		return nil, err
+	}
+	if tok := parser.peek(); tok.kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %s", tok)
+	}
+	return &Expression{text: strings.TrimSpace(text), root: root, terms: parser.terms}, nil
+}
+
+func (expression *Expression) String() string {
+	return expression.text
+}
+
+// Terms returns the keys of the prices and indicators used by the expression
+func (expression *Expression) Terms() []string {
+	keys := make([]string, len(expression.terms))
+	for i, term := range expression.terms {
+		keys[i] = term.key
+	}
+	return keys
+}
+
+// WarmUp returns the number of bars needed to compute all the indicators of the expression
+func (expression *Expression) WarmUp() int {
+	warmUp := 1
+	for _, term := range expression.terms {
+		if term.spec != nil && term.spec.WarmUp() > warmUp {
+			warmUp = term.spec.WarmUp()
+		}
+	}
+	return warmUp
+}
+
+// Values computes the terms of the expression on bars sorted by date and adds them to values by key
+//
+// A term is NaN when there is no bar or when its indicator is still warming up.
+func (expression *Expression) Values(bars []es.StockBar, values map[string]float64) error {
+	for _, term := range expression.terms {
+		if _, ok := values[term.key]; ok {
+			continue
+		}
+		if term.variable {
+			values[term.key] = math.NaN()
+			continue
+		}
+		if term.spec == nil {
+			values[term.key] = math.NaN()
+			if len(bars) > 0 {
+				values[term.key] = fields[term.field](bars[len(bars)-1])
+			}
+			continue
+		}
+		last, err := indicators.Last(term.spec, bars)
+		if err != nil {
+			return err
+		}
+		values[term.key] = last[term.output]
+	}
+	return nil
+}
+
+// Eval computes the value of the expression from the values of its terms, 1 or 0 for a condition
+func (expression *Expression) Eval(values map[string]float64) float64 {
+	return expression.root.eval(values)
+}
+
+// Match tells whether a condition is true for the values of its terms
+func (expression *Expression) Match(values map[string]float64) bool {
+	return truth(expression.Eval(values))
+}
+
+// Stream computes the terms of an expression bar after bar, for the evaluation of an expression on
+// each day of a history
+type Stream struct {
+	terms      []*term
+	indicators []indicators.Indicator
+	outputs    []int
+}
+
+// Stream creates a new stream of the terms of the expression
+func (expression *Expression) Stream() (*Stream, error) {
+	stream := &Stream{
+		terms:      expression.terms,
+		indicators: make([]indicators.Indicator, len(expression.terms)),
+		outputs:    make([]int, len(expression.terms)),
+	}
+	for i, term := range expression.terms {
+		if term.spec == nil {
+			continue
+		}
+		indicator, err := term.spec.New()
+		if err != nil {
+			return nil, err
+		}
+		stream.indicators[i] = indicator
+		for j, output := range term.spec.Outputs() {
+			if output == term.output {
+				stream.outputs[i] = j
+			}
+		}
+	}
+	return stream, nil
+}
+
+// Update adds the next bar and sets the values of the terms on this bar by key, variables are left as they are
+func (stream *Stream) Update(bar es.StockBar, values map[string]float64) {
+	for i, term := range stream.terms {
+		switch {
+		case term.variable:
+			continue
+		case term.spec == nil:
+			values[term.key] = fields[term.field](bar)
+		default:
+			values[term.key] = stream.indicators[i].Update(bar)[stream.outputs[i]]
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package screener
+
+import (
+	"math"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	expression, err := Parse(" RSI(14) < 30 and close > sma( 200 ) and volume > 1e6 ")
+	assert.Nil(t, err)
+	assert.Equal(t, "RSI(14) < 30 and close > sma( 200 ) and volume > 1e6", expression.String())
+	assert.Equal(t, []string{"rsi(14)", "close", "sma(200)", "volume"}, expression.Terms())
+	assert.Equal(t, 200, expression.WarmUp())
+	expression, err = Parse("bb(20, 2.5).lower > close or macd.signal > 0 or -close / 2 >= sma() - 3 * 2 or not (close == 2)")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bb(20,2.5).lower", "close", "macd.signal", "sma"}, expression.Terms())
+}
+
+var evalTests = []struct {
+	expression string
+	expected   float64
+}{
+	{"1 + 2 * 3", 7},
+	{"(1 + 2) * 3", 9},
+	{"10 / 4 - -1", 3.5},
+	{"close > 1 and volume < 1e3", 1},
+	{"close > 1 and volume > 1e3", 0},
+	{"close < 1 or volume == 100", 1},
+	{"not close < 1", 1},
+	{"not not 0", 0},
+	{"close != 2", 0},
+	{"sma(10) != 2", 0},
+	{"sma(10) < 2 or not sma(10) > 2", 1},
+	{"close * 50 <= volume", 1},
+	{"close >= 2.5", 0},
+}
+
+func TestEval(t *testing.T) {
+	values := map[string]float64{"close": 2, "volume": 100, "sma(10)": math.NaN()}
+	for _, tt := range evalTests {
+		expression, err := Parse(tt.expression)
+		assert.Nil(t, err, tt.expression)
+		assert.Equal(t, tt.expected, expression.Eval(values), tt.expression)
+	}
+}
+
+var parseErrorTests = []struct {
+	expression string
+	msg        string
+}{
+	{"close > ", "unexpected end of expression"},
+	{"close > 1 1", "unexpected \"1\" at 11"},
+	{"close # 1", "unexpected \"#\" at 7"},
+	{"(close > 1", "expected \")\" instead of end of expression"},
+	{"foo > 1", "unknown indicator: foo"},
+	{"rsi(0) < 30", "period of rsi must be at least 1: 0"},
+	{"sma(1e12) > 0", "period of sma must be at most 1000: 1e12"},
+	{"rsi(14, 2) < 30", "too many parameters for rsi: rsi:14:2"},
+	{"rsi(close) < 30", "expected a number instead of \"close\" at 5"},
+	{"bb.top > 1", "unknown output of bb: top"},
+	{"bb. > 1", "expected an output of bb instead of \">\" at 5"},
+	{"1.2.3 > 1", "bad number \"1.2.3\" at 1"},
+	{"and", "unexpected \"and\" at 1"},
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tt := range parseErrorTests {
+		_, err := Parse(tt.expression)
+		assert.EqualError(t, err, tt.msg, tt.expression)
+	}
+}
+
+func TestValues(t *testing.T) {
+	bars := []es.StockBar{
+		{Open: 1, High: 2, Low: 0.5, Close: 1, Volume: 10},
+		{Open: 2, High: 3, Low: 1.5, Close: 2, Volume: 20},
+		{Open: 3, High: 4, Low: 2.5, Close: 3, Volume: 30},
+	}
+	expression, _ := Parse("sma(2) + open + high + low + volume > sma(5) and stoch(2,1).d > 0")
+	values := map[string]float64{"open": 99}
+	assert.Nil(t, expression.Values(bars, values))
+	assert.Equal(t, 2.5, values["sma(2)"])
+	assert.Equal(t, 99.0, values["open"])
+	assert.Equal(t, 4.0, values["high"])
+	assert.Equal(t, 2.5, values["low"])
+	assert.Equal(t, 30.0, values["volume"])
+	assert.True(t, math.IsNaN(values["sma(5)"]))
+	assert.InDelta(t, 100*(3-1.5)/(4-1.5), values["stoch(2,1).d"], 1e-9)
+	values = map[string]float64{}
+	assert.Nil(t, expression.Values(nil, values))
+	assert.True(t, math.IsNaN(values["close"]+values["high"]))
+}
+
+func TestParseWithVariables(t *testing.T) {
+	expression, err := ParseWithVariables("pnl < -10 and close > 1", "pnl")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pnl", "close"}, expression.Terms())
+	assert.Equal(t, 1, expression.WarmUp())
+	bars := []es.StockBar{{Close: 2}}
+	values := map[string]float64{"pnl": -12}
+	assert.Nil(t, expression.Values(bars, values))
+	assert.True(t, expression.Match(values))
+	values = map[string]float64{}
+	assert.Nil(t, expression.Values(bars, values))
+	assert.True(t, math.IsNaN(values["pnl"]))
+	assert.False(t, expression.Match(values))
+	_, err = Parse("pnl < -10")
+	assert.EqualError(t, err, "unknown indicator: pnl")
+}
+
+func TestStream(t *testing.T) {
+	bars := []es.StockBar{
+		{Open: 1, High: 2, Low: 0.5, Close: 1, Volume: 10},
+		{Open: 2, High: 3, Low: 1.5, Close: 2, Volume: 20},
+		{Open: 3, High: 4, Low: 2.5, Close: 3, Volume: 30},
+	}
+	expression, _ := ParseWithVariables("sma(2) > 1 and bb(2,1).upper >= close and pnl < 0", "pnl")
+	stream, err := expression.Stream()
+	assert.Nil(t, err)
+	for i := range bars {
+		values := map[string]float64{"pnl": -1}
+		stream.Update(bars[i], values)
+		expected := map[string]float64{}
+		assert.Nil(t, expression.Values(bars[:i+1], expected))
+		for _, key := range []string{"sma(2)", "bb(2,1).upper", "close"} {
+			if math.IsNaN(expected[key]) {
+				assert.True(t, math.IsNaN(values[key]), key)
+			} else {
+				assert.InDelta(t, expected[key], values[key], 1e-9, key)
+			}
+		}
+		assert.Equal(t, -1.0, values["pnl"])
+		assert.Equal(t, i > 0, expression.Match(values))
+	}
+}
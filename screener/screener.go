@@ -0,0 +1,77 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package screener
+
+import (
+	"math"
+	"sort"
+
+	"github.com/clebi/gofin/es"
+)
+
+// BarsLoader reads the bars of a symbol sorted by date
+type BarsLoader func(symbol string) ([]es.StockBar, error)
+
+// Result is a symbol matching a filter with the values of the terms of the filter and of the sort
+type Result struct {
+	Symbol string
+	Values map[string]float64
+	Sort   float64
+}
+
+// Screen evaluates a filter on symbols and sorts the matching ones
+//
+// 	Screen(symbols, load, filter, sortBy, descending)
+//
+// Symbols without bars are left out. Results are sorted by the value of the sort expression, the ones
+// without value last, then by symbol; only by symbol when there is no sort expression.
+// returns the matching symbols
+func Screen(symbols []string, load BarsLoader, filter *Expression, sortBy *Expression, descending bool) ([]Result, error) {
+	results := []Result{}
+	for _, symbol := range symbols {
+		bars, err := load(symbol)
+		if err != nil {
+			return nil, err
+		}
+		if len(bars) == 0 {
+			continue
+		}
+		values := map[string]float64{}
+		if err := filter.Values(bars, values); err != nil {
+			return nil, err
+		}
+		if !filter.Match(values) {
+			continue
+		}
+		result := Result{Symbol: symbol, Values: values, Sort: math.NaN()}
+		if sortBy != nil {
+			if err := sortBy.Values(bars, values); err != nil {
+				return nil, err
+			}
+			result.Sort = sortBy.Eval(values)
+		}
+		results = append(results, result)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		left, right := results[i], results[j]
+		if math.IsNaN(left.Sort) != math.IsNaN(right.Sort) {
+			return math.IsNaN(right.Sort)
+		}
+		if !math.IsNaN(left.Sort) && left.Sort != right.Sort {
+			return (left.Sort < right.Sort) != descending
+		}
+		return left.Symbol < right.Symbol
+	})
+	return results, nil
+}
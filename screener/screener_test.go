@@ -0,0 +1,72 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package screener
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/clebi/gofin/es"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLoader(closes map[string][]float64) BarsLoader {
+	return func(symbol string) ([]es.StockBar, error) {
+		if symbol == "ERROR" {
+			return nil, errors.New("load error")
+		}
+		bars := make([]es.StockBar, len(closes[symbol]))
+		for i, close := range closes[symbol] {
+			bars[i] = es.StockBar{Symbol: symbol, Close: close, Volume: 100}
+		}
+		return bars, nil
+	}
+}
+
+var screenCloses = map[string][]float64{
+	"A": {1, 2, 3},
+	"B": {3, 2, 1},
+	"C": {5},
+	"D": {4, 4, 4},
+	"E": {},
+}
+
+func TestScreen(t *testing.T) {
+	filter, _ := Parse("close > 0.5")
+	sortBy, _ := Parse("close - sma(2)")
+	results, err := Screen([]string{"A", "B", "C", "D", "E"}, testLoader(screenCloses), filter, sortBy, true)
+	assert.Nil(t, err)
+	symbols := []string{}
+	for _, result := range results {
+		symbols = append(symbols, result.Symbol)
+	}
+	assert.Equal(t, []string{"A", "D", "B", "C"}, symbols)
+	assert.Equal(t, 0.5, results[0].Sort)
+	assert.Equal(t, 2.5, results[0].Values["sma(2)"])
+	assert.Equal(t, 3.0, results[0].Values["close"])
+	results, err = Screen([]string{"D", "B", "C", "A"}, testLoader(screenCloses), filter, sortBy, false)
+	assert.Nil(t, err)
+	assert.Equal(t, "B", results[0].Symbol)
+	assert.Equal(t, "C", results[3].Symbol)
+}
+
+func TestScreenFilter(t *testing.T) {
+	filter, _ := Parse("close < sma(3)")
+	results, err := Screen([]string{"D", "C", "B", "A"}, testLoader(screenCloses), filter, nil, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "B", results[0].Symbol)
+	_, err = Screen([]string{"A", "ERROR"}, testLoader(screenCloses), filter, nil, false)
+	assert.EqualError(t, err, "load error")
+}
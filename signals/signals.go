@@ -0,0 +1,202 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Package signals detects events on the history of a stock: moving average crossovers, RSI threshold
+// crossings, Bollinger band breakouts and new highs or lows of the year
+package signals
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/indicators"
+)
+
+// Kinds of signals
+const (
+	KindGoldenCross   = "golden_cross"
+	KindDeathCross    = "death_cross"
+	KindRSIOverbought = "rsi_overbought"
+	KindRSIOversold   = "rsi_oversold"
+	KindBreakoutUpper = "bollinger_breakout_upper"
+	KindBreakoutLower = "bollinger_breakout_lower"
+	KindYearHigh      = "year_high"
+	KindYearLow       = "year_low"
+)
+
+const (
+	defaultYearPeriod  = 252
+	defaultFastPeriod  = 50
+	defaultSlowPeriod  = 200
+	defaultRSIPeriod   = 14
+	defaultRSIHigh     = 70
+	defaultRSILow      = 30
+	defaultBandsPeriod = 20
+	defaultBandsWidth  = 2
+)
+
+// Kinds lists all the kinds of signals
+var Kinds = []string{
+	KindGoldenCross, KindDeathCross, KindRSIOverbought, KindRSIOversold,
+	KindBreakoutUpper, KindBreakoutLower, KindYearHigh, KindYearLow,
+}
+
+// Config contains the parameters of the detectors
+//
+// The golden cross is the fast simple moving average crossing above the slow one and the death cross
+// crossing below. RSI signals are given when the index crosses above the high threshold or below the low
+// one, breakouts when the close crosses out of the Bollinger bands. A new high or low of the year is a
+// close above or below all the closes of the previous YearPeriod bars.
+type Config struct {
+	FastPeriod  int
+	SlowPeriod  int
+	RSIPeriod   int
+	RSIHigh     float64
+	RSILow      float64
+	BandsPeriod int
+	BandsWidth  float64
+	YearPeriod  int
+}
+
+// DefaultConfig returns the usual parameters: 50 and 200 days crossovers, RSI 14 with 70 and 30,
+// Bollinger bands 20 and 2, and 252 bars for a year
+func DefaultConfig() Config {
+	return Config{
+		FastPeriod:  defaultFastPeriod,
+		SlowPeriod:  defaultSlowPeriod,
+		RSIPeriod:   defaultRSIPeriod,
+		RSIHigh:     defaultRSIHigh,
+		RSILow:      defaultRSILow,
+		BandsPeriod: defaultBandsPeriod,
+		BandsWidth:  defaultBandsWidth,
+		YearPeriod:  defaultYearPeriod,
+	}
+}
+
+// WarmUp returns the number of bars needed before all the detectors can give signals
+func (config Config) WarmUp() int {
+	warmUp := config.YearPeriod + 1
+	for _, period := range []int{config.SlowPeriod + 1, config.FastPeriod + 1, config.RSIPeriod + 2, config.BandsPeriod + 1} {
+		if period > warmUp {
+			warmUp = period
+		}
+	}
+	return warmUp
+}
+
+// crossing tells when a value crosses above or below a level, both are compared on consecutive bars
+type crossing struct {
+	previous float64
+}
+
+// update returns 1 when the difference turns positive, -1 when it turns negative and 0 otherwise
+func (cross *crossing) update(difference float64) int {
+	previous := cross.previous
+	cross.previous = difference
+	switch {
+	case math.IsNaN(previous) || math.IsNaN(difference):
+		return 0
+	case previous <= 0 && difference > 0:
+		return 1
+	case previous >= 0 && difference < 0:
+		return -1
+	}
+	return 0
+}
+
+func newCrossing() *crossing {
+	return &crossing{previous: math.NaN()}
+}
+
+// Detect scans bars sorted by date for signals
+//
+// 	Detect(bars, DefaultConfig())
+//
+// returns the signals sorted by date, an error when a period of the configuration is not positive
+func Detect(bars []es.StockBar, config Config) ([]es.Signal, error) {
+	fast, err := indicators.NewSMA(config.FastPeriod)
+	if err != nil {
+		return nil, err
+	}
+	slow, err := indicators.NewSMA(config.SlowPeriod)
+	if err != nil {
+		return nil, err
+	}
+	rsi, err := indicators.NewRSI(config.RSIPeriod)
+	if err != nil {
+		return nil, err
+	}
+	bands, err := indicators.NewBollinger(config.BandsPeriod, config.BandsWidth)
+	if err != nil {
+		return nil, err
+	}
+	if config.YearPeriod < 1 {
+		return nil, indicators.ErrBadPeriod
+	}
+	averages, high, low, upper, lower := newCrossing(), newCrossing(), newCrossing(), newCrossing(), newCrossing()
+	averagesDetail := fmt.Sprintf("sma:%d/sma:%d", config.FastPeriod, config.SlowPeriod)
+	rsiDetail := fmt.Sprintf("rsi:%d", config.RSIPeriod)
+	bandsDetail := fmt.Sprintf("bb:%d:%g", config.BandsPeriod, config.BandsWidth)
+	signals := []es.Signal{}
+	add := func(bar es.StockBar, kind string, detail string) {
+		signals = append(signals, es.Signal{Symbol: bar.Symbol, Date: bar.Date, Kind: kind, Value: bar.Close, Detail: detail})
+	}
+	for i, bar := range bars {
+		switch averages.update(fast.Update(bar.Close) - slow.Update(bar.Close)) {
+		case 1:
+			add(bar, KindGoldenCross, averagesDetail)
+		case -1:
+			add(bar, KindDeathCross, averagesDetail)
+		}
+		index := rsi.Update(bar.Close)
+		if high.update(index-config.RSIHigh) == 1 {
+			add(bar, KindRSIOverbought, rsiDetail)
+		}
+		if low.update(index-config.RSILow) == -1 {
+			add(bar, KindRSIOversold, rsiDetail)
+		}
+		_, upperBand, lowerBand := bands.Update(bar.Close)
+		if upper.update(bar.Close-upperBand) == 1 {
+			add(bar, KindBreakoutUpper, bandsDetail)
+		}
+		if lower.update(bar.Close-lowerBand) == -1 {
+			add(bar, KindBreakoutLower, bandsDetail)
+		}
+		if i >= config.YearPeriod {
+			yearHigh, yearLow := math.Inf(-1), math.Inf(1)
+			for _, previous := range bars[i-config.YearPeriod : i] {
+				yearHigh, yearLow = math.Max(yearHigh, previous.Close), math.Min(yearLow, previous.Close)
+			}
+			if bar.Close > yearHigh {
+				add(bar, KindYearHigh, "")
+			}
+			if bar.Close < yearLow {
+				add(bar, KindYearLow, "")
+			}
+		}
+	}
+	return signals, nil
+}
+
+// Since keeps the signals from a date
+func Since(signals []es.Signal, start time.Time) []es.Signal {
+	kept := []es.Signal{}
+	for _, signal := range signals {
+		if !signal.Date.Before(start) {
+			kept = append(kept, signal)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 Clément Bizeau
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package signals
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clebi/gofin/es"
+	"github.com/clebi/gofin/indicators"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{
+	FastPeriod:  2,
+	SlowPeriod:  3,
+	RSIPeriod:   2,
+	RSIHigh:     70,
+	RSILow:      30,
+	BandsPeriod: 3,
+	BandsWidth:  1,
+	YearPeriod:  3,
+}
+
+func testBars(closes ...float64) []es.StockBar {
+	bars := make([]es.StockBar, len(closes))
+	for i, close := range closes {
+		bars[i] = es.StockBar{Symbol: "TEST", Date: time.Date(2017, time.January, 2+i, 0, 0, 0, 0, time.UTC), Close: close}
+	}
+	return bars
+}
+
+func TestDetect(t *testing.T) {
+	bars := testBars(5, 4, 3, 2, 3, 4, 5, 4, 3, 2)
+	signals, err := Detect(bars, testConfig)
+	assert.Nil(t, err)
+	expected := []struct {
+		bar  int
+		kind string
+	}{
+		{3, KindYearLow},
+		{5, KindGoldenCross},
+		{5, KindRSIOverbought},
+		{5, KindBreakoutUpper},
+		{5, KindYearHigh},
+		{6, KindYearHigh},
+		{8, KindDeathCross},
+		{8, KindRSIOversold},
+		{8, KindBreakoutLower},
+		{8, KindYearLow},
+		{9, KindYearLow},
+	}
+	assert.Equal(t, len(expected), len(signals))
+	for i, tt := range expected {
+		assert.Equal(t, bars[tt.bar].Date, signals[i].Date, tt.kind)
+		assert.Equal(t, tt.kind, signals[i].Kind)
+		assert.Equal(t, bars[tt.bar].Close, signals[i].Value)
+		assert.Equal(t, "TEST", signals[i].Symbol)
+	}
+	assert.Equal(t, "sma:2/sma:3", signals[1].Detail)
+	assert.Equal(t, "rsi:2", signals[2].Detail)
+	assert.Equal(t, "bb:3:1", signals[3].Detail)
+}
+
+func TestDetectBadConfig(t *testing.T) {
+	for _, config := range []Config{
+		{SlowPeriod: 3, RSIPeriod: 2, BandsPeriod: 3, YearPeriod: 3},
+		{FastPeriod: 2, RSIPeriod: 2, BandsPeriod: 3, YearPeriod: 3},
+		{FastPeriod: 2, SlowPeriod: 3, BandsPeriod: 3, YearPeriod: 3},
+		{FastPeriod: 2, SlowPeriod: 3, RSIPeriod: 2, YearPeriod: 3},
+		{FastPeriod: 2, SlowPeriod: 3, RSIPeriod: 2, BandsPeriod: 3},
+	} {
+		_, err := Detect(testBars(1, 2), config)
+		assert.Equal(t, indicators.ErrBadPeriod, err)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	assert.Equal(t, 253, DefaultConfig().WarmUp())
+	assert.Equal(t, 4, testConfig.WarmUp())
+	config := testConfig
+	config.RSIPeriod = 10
+	assert.Equal(t, 12, config.WarmUp())
+}
+
+func TestSince(t *testing.T) {
+	signals, _ := Detect(testBars(5, 4, 3, 2, 3, 4, 5, 4, 3, 2), testConfig)
+	kept := Since(signals, time.Date(2017, time.January, 10, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 5, len(kept))
+	assert.Equal(t, KindDeathCross, kept[0].Kind)
+}